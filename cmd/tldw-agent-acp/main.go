@@ -3,12 +3,18 @@ package main
 import (
 	"log"
 	"os"
+	"os/signal"
+	"syscall"
+	"time"
 
 	"github.com/tldw/tldw-agent/internal/acp"
 	"github.com/tldw/tldw-agent/internal/config"
 )
 
 func main() {
+	// When started as the sandbox trampoline this execs the agent
+	acp.EnterSandbox()
+
 	log.SetOutput(os.Stderr)
 	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
 
@@ -19,6 +25,15 @@ func main() {
 	}
 
 	runner := acp.NewRunner(cfg)
+
+	// Let in-flight prompts finish before exiting on SIGTERM
+	go func() {
+		term := make(chan os.Signal, 1)
+		signal.Notify(term, syscall.SIGTERM)
+		<-term
+		runner.Drain(time.Duration(cfg.Agent.DrainTimeoutMs) * time.Millisecond)
+	}()
+
 	if err := runner.Run(os.Stdin, os.Stdout); err != nil {
 		log.Fatalf("ACP runner error: %v", err)
 	}
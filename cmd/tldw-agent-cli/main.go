@@ -0,0 +1,130 @@
+// tldw-agent-cli calls workspace MCP tools interactively, for debugging
+// tool behavior without the browser extension. Each input line is a tool
+// name followed by optional JSON arguments:
+//
+//	fs.read {"path": "main.go"}
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/tldw/tldw-agent/internal/config"
+	"github.com/tldw/tldw-agent/internal/mcp"
+)
+
+func main() {
+	workspace := flag.String("workspace", "", "workspace root (default: workspace.default_root, then the current directory)")
+	list := flag.Bool("list", false, "list the available tools and exit")
+	flag.Parse()
+
+	log.SetOutput(os.Stderr)
+	log.SetFlags(0)
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Printf("Warning: Could not load config, using defaults: %v", err)
+		cfg = config.Default()
+	}
+
+	server := mcp.NewServer(cfg)
+	defer server.Close()
+
+	if *list {
+		printTools(server)
+		return
+	}
+
+	root := *workspace
+	if root == "" {
+		root = cfg.Workspace.DefaultRoot
+	}
+	if root == "" {
+		if root, err = os.Getwd(); err != nil {
+			log.Fatalf("Failed to determine working directory: %v", err)
+		}
+	}
+	if err := server.SetWorkspace(root); err != nil {
+		log.Fatalf("Failed to open workspace %s: %v", root, err)
+	}
+
+	// Only prompt when a person is typing
+	interactive := false
+	if info, err := os.Stdin.Stat(); err == nil {
+		interactive = info.Mode()&os.ModeCharDevice != 0
+	}
+	if interactive {
+		fmt.Printf("Workspace: %s\nEnter a tool name and JSON arguments, \"list\" for tools, or \"quit\".\n", server.WorkspaceRoot())
+	}
+
+	scanner := bufio.NewScanner(os.Stdin)
+	scanner.Buffer(make([]byte, 0, 64*1024), int(cfg.Server.MaxMessageBytes))
+	for {
+		if interactive {
+			fmt.Print("> ")
+		}
+		if !scanner.Scan() {
+			break
+		}
+		line := strings.TrimSpace(scanner.Text())
+		switch line {
+		case "":
+			continue
+		case "quit", "exit":
+			return
+		case "list", "help":
+			printTools(server)
+			continue
+		}
+		call(server, line)
+	}
+	if err := scanner.Err(); err != nil {
+		log.Fatalf("Failed to read input: %v", err)
+	}
+}
+
+// call runs one "tool {args}" line and prints the result. Typing the call
+// counts as approving it. Ctrl-C cancels the running tool rather than
+// exiting.
+func call(server *mcp.Server, line string) {
+	name, args, _ := strings.Cut(line, " ")
+	args = strings.TrimSpace(args)
+	if args == "" {
+		args = "{}"
+	}
+	if !json.Valid([]byte(args)) {
+		fmt.Fprintf(os.Stderr, "Invalid JSON arguments for %s\n", name)
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	result, err := server.ExecuteToolWithContext(mcp.WithApproval(ctx), name, json.RawMessage(args))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		return
+	}
+
+	out, err := json.MarshalIndent(result, "", "  ")
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to format result: %v\n", err)
+		return
+	}
+	fmt.Println(string(out))
+}
+
+func printTools(server *mcp.Server) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	for _, tool := range server.ListTools() {
+		fmt.Fprintf(w, "%s\t%s\t%s\n", tool.Name, tool.Tier, tool.Description)
+	}
+	w.Flush()
+}
@@ -3,8 +3,14 @@
 package main
 
 import (
+	"fmt"
 	"log"
+	"net/http"
 	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/tldw/tldw-agent/internal/config"
 	"github.com/tldw/tldw-agent/internal/mcp"
@@ -26,11 +32,62 @@ func main() {
 	// Create MCP server with workspace tools
 	mcpServer := mcp.NewServer(cfg)
 
+	// Reload configuration on SIGHUP without dropping in-flight requests
+	go reloadOnSIGHUP(mcpServer)
+
+	// Optionally serve the same workspace to WebSocket clients
+	if cfg.Server.WSPort != 0 {
+		go serveWebSocket(mcpServer, cfg)
+	}
+
 	// Create native messaging handler
-	handler := native.NewHandler(mcpServer, cfg)
+	handler := native.NewHandler(mcpServer)
 
 	// Run the native messaging loop (reads from stdin, writes to stdout)
-	if err := handler.Run(); err != nil {
+	err = handler.Run()
+
+	// Don't leave background commands running once the extension is gone
+	mcpServer.Close()
+
+	if err != nil {
 		log.Fatalf("Native messaging handler error: %v", err)
 	}
 }
+
+// serveWebSocket serves MCP over WebSocket on the loopback interface.
+// Failing to listen is logged but doesn't stop native messaging.
+func serveWebSocket(server *mcp.Server, cfg *config.Config) {
+	auth := mcp.AuthMiddleware(server)
+	httpServer := &http.Server{
+		Addr:              fmt.Sprintf("127.0.0.1:%d", cfg.Server.WSPort),
+		Handler:           auth(mcp.NewWSHandler(server)),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	if err := httpServer.ListenAndServe(); err != nil {
+		log.Printf("WebSocket server error: %v", err)
+	}
+}
+
+// reloadOnSIGHUP reloads the config file each time SIGHUP is received and
+// swaps it into the running server. Invalid configs are rejected and the
+// previous config stays in effect.
+func reloadOnSIGHUP(server *mcp.Server) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+
+	for range hup {
+		cfg, err := config.Load()
+		if err != nil {
+			log.Printf("Config reload failed, keeping current config: %v", err)
+			continue
+		}
+
+		changed := config.Diff(server.Config(), cfg)
+		server.SetConfig(cfg)
+		if len(changed) == 0 {
+			log.Println("Config reloaded: no changes")
+		} else {
+			log.Printf("Config reloaded: changed %s", strings.Join(changed, ", "))
+		}
+	}
+}
@@ -0,0 +1,77 @@
+// tldw-agent-mcp serves the workspace MCP tools over HTTP+SSE on
+// localhost, for MCP clients other than the browser extension.
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/tldw/tldw-agent/internal/config"
+	"github.com/tldw/tldw-agent/internal/mcp"
+)
+
+func main() {
+	log.SetOutput(os.Stderr)
+	log.SetFlags(log.Ldate | log.Ltime | log.Lshortfile)
+
+	// Unlike the native host there are no safe defaults to fall back
+	// to: the defaults leave the transport disabled
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Could not load config: %v", err)
+	}
+	if cfg.Server.MCPPort == 0 {
+		log.Fatal("MCP transport is disabled; set server.mcp_port to enable it")
+	}
+
+	mcpServer := mcp.NewServer(cfg)
+	defer mcpServer.Close()
+
+	// Serve the configured workspace, or the directory we were started in
+	root := cfg.Workspace.DefaultRoot
+	if root == "" {
+		if root, err = os.Getwd(); err != nil {
+			log.Fatalf("Failed to determine working directory: %v", err)
+		}
+	}
+	if err := mcpServer.SetWorkspace(root); err != nil {
+		log.Fatalf("Failed to open workspace %s: %v", root, err)
+	}
+
+	// Event streams never finish on their own, so shutting down cancels
+	// the base context to end them
+	baseCtx, cancelStreams := context.WithCancel(context.Background())
+
+	// Only listen on loopback: the tools give full workspace access
+	httpServer := &http.Server{
+		Addr:              fmt.Sprintf("127.0.0.1:%d", cfg.Server.MCPPort),
+		Handler:           mcp.AuthMiddleware(mcpServer)(mcpServer),
+		ReadHeaderTimeout: 10 * time.Second,
+		BaseContext:       func(net.Listener) context.Context { return baseCtx },
+	}
+
+	go func() {
+		stop := make(chan os.Signal, 1)
+		signal.Notify(stop, os.Interrupt, syscall.SIGTERM)
+		<-stop
+		cancelStreams()
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := httpServer.Shutdown(ctx); err != nil {
+			log.Printf("MCP server shutdown: %v", err)
+		}
+	}()
+
+	log.Printf("Serving MCP over HTTP+SSE on http://%s/sse", httpServer.Addr)
+	if err := httpServer.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.Printf("MCP server error: %v", err)
+	}
+}
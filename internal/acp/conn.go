@@ -2,17 +2,25 @@ package acp
 
 import (
 	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"sync"
 	"sync/atomic"
+	"time"
 )
 
 type RequestHandler func(msg *RPCMessage) (*RPCResponse, error)
 type NotificationHandler func(msg *RPCMessage)
 
+// ContextRequestHandler is a RequestHandler that also receives a context
+// carrying the request's trace ID (see TraceIDKey).
+type ContextRequestHandler func(ctx context.Context, msg *RPCMessage) (*RPCResponse, error)
+
 // Conn manages JSON-RPC communication over ACP stdio framing.
 type Conn struct {
 	reader *bufio.Reader
@@ -24,21 +32,67 @@ type Conn struct {
 	pendingMu sync.Mutex
 	nextID    int64
 
-	handler      RequestHandler
+	handler      ContextRequestHandler
 	notification NotificationHandler
+
+	// inflight bounds concurrently handled messages; nil means unlimited
+	inflight chan struct{}
+
+	// compressThreshold is the size above which outgoing messages are
+	// compressed; 0 disables compression
+	compressThreshold int
+
+	// maxMessageSize caps incoming and outgoing messages
+	maxMessageSize int
+
+	// outbox queues outgoing messages for the sender goroutine, so they
+	// are written in the order they were sent. It is closed when Run
+	// returns.
+	outbox   chan *RPCMessage
+	outboxMu sync.Mutex
+	closed   bool
+	sent     chan struct{}
+
+	// active tracks requests being handled; once draining is set no new
+	// ones are started
+	active   sync.WaitGroup
+	activeMu sync.Mutex
+	draining bool
 }
 
+// outboxSize is how many outgoing messages may be queued before senders
+// block.
+const outboxSize = 256
+
+// errConnClosed is returned when sending on a Conn whose read loop has
+// ended.
+var errConnClosed = errors.New("connection closed")
+
 // NewConn creates a new ACP connection.
 func NewConn(r io.Reader, w io.Writer) *Conn {
-	return &Conn{
+	c := &Conn{
 		reader:  bufio.NewReader(r),
 		writer:  w,
 		pending: make(map[string]chan *RPCMessage),
+		outbox:  make(chan *RPCMessage, outboxSize),
+
+		maxMessageSize: MaxMessageSize,
+		sent:           make(chan struct{}),
 	}
+	go c.sendLoop()
+	return c
 }
 
 // SetHandler registers a request handler.
 func (c *Conn) SetHandler(handler RequestHandler) {
+	c.handler = func(_ context.Context, msg *RPCMessage) (*RPCResponse, error) {
+		return handler(msg)
+	}
+}
+
+// SetContextHandler registers a request handler that receives the
+// request's trace ID in its context.
+func (c *Conn) SetContextHandler(handler ContextRequestHandler) {
 	c.handler = handler
 }
 
@@ -47,10 +101,63 @@ func (c *Conn) SetNotificationHandler(handler NotificationHandler) {
 	c.notification = handler
 }
 
-// Run starts the read loop and blocks until EOF or error.
+// SetMaxConcurrent limits how many incoming messages are handled at once.
+// Requests beyond the limit are answered immediately with an error, and
+// notifications are dropped. It must be called before Run; n <= 0 removes
+// the limit.
+func (c *Conn) SetMaxConcurrent(n int) {
+	if n <= 0 {
+		c.inflight = nil
+		return
+	}
+	c.inflight = make(chan struct{}, n)
+}
+
+// EnableCompression makes the connection gzip outgoing messages larger
+// than threshold bytes. Compressed messages are always understood when
+// reading, but a peer running an older version cannot read them, so this
+// should only be enabled when both sides support it.
+func (c *Conn) EnableCompression(threshold int) {
+	c.compressThreshold = threshold
+}
+
+// SetMaxMessageSize sets the largest message, in bytes, the connection
+// reads or writes; n <= 0 restores the MaxMessageSize default. It must be
+// called before Run.
+func (c *Conn) SetMaxMessageSize(n int) {
+	if n <= 0 {
+		n = MaxMessageSize
+	}
+	c.maxMessageSize = n
+}
+
+// acquire takes an in-flight slot, reporting false if none is free.
+func (c *Conn) acquire() bool {
+	if c.inflight == nil {
+		return true
+	}
+	select {
+	case c.inflight <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// release frees a slot taken by acquire.
+func (c *Conn) release() {
+	if c.inflight != nil {
+		<-c.inflight
+	}
+}
+
+// Run starts the read loop and blocks until EOF or error. Requests are
+// handled concurrently, so a slow handler does not hold up responses to
+// calls this side has made; notifications are handled in order.
 func (c *Conn) Run() error {
+	defer c.closeOutbox()
 	for {
-		payload, err := ReadLineMessage(c.reader)
+		payload, err := ReadLineMessage(c.reader, c.maxMessageSize)
 		if err != nil {
 			if err == io.EOF {
 				return nil
@@ -58,38 +165,177 @@ func (c *Conn) Run() error {
 			return err
 		}
 
+		if isBatch(payload) {
+			if err := c.handleBatch(payload); err != nil {
+				return err
+			}
+			continue
+		}
+
 		var msg RPCMessage
 		if err := json.Unmarshal(payload, &msg); err != nil {
 			return fmt.Errorf("unmarshal message: %w", err)
 		}
 
-		if msg.Method != "" {
-			if len(msg.ID) == 0 || string(msg.ID) == "null" {
-				if c.notification != nil {
-					c.notification(&msg)
-				}
-				continue
+		c.dispatch(&msg, func(resp *RPCResponse) {
+			if resp == nil {
+				return
 			}
-
-			resp, err := c.handleRequest(&msg)
-			if err != nil {
-				resp = NewErrorResponse(msg.ID, ErrInternal, err.Error())
+			if err := c.SendResponse(resp); err != nil {
+				log.Printf("acp: failed to send %s response: %v", msg.Method, err)
 			}
+		})
+	}
+}
+
+// dispatch handles one incoming message. For a request it returns true
+// and later calls respond, possibly from another goroutine, with the
+// response (nil if the handler sent none); notifications and responses
+// return false.
+func (c *Conn) dispatch(msg *RPCMessage, respond func(*RPCResponse)) bool {
+	if msg.Method == "" {
+		if len(msg.ID) > 0 {
+			c.deliverResponse(msg)
+		}
+		return false
+	}
+
+	if len(msg.ID) == 0 || string(msg.ID) == "null" {
+		if !c.acquire() {
+			log.Printf("acp: dropping %s notification: too many concurrent requests", msg.Method)
+			return false
+		}
+		if c.notification != nil {
+			c.notification(msg)
+		}
+		c.release()
+		return false
+	}
+
+	if !c.begin() {
+		respond(NewErrorResponse(msg.ID, ErrInternal, "shutting down"))
+		return true
+	}
+	if !c.acquire() {
+		c.active.Done()
+		respond(NewErrorResponse(msg.ID, ErrInternal, "too many concurrent requests"))
+		return true
+	}
+	go func() {
+		defer c.active.Done()
+		resp, err := c.handleRequest(msg)
+		c.release()
+		if err != nil {
+			resp = NewErrorResponse(msg.ID, ErrInternal, err.Error())
+		}
+		respond(resp)
+	}()
+	return true
+}
+
+// begin registers a request about to be handled, reporting false if the
+// connection is draining.
+func (c *Conn) begin() bool {
+	c.activeMu.Lock()
+	defer c.activeMu.Unlock()
+	if c.draining {
+		return false
+	}
+	c.active.Add(1)
+	return true
+}
+
+// Drain stops the connection from handling new requests, which are
+// answered with an error from then on, and waits up to timeout for the
+// requests already being handled to be answered. Responses to calls made
+// on this side are still delivered. It reports whether every request
+// finished in time.
+func (c *Conn) Drain(timeout time.Duration) bool {
+	c.activeMu.Lock()
+	c.draining = true
+	c.activeMu.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		c.active.Wait()
+		close(done)
+	}()
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-done:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// Flush stops accepting outgoing messages and waits until those already
+// queued have been written.
+func (c *Conn) Flush() {
+	c.closeOutbox()
+	<-c.sent
+}
+
+// isBatch reports whether payload is a JSON array, i.e. a batch.
+func isBatch(payload []byte) bool {
+	trimmed := bytes.TrimLeft(payload, " \t")
+	return len(trimmed) > 0 && trimmed[0] == '['
+}
+
+// handleBatch dispatches each message of a batch. The responses to its
+// requests are written together as one array once all have completed;
+// a batch of only notifications or responses gets no reply.
+func (c *Conn) handleBatch(payload []byte) error {
+	var raw []json.RawMessage
+	if err := json.Unmarshal(payload, &raw); err != nil {
+		return fmt.Errorf("unmarshal batch: %w", err)
+	}
+	if len(raw) == 0 {
+		return c.SendResponse(NewErrorResponse(nil, ErrInvalidReq, "empty batch"))
+	}
+
+	var wg sync.WaitGroup
+	responses := make([]*RPCResponse, len(raw))
+	for i, data := range raw {
+		var msg RPCMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			responses[i] = NewErrorResponse(nil, ErrInvalidReq, "invalid request")
+			continue
+		}
+		wg.Add(1)
+		if !c.dispatch(&msg, func(resp *RPCResponse) {
+			responses[i] = resp
+			wg.Done()
+		}) {
+			wg.Done()
+		}
+	}
+
+	// Wait off the read loop, since handlers may themselves need responses
+	go func() {
+		wg.Wait()
+		batch := make([]*RPCResponse, 0, len(responses))
+		for _, resp := range responses {
 			if resp != nil {
-				if err := c.SendResponse(resp); err != nil {
-					return err
+				if resp.JSONRPC == "" {
+					resp.JSONRPC = JSONRPCVersion
 				}
+				batch = append(batch, resp)
 			}
-			continue
 		}
-
-		if len(msg.ID) > 0 {
-			c.deliverResponse(&msg)
+		if len(batch) == 0 {
+			return
 		}
-	}
+		if err := c.send(batch); err != nil {
+			log.Printf("acp: failed to send batch response: %v", err)
+		}
+	}()
+	return nil
 }
 
-// Call sends a request and waits for a response.
+// Call sends a request and waits for a response. If ctx carries a trace ID
+// (see WithTraceID) it is sent as the "x-trace-id" params field.
 func (c *Conn) Call(ctx context.Context, method string, params interface{}) (*RPCMessage, error) {
 	var rawParams json.RawMessage
 	if params != nil {
@@ -111,7 +357,7 @@ func (c *Conn) CallRaw(ctx context.Context, method string, params json.RawMessag
 		JSONRPC: JSONRPCVersion,
 		ID:      idRaw,
 		Method:  method,
-		Params:  params,
+		Params:  injectTraceID(params, TraceID(ctx)),
 	}
 
 	respCh := make(chan *RPCMessage, 1)
@@ -138,6 +384,69 @@ func (c *Conn) CallRaw(ctx context.Context, method string, params json.RawMessag
 	}
 }
 
+// CallBatch sends several requests as one batch and waits for all of
+// their responses, which are returned in request order.
+func (c *Conn) CallBatch(ctx context.Context, requests []RPCRequest) ([]RPCMessage, error) {
+	if len(requests) == 0 {
+		return nil, nil
+	}
+
+	traceID := TraceID(ctx)
+	msgs := make([]*RPCMessage, len(requests))
+	chans := make([]chan *RPCMessage, len(requests))
+	keys := make([]string, len(requests))
+	for i, req := range requests {
+		var rawParams json.RawMessage
+		if req.Params != nil {
+			data, err := json.Marshal(req.Params)
+			if err != nil {
+				return nil, fmt.Errorf("marshal params for %s: %w", req.Method, err)
+			}
+			rawParams = data
+		}
+		id := atomic.AddInt64(&c.nextID, 1)
+		idRaw := json.RawMessage(fmt.Sprintf("%d", id))
+		msgs[i] = &RPCMessage{
+			JSONRPC: JSONRPCVersion,
+			ID:      idRaw,
+			Method:  req.Method,
+			Params:  injectTraceID(rawParams, traceID),
+		}
+		chans[i] = make(chan *RPCMessage, 1)
+		keys[i] = string(idRaw)
+	}
+
+	c.pendingMu.Lock()
+	for i, key := range keys {
+		c.pending[key] = chans[i]
+	}
+	c.pendingMu.Unlock()
+	forget := func() {
+		c.pendingMu.Lock()
+		for _, key := range keys {
+			delete(c.pending, key)
+		}
+		c.pendingMu.Unlock()
+	}
+
+	if err := c.send(msgs); err != nil {
+		forget()
+		return nil, err
+	}
+
+	results := make([]RPCMessage, len(requests))
+	for i, ch := range chans {
+		select {
+		case <-ctx.Done():
+			forget()
+			return nil, ctx.Err()
+		case resp := <-ch:
+			results[i] = *resp
+		}
+	}
+	return results, nil
+}
+
 // Notify sends a JSON-RPC notification.
 func (c *Conn) Notify(method string, params interface{}) error {
 	var rawParams json.RawMessage
@@ -161,20 +470,71 @@ func (c *Conn) NotifyRaw(method string, params json.RawMessage) error {
 	return c.SendMessage(msg)
 }
 
-// SendResponse sends a JSON-RPC response.
+// SendResponse sends a JSON-RPC response. Like SendMessage, it only
+// queues the response, so it is never written ahead of notifications sent
+// before it.
 func (c *Conn) SendResponse(resp *RPCResponse) error {
-	if resp.JSONRPC == "" {
-		resp.JSONRPC = JSONRPCVersion
+	msg := &RPCMessage{
+		JSONRPC: resp.JSONRPC,
+		ID:      resp.ID,
+		Error:   resp.Error,
 	}
-	return c.send(resp)
+	if resp.Result != nil {
+		data, err := json.Marshal(resp.Result)
+		if err != nil {
+			return fmt.Errorf("marshal result: %w", err)
+		}
+		msg.Result = data
+	}
+	return c.SendMessage(msg)
 }
 
-// SendMessage sends a raw JSON-RPC message.
+// SendMessage queues a raw JSON-RPC message for sending. Messages are
+// written one at a time in the order they were queued; a write failure is
+// logged, and fails the pending call if msg is a request.
 func (c *Conn) SendMessage(msg *RPCMessage) error {
 	if msg.JSONRPC == "" {
 		msg.JSONRPC = JSONRPCVersion
 	}
-	return c.send(msg)
+
+	c.outboxMu.Lock()
+	defer c.outboxMu.Unlock()
+	if c.closed {
+		return errConnClosed
+	}
+	c.outbox <- msg
+	return nil
+}
+
+// sendLoop writes queued messages until the outbox is closed.
+func (c *Conn) sendLoop() {
+	defer close(c.sent)
+	for msg := range c.outbox {
+		err := c.send(msg)
+		if err == nil {
+			continue
+		}
+		if msg.Method != "" && len(msg.ID) > 0 {
+			c.deliverResponse(&RPCMessage{
+				JSONRPC: JSONRPCVersion,
+				ID:      msg.ID,
+				Error:   &RPCError{Code: ErrInternal, Message: err.Error()},
+			})
+			continue
+		}
+		log.Printf("acp: failed to send message: %v", err)
+	}
+}
+
+// closeOutbox stops accepting messages; those already queued are still
+// written.
+func (c *Conn) closeOutbox() {
+	c.outboxMu.Lock()
+	defer c.outboxMu.Unlock()
+	if !c.closed {
+		c.closed = true
+		close(c.outbox)
+	}
 }
 
 func (c *Conn) send(msg interface{}) error {
@@ -183,16 +543,32 @@ func (c *Conn) send(msg interface{}) error {
 		return fmt.Errorf("marshal message: %w", err)
 	}
 
+	if c.compressThreshold > 0 && len(data) > c.compressThreshold && len(data) <= c.maxMessageSize {
+		compressed, err := compressMessage(data)
+		if err != nil {
+			return fmt.Errorf("compress message: %w", err)
+		}
+		// Incompressible payloads are sent as is
+		if len(compressed) < len(data) {
+			data = compressed
+		}
+	}
+
 	c.writeMu.Lock()
 	defer c.writeMu.Unlock()
-	return WriteLineMessage(c.writer, data)
+	return WriteLineMessage(c.writer, data, c.maxMessageSize)
 }
 
 func (c *Conn) handleRequest(msg *RPCMessage) (*RPCResponse, error) {
 	if c.handler == nil {
 		return NewErrorResponse(msg.ID, ErrMethodNotFound, "method not found"), nil
 	}
-	return c.handler(msg)
+
+	ctx := context.Background()
+	if traceID := extractTraceID(msg.Params); traceID != "" {
+		ctx = WithTraceID(ctx, traceID)
+	}
+	return c.handler(ctx, msg)
 }
 
 func (c *Conn) deliverResponse(msg *RPCMessage) {
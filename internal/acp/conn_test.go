@@ -0,0 +1,263 @@
+package acp
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// newConnPair returns two connected Conns whose read loops are running.
+func newConnPair(t *testing.T, setup func(server *Conn)) (client, server *Conn) {
+	t.Helper()
+	clientSide, serverSide := net.Pipe()
+	client = NewConn(clientSide, clientSide)
+	server = NewConn(serverSide, serverSide)
+	if setup != nil {
+		setup(server)
+	}
+	go func() { _ = client.Run() }()
+	go func() { _ = server.Run() }()
+	t.Cleanup(func() {
+		_ = clientSide.Close()
+		_ = serverSide.Close()
+	})
+	return client, server
+}
+
+func TestConnPropagatesTraceID(t *testing.T) {
+	client, _ := newConnPair(t, func(server *Conn) {
+		server.SetContextHandler(func(ctx context.Context, msg *RPCMessage) (*RPCResponse, error) {
+			var params map[string]interface{}
+			_ = json.Unmarshal(msg.Params, &params)
+			return NewResultResponse(msg.ID, map[string]interface{}{
+				"trace": TraceID(ctx),
+				"value": params["value"],
+			}), nil
+		})
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	resp, err := client.Call(WithTraceID(ctx, "trace-123"), "echo", map[string]interface{}{"value": "x"})
+	if err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	var result map[string]interface{}
+	if err := json.Unmarshal(resp.Result, &result); err != nil {
+		t.Fatalf("failed to unmarshal result: %v", err)
+	}
+	if result["trace"] != "trace-123" || result["value"] != "x" {
+		t.Fatalf("unexpected result: %#v", result)
+	}
+
+	resp, err = client.Call(ctx, "echo", nil)
+	if err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	result = nil
+	_ = json.Unmarshal(resp.Result, &result)
+	if result["trace"] != "" {
+		t.Fatalf("unexpected trace without trace ID: %#v", result)
+	}
+}
+
+func TestConnRejectsRequestsOverConcurrencyLimit(t *testing.T) {
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	client, _ := newConnPair(t, func(server *Conn) {
+		server.SetMaxConcurrent(1)
+		server.SetHandler(func(msg *RPCMessage) (*RPCResponse, error) {
+			if msg.Method == "slow" {
+				close(started)
+				<-unblock
+			}
+			return NewResultResponse(msg.ID, "done"), nil
+		})
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	slowDone := make(chan *RPCMessage, 1)
+	go func() {
+		resp, _ := client.Call(ctx, "slow", nil)
+		slowDone <- resp
+	}()
+	<-started
+
+	resp, err := client.Call(ctx, "fast", nil)
+	if err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	if resp.Error == nil || resp.Error.Code != ErrInternal || resp.Error.Message != "too many concurrent requests" {
+		t.Fatalf("expected concurrency error, got %#v", resp)
+	}
+
+	close(unblock)
+	if resp := <-slowDone; resp == nil || resp.Error != nil {
+		t.Fatalf("slow request failed: %#v", resp)
+	}
+
+	// The slot is free again once the slow request has been answered
+	resp, err = client.Call(ctx, "fast", nil)
+	if err != nil || resp.Error != nil {
+		t.Fatalf("request after limit cleared failed: %v %#v", err, resp)
+	}
+}
+
+func TestConnCompressesLargeMessages(t *testing.T) {
+	large := strings.Repeat("line of file content\n", 5000)
+	client, _ := newConnPair(t, func(server *Conn) {
+		server.EnableCompression(1024)
+		server.SetHandler(func(msg *RPCMessage) (*RPCResponse, error) {
+			return NewResultResponse(msg.ID, large), nil
+		})
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	resp, err := client.Call(ctx, "read", nil)
+	if err != nil {
+		t.Fatalf("Call failed: %v", err)
+	}
+	var result string
+	if err := json.Unmarshal(resp.Result, &result); err != nil || result != large {
+		t.Fatalf("large result did not round trip: %v", err)
+	}
+}
+
+func TestConnCallBatch(t *testing.T) {
+	client, _ := newConnPair(t, func(server *Conn) {
+		server.SetHandler(func(msg *RPCMessage) (*RPCResponse, error) {
+			if msg.Method == "fail" {
+				return NewErrorResponse(msg.ID, ErrInvalidParams, "bad params"), nil
+			}
+			var params map[string]interface{}
+			_ = json.Unmarshal(msg.Params, &params)
+			return NewResultResponse(msg.ID, params["value"]), nil
+		})
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	resps, err := client.CallBatch(ctx, []RPCRequest{
+		{Method: "echo", Params: map[string]interface{}{"value": "a"}},
+		{Method: "fail"},
+		{Method: "echo", Params: map[string]interface{}{"value": "b"}},
+	})
+	if err != nil {
+		t.Fatalf("CallBatch failed: %v", err)
+	}
+	if len(resps) != 3 {
+		t.Fatalf("expected 3 responses, got %d", len(resps))
+	}
+	if string(resps[0].Result) != `"a"` || string(resps[2].Result) != `"b"` {
+		t.Fatalf("unexpected results: %s, %s", resps[0].Result, resps[2].Result)
+	}
+	if resps[1].Error == nil || resps[1].Error.Code != ErrInvalidParams {
+		t.Fatalf("expected error response, got %#v", resps[1])
+	}
+}
+
+func TestConnDeliversNotificationsBeforeResponse(t *testing.T) {
+	var mu sync.Mutex
+	var received []int
+	var server *Conn
+	client, _ := newConnPair(t, func(s *Conn) {
+		server = s
+		s.SetHandler(func(msg *RPCMessage) (*RPCResponse, error) {
+			for i := 0; i < 50; i++ {
+				if err := server.Notify("update", map[string]int{"seq": i}); err != nil {
+					return nil, err
+				}
+			}
+			return NewResultResponse(msg.ID, "done"), nil
+		})
+	})
+	client.SetNotificationHandler(func(msg *RPCMessage) {
+		var params map[string]int
+		_ = json.Unmarshal(msg.Params, &params)
+		mu.Lock()
+		received = append(received, params["seq"])
+		mu.Unlock()
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	resp, err := client.Call(ctx, "prompt", nil)
+	if err != nil || resp.Error != nil {
+		t.Fatalf("Call failed: %v %#v", err, resp)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(received) != 50 {
+		t.Fatalf("expected 50 notifications before the response, got %d", len(received))
+	}
+	for i, seq := range received {
+		if seq != i {
+			t.Fatalf("notification %d out of order: %v", i, received)
+		}
+	}
+}
+
+func TestConnDrainWaitsForInFlightRequests(t *testing.T) {
+	started := make(chan struct{})
+	unblock := make(chan struct{})
+	var server *Conn
+	client, _ := newConnPair(t, func(s *Conn) {
+		server = s
+		s.SetHandler(func(msg *RPCMessage) (*RPCResponse, error) {
+			if msg.Method == "slow" {
+				close(started)
+				<-unblock
+			}
+			return NewResultResponse(msg.ID, "done"), nil
+		})
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	slowDone := make(chan *RPCMessage, 1)
+	go func() {
+		resp, _ := client.Call(ctx, "slow", nil)
+		slowDone <- resp
+	}()
+	<-started
+
+	drained := make(chan bool, 1)
+	go func() { drained <- server.Drain(time.Second) }()
+
+	// Wait for the drain to start refusing requests
+	var resp *RPCMessage
+	for {
+		var err error
+		resp, err = client.Call(ctx, "fast", nil)
+		if err != nil {
+			t.Fatalf("Call failed: %v", err)
+		}
+		if resp.Error != nil {
+			break
+		}
+	}
+	if resp.Error.Message != "shutting down" {
+		t.Fatalf("expected shutdown error, got %#v", resp.Error)
+	}
+
+	close(unblock)
+	if ok := <-drained; !ok {
+		t.Fatal("drain timed out with no requests left")
+	}
+	if resp := <-slowDone; resp == nil || resp.Error != nil {
+		t.Fatalf("in-flight request was not answered: %#v", resp)
+	}
+}
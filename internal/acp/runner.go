@@ -3,12 +3,16 @@ package acp
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"log"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/tldw/tldw-agent/internal/config"
@@ -20,6 +24,9 @@ const (
 	defaultProtocolVersion = 1
 	runnerName             = "tldw-agent-runner"
 	runnerVersion          = "0.1.0"
+
+	circuitFailureThreshold = 3
+	circuitFailureWindow    = 60 * time.Second
 )
 
 type Runner struct {
@@ -31,6 +38,41 @@ type Runner struct {
 	spawnFunc  func() (*Conn, *exec.Cmd, error)
 	capsMu     sync.Mutex
 	cachedCaps map[string]interface{}
+	breakersMu sync.Mutex
+	breakers   map[string]*spawnBreaker
+
+	// draining is set once Drain is called; stopped is closed when the
+	// drain is complete
+	draining int32
+	stopped  chan struct{}
+}
+
+// spawnBreaker tracks consecutive downstream startup failures for one agent command.
+type spawnBreaker struct {
+	failures    int32
+	lastFailure int64 // unix nanoseconds
+}
+
+func (b *spawnBreaker) open(now time.Time) bool {
+	if atomic.LoadInt32(&b.failures) < circuitFailureThreshold {
+		return false
+	}
+	last := time.Unix(0, atomic.LoadInt64(&b.lastFailure))
+	return now.Sub(last) < circuitFailureWindow
+}
+
+func (b *spawnBreaker) recordFailure(now time.Time) {
+	last := time.Unix(0, atomic.SwapInt64(&b.lastFailure, now.UnixNano()))
+	if now.Sub(last) >= circuitFailureWindow {
+		atomic.StoreInt32(&b.failures, 1)
+		return
+	}
+	atomic.AddInt32(&b.failures, 1)
+}
+
+func (b *spawnBreaker) reset() {
+	atomic.StoreInt32(&b.failures, 0)
+	atomic.StoreInt64(&b.lastFailure, 0)
 }
 
 type Session struct {
@@ -47,6 +89,8 @@ func NewRunner(cfg *config.Config) *Runner {
 	runner := &Runner{
 		cfg:      cfg,
 		sessions: make(map[string]*Session),
+		breakers: make(map[string]*spawnBreaker),
+		stopped:  make(chan struct{}),
 	}
 	runner.spawnFunc = runner.spawnDownstream
 	return runner
@@ -58,19 +102,50 @@ func (r *Runner) SetSpawnFunc(spawn func() (*Conn, *exec.Cmd, error)) {
 
 func (r *Runner) Run(stdin io.Reader, stdout io.Writer) error {
 	r.upstream = NewConn(stdin, stdout)
-	r.upstream.SetHandler(r.handleUpstreamRequest)
+	r.upstream.SetMaxMessageSize(int(r.cfg.Server.MaxMessageBytes))
+	r.upstream.SetContextHandler(r.handleUpstreamRequest)
 	r.upstream.SetNotificationHandler(r.handleUpstreamNotification)
 
-	err := r.upstream.Run()
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- r.upstream.Run()
+	}()
+
+	select {
+	case err := <-runErr:
+		r.shutdown()
+		return err
+	case <-r.stopped:
+		return nil
+	}
+}
+
+// Drain shuts the runner down gracefully: new session/new requests are
+// refused, requests already in progress get up to timeout to finish, and
+// then the remaining downstream processes are killed. Run returns once
+// Drain is complete.
+func (r *Runner) Drain(timeout time.Duration) {
+	if !atomic.CompareAndSwapInt32(&r.draining, 0, 1) {
+		return
+	}
+
+	if r.upstream != nil {
+		if !r.upstream.Drain(timeout) {
+			log.Printf("acp: drain timed out after %s; killing remaining sessions", timeout)
+		}
+	}
 	r.shutdown()
-	return err
+	if r.upstream != nil {
+		r.upstream.Flush()
+	}
+	close(r.stopped)
 }
 
 func (r *Runner) handleUpstreamNotification(msg *RPCMessage) {
 	// No upstream notifications are required for MVP.
 }
 
-func (r *Runner) handleUpstreamRequest(msg *RPCMessage) (*RPCResponse, error) {
+func (r *Runner) handleUpstreamRequest(ctx context.Context, msg *RPCMessage) (*RPCResponse, error) {
 	if msg.JSONRPC != "" && msg.JSONRPC != JSONRPCVersion {
 		return NewErrorResponse(msg.ID, ErrInvalidReq, "unsupported jsonrpc version"), nil
 	}
@@ -79,13 +154,15 @@ func (r *Runner) handleUpstreamRequest(msg *RPCMessage) (*RPCResponse, error) {
 	case "initialize":
 		return r.handleInitialize(msg)
 	case "session/new":
-		return r.handleSessionNew(msg)
+		return r.handleSessionNew(ctx, msg)
 	case "session/prompt":
-		return r.handleSessionPrompt(msg)
+		return r.handleSessionPrompt(ctx, msg)
 	case "session/cancel":
 		return r.handleSessionCancel(msg)
 	case "_tldw/session/close":
 		return r.handleSessionClose(msg)
+	case "_tldw/debug/sessions":
+		return r.handleDebugSessions(msg)
 	case "session/load":
 		return NewErrorResponse(msg.ID, ErrMethodNotFound, "session/load not supported"), nil
 	default:
@@ -110,10 +187,33 @@ func (r *Runner) handleInitialize(msg *RPCMessage) (*RPCResponse, error) {
 }
 
 type sessionNewParams struct {
-	Cwd string `json:"cwd"`
+	Cwd   string           `json:"cwd"`
+	Roots []sessionNewRoot `json:"roots,omitempty"`
+}
+
+type sessionNewRoot struct {
+	Label string `json:"label"`
+	Cwd   string `json:"cwd"`
+}
+
+// setParam returns params, a JSON object, with key set to value.
+func setParam(params json.RawMessage, key string, value interface{}) (json.RawMessage, error) {
+	fields := map[string]json.RawMessage{}
+	if err := json.Unmarshal(params, &fields); err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+	fields[key] = data
+	return json.Marshal(fields)
 }
 
-func (r *Runner) handleSessionNew(msg *RPCMessage) (*RPCResponse, error) {
+func (r *Runner) handleSessionNew(ctx context.Context, msg *RPCMessage) (*RPCResponse, error) {
+	if atomic.LoadInt32(&r.draining) != 0 {
+		return NewErrorResponse(msg.ID, ErrInternal, "runner is shutting down"), nil
+	}
 	if r.cfg.Agent.Command == "" {
 		return NewErrorResponse(msg.ID, ErrInvalidParams, "agent.command is required"), nil
 	}
@@ -122,17 +222,45 @@ func (r *Runner) handleSessionNew(msg *RPCMessage) (*RPCResponse, error) {
 	if err := json.Unmarshal(msg.Params, &params); err != nil {
 		return NewErrorResponse(msg.ID, ErrInvalidParams, "invalid session/new params"), nil
 	}
-	if params.Cwd == "" || !filepath.IsAbs(params.Cwd) {
+	if (params.Cwd == "" && len(params.Roots) == 0) || (params.Cwd != "" && !filepath.IsAbs(params.Cwd)) {
 		return NewErrorResponse(msg.ID, ErrInvalidParams, "cwd must be an absolute path"), nil
 	}
 
+	// With roots the first one is active; cwd alone keeps the single-root
+	// behavior
 	ws := workspace.NewSession(r.cfg)
-	if err := ws.SetRoot(params.Cwd); err != nil {
-		return NewErrorResponse(msg.ID, ErrInvalidParams, fmt.Sprintf("invalid cwd: %v", err)), nil
+	for _, root := range params.Roots {
+		if !filepath.IsAbs(root.Cwd) {
+			return NewErrorResponse(msg.ID, ErrInvalidParams, fmt.Sprintf("root %q: cwd must be an absolute path", root.Label)), nil
+		}
+		if err := ws.AddRoot(root.Label, root.Cwd); err != nil {
+			return NewErrorResponse(msg.ID, ErrInvalidParams, fmt.Sprintf("invalid root %q: %v", root.Label, err)), nil
+		}
+	}
+	downstreamParams := msg.Params
+	if len(params.Roots) == 0 {
+		if err := ws.SetRoot(params.Cwd); err != nil {
+			return NewErrorResponse(msg.ID, ErrInvalidParams, fmt.Sprintf("invalid cwd: %v", err)), nil
+		}
+	} else if params.Cwd == "" {
+		// ACP agents require a cwd; give them the active root
+		withCwd, err := setParam(msg.Params, "cwd", ws.Root())
+		if err != nil {
+			return NewErrorResponse(msg.ID, ErrInvalidParams, "invalid session/new params"), nil
+		}
+		downstreamParams = withCwd
+	}
+
+	breaker := r.breaker()
+	if breaker.open(time.Now()) {
+		logf(ctx, "session/new rejected: downstream circuit open")
+		return NewErrorResponse(msg.ID, ErrInternal, fmt.Sprintf("downstream circuit open: %d consecutive failures", circuitFailureThreshold)), nil
 	}
 
 	downstream, cmd, err := r.spawnFunc()
 	if err != nil {
+		breaker.recordFailure(time.Now())
+		logf(ctx, "session/new: failed to spawn downstream: %v", err)
 		return NewErrorResponse(msg.ID, ErrInternal, err.Error()), nil
 	}
 
@@ -146,8 +274,8 @@ func (r *Runner) handleSessionNew(msg *RPCMessage) (*RPCResponse, error) {
 		runErr:     runErr,
 	}
 
-	downstream.SetHandler(func(req *RPCMessage) (*RPCResponse, error) {
-		return r.handleDownstreamRequest(session, req)
+	downstream.SetContextHandler(func(ctx context.Context, req *RPCMessage) (*RPCResponse, error) {
+		return r.handleDownstreamRequest(ctx, session, req)
 	})
 	downstream.SetNotificationHandler(func(note *RPCMessage) {
 		r.handleDownstreamNotification(session, note)
@@ -163,6 +291,10 @@ func (r *Runner) handleSessionNew(msg *RPCMessage) (*RPCResponse, error) {
 			"fs": map[string]bool{
 				"readTextFile":  true,
 				"writeTextFile": true,
+				"list":          true,
+				"stat":          true,
+				"mkdir":         true,
+				"delete":        true,
 			},
 			"terminal": r.cfg.Execution.Enabled,
 		},
@@ -173,19 +305,25 @@ func (r *Runner) handleSessionNew(msg *RPCMessage) (*RPCResponse, error) {
 		},
 	}
 
-	initResp, err := downstream.Call(context.Background(), "initialize", initParams)
+	initResp, err := downstream.Call(ctx, "initialize", initParams)
 	if err != nil {
+		breaker.recordFailure(time.Now())
+		logf(ctx, "session/new: downstream initialize failed: %v", err)
 		return NewErrorResponse(msg.ID, ErrInternal, fmt.Sprintf("downstream initialize failed: %v", err)), nil
 	}
 	if initResp != nil && initResp.Error != nil {
+		breaker.recordFailure(time.Now())
+		logf(ctx, "session/new: downstream initialize returned error: %s", initResp.Error.Message)
 		return &RPCResponse{JSONRPC: JSONRPCVersion, ID: msg.ID, Error: initResp.Error}, nil
 	}
+	breaker.reset()
 	if initResp != nil && initResp.Result != nil {
 		r.updateCachedCapabilities(initResp.Result)
 	}
 
-	resp, err := downstream.CallRaw(context.Background(), "session/new", msg.Params)
+	resp, err := downstream.CallRaw(ctx, "session/new", downstreamParams)
 	if err != nil {
+		logf(ctx, "session/new: downstream session/new failed: %v", err)
 		return NewErrorResponse(msg.ID, ErrInternal, fmt.Sprintf("downstream session/new failed: %v", err)), nil
 	}
 	if resp.Error != nil {
@@ -215,7 +353,7 @@ type sessionPromptParams struct {
 	SessionID string `json:"sessionId"`
 }
 
-func (r *Runner) handleSessionPrompt(msg *RPCMessage) (*RPCResponse, error) {
+func (r *Runner) handleSessionPrompt(ctx context.Context, msg *RPCMessage) (*RPCResponse, error) {
 	var params sessionPromptParams
 	if err := json.Unmarshal(msg.Params, &params); err != nil {
 		return NewErrorResponse(msg.ID, ErrInvalidParams, "invalid session/prompt params"), nil
@@ -226,8 +364,9 @@ func (r *Runner) handleSessionPrompt(msg *RPCMessage) (*RPCResponse, error) {
 		return NewErrorResponse(msg.ID, ErrInvalidParams, "unknown session"), nil
 	}
 
-	resp, err := session.downstream.CallRaw(context.Background(), "session/prompt", msg.Params)
+	resp, err := session.downstream.CallRaw(ctx, "session/prompt", msg.Params)
 	if err != nil {
+		logf(ctx, "session %s: downstream session/prompt failed: %v", session.id, err)
 		return NewErrorResponse(msg.ID, ErrInternal, fmt.Sprintf("downstream session/prompt failed: %v", err)), nil
 	}
 	if resp.Error != nil {
@@ -261,6 +400,42 @@ func (r *Runner) handleSessionClose(msg *RPCMessage) (*RPCResponse, error) {
 	return NewResultResponse(msg.ID, nil), nil
 }
 
+type debugSessionInfo struct {
+	ID        string   `json:"id"`
+	Root      string   `json:"root"`
+	Terminals []string `json:"terminals"`
+	PID       int      `json:"pid,omitempty"`
+}
+
+func (r *Runner) handleDebugSessions(msg *RPCMessage) (*RPCResponse, error) {
+	if !r.cfg.Debug {
+		return NewErrorResponse(msg.ID, ErrMethodNotFound, "method not found"), nil
+	}
+
+	r.sessionsMu.Lock()
+	sessions := make([]*Session, 0, len(r.sessions))
+	for _, session := range r.sessions {
+		sessions = append(sessions, session)
+	}
+	r.sessionsMu.Unlock()
+
+	infos := make([]debugSessionInfo, 0, len(sessions))
+	for _, session := range sessions {
+		info := debugSessionInfo{
+			ID:        session.id,
+			Root:      session.workspace.Root(),
+			Terminals: session.terminal.terminalIDs(),
+		}
+		if session.process != nil && session.process.Process != nil {
+			info.PID = session.process.Process.Pid
+		}
+		infos = append(infos, info)
+	}
+	sort.Slice(infos, func(i, j int) bool { return infos[i].ID < infos[j].ID })
+
+	return NewResultResponse(msg.ID, map[string]interface{}{"sessions": infos}), nil
+}
+
 func (r *Runner) buildAgentCapabilities() map[string]interface{} {
 	base := defaultAgentCapabilities()
 	cached := r.getCachedCapabilities()
@@ -323,6 +498,9 @@ func (r *Runner) updateCachedCapabilities(raw json.RawMessage) {
 }
 
 func (r *Runner) refreshCapabilities() map[string]interface{} {
+	if r.breaker().open(time.Now()) {
+		return nil
+	}
 	downstream, cmd, err := r.spawnFunc()
 	if err != nil {
 		return nil
@@ -341,6 +519,10 @@ func (r *Runner) refreshCapabilities() map[string]interface{} {
 			"fs": map[string]bool{
 				"readTextFile":  true,
 				"writeTextFile": true,
+				"list":          true,
+				"stat":          true,
+				"mkdir":         true,
+				"delete":        true,
 			},
 			"terminal": r.cfg.Execution.Enabled,
 		},
@@ -445,30 +627,38 @@ func (r *Runner) handleDownstreamNotification(session *Session, msg *RPCMessage)
 	_ = r.upstream.SendMessage(msg)
 }
 
-func (r *Runner) handleDownstreamRequest(session *Session, msg *RPCMessage) (*RPCResponse, error) {
+func (r *Runner) handleDownstreamRequest(ctx context.Context, session *Session, msg *RPCMessage) (*RPCResponse, error) {
 	switch msg.Method {
 	case "fs/read_text_file":
 		return r.handleFSRead(session, msg)
 	case "fs/write_text_file":
-		return r.handleFSWrite(session, msg)
+		return r.handleFSWrite(ctx, session, msg)
+	case "fs/list":
+		return r.handleFSList(session, msg)
+	case "fs/stat":
+		return r.handleFSStat(session, msg)
+	case "fs/mkdir":
+		return r.handleFSMkdir(session, msg)
+	case "fs/delete":
+		return r.handleFSDelete(session, msg)
 	case "terminal/create":
-		return r.handleTerminalCreate(session, msg)
+		return r.handleTerminalCreate(ctx, session, msg)
 	case "terminal/output":
-		return r.handleTerminalOutput(session, msg)
+		return r.handleTerminalOutput(ctx, session, msg)
 	case "terminal/wait_for_exit":
-		return r.handleTerminalWait(session, msg)
+		return r.handleTerminalWait(ctx, session, msg)
 	case "terminal/kill":
-		return r.handleTerminalKill(session, msg)
+		return r.handleTerminalKill(ctx, session, msg)
 	case "terminal/release":
-		return r.handleTerminalRelease(session, msg)
+		return r.handleTerminalRelease(ctx, session, msg)
 	case "session/request_permission":
-		return r.handlePermissionRequest(session, msg)
+		return r.handlePermissionRequest(ctx, session, msg)
 	default:
 		return NewErrorResponse(msg.ID, ErrMethodNotFound, "method not found"), nil
 	}
 }
 
-func (r *Runner) handlePermissionRequest(session *Session, msg *RPCMessage) (*RPCResponse, error) {
+func (r *Runner) handlePermissionRequest(ctx context.Context, session *Session, msg *RPCMessage) (*RPCResponse, error) {
 	if r.upstream == nil {
 		fallback := map[string]interface{}{
 			"outcome": map[string]interface{}{"outcome": "cancelled"},
@@ -476,8 +666,17 @@ func (r *Runner) handlePermissionRequest(session *Session, msg *RPCMessage) (*RP
 		return NewResultResponse(msg.ID, fallback), nil
 	}
 
-	resp, err := r.upstream.CallRaw(context.Background(), "session/request_permission", msg.Params)
+	// Don't leave the agent waiting forever if the user never answers,
+	// e.g. because the browser tab was closed
+	if timeout := r.cfg.Security.PermissionTimeoutMs; timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(timeout)*time.Millisecond)
+		defer cancel()
+	}
+
+	resp, err := r.upstream.CallRaw(ctx, "session/request_permission", msg.Params)
 	if err != nil || resp == nil {
+		logf(ctx, "session %s: upstream permission request failed: %v", session.id, err)
 		fallback := map[string]interface{}{
 			"outcome": map[string]interface{}{"outcome": "cancelled"},
 		}
@@ -534,7 +733,7 @@ func (r *Runner) handleFSRead(session *Session, msg *RPCMessage) (*RPCResponse,
 	return NewResultResponse(msg.ID, map[string]interface{}{"content": content}), nil
 }
 
-func (r *Runner) handleFSWrite(session *Session, msg *RPCMessage) (*RPCResponse, error) {
+func (r *Runner) handleFSWrite(ctx context.Context, session *Session, msg *RPCMessage) (*RPCResponse, error) {
 	var params struct {
 		SessionID string `json:"sessionId"`
 		Path      string `json:"path"`
@@ -551,7 +750,7 @@ func (r *Runner) handleFSWrite(session *Session, msg *RPCMessage) (*RPCResponse,
 	}
 
 	args := map[string]interface{}{"path": params.Path, "content": params.Content}
-	res, err := session.fsTools.Write(args)
+	res, err := session.fsTools.Write(ctx, args)
 	if err != nil || !res.OK {
 		return NewErrorResponse(msg.ID, ErrInternal, "failed to write file"), nil
 	}
@@ -559,13 +758,131 @@ func (r *Runner) handleFSWrite(session *Session, msg *RPCMessage) (*RPCResponse,
 	return NewResultResponse(msg.ID, nil), nil
 }
 
-func (r *Runner) handleTerminalCreate(session *Session, msg *RPCMessage) (*RPCResponse, error) {
+func (r *Runner) handleFSList(session *Session, msg *RPCMessage) (*RPCResponse, error) {
+	var params struct {
+		SessionID     string `json:"sessionId"`
+		Path          string `json:"path"`
+		Depth         int    `json:"depth"`
+		IncludeHidden bool   `json:"includeHidden"`
+	}
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return NewErrorResponse(msg.ID, ErrInvalidParams, "invalid fs/list params"), nil
+	}
+	if params.Path == "" || !filepath.IsAbs(params.Path) {
+		return NewErrorResponse(msg.ID, ErrInvalidParams, "path must be absolute"), nil
+	}
+	if params.SessionID != "" && session.id != params.SessionID {
+		return NewErrorResponse(msg.ID, ErrInvalidParams, "sessionId mismatch"), nil
+	}
+
+	args := map[string]interface{}{"path": params.Path, "include_hidden": params.IncludeHidden}
+	if params.Depth > 0 {
+		args["depth"] = float64(params.Depth)
+	}
+	res, err := session.fsTools.List(args)
+	if err != nil || !res.OK {
+		return NewErrorResponse(msg.ID, ErrInternal, "failed to list directory"), nil
+	}
+
+	data, ok := res.Data.(map[string]interface{})
+	if !ok {
+		return NewErrorResponse(msg.ID, ErrInternal, "unexpected list result"), nil
+	}
+	return NewResultResponse(msg.ID, map[string]interface{}{
+		"entries":   data["entries"],
+		"truncated": data["truncated"],
+	}), nil
+}
+
+func (r *Runner) handleFSStat(session *Session, msg *RPCMessage) (*RPCResponse, error) {
+	var params struct {
+		SessionID string `json:"sessionId"`
+		Path      string `json:"path"`
+	}
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return NewErrorResponse(msg.ID, ErrInvalidParams, "invalid fs/stat params"), nil
+	}
+	if params.Path == "" || !filepath.IsAbs(params.Path) {
+		return NewErrorResponse(msg.ID, ErrInvalidParams, "path must be absolute"), nil
+	}
+	if params.SessionID != "" && session.id != params.SessionID {
+		return NewErrorResponse(msg.ID, ErrInvalidParams, "sessionId mismatch"), nil
+	}
+
+	res, err := session.fsTools.Stat(map[string]interface{}{"path": params.Path})
+	if err != nil || !res.OK {
+		return NewErrorResponse(msg.ID, ErrInternal, "failed to stat path"), nil
+	}
+
+	data, ok := res.Data.(map[string]interface{})
+	if !ok {
+		return NewErrorResponse(msg.ID, ErrInternal, "unexpected stat result"), nil
+	}
+	return NewResultResponse(msg.ID, map[string]interface{}{
+		"type":  data["type"],
+		"size":  data["size"],
+		"mtime": data["mtime"],
+		"mode":  data["mode"],
+	}), nil
+}
+
+func (r *Runner) handleFSMkdir(session *Session, msg *RPCMessage) (*RPCResponse, error) {
+	var params struct {
+		SessionID string `json:"sessionId"`
+		Path      string `json:"path"`
+	}
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return NewErrorResponse(msg.ID, ErrInvalidParams, "invalid fs/mkdir params"), nil
+	}
+	if params.Path == "" || !filepath.IsAbs(params.Path) {
+		return NewErrorResponse(msg.ID, ErrInvalidParams, "path must be absolute"), nil
+	}
+	if params.SessionID != "" && session.id != params.SessionID {
+		return NewErrorResponse(msg.ID, ErrInvalidParams, "sessionId mismatch"), nil
+	}
+
+	res, err := session.fsTools.Mkdir(map[string]interface{}{"path": params.Path})
+	if err != nil || !res.OK {
+		return NewErrorResponse(msg.ID, ErrInternal, "failed to create directory"), nil
+	}
+
+	return NewResultResponse(msg.ID, nil), nil
+}
+
+func (r *Runner) handleFSDelete(session *Session, msg *RPCMessage) (*RPCResponse, error) {
+	var params struct {
+		SessionID string `json:"sessionId"`
+		Path      string `json:"path"`
+		Recursive bool   `json:"recursive"`
+	}
+	if err := json.Unmarshal(msg.Params, &params); err != nil {
+		return NewErrorResponse(msg.ID, ErrInvalidParams, "invalid fs/delete params"), nil
+	}
+	if params.Path == "" || !filepath.IsAbs(params.Path) {
+		return NewErrorResponse(msg.ID, ErrInvalidParams, "path must be absolute"), nil
+	}
+	if params.SessionID != "" && session.id != params.SessionID {
+		return NewErrorResponse(msg.ID, ErrInvalidParams, "sessionId mismatch"), nil
+	}
+
+	args := map[string]interface{}{"path": params.Path, "recursive": params.Recursive}
+	res, err := session.fsTools.Delete(args)
+	if err != nil || !res.OK {
+		return NewErrorResponse(msg.ID, ErrInternal, "failed to delete path"), nil
+	}
+
+	return NewResultResponse(msg.ID, nil), nil
+}
+
+func (r *Runner) handleTerminalCreate(ctx context.Context, session *Session, msg *RPCMessage) (*RPCResponse, error) {
 	var params struct {
 		SessionID       string   `json:"sessionId"`
 		Command         string   `json:"command"`
 		Args            []string `json:"args"`
 		Cwd             string   `json:"cwd"`
 		OutputByteLimit int      `json:"outputByteLimit"`
+		Shell           string   `json:"shell"`
+		Stream          bool     `json:"stream"`
 	}
 	if err := json.Unmarshal(msg.Params, &params); err != nil {
 		return NewErrorResponse(msg.ID, ErrInvalidParams, "invalid terminal/create params"), nil
@@ -574,7 +891,20 @@ func (r *Runner) handleTerminalCreate(session *Session, msg *RPCMessage) (*RPCRe
 		return NewErrorResponse(msg.ID, ErrInvalidParams, "sessionId mismatch"), nil
 	}
 
-	termID, err := session.terminal.Create(params.Command, params.Args, params.Cwd, params.OutputByteLimit)
+	// Streamed output is pushed upstream as terminal/output notifications;
+	// terminal/output requests keep working for clients that poll
+	var notify TerminalNotifyFunc
+	if params.Stream && r.upstream != nil {
+		notify = func(terminalID, chunk string) {
+			_ = r.upstream.Notify("terminal/output", map[string]string{
+				"sessionId":  session.id,
+				"terminalId": terminalID,
+				"output":     chunk,
+			})
+		}
+	}
+
+	termID, err := session.terminal.Create(ctx, params.Command, params.Args, params.Cwd, params.OutputByteLimit, params.Shell, notify)
 	if err != nil {
 		return NewErrorResponse(msg.ID, ErrInternal, err.Error()), nil
 	}
@@ -582,7 +912,7 @@ func (r *Runner) handleTerminalCreate(session *Session, msg *RPCMessage) (*RPCRe
 	return NewResultResponse(msg.ID, map[string]string{"terminalId": termID}), nil
 }
 
-func (r *Runner) handleTerminalOutput(session *Session, msg *RPCMessage) (*RPCResponse, error) {
+func (r *Runner) handleTerminalOutput(ctx context.Context, session *Session, msg *RPCMessage) (*RPCResponse, error) {
 	var params struct {
 		SessionID  string `json:"sessionId"`
 		TerminalID string `json:"terminalId"`
@@ -610,7 +940,7 @@ func (r *Runner) handleTerminalOutput(session *Session, msg *RPCMessage) (*RPCRe
 	return NewResultResponse(msg.ID, result), nil
 }
 
-func (r *Runner) handleTerminalWait(session *Session, msg *RPCMessage) (*RPCResponse, error) {
+func (r *Runner) handleTerminalWait(ctx context.Context, session *Session, msg *RPCMessage) (*RPCResponse, error) {
 	var params struct {
 		SessionID  string `json:"sessionId"`
 		TerminalID string `json:"terminalId"`
@@ -622,7 +952,7 @@ func (r *Runner) handleTerminalWait(session *Session, msg *RPCMessage) (*RPCResp
 		return NewErrorResponse(msg.ID, ErrInvalidParams, "sessionId mismatch"), nil
 	}
 
-	status, err := session.terminal.WaitForExit(params.TerminalID)
+	status, err := session.terminal.WaitForExit(ctx, params.TerminalID)
 	if err != nil {
 		return NewErrorResponse(msg.ID, ErrInternal, err.Error()), nil
 	}
@@ -633,7 +963,7 @@ func (r *Runner) handleTerminalWait(session *Session, msg *RPCMessage) (*RPCResp
 	}), nil
 }
 
-func (r *Runner) handleTerminalKill(session *Session, msg *RPCMessage) (*RPCResponse, error) {
+func (r *Runner) handleTerminalKill(ctx context.Context, session *Session, msg *RPCMessage) (*RPCResponse, error) {
 	var params struct {
 		SessionID  string `json:"sessionId"`
 		TerminalID string `json:"terminalId"`
@@ -651,7 +981,7 @@ func (r *Runner) handleTerminalKill(session *Session, msg *RPCMessage) (*RPCResp
 	return NewResultResponse(msg.ID, nil), nil
 }
 
-func (r *Runner) handleTerminalRelease(session *Session, msg *RPCMessage) (*RPCResponse, error) {
+func (r *Runner) handleTerminalRelease(ctx context.Context, session *Session, msg *RPCMessage) (*RPCResponse, error) {
 	var params struct {
 		SessionID  string `json:"sessionId"`
 		TerminalID string `json:"terminalId"`
@@ -663,22 +993,64 @@ func (r *Runner) handleTerminalRelease(session *Session, msg *RPCMessage) (*RPCR
 		return NewErrorResponse(msg.ID, ErrInvalidParams, "sessionId mismatch"), nil
 	}
 
-	if err := session.terminal.Release(params.TerminalID); err != nil {
+	if err := session.terminal.Release(ctx, params.TerminalID); err != nil {
 		return NewErrorResponse(msg.ID, ErrInternal, err.Error()), nil
 	}
 	return NewResultResponse(msg.ID, nil), nil
 }
 
+// breaker returns the spawn circuit breaker for the configured agent command.
+func (r *Runner) breaker() *spawnBreaker {
+	r.breakersMu.Lock()
+	defer r.breakersMu.Unlock()
+	b, ok := r.breakers[r.cfg.Agent.Command]
+	if !ok {
+		b = &spawnBreaker{}
+		r.breakers[r.cfg.Agent.Command] = b
+	}
+	return b
+}
+
 func (r *Runner) getSession(id string) *Session {
 	r.sessionsMu.Lock()
 	defer r.sessionsMu.Unlock()
 	return r.sessions[id]
 }
 
+// spawnRetryDelays are the waits before each retry of a failed downstream
+// start.
+var spawnRetryDelays = []time.Duration{100 * time.Millisecond, 200 * time.Millisecond, 400 * time.Millisecond}
+
+// spawnDownstream starts the downstream agent, retrying with backoff when
+// the start fails for a reason that may be transient.
 func (r *Runner) spawnDownstream() (*Conn, *exec.Cmd, error) {
+	for attempt := 0; ; attempt++ {
+		conn, cmd, err := r.startDownstream()
+		if err == nil {
+			return conn, cmd, nil
+		}
+		log.Printf("acp: downstream start attempt %d failed: %v", attempt+1, err)
+		if attempt >= len(spawnRetryDelays) || isPermanentStartError(err) {
+			return nil, nil, err
+		}
+		time.Sleep(spawnRetryDelays[attempt])
+	}
+}
+
+// isPermanentStartError reports whether retrying a failed start cannot help.
+func isPermanentStartError(err error) bool {
+	return errors.Is(err, exec.ErrNotFound) || errors.Is(err, os.ErrPermission)
+}
+
+func (r *Runner) startDownstream() (*Conn, *exec.Cmd, error) {
 	cmd := exec.Command(r.cfg.Agent.Command, r.cfg.Agent.Args...)
 	cmd.Env = append(os.Environ(), r.cfg.Agent.Env...)
 	cmd.Stderr = os.Stderr
+	if r.cfg.Security.SandboxEnabled {
+		if err := sandboxCommand(cmd, r.cfg.Security.AllowedSyscalls); err != nil {
+			return nil, nil, fmt.Errorf("sandbox downstream: %w", err)
+		}
+	}
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
@@ -693,5 +1065,7 @@ func (r *Runner) spawnDownstream() (*Conn, *exec.Cmd, error) {
 		return nil, nil, fmt.Errorf("start downstream: %w", err)
 	}
 
-	return NewConn(stdout, stdin), cmd, nil
+	conn := NewConn(stdout, stdin)
+	conn.SetMaxMessageSize(int(r.cfg.Server.MaxMessageBytes))
+	return conn, cmd, nil
 }
@@ -3,8 +3,10 @@ package acp
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net"
 	"os/exec"
+	"path/filepath"
 	"sync"
 	"testing"
 	"time"
@@ -41,8 +43,13 @@ func newStubAgent(conn *Conn, sessionID string, caps map[string]interface{}) *st
 			}
 			return NewResultResponse(msg.ID, result), nil
 		case "session/new":
+			var params struct {
+				Cwd string `json:"cwd"`
+			}
+			_ = json.Unmarshal(msg.Params, &params)
 			return NewResultResponse(msg.ID, map[string]interface{}{
 				"sessionId": agent.sessionID,
+				"cwd":       params.Cwd,
 			}), nil
 		case "session/prompt":
 			var params promptParams
@@ -314,3 +321,220 @@ func extractSessionID(t *testing.T, raw json.RawMessage) string {
 	}
 	return payload.SessionID
 }
+
+func TestRunnerDebugSessionsRequiresDebug(t *testing.T) {
+	cfg := config.Default()
+	runner := NewRunner(cfg)
+
+	msg := &RPCMessage{JSONRPC: JSONRPCVersion, ID: json.RawMessage("1"), Method: "_tldw/debug/sessions"}
+	resp, err := runner.handleUpstreamRequest(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("handleUpstreamRequest error: %v", err)
+	}
+	if resp.Error == nil || resp.Error.Code != ErrMethodNotFound {
+		t.Fatalf("expected method not found without debug, got %#v", resp)
+	}
+
+	cfg.Debug = true
+	resp, err = runner.handleUpstreamRequest(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("handleUpstreamRequest error: %v", err)
+	}
+	if resp.Error != nil {
+		t.Fatalf("unexpected error with debug enabled: %#v", resp.Error)
+	}
+	result, ok := resp.Result.(map[string]interface{})
+	if !ok {
+		t.Fatalf("unexpected result type: %T", resp.Result)
+	}
+	if sessions, ok := result["sessions"].([]debugSessionInfo); !ok || len(sessions) != 0 {
+		t.Fatalf("unexpected sessions: %#v", result["sessions"])
+	}
+}
+
+func TestRunnerCircuitOpensAfterRepeatedSpawnFailures(t *testing.T) {
+	cfg := config.Default()
+	cfg.Agent.Command = "missing-agent"
+	runner := NewRunner(cfg)
+
+	spawnCalls := 0
+	runner.SetSpawnFunc(func() (*Conn, *exec.Cmd, error) {
+		spawnCalls++
+		return nil, nil, errors.New("start downstream: executable not found")
+	})
+
+	params, _ := json.Marshal(map[string]interface{}{"cwd": t.TempDir()})
+	msg := &RPCMessage{JSONRPC: JSONRPCVersion, ID: json.RawMessage("1"), Method: "session/new", Params: params}
+
+	for i := 0; i < circuitFailureThreshold; i++ {
+		resp, err := runner.handleUpstreamRequest(context.Background(), msg)
+		if err != nil {
+			t.Fatalf("handleUpstreamRequest error: %v", err)
+		}
+		if resp.Error == nil {
+			t.Fatalf("expected spawn failure on attempt %d", i+1)
+		}
+	}
+
+	resp, err := runner.handleUpstreamRequest(context.Background(), msg)
+	if err != nil {
+		t.Fatalf("handleUpstreamRequest error: %v", err)
+	}
+	if resp.Error == nil || resp.Error.Message != "downstream circuit open: 3 consecutive failures" {
+		t.Fatalf("expected open circuit, got %#v", resp.Error)
+	}
+	if spawnCalls != circuitFailureThreshold {
+		t.Fatalf("spawn called %d times, want %d", spawnCalls, circuitFailureThreshold)
+	}
+}
+
+// startStubRunner runs a Runner whose downstream is an in-process stub agent
+// and returns the runner along with a connected upstream client.
+func startStubRunner(t *testing.T, cfg *config.Config, sessionID string) (*Runner, *Conn) {
+	t.Helper()
+	runner := NewRunner(cfg)
+
+	var (
+		mu           sync.Mutex
+		spawnedConns []net.Conn
+	)
+	runner.SetSpawnFunc(func() (*Conn, *exec.Cmd, error) {
+		clientConn, serverConn := net.Pipe()
+
+		stubConn := NewConn(serverConn, serverConn)
+		newStubAgent(stubConn, sessionID, map[string]interface{}{})
+		mu.Lock()
+		spawnedConns = append(spawnedConns, clientConn, serverConn)
+		mu.Unlock()
+		go func() {
+			_ = stubConn.Run()
+		}()
+
+		return NewConn(clientConn, clientConn), nil, nil
+	})
+
+	upstreamConn, runnerConn := net.Pipe()
+	upstream := NewConn(upstreamConn, upstreamConn)
+	go func() {
+		_ = upstream.Run()
+	}()
+
+	runErr := make(chan error, 1)
+	go func() {
+		runErr <- runner.Run(runnerConn, runnerConn)
+	}()
+
+	t.Cleanup(func() {
+		_ = upstreamConn.Close()
+		_ = runnerConn.Close()
+		mu.Lock()
+		conns := append([]net.Conn(nil), spawnedConns...)
+		mu.Unlock()
+		for _, conn := range conns {
+			_ = conn.Close()
+		}
+		select {
+		case <-runErr:
+		case <-time.After(time.Second):
+		}
+	})
+
+	return runner, upstream
+}
+
+func TestRunnerSessionNewWithRoots(t *testing.T) {
+	cfg := config.Default()
+	cfg.Agent.Command = "stub-agent"
+	runner, upstream := startStubRunner(t, cfg, "session_roots")
+
+	frontend := t.TempDir()
+	backend := t.TempDir()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	resp, err := upstream.Call(ctx, "session/new", map[string]interface{}{
+		"roots": []map[string]string{
+			{"label": "frontend", "cwd": frontend},
+			{"label": "backend", "cwd": backend},
+		},
+	})
+	if err != nil {
+		t.Fatalf("session/new failed: %v", err)
+	}
+	if resp.Error != nil {
+		t.Fatalf("session/new error: %#v", resp.Error)
+	}
+
+	session := runner.getSession(extractSessionID(t, resp.Result))
+	if session == nil {
+		t.Fatalf("session not registered")
+	}
+	if got := session.workspace.Root(); got != frontend {
+		t.Fatalf("active root = %q, want %q", got, frontend)
+	}
+	got, err := session.workspace.ResolvePath("@root:backend/main.go")
+	if err != nil {
+		t.Fatalf("failed to resolve labelled path: %v", err)
+	}
+	// Resolve both sides: TempDir may be under a symlink, as /var is on macOS
+	gotDir, _ := filepath.EvalSymlinks(filepath.Dir(got))
+	wantDir, _ := filepath.EvalSymlinks(backend)
+	if filepath.Base(got) != "main.go" || gotDir != wantDir {
+		t.Fatalf("labelled path resolved to %q, want it under %q", got, backend)
+	}
+
+	// The agent is given the active root as its cwd
+	var result struct {
+		Cwd string `json:"cwd"`
+	}
+	if err := json.Unmarshal(resp.Result, &result); err != nil || result.Cwd != frontend {
+		t.Fatalf("downstream cwd = %q, want %q (%v)", result.Cwd, frontend, err)
+	}
+}
+
+func TestSpawnDownstreamDoesNotRetryMissingCommand(t *testing.T) {
+	cfg := config.Default()
+	cfg.Agent.Command = "tldw-agent-test-missing-command"
+	runner := NewRunner(cfg)
+
+	start := time.Now()
+	_, _, err := runner.spawnDownstream()
+	if !errors.Is(err, exec.ErrNotFound) {
+		t.Fatalf("expected exec.ErrNotFound, got %v", err)
+	}
+	if elapsed := time.Since(start); elapsed >= spawnRetryDelays[0] {
+		t.Fatalf("missing command was retried (took %s)", elapsed)
+	}
+}
+
+func TestPermissionRequestTimesOutAsCancelled(t *testing.T) {
+	cfg := config.Default()
+	cfg.Security.PermissionTimeoutMs = 50
+	runner := NewRunner(cfg)
+
+	// The upstream peer reads requests but never answers them
+	upstreamConn, peerConn := net.Pipe()
+	defer upstreamConn.Close()
+	defer peerConn.Close()
+	unanswered := make(chan struct{})
+	defer close(unanswered)
+	peer := NewConn(peerConn, peerConn)
+	peer.SetHandler(func(msg *RPCMessage) (*RPCResponse, error) {
+		<-unanswered
+		return nil, nil
+	})
+	go func() { _ = peer.Run() }()
+	runner.upstream = NewConn(upstreamConn, upstreamConn)
+	go func() { _ = runner.upstream.Run() }()
+
+	msg := &RPCMessage{JSONRPC: JSONRPCVersion, ID: json.RawMessage("1"), Method: "session/request_permission"}
+	resp, err := runner.handlePermissionRequest(context.Background(), &Session{id: "s1"}, msg)
+	if err != nil {
+		t.Fatalf("handlePermissionRequest failed: %v", err)
+	}
+	data, _ := json.Marshal(resp.Result)
+	if string(data) != `{"outcome":{"outcome":"cancelled"}}` {
+		t.Fatalf("expected cancelled outcome, got %s", data)
+	}
+}
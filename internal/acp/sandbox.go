@@ -0,0 +1,7 @@
+package acp
+
+// sandboxEnv carries the allowed syscalls, comma-separated, from the runner
+// to the sandbox trampoline: a re-exec of this binary that installs the
+// seccomp filter and then execs the agent, so the filter applies to the
+// agent alone and not to the runner.
+const sandboxEnv = "TLDW_SANDBOX_SYSCALLS"
@@ -0,0 +1,111 @@
+//go:build linux
+
+package acp
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"os"
+	"os/exec"
+	"strings"
+	"syscall"
+
+	seccomp "github.com/elastic/go-seccomp-bpf"
+	"github.com/elastic/go-seccomp-bpf/arch"
+)
+
+// sandboxCommand rewrites cmd to start through the sandbox trampoline,
+// which limits the agent to the allowed syscalls.
+func sandboxCommand(cmd *exec.Cmd, allowed []string) error {
+	if cmd.Err != nil {
+		// Let Start report the lookup failure
+		return nil
+	}
+	if !seccomp.Supported() {
+		return fmt.Errorf("seccomp is not supported by this kernel")
+	}
+	self, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("locate runner executable: %w", err)
+	}
+
+	cmd.Args = append([]string{self, cmd.Path}, cmd.Args[1:]...)
+	cmd.Path = self
+	if cmd.Env == nil {
+		cmd.Env = os.Environ()
+	}
+	cmd.Env = append(cmd.Env, sandboxEnv+"="+strings.Join(allowed, ","))
+	return nil
+}
+
+// EnterSandbox turns the process into the sandbox trampoline if it was
+// started as one by the runner: it installs the seccomp filter and execs
+// the agent, never returning. Otherwise it does nothing. It must be called
+// at the start of main.
+func EnterSandbox() {
+	allowed, ok := os.LookupEnv(sandboxEnv)
+	if !ok {
+		return
+	}
+	os.Unsetenv(sandboxEnv)
+	if len(os.Args) < 2 {
+		log.Fatalf("sandbox: no command to run")
+	}
+
+	if err := loadSeccompFilter(strings.Split(allowed, ",")); err != nil {
+		log.Fatalf("sandbox: %v", err)
+	}
+	err := syscall.Exec(os.Args[1], os.Args[1:], os.Environ())
+	log.Fatalf("sandbox: exec %s: %v", os.Args[1], err)
+}
+
+// restrictedSyscalls are only allowed when their arguments meet these
+// conditions.
+var restrictedSyscalls = map[string]seccomp.ArgumentConditions{
+	// A negative pid signals a process group or, with -1, every process
+	// of the user. The pid is sign-extended, so those are above MaxInt32.
+	"kill": {{Argument: 0, Operation: seccomp.LessOrEqual, Value: math.MaxInt32}},
+}
+
+// loadSeccompFilter restricts this process and everything it executes to
+// the allowed syscalls. Names unknown on this architecture are skipped.
+func loadSeccompFilter(allowed []string) error {
+	info, err := arch.GetInfo("")
+	if err != nil {
+		return err
+	}
+
+	seen := make(map[string]bool, len(allowed))
+	names := make([]string, 0, len(allowed))
+	var restricted []seccomp.NameWithConditions
+	for _, name := range allowed {
+		name = strings.TrimSpace(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		if _, ok := info.SyscallNames[name]; !ok {
+			continue
+		}
+		if conditions, ok := restrictedSyscalls[name]; ok {
+			restricted = append(restricted, seccomp.NameWithConditions{Name: name, Conditions: conditions})
+			continue
+		}
+		names = append(names, name)
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("no allowed syscalls known on %s", info.Name)
+	}
+
+	return seccomp.LoadFilter(seccomp.Filter{
+		NoNewPrivs: true,
+		Flag:       seccomp.FilterFlagTSync,
+		Policy: seccomp.Policy{
+			DefaultAction: seccomp.ActionErrno,
+			Syscalls: []seccomp.SyscallGroup{
+				{Action: seccomp.ActionAllow, Names: names, NamesWithCondtions: restricted},
+			},
+		},
+	})
+}
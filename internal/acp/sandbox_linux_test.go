@@ -0,0 +1,85 @@
+//go:build linux
+
+package acp
+
+import (
+	"errors"
+	"os"
+	"os/exec"
+	"syscall"
+	"testing"
+
+	seccomp "github.com/elastic/go-seccomp-bpf"
+
+	"github.com/tldw/tldw-agent/internal/config"
+)
+
+// seccompChildEnv makes the test binary act as the filtered child of
+// TestLoadSeccompFilter. The filter cannot be removed once loaded, so it
+// never runs in the test process itself.
+const seccompChildEnv = "TLDW_AGENT_SECCOMP_CHILD"
+
+func TestMain(m *testing.M) {
+	if os.Getenv(seccompChildEnv) == "1" {
+		os.Exit(seccompChild())
+	}
+	os.Exit(m.Run())
+}
+
+// seccompChild loads the default filter and probes it, returning a
+// distinct exit code for each failed check.
+func seccompChild() int {
+	if err := loadSeccompFilter(config.DefaultAllowedSyscalls); err != nil {
+		return 2
+	}
+	// Allowed
+	if err := syscall.Kill(os.Getpid(), 0); err != nil {
+		return 3
+	}
+	fd, err := syscall.Socket(syscall.AF_INET, syscall.SOCK_STREAM, 0)
+	if err != nil {
+		return 4
+	}
+	syscall.Close(fd)
+	// Not in the list
+	if _, err := syscall.Getpgid(0); !errors.Is(err, syscall.EPERM) {
+		return 5
+	}
+	if err := syscall.Bind(-1, &syscall.SockaddrInet4{}); !errors.Is(err, syscall.EPERM) {
+		return 6
+	}
+	// Restricted: no process groups or broadcast
+	if err := syscall.Kill(-1, 0); !errors.Is(err, syscall.EPERM) {
+		return 7
+	}
+	if err := syscall.Kill(-os.Getpid(), 0); !errors.Is(err, syscall.EPERM) {
+		return 8
+	}
+	return 0
+}
+
+func TestLoadSeccompFilter(t *testing.T) {
+	if !seccomp.Supported() {
+		t.Skip("seccomp is not supported by this kernel")
+	}
+	self, err := os.Executable()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(self, "-test.run=^$")
+	cmd.Env = append(os.Environ(), seccompChildEnv+"=1")
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("filtered child failed: %v\n%s", err, out)
+	}
+}
+
+func TestLoadSeccompFilterUnknownNames(t *testing.T) {
+	if !seccomp.Supported() {
+		t.Skip("seccomp is not supported by this kernel")
+	}
+	// Fails before anything is loaded
+	if err := loadSeccompFilter([]string{"no_such_syscall", " "}); err == nil {
+		t.Fatal("expected error for a list without known syscalls")
+	}
+}
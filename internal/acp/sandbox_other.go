@@ -0,0 +1,17 @@
+//go:build !linux
+
+package acp
+
+import (
+	"log"
+	"os/exec"
+)
+
+// sandboxCommand leaves cmd unchanged; seccomp is only available on Linux.
+func sandboxCommand(cmd *exec.Cmd, allowed []string) error {
+	log.Printf("acp: security.sandbox_enabled has no effect on this platform; agent runs unsandboxed")
+	return nil
+}
+
+// EnterSandbox does nothing on platforms without seccomp.
+func EnterSandbox() {}
@@ -3,17 +3,29 @@ package acp
 import (
 	"bufio"
 	"bytes"
+	"compress/gzip"
+	"encoding/base64"
 	"fmt"
 	"io"
 )
 
 const (
-	// MaxMessageSize caps ACP stdio messages to 1MB.
+	// MaxMessageSize is the default cap on ACP stdio messages (1MB).
 	MaxMessageSize = 1024 * 1024
+
+	// compressedMarker starts a line holding a compressed message: the
+	// marker followed by the base64-encoded gzip stream. Base64 keeps the
+	// binary stream free of newlines, which delimit messages.
+	compressedMarker = 0x1f
 )
 
-// ReadLineMessage reads a single JSON-RPC message delimited by a newline.
-func ReadLineMessage(r *bufio.Reader) ([]byte, error) {
+// ReadLineMessage reads a single JSON-RPC message delimited by a newline,
+// rejecting messages larger than maxSize bytes (MaxMessageSize if
+// maxSize <= 0).
+func ReadLineMessage(r *bufio.Reader, maxSize int) ([]byte, error) {
+	if maxSize <= 0 {
+		maxSize = MaxMessageSize
+	}
 	for {
 		line, err := r.ReadBytes('\n')
 		if err != nil && err != io.EOF {
@@ -41,8 +53,16 @@ func ReadLineMessage(r *bufio.Reader) ([]byte, error) {
 		if bytes.Contains(trimmed, []byte{'\n'}) {
 			return nil, fmt.Errorf("message contains embedded newline")
 		}
-		if len(trimmed) > MaxMessageSize {
-			return nil, fmt.Errorf("message length %d exceeds maximum %d", len(trimmed), MaxMessageSize)
+		if len(trimmed) > maxSize {
+			return nil, fmt.Errorf("message length %d exceeds maximum %d", len(trimmed), maxSize)
+		}
+
+		if trimmed[0] == compressedMarker {
+			// A line that does not decode is passed through as is, so the
+			// caller reports it like any other malformed message
+			if data, err := decompressMessage(trimmed[1:], maxSize); err == nil {
+				return data, nil
+			}
 		}
 
 		return trimmed, nil
@@ -50,15 +70,19 @@ func ReadLineMessage(r *bufio.Reader) ([]byte, error) {
 }
 
 // WriteLineMessage writes a single JSON-RPC message followed by a newline.
-func WriteLineMessage(w io.Writer, data []byte) error {
+// Messages larger than maxSize bytes are rejected, as in ReadLineMessage.
+func WriteLineMessage(w io.Writer, data []byte, maxSize int) error {
+	if maxSize <= 0 {
+		maxSize = MaxMessageSize
+	}
 	if len(data) == 0 {
 		return fmt.Errorf("message is empty")
 	}
 	if bytes.Contains(data, []byte{'\n'}) {
 		return fmt.Errorf("message contains embedded newline")
 	}
-	if len(data) > MaxMessageSize {
-		return fmt.Errorf("message length %d exceeds maximum %d", len(data), MaxMessageSize)
+	if len(data) > maxSize {
+		return fmt.Errorf("message length %d exceeds maximum %d", len(data), maxSize)
 	}
 
 	if _, err := w.Write(append(data, '\n')); err != nil {
@@ -67,3 +91,40 @@ func WriteLineMessage(w io.Writer, data []byte) error {
 
 	return nil
 }
+
+// compressMessage encodes data as a compressed message line.
+func compressMessage(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.WriteByte(compressedMarker)
+	enc := base64.NewEncoder(base64.StdEncoding, &buf)
+	zw := gzip.NewWriter(enc)
+	if _, err := zw.Write(data); err != nil {
+		return nil, err
+	}
+	if err := zw.Close(); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// decompressMessage decodes the body of a compressed message line. The
+// decompressed message is subject to maxSize too.
+func decompressMessage(body []byte, maxSize int) ([]byte, error) {
+	zr, err := gzip.NewReader(base64.NewDecoder(base64.StdEncoding, bytes.NewReader(body)))
+	if err != nil {
+		return nil, err
+	}
+	defer zr.Close()
+
+	data, err := io.ReadAll(io.LimitReader(zr, int64(maxSize)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxSize {
+		return nil, fmt.Errorf("decompressed message exceeds maximum %d", maxSize)
+	}
+	return data, nil
+}
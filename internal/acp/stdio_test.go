@@ -3,6 +3,7 @@ package acp
 import (
 	"bufio"
 	"bytes"
+	"strings"
 	"testing"
 )
 
@@ -10,7 +11,7 @@ func TestReadLineMessage(t *testing.T) {
 	input := []byte("{\"jsonrpc\":\"2.0\"}\n")
 	reader := bufio.NewReader(bytes.NewReader(input))
 
-	msg, err := ReadLineMessage(reader)
+	msg, err := ReadLineMessage(reader, 0)
 	if err != nil {
 		t.Fatalf("ReadLineMessage error: %v", err)
 	}
@@ -24,7 +25,51 @@ func TestWriteLineMessageRejectsNewline(t *testing.T) {
 	var buf bytes.Buffer
 	data := []byte("{\n}")
 
-	if err := WriteLineMessage(&buf, data); err == nil {
+	if err := WriteLineMessage(&buf, data, 0); err == nil {
 		t.Fatalf("expected error for embedded newline")
 	}
 }
+
+func TestCompressedMessageRoundTrip(t *testing.T) {
+	payload := []byte(`{"jsonrpc":"2.0","result":"` + strings.Repeat("abcdefgh", 1000) + `"}`)
+	line, err := compressMessage(payload)
+	if err != nil {
+		t.Fatalf("compressMessage error: %v", err)
+	}
+	if len(line) >= len(payload) {
+		t.Fatalf("compressed message is %d bytes, payload %d", len(line), len(payload))
+	}
+
+	var buf bytes.Buffer
+	if err := WriteLineMessage(&buf, line, 0); err != nil {
+		t.Fatalf("WriteLineMessage error: %v", err)
+	}
+	msg, err := ReadLineMessage(bufio.NewReader(&buf), 0)
+	if err != nil {
+		t.Fatalf("ReadLineMessage error: %v", err)
+	}
+	if !bytes.Equal(msg, payload) {
+		t.Fatalf("round trip changed the message")
+	}
+}
+
+func TestReadLineMessagePassesThroughInvalidCompression(t *testing.T) {
+	input := []byte("\x1f{not compressed}\n")
+	msg, err := ReadLineMessage(bufio.NewReader(bytes.NewReader(input)), 0)
+	if err != nil {
+		t.Fatalf("ReadLineMessage error: %v", err)
+	}
+	if string(msg) != "\x1f{not compressed}" {
+		t.Fatalf("unexpected message: %q", msg)
+	}
+}
+
+func TestReadLineMessageHonorsMaxSize(t *testing.T) {
+	line := []byte(`{"jsonrpc":"2.0","method":"x"}` + "\n")
+	if _, err := ReadLineMessage(bufio.NewReader(bytes.NewReader(line)), 10); err == nil {
+		t.Fatal("expected error for message over the limit")
+	}
+	if _, err := ReadLineMessage(bufio.NewReader(bytes.NewReader(line)), len(line)); err != nil {
+		t.Fatalf("ReadLineMessage error: %v", err)
+	}
+}
@@ -7,16 +7,23 @@ import (
 	"os"
 	"os/exec"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
 	"sync/atomic"
 	"syscall"
+	"time"
+	"unicode/utf8"
 
 	"github.com/tldw/tldw-agent/internal/config"
 	"github.com/tldw/tldw-agent/internal/mcp/tools"
 	"github.com/tldw/tldw-agent/internal/workspace"
 )
 
+// terminalWaitDelay is how long a terminal's output is still collected
+// after its command exits, for background children that keep it open.
+const terminalWaitDelay = 2 * time.Second
+
 type TerminalManager struct {
 	config    *config.Config
 	session   *workspace.Session
@@ -75,11 +82,27 @@ func NewTerminalManager(cfg *config.Config, session *workspace.Session) *Termina
 	}
 }
 
-func (m *TerminalManager) Create(command string, args []string, cwd string, outputLimit int) (string, error) {
+// TerminalNotifyFunc receives output from a streaming terminal as it
+// arrives.
+type TerminalNotifyFunc func(terminalID, chunk string)
+
+// Create starts an allowlisted command in a new terminal. shell, if not
+// empty, must be one of execution.allowed_shells and replaces
+// execution.shell for this terminal. If notify is not nil, output is also
+// pushed to it as it arrives; it stays available through Output either way.
+// ctx only bounds starting the command: the terminal runs until it exits
+// or is killed or released.
+func (m *TerminalManager) Create(ctx context.Context, command string, args []string, cwd string, outputLimit int, shell string, notify TerminalNotifyFunc) (string, error) {
 	if !m.config.Execution.Enabled {
 		return "", fmt.Errorf("terminal execution disabled")
 	}
 
+	if shell == "" {
+		shell = m.config.Execution.Shell
+	} else if !m.shellAllowed(shell) {
+		return "", fmt.Errorf("shell %q not in allowed shells", shell)
+	}
+
 	cmdDef, extraArgs, err := m.matchAllowlist(command, args)
 	if err != nil {
 		return "", err
@@ -117,29 +140,51 @@ func (m *TerminalManager) Create(command string, args []string, cwd string, outp
 		limit = 1024 * 1024
 	}
 
-	ctx, cancel := context.WithCancel(context.Background())
-	cmd := buildShellCommand(ctx, m.config.Execution.Shell, fullCmd)
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+
+	procCtx, cancel := context.WithCancel(context.Background())
+	cmd := buildShellCommand(procCtx, shell, fullCmd)
 	cmd.Dir = absCwd
 	cmd.Env = append(os.Environ(), cmdDef.Env...)
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error { return killProcessGroup(cmd) }
 
-	stdout, err := cmd.StdoutPipe()
-	if err != nil {
-		cancel()
-		return "", fmt.Errorf("stdout pipe: %w", err)
+	// With writers rather than pipes, cmd.Wait returns only once all
+	// output has been copied, so none is lost when the command exits
+	// quickly. WaitDelay bounds that wait when a background child keeps
+	// the output open.
+	termID := fmt.Sprintf("term_%d", atomic.AddInt64(&m.nextID, 1))
+	buffer := &cappedBuffer{limit: limit}
+	var notifyPipe *io.PipeWriter
+	if notify == nil {
+		cmd.Stdout = buffer
+	} else {
+		var pr *io.PipeReader
+		pr, notifyPipe = io.Pipe()
+		cmd.Stdout = io.MultiWriter(buffer, notifyPipe)
+		go notifyOutput(pr, termID, notify)
 	}
-	stderr, err := cmd.StderrPipe()
-	if err != nil {
+	cmd.Stderr = cmd.Stdout
+	cmd.WaitDelay = terminalWaitDelay
+
+	// Hold the lock from the limit check until the terminal is registered
+	// so concurrent creates cannot overshoot the limit
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if maxTerminals := m.config.Execution.MaxTerminals; maxTerminals > 0 && m.countLocked() >= maxTerminals {
 		cancel()
-		return "", fmt.Errorf("stderr pipe: %w", err)
+		closePipe(notifyPipe)
+		return "", fmt.Errorf("max concurrent terminals reached")
 	}
 
 	if err := cmd.Start(); err != nil {
 		cancel()
+		closePipe(notifyPipe)
 		return "", fmt.Errorf("start command: %w", err)
 	}
 
-	termID := fmt.Sprintf("term_%d", atomic.AddInt64(&m.nextID, 1))
-	buffer := &cappedBuffer{limit: limit}
 	proc := &terminalProcess{
 		id:     termID,
 		cmd:    cmd,
@@ -148,14 +193,11 @@ func (m *TerminalManager) Create(command string, args []string, cwd string, outp
 		done:   make(chan struct{}),
 	}
 
-	go streamOutput(buffer, stdout)
-	go streamOutput(buffer, stderr)
-
+	// This goroutine is the only caller of cmd.Wait, so the process is
+	// always reaped exactly once, however Kill and Release interleave
 	go func() {
-		err := cmd.Wait()
-		if err != nil {
-			_ = err
-		}
+		_ = cmd.Wait()
+		closePipe(notifyPipe)
 		code := cmd.ProcessState.ExitCode()
 		proc.exitCode = &code
 		if status, ok := cmd.ProcessState.Sys().(syscall.WaitStatus); ok && status.Signaled() {
@@ -165,13 +207,30 @@ func (m *TerminalManager) Create(command string, args []string, cwd string, outp
 		close(proc.done)
 	}()
 
-	m.mu.Lock()
 	m.terminals[termID] = proc
-	m.mu.Unlock()
 
 	return termID, nil
 }
 
+// Count returns the number of terminals whose process is still running.
+func (m *TerminalManager) Count() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.countLocked()
+}
+
+func (m *TerminalManager) countLocked() int {
+	live := 0
+	for _, proc := range m.terminals {
+		select {
+		case <-proc.done:
+		default:
+			live++
+		}
+	}
+	return live
+}
+
 func (m *TerminalManager) Output(terminalID string) (string, bool, *TerminalExitStatus, error) {
 	proc := m.get(terminalID)
 	if proc == nil {
@@ -189,14 +248,19 @@ func (m *TerminalManager) Output(terminalID string) (string, bool, *TerminalExit
 	return string(data), truncated, exitStatus, nil
 }
 
-func (m *TerminalManager) WaitForExit(terminalID string) (*TerminalExitStatus, error) {
+// WaitForExit blocks until the terminal's command exits or ctx is done.
+func (m *TerminalManager) WaitForExit(ctx context.Context, terminalID string) (*TerminalExitStatus, error) {
 	proc := m.get(terminalID)
 	if proc == nil {
 		return nil, fmt.Errorf("terminal not found")
 	}
 
-	<-proc.done
-	return &TerminalExitStatus{ExitCode: proc.exitCode, Signal: proc.signal}, nil
+	select {
+	case <-proc.done:
+		return &TerminalExitStatus{ExitCode: proc.exitCode, Signal: proc.signal}, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
 }
 
 func (m *TerminalManager) Kill(terminalID string) error {
@@ -206,24 +270,55 @@ func (m *TerminalManager) Kill(terminalID string) error {
 	}
 
 	proc.cancel()
-	if proc.cmd.Process != nil {
-		return proc.cmd.Process.Kill()
-	}
-	return nil
+	// Kill the whole group, or commands started by the shell would be
+	// left running
+	return killProcessGroup(proc.cmd)
 }
 
-func (m *TerminalManager) Release(terminalID string) error {
+// Release kills the terminal's command and forgets the terminal once the
+// process has been reaped. If ctx is done first the terminal is still
+// forgotten and reaping finishes in the background.
+func (m *TerminalManager) Release(ctx context.Context, terminalID string) error {
 	proc := m.get(terminalID)
 	if proc == nil {
 		return fmt.Errorf("terminal not found")
 	}
 	_ = m.Kill(terminalID)
 
+	// Wait until the process has been reaped so it cannot linger as a
+	// zombie once the terminal is forgotten
+	var err error
+	select {
+	case <-proc.done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
 	m.mu.Lock()
 	delete(m.terminals, terminalID)
 	m.mu.Unlock()
 
-	return nil
+	return err
+}
+
+func (m *TerminalManager) shellAllowed(shell string) bool {
+	for _, allowed := range m.config.Execution.AllowedShells {
+		if shell == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *TerminalManager) terminalIDs() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	ids := make([]string, 0, len(m.terminals))
+	for id := range m.terminals {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
 }
 
 func (m *TerminalManager) get(terminalID string) *terminalProcess {
@@ -293,8 +388,49 @@ func buildShellCommand(ctx context.Context, shell, command string) *exec.Cmd {
 	return exec.CommandContext(ctx, shell, "-c", command)
 }
 
-func streamOutput(buffer *cappedBuffer, r io.Reader) {
-	_, _ = io.Copy(buffer, r)
+// closePipe ends the output stream of a streaming terminal.
+func closePipe(pw *io.PipeWriter) {
+	if pw != nil {
+		_ = pw.Close()
+	}
+}
+
+// notifyOutput passes output read from r to notify in chunks, holding back
+// a trailing partial UTF-8 sequence until the rest of it arrives.
+func notifyOutput(r io.Reader, terminalID string, notify TerminalNotifyFunc) {
+	buf := make([]byte, 4096)
+	var pending []byte
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			pending = append(pending, buf[:n]...)
+			cut := completeRunes(pending)
+			if cut > 0 {
+				notify(terminalID, string(pending[:cut]))
+				pending = append([]byte{}, pending[cut:]...)
+			}
+		}
+		if err != nil {
+			if len(pending) > 0 {
+				notify(terminalID, string(pending))
+			}
+			return
+		}
+	}
+}
+
+// completeRunes returns the length of the longest prefix of data that does
+// not end in an incomplete UTF-8 sequence.
+func completeRunes(data []byte) int {
+	for i := len(data) - 1; i >= 0 && i >= len(data)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(data[i]) {
+			if !utf8.FullRune(data[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(data)
 }
 
 func containsShellMeta(s string) bool {
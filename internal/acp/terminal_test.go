@@ -1,7 +1,12 @@
 package acp
 
 import (
+	"context"
+	"os"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/tldw/tldw-agent/internal/config"
 	"github.com/tldw/tldw-agent/internal/workspace"
@@ -60,3 +65,211 @@ func TestContainsShellMeta(t *testing.T) {
 		}
 	}
 }
+
+func TestCreateEnforcesMaxTerminals(t *testing.T) {
+	cfg := config.Default()
+	cfg.Execution.MaxTerminals = 1
+	cfg.Execution.CustomCommands = []config.CustomCommand{{ID: "sleep", Template: "sleep 5"}}
+	session := workspace.NewSession(cfg)
+	if err := session.SetRoot(t.TempDir()); err != nil {
+		t.Fatalf("SetRoot failed: %v", err)
+	}
+	manager := NewTerminalManager(cfg, session)
+
+	first, err := manager.Create(context.Background(), "sleep", []string{"5"}, "", 0, "", nil)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	if _, err := manager.Create(context.Background(), "sleep", []string{"5"}, "", 0, "", nil); err == nil || err.Error() != "max concurrent terminals reached" {
+		t.Fatalf("expected terminal limit error, got %v", err)
+	}
+
+	// An exited terminal no longer counts, even before it is released
+	if err := manager.Kill(first); err != nil {
+		t.Fatalf("Kill failed: %v", err)
+	}
+	if _, err := manager.WaitForExit(context.Background(), first); err != nil {
+		t.Fatalf("WaitForExit failed: %v", err)
+	}
+	if n := manager.Count(); n != 0 {
+		t.Fatalf("expected no live terminals, got %d", n)
+	}
+	second, err := manager.Create(context.Background(), "sleep", []string{"5"}, "", 0, "", nil)
+	if err != nil {
+		t.Fatalf("Create after exit failed: %v", err)
+	}
+	_ = manager.Release(context.Background(), second)
+}
+
+func TestReleaseReapsProcess(t *testing.T) {
+	cfg := config.Default()
+	cfg.Execution.CustomCommands = []config.CustomCommand{{ID: "sleep", Template: "sleep 5"}}
+	session := workspace.NewSession(cfg)
+	if err := session.SetRoot(t.TempDir()); err != nil {
+		t.Fatalf("SetRoot failed: %v", err)
+	}
+	manager := NewTerminalManager(cfg, session)
+
+	id, err := manager.Create(context.Background(), "sleep", []string{"5"}, "", 0, "", nil)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	proc := manager.get(id)
+	if err := manager.Release(context.Background(), id); err != nil {
+		t.Fatalf("Release failed: %v", err)
+	}
+
+	select {
+	case <-proc.done:
+	default:
+		t.Fatal("Release returned before the process was reaped")
+	}
+	if proc.cmd.ProcessState == nil {
+		t.Fatal("process was never waited for")
+	}
+	if manager.get(id) != nil {
+		t.Fatal("released terminal is still registered")
+	}
+}
+
+func TestWaitForExitHonorsContext(t *testing.T) {
+	cfg := config.Default()
+	cfg.Execution.CustomCommands = []config.CustomCommand{{ID: "sleep", Template: "sleep 5"}}
+	session := workspace.NewSession(cfg)
+	if err := session.SetRoot(t.TempDir()); err != nil {
+		t.Fatalf("SetRoot failed: %v", err)
+	}
+	manager := NewTerminalManager(cfg, session)
+
+	id, err := manager.Create(context.Background(), "sleep", []string{"5"}, "", 0, "", nil)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer manager.Release(context.Background(), id)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := manager.WaitForExit(ctx, id); err != context.DeadlineExceeded {
+		t.Fatalf("expected deadline error, got %v", err)
+	}
+}
+
+func TestKillStopsProcessGroup(t *testing.T) {
+	if runtime.GOOS != "linux" {
+		t.Skip("checks process state through /proc")
+	}
+	cfg := config.Default()
+	cfg.Execution.CustomCommands = []config.CustomCommand{{ID: "bg", Template: "sleep 30 & echo $! ; wait"}}
+	session := workspace.NewSession(cfg)
+	if err := session.SetRoot(t.TempDir()); err != nil {
+		t.Fatalf("SetRoot failed: %v", err)
+	}
+	manager := NewTerminalManager(cfg, session)
+
+	id, err := manager.Create(context.Background(), "sleep", []string{"30", "&", "echo", "$!", ";", "wait"}, "", 0, "", nil)
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer manager.Release(context.Background(), id)
+
+	// Wait for the shell to report the background child's pid
+	var pid string
+	deadline := time.Now().Add(2 * time.Second)
+	for pid == "" && time.Now().Before(deadline) {
+		output, _, _, _ := manager.Output(id)
+		pid = strings.TrimSpace(output)
+		time.Sleep(10 * time.Millisecond)
+	}
+	if pid == "" {
+		t.Fatal("background child pid was not reported")
+	}
+
+	if err := manager.Kill(id); err != nil {
+		t.Fatalf("Kill failed: %v", err)
+	}
+	if _, err := manager.WaitForExit(context.Background(), id); err != nil {
+		t.Fatalf("WaitForExit failed: %v", err)
+	}
+
+	// The child is gone, or a zombie awaiting its new parent
+	for time.Now().Before(deadline) {
+		stat, err := os.ReadFile("/proc/" + pid + "/stat")
+		if err != nil || strings.Contains(string(stat), ") Z ") {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("background child %s survived Kill", pid)
+}
+
+func TestCreateRejectsShellOutsideAllowlist(t *testing.T) {
+	cfg := config.Default()
+	cfg.Execution.AllowedShells = []string{"sh"}
+	cfg.Execution.CustomCommands = []config.CustomCommand{{ID: "true", Template: "true"}}
+	session := workspace.NewSession(cfg)
+	if err := session.SetRoot(t.TempDir()); err != nil {
+		t.Fatalf("SetRoot failed: %v", err)
+	}
+	manager := NewTerminalManager(cfg, session)
+
+	if _, err := manager.Create(context.Background(), "true", nil, "", 0, "csh", nil); err == nil {
+		t.Fatal("expected error for shell outside the allowlist")
+	}
+	if runtime.GOOS == "windows" {
+		return
+	}
+	id, err := manager.Create(context.Background(), "true", nil, "", 0, "sh", nil)
+	if err != nil {
+		t.Fatalf("Create with allowed shell failed: %v", err)
+	}
+	_ = manager.Release(context.Background(), id)
+}
+
+func TestCreateStreamsOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell command")
+	}
+	cfg := config.Default()
+	cfg.Execution.CustomCommands = []config.CustomCommand{{ID: "echo", Template: "echo héllo"}}
+	session := workspace.NewSession(cfg)
+	if err := session.SetRoot(t.TempDir()); err != nil {
+		t.Fatalf("SetRoot failed: %v", err)
+	}
+	manager := NewTerminalManager(cfg, session)
+
+	chunks := make(chan string, 16)
+	id, err := manager.Create(context.Background(), "echo", []string{"héllo"}, "", 0, "", func(terminalID, chunk string) {
+		chunks <- terminalID + ":" + chunk
+	})
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	defer manager.Release(context.Background(), id)
+
+	select {
+	case got := <-chunks:
+		if got != id+":héllo\n" {
+			t.Fatalf("unexpected chunk %q", got)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no output was streamed")
+	}
+
+	// Polling still sees the same output
+	if _, err := manager.WaitForExit(context.Background(), id); err != nil {
+		t.Fatalf("WaitForExit failed: %v", err)
+	}
+	if output, _, _, _ := manager.Output(id); output != "héllo\n" {
+		t.Fatalf("unexpected polled output %q", output)
+	}
+}
+
+func TestCompleteRunesHoldsBackPartialSequence(t *testing.T) {
+	data := []byte("ab\xc3")
+	if n := completeRunes(data); n != 2 {
+		t.Fatalf("expected 2, got %d", n)
+	}
+	if n := completeRunes([]byte("abé")); n != 4 {
+		t.Fatalf("expected 4, got %d", n)
+	}
+}
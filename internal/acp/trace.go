@@ -0,0 +1,72 @@
+package acp
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+)
+
+type contextKey string
+
+// TraceIDKey is the context key under which request handlers find the
+// trace ID of the request being handled.
+const TraceIDKey contextKey = "x-trace-id"
+
+// traceIDParam is the top-level params field carrying the trace ID.
+const traceIDParam = "x-trace-id"
+
+// WithTraceID returns a context carrying traceID. Requests sent with
+// Call or CallRaw under that context carry the ID to the peer.
+func WithTraceID(ctx context.Context, traceID string) context.Context {
+	return context.WithValue(ctx, TraceIDKey, traceID)
+}
+
+// TraceID returns the trace ID carried by ctx, or "" if there is none.
+func TraceID(ctx context.Context) string {
+	traceID, _ := ctx.Value(TraceIDKey).(string)
+	return traceID
+}
+
+// injectTraceID adds traceID to params as a top-level field. Params that
+// are not a JSON object are returned unchanged.
+func injectTraceID(params json.RawMessage, traceID string) json.RawMessage {
+	if traceID == "" {
+		return params
+	}
+
+	fields := map[string]json.RawMessage{}
+	if len(params) > 0 && string(params) != "null" {
+		if err := json.Unmarshal(params, &fields); err != nil {
+			return params
+		}
+	}
+	id, _ := json.Marshal(traceID)
+	fields[traceIDParam] = id
+
+	data, err := json.Marshal(fields)
+	if err != nil {
+		return params
+	}
+	return data
+}
+
+// extractTraceID returns the trace ID carried in params, if any.
+func extractTraceID(params json.RawMessage) string {
+	var fields struct {
+		TraceID string `json:"x-trace-id"`
+	}
+	if len(params) == 0 || json.Unmarshal(params, &fields) != nil {
+		return ""
+	}
+	return fields.TraceID
+}
+
+// logf logs a message, prefixed with the trace ID carried by ctx if any.
+func logf(ctx context.Context, format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	if traceID := TraceID(ctx); traceID != "" {
+		msg = fmt.Sprintf("[trace %s] %s", traceID, msg)
+	}
+	log.Output(2, msg)
+}
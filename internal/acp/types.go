@@ -31,6 +31,12 @@ type RPCMessage struct {
 	Error   *RPCError       `json:"error,omitempty"`
 }
 
+// RPCRequest is one request of a batch sent with Conn.CallBatch.
+type RPCRequest struct {
+	Method string
+	Params interface{}
+}
+
 // RPCResponse is a JSON-RPC response payload.
 type RPCResponse struct {
 	JSONRPC string          `json:"jsonrpc"`
@@ -2,76 +2,238 @@
 package config
 
 import (
+	"bytes"
+	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
+	"slices"
+	"strings"
 
+	"github.com/BurntSushi/toml"
+	"github.com/gobwas/glob"
 	"gopkg.in/yaml.v3"
 )
 
 // Config holds all configuration for the tldw-agent.
 type Config struct {
-	Server    ServerConfig    `yaml:"server"`
-	Workspace WorkspaceConfig `yaml:"workspace"`
-	Execution ExecutionConfig `yaml:"execution"`
-	Security  SecurityConfig  `yaml:"security"`
-	Agent     AgentConfig     `yaml:"agent"`
+	Server    ServerConfig    `yaml:"server" toml:"server"`
+	Workspace WorkspaceConfig `yaml:"workspace" toml:"workspace"`
+	Execution ExecutionConfig `yaml:"execution" toml:"execution"`
+	Security  SecurityConfig  `yaml:"security" toml:"security"`
+	Agent     AgentConfig     `yaml:"agent" toml:"agent"`
+	Debug     bool            `yaml:"debug" toml:"debug"`
+
+	// Source lists the config file(s) this configuration was loaded from.
+	Source string `yaml:"-" toml:"-"`
+
+	blocked *compiledPatterns
 }
 
 // ServerConfig holds LLM server connection settings.
 type ServerConfig struct {
-	LLMEndpoint string `yaml:"llm_endpoint"`
-	APIKey      string `yaml:"api_key"`
+	LLMEndpoint string `yaml:"llm_endpoint" toml:"llm_endpoint"`
+	APIKey      string `yaml:"api_key" toml:"api_key"`
+
+	// EmbeddingModel is the model requested from the embeddings API by
+	// search.semantic; empty lets the server pick.
+	EmbeddingModel string `yaml:"embedding_model" toml:"embedding_model"`
+
+	// MaxMessageBytes caps the size of a single native messaging request
+	// and of ACP stdio messages. Native messaging responses stay limited
+	// to 1MB, which is what browsers accept.
+	MaxMessageBytes int64 `yaml:"max_message_bytes" toml:"max_message_bytes"`
+
+	// MCPPort is the localhost port tldw-agent-mcp serves the MCP
+	// HTTP+SSE transport on; 0 disables it.
+	MCPPort int `yaml:"mcp_port" toml:"mcp_port"`
+
+	// WSPort is the localhost port tldw-agent-host serves MCP over
+	// WebSocket on, alongside native messaging; 0 disables it.
+	WSPort int `yaml:"ws_port" toml:"ws_port"`
+
+	// CORSOrigins lists the browser origins ("*" for any) allowed to call
+	// the HTTP and WebSocket transports cross-origin.
+	CORSOrigins []string `yaml:"cors_origins" toml:"cors_origins"`
 }
 
 // AgentConfig holds downstream ACP agent launch settings.
 type AgentConfig struct {
-	Command string   `yaml:"command"`
-	Args    []string `yaml:"args"`
-	Env     []string `yaml:"env"`
+	Command string   `yaml:"command" toml:"command"`
+	Args    []string `yaml:"args" toml:"args"`
+	Env     []string `yaml:"env" toml:"env"`
+
+	// DrainTimeoutMs is how long in-flight requests get to finish on
+	// SIGTERM before the agent processes are killed.
+	DrainTimeoutMs int `yaml:"drain_timeout_ms" toml:"drain_timeout_ms"`
 }
 
 // WorkspaceConfig holds workspace-related settings.
 type WorkspaceConfig struct {
-	DefaultRoot      string   `yaml:"default_root"`
-	BlockedPaths     []string `yaml:"blocked_paths"`
-	MaxFileSizeBytes int64    `yaml:"max_file_size_bytes"`
+	DefaultRoot      string   `yaml:"default_root" toml:"default_root"`
+	BlockedPaths     []string `yaml:"blocked_paths" toml:"blocked_paths"`
+	AllowedPaths     []string `yaml:"allowed_paths" toml:"allowed_paths"`
+	MaxFileSizeBytes int64    `yaml:"max_file_size_bytes" toml:"max_file_size_bytes"`
+	RespectGitignore bool     `yaml:"respect_gitignore" toml:"respect_gitignore"`
+
+	// MaxDiskUsageBytes caps the total size of the workspace root that
+	// writes may grow it to (0 = unlimited). The file tools check the size
+	// of what they write; git.apply checks the patch size, and exec.run is
+	// refused once the limit is reached but is not capped while it runs.
+	MaxDiskUsageBytes int64 `yaml:"max_disk_usage_bytes" toml:"max_disk_usage_bytes"`
+
+	// DiskUsageSkipDirs are directory names workspace.disk_usage does not
+	// descend into.
+	DiskUsageSkipDirs []string `yaml:"disk_usage_skip_dirs" toml:"disk_usage_skip_dirs"`
+
+	// AuditLogSize is the number of file operations kept in the in-memory
+	// audit log before the oldest entries are overwritten.
+	AuditLogSize int `yaml:"audit_log_size" toml:"audit_log_size"`
+
+	// RecentFilesLimit is the number of files tracked by workspace.recent_files.
+	RecentFilesLimit int `yaml:"recent_files_limit" toml:"recent_files_limit"`
+
+	// CacheTTLMs is how long the results of read-only tools are cached
+	// (0 = no caching).
+	CacheTTLMs int `yaml:"cache_ttl_ms" toml:"cache_ttl_ms"`
 }
 
 // CustomCommand represents a user-defined allowlisted command.
 type CustomCommand struct {
-	ID          string   `yaml:"id"`
-	Template    string   `yaml:"template"`
-	Description string   `yaml:"description"`
-	Category    string   `yaml:"category"`
-	AllowArgs   bool     `yaml:"allow_args"`
-	MaxArgs     int      `yaml:"max_args"`
-	Env         []string `yaml:"env,omitempty"`
+	ID          string   `yaml:"id" toml:"id"`
+	Template    string   `yaml:"template" toml:"template"`
+	Description string   `yaml:"description" toml:"description"`
+	Category    string   `yaml:"category" toml:"category"`
+	AllowArgs   bool     `yaml:"allow_args" toml:"allow_args"`
+	MaxArgs     int      `yaml:"max_args" toml:"max_args"`
+	Env         []string `yaml:"env,omitempty" toml:"env,omitempty"`
 }
 
 // ExecutionConfig holds command execution settings.
 type ExecutionConfig struct {
-	Enabled        bool            `yaml:"enabled"`
-	TimeoutMs      int             `yaml:"timeout_ms"`
-	Shell          string          `yaml:"shell"`
-	NetworkAllowed bool            `yaml:"network_allowed"`
-	MaxOutputBytes int             `yaml:"max_output_bytes"`
-	CustomCommands []CustomCommand `yaml:"custom_commands"`
+	Enabled        bool            `yaml:"enabled" toml:"enabled"`
+	TimeoutMs      int             `yaml:"timeout_ms" toml:"timeout_ms"`
+	ReadTimeoutMs  int             `yaml:"read_timeout_ms" toml:"read_timeout_ms"`
+	Shell          string          `yaml:"shell" toml:"shell"`
+	NetworkAllowed bool            `yaml:"network_allowed" toml:"network_allowed"`
+	MaxOutputBytes int             `yaml:"max_output_bytes" toml:"max_output_bytes"`
+	CustomCommands []CustomCommand `yaml:"custom_commands" toml:"custom_commands"`
+
+	// EnvAllowlist names the agent's environment variables that custom
+	// command env values may reference as ${VAR}.
+	EnvAllowlist []string `yaml:"env_allowlist" toml:"env_allowlist"`
+
+	// HiddenEnvVars are glob patterns, matched case-insensitively, for
+	// environment variables whose values exec.env redacts.
+	HiddenEnvVars []string `yaml:"hidden_env_vars" toml:"hidden_env_vars"`
+
+	// MaxTerminals caps the ACP terminals a session may have running at
+	// once (0 = unlimited).
+	MaxTerminals int `yaml:"max_terminals" toml:"max_terminals"`
+
+	// MaxBackground caps the exec.run background commands that may be
+	// running at once (0 = unlimited).
+	MaxBackground int `yaml:"max_background" toml:"max_background"`
+
+	// BackgroundTimeoutMs is how long a background command may run before
+	// it is killed (0 = no limit).
+	BackgroundTimeoutMs int `yaml:"background_timeout_ms" toml:"background_timeout_ms"`
+
+	// AllowedShells are the shells an ACP terminal may request in place
+	// of Shell.
+	AllowedShells []string `yaml:"allowed_shells" toml:"allowed_shells"`
 }
 
 // SecurityConfig holds security-related settings.
 type SecurityConfig struct {
-	RequireApprovalForWrites bool `yaml:"require_approval_for_writes"`
-	RequireApprovalForExec   bool `yaml:"require_approval_for_exec"`
-	RedactSecrets            bool `yaml:"redact_secrets"`
+	RequireApprovalForWrites bool `yaml:"require_approval_for_writes" toml:"require_approval_for_writes"`
+	RequireApprovalForExec   bool `yaml:"require_approval_for_exec" toml:"require_approval_for_exec"`
+	RedactSecrets            bool `yaml:"redact_secrets" toml:"redact_secrets"`
+
+	// Per-tier limits on tool calls per minute (0 = unlimited).
+	ReadRPM  int `yaml:"read_rpm" toml:"read_rpm"`
+	WriteRPM int `yaml:"write_rpm" toml:"write_rpm"`
+	ExecRPM  int `yaml:"exec_rpm" toml:"exec_rpm"`
+
+	// PermissionTimeoutMs is how long an ACP permission request waits for
+	// the user before it is treated as cancelled (0 = wait forever).
+	PermissionTimeoutMs int `yaml:"permission_timeout_ms" toml:"permission_timeout_ms"`
+
+	// SandboxEnabled runs ACP downstream agents under a seccomp filter on
+	// Linux that only permits AllowedSyscalls. Other syscalls fail with
+	// EPERM. It has no effect on other platforms.
+	SandboxEnabled  bool     `yaml:"sandbox_enabled" toml:"sandbox_enabled"`
+	AllowedSyscalls []string `yaml:"allowed_syscalls" toml:"allowed_syscalls"`
+
+	// SecretPatterns are regular expressions checked against every tool
+	// result sent to the extension when RedactSecrets is set. Matches are
+	// replaced with [REDACTED].
+	SecretPatterns []string `yaml:"secret_patterns" toml:"secret_patterns"`
+
+	// ToolApprovalRules override RequireApprovalForWrites and
+	// RequireApprovalForExec for individual tools. The first rule whose
+	// pattern matches a tool name applies.
+	ToolApprovalRules []ToolApprovalRule `yaml:"tool_approval_rules" toml:"tool_approval_rules"`
+}
+
+// ToolApprovalRule sets whether calls to the tools matching Pattern, a
+// tool name in which "*" matches any run of characters (as in "fs.*"),
+// need the user's approval.
+type ToolApprovalRule struct {
+	Pattern string `yaml:"pattern" toml:"pattern"`
+	Require bool   `yaml:"require" toml:"require"`
+}
+
+// DefaultSecretPatterns match well-known credential formats.
+var DefaultSecretPatterns = []string{
+	`AKIA[0-9A-Z]{16}`,                                              // AWS access key ID
+	`gh[pousr]_[A-Za-z0-9]{36,}`,                                    // GitHub token
+	`xox[abprs]-[A-Za-z0-9-]{10,}`,                                  // Slack token
+	`sk-[A-Za-z0-9_-]{20,}`,                                         // OpenAI-style API key
+	`-----BEGIN [A-Z ]*PRIVATE KEY-----`,                            // PEM private key
+	`eyJ[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}\.[A-Za-z0-9_-]{10,}`, // JWT
+}
+
+// DefaultAllowedSyscalls covers file I/O, memory management, signals,
+// process management and outgoing network connections, which agents need
+// to reach their model API. Servers cannot be run: bind, listen and accept
+// are not included. The sandbox only lets kill signal single processes,
+// not process groups. Names the running architecture does not have are
+// ignored.
+var DefaultAllowedSyscalls = []string{
+	// File I/O
+	"read", "write", "readv", "writev", "pread64", "pwrite64", "open", "openat", "openat2",
+	"close", "close_range", "lseek", "stat", "fstat", "lstat", "newfstatat", "statx",
+	"statfs", "fstatfs", "access", "faccessat", "faccessat2", "getdents64", "readlink",
+	"readlinkat", "fcntl", "flock", "fsync", "fdatasync", "truncate", "ftruncate", "rename",
+	"renameat", "renameat2", "mkdir", "mkdirat", "rmdir", "unlink", "unlinkat", "symlink",
+	"symlinkat", "link", "linkat", "chmod", "fchmod", "fchmodat", "umask", "utimensat",
+	"getcwd", "chdir", "fchdir", "dup", "dup2", "dup3", "pipe", "pipe2", "ioctl",
+	"select", "pselect6", "poll", "ppoll", "epoll_create", "epoll_create1", "epoll_ctl",
+	"epoll_wait", "epoll_pwait", "eventfd2",
+	// Memory
+	"brk", "mmap", "munmap", "mprotect", "mremap", "madvise",
+	// Signals
+	"rt_sigaction", "rt_sigprocmask", "rt_sigreturn", "sigaltstack", "kill", "tgkill",
+	// Networking
+	"socket", "socketpair", "connect", "getsockname", "getpeername", "setsockopt",
+	"getsockopt", "sendto", "recvfrom", "sendmsg", "recvmsg", "sendmmsg", "recvmmsg",
+	"shutdown",
+	// Process management
+	"execve", "execveat", "clone", "clone3", "fork", "vfork", "wait4", "waitid", "exit",
+	"exit_group", "getpid", "getppid", "gettid", "getuid", "geteuid", "getgid", "getegid",
+	"set_tid_address", "set_robust_list", "rseq", "futex", "arch_prctl", "prctl",
+	"prlimit64", "getrlimit", "sched_yield", "sched_getaffinity", "nanosleep",
+	"clock_nanosleep", "clock_gettime", "gettimeofday", "getrandom", "uname",
 }
 
 // Default returns a Config with sensible defaults.
 func Default() *Config {
 	return &Config{
 		Server: ServerConfig{
-			LLMEndpoint: "http://localhost:8000",
-			APIKey:      "",
+			LLMEndpoint:     "http://localhost:8000",
+			APIKey:          "",
+			MaxMessageBytes: 1024 * 1024, // 1MB
 		},
 		Workspace: WorkspaceConfig{
 			DefaultRoot: "",
@@ -82,46 +244,144 @@ func Default() *Config {
 				"**/node_modules/**",
 				"**/.git/objects/**",
 			},
-			MaxFileSizeBytes: 10 * 1024 * 1024, // 10MB
+			MaxFileSizeBytes:  10 * 1024 * 1024, // 10MB
+			RespectGitignore:  true,
+			DiskUsageSkipDirs: []string{"node_modules", "vendor"},
+			AuditLogSize:      1000,
+			RecentFilesLimit:  20,
 		},
 		Execution: ExecutionConfig{
 			Enabled:        true,
 			TimeoutMs:      30000,
+			ReadTimeoutMs:  5000,
 			Shell:          "auto",
 			NetworkAllowed: false,
 			MaxOutputBytes: 1024 * 1024, // 1MB
 			CustomCommands: []CustomCommand{},
+			EnvAllowlist:   []string{"HOME", "USER", "PATH", "LANG", "TMPDIR", "GOPATH", "VIRTUAL_ENV", "NODE_ENV", "CI"},
+			HiddenEnvVars:  []string{"*TOKEN*", "*KEY*", "*SECRET*", "*PASSWORD*"},
+			MaxTerminals:   10,
+			AllowedShells:  []string{"sh", "bash", "zsh", "fish", "powershell", "pwsh", "cmd"},
+
+			MaxBackground:       4,
+			BackgroundTimeoutMs: 30 * 60 * 1000, // 30 minutes
 		},
 		Security: SecurityConfig{
 			RequireApprovalForWrites: true,
 			RequireApprovalForExec:   true,
 			RedactSecrets:            true,
+			PermissionTimeoutMs:      30000,
+			AllowedSyscalls:          append([]string(nil), DefaultAllowedSyscalls...),
+			SecretPatterns:           append([]string(nil), DefaultSecretPatterns...),
 		},
 		Agent: AgentConfig{
 			Command: "",
 			Args:    []string{},
 			Env:     []string{},
+
+			DrainTimeoutMs: 10000,
 		},
 	}
 }
 
-// ConfigPath returns the path to the config file.
+// ProjectConfigName is the file name of the per-project config that is
+// merged on top of the global config.
+const ProjectConfigName = ".tldw-agent.yaml"
+
+// Format identifies a config file encoding.
+type Format int
+
+const (
+	// YAML is the default config format.
+	YAML Format = iota
+	// TOML is selected for files with a .toml extension.
+	TOML
+)
+
+// String returns the conventional name of the format.
+func (f Format) String() string {
+	if f == TOML {
+		return "toml"
+	}
+	return "yaml"
+}
+
+// FormatFromPath picks the config format from a file extension. Anything
+// other than .toml is treated as YAML.
+func FormatFromPath(path string) Format {
+	if strings.EqualFold(filepath.Ext(path), ".toml") {
+		return TOML
+	}
+	return YAML
+}
+
+// ConfigPath returns the path to the config file. TLDW_CONFIG_PATH
+// overrides the default ~/.tldw-agent/config.yaml location.
 func ConfigPath() string {
+	if path := os.Getenv("TLDW_CONFIG_PATH"); path != "" {
+		return path
+	}
+	dir := DataDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "config.yaml")
+}
+
+// DataDir returns the per-user directory holding the config file and other
+// host state, ~/.tldw-agent.
+func DataDir() string {
 	home, err := os.UserHomeDir()
 	if err != nil {
 		return ""
 	}
-	return filepath.Join(home, ".tldw-agent", "config.yaml")
+	return filepath.Join(home, ".tldw-agent")
+}
+
+// ProjectConfigPath returns the path of the per-project config for a workspace root.
+func ProjectConfigPath(root string) string {
+	return filepath.Join(root, ProjectConfigName)
 }
 
-// Load reads configuration from the default config file.
+// Load reads configuration from the default config file, merges the
+// project config of the default workspace root if one is configured,
+// applies TLDW_* environment variable overrides on top of it, and
+// validates the result.
 func Load() (*Config, error) {
 	path := ConfigPath()
-	return LoadFrom(path)
+	cfg, err := LoadFrom(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if root := cfg.Workspace.DefaultRoot; root != "" {
+		if err := cfg.mergeProjectFile(ProjectConfigPath(root)); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := ApplyEnvironment(cfg); err != nil {
+		return nil, err
+	}
+
+	if errs := Errors(cfg.Validate()); len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	// Environment overrides may have replaced the blocked paths.
+	cfg.Compile()
+
+	return cfg, nil
 }
 
-// LoadFrom reads configuration from a specific file path.
+// LoadFrom reads configuration from a specific file path, choosing the
+// format from the file extension.
 func LoadFrom(path string) (*Config, error) {
+	return LoadFromFormat(path, FormatFromPath(path))
+}
+
+// LoadFromFormat reads configuration from a file in the given format.
+func LoadFromFormat(path string, format Format) (*Config, error) {
 	cfg := Default()
 
 	data, err := os.ReadFile(path)
@@ -132,28 +392,102 @@ func LoadFrom(path string) (*Config, error) {
 		return nil, err
 	}
 
-	if err := yaml.Unmarshal(data, cfg); err != nil {
+	if err := unmarshal(data, format, cfg); err != nil {
 		return nil, err
 	}
+	cfg.Source = path
+	cfg.Compile()
 
 	return cfg, nil
 }
 
+// LoadWithProject reads the global config and deep-merges a per-project
+// config on top of it. Scalar values in the project file override the
+// global ones, while blocked_paths and custom_commands are appended.
+// The security section of the project file is ignored. A missing project
+// file is not an error.
+func LoadWithProject(globalPath, projectPath string) (*Config, error) {
+	cfg, err := LoadFrom(globalPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := cfg.mergeProjectFile(projectPath); err != nil {
+		return nil, err
+	}
+
+	return cfg, nil
+}
+
+// mergeProjectFile merges the project config at path into c. The project
+// file is checked in with the code the agent works on, so it cannot change
+// the security settings: those stay as the user configured them.
+func (c *Config) mergeProjectFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	// Decode the list fields separately so they can be appended rather
+	// than replaced by the overlay below.
+	var lists struct {
+		Workspace struct {
+			BlockedPaths []string `yaml:"blocked_paths" toml:"blocked_paths"`
+		} `yaml:"workspace" toml:"workspace"`
+		Execution struct {
+			CustomCommands []CustomCommand `yaml:"custom_commands" toml:"custom_commands"`
+		} `yaml:"execution" toml:"execution"`
+	}
+	if err := yaml.Unmarshal(data, &lists); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	blocked := append([]string{}, c.Workspace.BlockedPaths...)
+	commands := append([]CustomCommand{}, c.Execution.CustomCommands...)
+	source := c.Source
+	security := c.Security
+
+	if err := yaml.Unmarshal(data, c); err != nil {
+		return fmt.Errorf("parse %s: %w", path, err)
+	}
+
+	c.Workspace.BlockedPaths = append(blocked, lists.Workspace.BlockedPaths...)
+	c.Execution.CustomCommands = append(commands, lists.Execution.CustomCommands...)
+	c.Security = security
+	if source != "" {
+		c.Source = source + ", " + path
+	} else {
+		c.Source = path
+	}
+
+	c.Compile()
+	return nil
+}
+
 // Save writes the configuration to the default config file.
 func (c *Config) Save() error {
 	path := ConfigPath()
 	return c.SaveTo(path)
 }
 
-// SaveTo writes the configuration to a specific file path.
+// SaveTo writes the configuration to a specific file path, choosing the
+// format from the file extension.
 func (c *Config) SaveTo(path string) error {
+	return c.SaveToFormat(path, FormatFromPath(path))
+}
+
+// SaveToFormat writes the configuration to a file in the given format.
+func (c *Config) SaveToFormat(path string, format Format) error {
 	// Ensure directory exists
 	dir := filepath.Dir(path)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return err
 	}
 
-	data, err := yaml.Marshal(c)
+	data, err := marshal(c, format)
 	if err != nil {
 		return err
 	}
@@ -161,6 +495,24 @@ func (c *Config) SaveTo(path string) error {
 	return os.WriteFile(path, data, 0644)
 }
 
+func unmarshal(data []byte, format Format, v interface{}) error {
+	if format == TOML {
+		return toml.Unmarshal(data, v)
+	}
+	return yaml.Unmarshal(data, v)
+}
+
+func marshal(v interface{}, format Format) ([]byte, error) {
+	if format == TOML {
+		var buf bytes.Buffer
+		if err := toml.NewEncoder(&buf).Encode(v); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+	return yaml.Marshal(v)
+}
+
 // GetShell returns the shell to use for command execution.
 func (c *Config) GetShell() string {
 	if c.Execution.Shell != "auto" {
@@ -173,16 +525,63 @@ func (c *Config) GetShell() string {
 	return "bash"
 }
 
-// IsPathBlocked checks if a path matches any of the blocked patterns.
+// Compile pre-compiles the blocked path patterns so IsPathBlocked does not
+// have to parse them on every call. It must be called again after
+// BlockedPaths is modified; until then IsPathBlocked falls back to
+// compiling patterns on demand. Invalid patterns are left out, as they
+// are by the fallback; Validate reports them.
+func (c *Config) Compile() {
+	globs := make([]glob.Glob, 0, len(c.Workspace.BlockedPaths))
+	for _, pattern := range c.Workspace.BlockedPaths {
+		g, err := compileBlockedPattern(pattern)
+		if err != nil {
+			continue
+		}
+		globs = append(globs, g)
+	}
+
+	c.blocked = &compiledPatterns{
+		patterns: append([]string{}, c.Workspace.BlockedPaths...),
+		globs:    globs,
+	}
+}
+
+// compiledPatterns caches compiled blocked path globs together with the
+// patterns they were built from so stale caches can be detected.
+type compiledPatterns struct {
+	patterns []string
+	globs    []glob.Glob
+}
+
+// compileBlockedPattern compiles a blocked path pattern with / as the
+// separator: * and ? stay within one path element, ** spans any number of
+// them, and {a,b} matches either alternative.
+func compileBlockedPattern(pattern string) (glob.Glob, error) {
+	return glob.Compile(filepath.ToSlash(pattern), '/')
+}
+
+// IsPathBlocked checks if a path matches any of the blocked patterns. A
+// pattern may match either the file name or the whole path.
 func (c *Config) IsPathBlocked(path string) bool {
+	base := filepath.Base(path)
+	full := filepath.ToSlash(path)
+
+	if compiled := c.blocked; compiled != nil && slices.Equal(compiled.patterns, c.Workspace.BlockedPaths) {
+		for _, g := range compiled.globs {
+			if g.Match(base) || g.Match(full) {
+				return true
+			}
+		}
+		return false
+	}
+
 	for _, pattern := range c.Workspace.BlockedPaths {
-		matched, err := filepath.Match(pattern, filepath.Base(path))
-		if err == nil && matched {
-			return true
+		g, err := compileBlockedPattern(pattern)
+		if err != nil {
+			continue
 		}
-		// Also check the full path for glob patterns
-		matched, err = filepath.Match(pattern, path)
-		if err == nil && matched {
+		// Check the base name as well as the full path for glob patterns
+		if g.Match(base) || g.Match(full) {
 			return true
 		}
 	}
@@ -0,0 +1,103 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIsPathBlocked(t *testing.T) {
+	tests := []struct {
+		pattern string
+		path    string
+		want    bool
+	}{
+		// Matched against the file name, as before
+		{".env", "/work/app/.env", true},
+		{".env", "/work/app/.env.example", false},
+		{"*.pem", "/work/certs/server.pem", true},
+		{"*.key", "/work/key.txt", false},
+		// Or the whole path
+		{"/work/secrets/*", "/work/secrets/token", true},
+		// * stays within one path element
+		{"/work/*/token", "/work/a/b/token", false},
+		// ** spans directories
+		{"**/node_modules/**", "/work/app/node_modules/pkg/index.js", true},
+		{"**/node_modules/**", "/work/app/src/index.js", false},
+		{"**/.git/objects/**", "/work/.git/objects/ab/cdef", true},
+		// Alternatives
+		{"*.{pem,key}", "/work/id.key", true},
+		{"*.{pem,key}", "/work/id.pub", false},
+	}
+	for _, tt := range tests {
+		cfg := Default()
+		cfg.Workspace.BlockedPaths = []string{tt.pattern}
+		// Compiled and on-demand matching must agree
+		if got := cfg.IsPathBlocked(tt.path); got != tt.want {
+			t.Errorf("uncompiled %q on %q = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+		cfg.Compile()
+		if got := cfg.IsPathBlocked(tt.path); got != tt.want {
+			t.Errorf("compiled %q on %q = %v, want %v", tt.pattern, tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestInvalidBlockedPathIsConfigError(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("workspace:\n  blocked_paths: [\"*.pem\", \"[\"]\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadFrom(path)
+	if err != nil {
+		t.Fatalf("LoadFrom failed: %v", err)
+	}
+	errs := Errors(cfg.Validate())
+	if len(errs) != 1 || errs[0].Field != "workspace.blocked_paths[1]" {
+		t.Fatalf("expected a config error for blocked_paths[1], got %v", errs)
+	}
+
+	// The valid patterns still apply
+	if !cfg.IsPathBlocked("/work/server.pem") {
+		t.Fatal("expected *.pem to stay blocked")
+	}
+}
+
+func TestRelativeAllowedPathIsConfigError(t *testing.T) {
+	cfg := Default()
+	abs, err := filepath.Abs("testdata")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg.Workspace.AllowedPaths = []string{abs, "src"}
+
+	errs := Errors(cfg.Validate())
+	if len(errs) != 1 || errs[0].Field != "workspace.allowed_paths[1]" {
+		t.Fatalf("expected a config error for allowed_paths[1], got %v", errs)
+	}
+}
+
+func TestProjectFileCannotChangeSecurity(t *testing.T) {
+	dir := t.TempDir()
+	global := filepath.Join(dir, "config.yaml")
+	project := filepath.Join(dir, ProjectConfigName)
+	if err := os.WriteFile(global, []byte("security:\n  require_approval_for_exec: true\n  redact_secrets: true\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(project, []byte("workspace:\n  blocked_paths: [\"*.db\"]\nsecurity:\n  require_approval_for_exec: false\n  redact_secrets: false\n  exec_rpm: 1000\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg, err := LoadWithProject(global, project)
+	if err != nil {
+		t.Fatalf("LoadWithProject failed: %v", err)
+	}
+	if !cfg.Security.RequireApprovalForExec || !cfg.Security.RedactSecrets || cfg.Security.ExecRPM != 0 {
+		t.Fatalf("project file changed security settings: %+v", cfg.Security)
+	}
+	// The rest of the project file still applies
+	if !cfg.IsPathBlocked("/work/app.db") {
+		t.Fatal("expected the project blocked_paths to be merged")
+	}
+}
@@ -0,0 +1,29 @@
+package config
+
+import "reflect"
+
+// Diff returns the dotted yaml keys whose values differ between two configs.
+func Diff(old, updated *Config) []string {
+	var changed []string
+	diffValues(reflect.ValueOf(old).Elem(), reflect.ValueOf(updated).Elem(), "", &changed)
+	return changed
+}
+
+func diffValues(a, b reflect.Value, path string, changed *[]string) {
+	t := a.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := yamlFieldName(t.Field(i))
+		if name == "" || name == "-" {
+			continue
+		}
+		key := joinSchemaPath(path, name)
+		fa, fb := a.Field(i), b.Field(i)
+		if fa.Kind() == reflect.Struct {
+			diffValues(fa, fb, key, changed)
+			continue
+		}
+		if !reflect.DeepEqual(fa.Interface(), fb.Interface()) {
+			*changed = append(*changed, key)
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// envPrefix is prepended to every environment variable override.
+const envPrefix = "TLDW"
+
+// ApplyEnvironment overrides config fields from TLDW_* environment variables.
+// Variable names are derived from the yaml tags of each field, for example
+// server.llm_endpoint becomes TLDW_SERVER_LLM_ENDPOINT. Booleans accept
+// "true"/"false"/"1"/"0" and string lists are comma-separated. Values are
+// never included in returned errors so secrets such as TLDW_SERVER_API_KEY
+// do not end up in logs.
+func ApplyEnvironment(cfg *Config) error {
+	return applyEnv(reflect.ValueOf(cfg).Elem(), envPrefix)
+}
+
+func applyEnv(v reflect.Value, prefix string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name := yamlFieldName(field)
+		if name == "" || name == "-" {
+			continue
+		}
+
+		key := prefix + "_" + strings.ToUpper(name)
+		fv := v.Field(i)
+		if fv.Kind() == reflect.Struct {
+			if err := applyEnv(fv, key); err != nil {
+				return err
+			}
+			continue
+		}
+
+		raw, ok := os.LookupEnv(key)
+		if !ok {
+			continue
+		}
+		if err := setFromEnv(fv, raw); err != nil {
+			return fmt.Errorf("invalid %s: %w", key, err)
+		}
+	}
+	return nil
+}
+
+func setFromEnv(fv reflect.Value, raw string) error {
+	switch fv.Kind() {
+	case reflect.String:
+		fv.SetString(raw)
+	case reflect.Bool:
+		b, err := parseEnvBool(raw)
+		if err != nil {
+			return err
+		}
+		fv.SetBool(b)
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(strings.TrimSpace(raw), 10, fv.Type().Bits())
+		if err != nil {
+			return fmt.Errorf("expected an integer")
+		}
+		fv.SetInt(n)
+	case reflect.Slice:
+		if fv.Type().Elem().Kind() != reflect.String {
+			return fmt.Errorf("field cannot be set from the environment")
+		}
+		items := []string{}
+		for _, item := range strings.Split(raw, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				items = append(items, item)
+			}
+		}
+		fv.Set(reflect.ValueOf(items))
+	default:
+		return fmt.Errorf("field cannot be set from the environment")
+	}
+	return nil
+}
+
+func parseEnvBool(raw string) (bool, error) {
+	switch strings.ToLower(strings.TrimSpace(raw)) {
+	case "true", "1":
+		return true, nil
+	case "false", "0":
+		return false, nil
+	}
+	return false, fmt.Errorf("expected true, false, 1, or 0")
+}
+
+// yamlFieldName returns the yaml key for a struct field, or "-" for fields
+// that are not part of the config file.
+func yamlFieldName(field reflect.StructField) string {
+	if !field.IsExported() {
+		return "-"
+	}
+	tag := field.Tag.Get("yaml")
+	if tag == "" {
+		return strings.ToLower(field.Name)
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	return name
+}
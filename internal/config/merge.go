@@ -0,0 +1,116 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Merge returns a copy of base with every field that is set in patch
+// applied on top. Nested sections are merged field by field rather than
+// replaced; lists in patch replace the base list. Fields left at their
+// zero value in patch keep the base value. Neither argument is modified.
+func Merge(base, patch *Config) *Config {
+	merged := *base
+	mergeValues(reflect.ValueOf(&merged).Elem(), reflect.ValueOf(patch).Elem())
+	return &merged
+}
+
+func mergeValues(dst, src reflect.Value) {
+	t := dst.Type()
+	for i := 0; i < t.NumField(); i++ {
+		name := yamlFieldName(t.Field(i))
+		if name == "" || name == "-" {
+			continue
+		}
+		fd, fs := dst.Field(i), src.Field(i)
+		if fd.Kind() == reflect.Struct {
+			mergeValues(fd, fs)
+			continue
+		}
+		if fs.IsZero() {
+			continue
+		}
+		if fs.Kind() == reflect.Slice {
+			fd.Set(reflect.AppendSlice(reflect.MakeSlice(fs.Type(), 0, fs.Len()), fs))
+			continue
+		}
+		fd.Set(fs)
+	}
+}
+
+// ApplyPatch decodes a partial config document (YAML or JSON) and merges
+// it into a copy of base. Unlike Merge, keys that are present in the
+// document with a zero value (false, 0, "" or an empty list) are applied
+// too, so a patch can switch settings off. Unknown keys are rejected.
+func ApplyPatch(base *Config, data []byte) (*Config, error) {
+	var patch Config
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	dec.KnownFields(true)
+	if err := dec.Decode(&patch); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("parse patch: %w", err)
+	}
+
+	var raw map[string]interface{}
+	if err := yaml.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("parse patch: %w", err)
+	}
+
+	merged := Merge(base, &patch)
+	for _, key := range zeroKeys(raw, "") {
+		clearField(reflect.ValueOf(merged).Elem(), strings.Split(key, "."))
+	}
+
+	merged.Compile()
+	return merged, nil
+}
+
+// zeroKeys returns the dotted keys of the leaves of doc that hold a zero value.
+func zeroKeys(doc map[string]interface{}, prefix string) []string {
+	var keys []string
+	for name, value := range doc {
+		key := joinSchemaPath(prefix, name)
+		switch v := value.(type) {
+		case map[string]interface{}:
+			keys = append(keys, zeroKeys(v, key)...)
+		case []interface{}:
+			if len(v) == 0 {
+				keys = append(keys, key)
+			}
+		case nil:
+			keys = append(keys, key)
+		default:
+			if reflect.ValueOf(v).IsZero() {
+				keys = append(keys, key)
+			}
+		}
+	}
+	return keys
+}
+
+// clearField sets the field at the given yaml path to its zero value, or
+// to an empty list for list fields.
+func clearField(v reflect.Value, path []string) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		if yamlFieldName(t.Field(i)) != path[0] {
+			continue
+		}
+		f := v.Field(i)
+		switch {
+		case len(path) > 1 && f.Kind() == reflect.Struct:
+			clearField(f, path[1:])
+		case len(path) > 1:
+			// Path descends into a non-struct field; nothing to clear
+		case f.Kind() == reflect.Slice:
+			f.Set(reflect.MakeSlice(f.Type(), 0, 0))
+		default:
+			f.Set(reflect.Zero(f.Type()))
+		}
+		return
+	}
+}
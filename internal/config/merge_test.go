@@ -0,0 +1,87 @@
+package config
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestMergeOverlaysSetFields(t *testing.T) {
+	base := Default()
+	base.Server.APIKey = "secret"
+
+	patch := &Config{}
+	patch.Server.LLMEndpoint = "http://llm:9000"
+	patch.Execution.TimeoutMs = 1000
+
+	merged := Merge(base, patch)
+
+	if merged.Server.LLMEndpoint != "http://llm:9000" {
+		t.Fatalf("expected llm_endpoint to be patched, got %q", merged.Server.LLMEndpoint)
+	}
+	if merged.Execution.TimeoutMs != 1000 {
+		t.Fatalf("expected timeout_ms 1000, got %d", merged.Execution.TimeoutMs)
+	}
+	if merged.Server.APIKey != "secret" {
+		t.Fatalf("expected api_key to be kept, got %q", merged.Server.APIKey)
+	}
+	if merged.Execution.Shell != base.Execution.Shell || !merged.Execution.Enabled {
+		t.Fatalf("expected unset execution fields to be kept, got %+v", merged.Execution)
+	}
+}
+
+func TestMergeReplacesLists(t *testing.T) {
+	base := Default()
+	patch := &Config{}
+	patch.Workspace.BlockedPaths = []string{"*.secret"}
+
+	merged := Merge(base, patch)
+
+	if !slices.Equal(merged.Workspace.BlockedPaths, []string{"*.secret"}) {
+		t.Fatalf("expected blocked_paths to be replaced, got %v", merged.Workspace.BlockedPaths)
+	}
+
+	patch.Workspace.BlockedPaths[0] = "changed"
+	if merged.Workspace.BlockedPaths[0] != "*.secret" {
+		t.Fatalf("merged config shares its list with the patch")
+	}
+}
+
+func TestMergeDoesNotModifyBase(t *testing.T) {
+	base := Default()
+	patch := &Config{Debug: true}
+	patch.Server.LLMEndpoint = "http://llm:9000"
+
+	Merge(base, patch)
+
+	if base.Debug || base.Server.LLMEndpoint != Default().Server.LLMEndpoint {
+		t.Fatalf("base config was modified: %+v", base)
+	}
+}
+
+func TestApplyPatchAppliesExplicitZeroValues(t *testing.T) {
+	base := Default()
+
+	merged, err := ApplyPatch(base, []byte(`{"execution": {"enabled": false}, "workspace": {"blocked_paths": []}}`))
+	if err != nil {
+		t.Fatalf("ApplyPatch: %v", err)
+	}
+
+	if merged.Execution.Enabled {
+		t.Fatalf("expected execution.enabled to be switched off")
+	}
+	if merged.Workspace.BlockedPaths == nil || len(merged.Workspace.BlockedPaths) != 0 {
+		t.Fatalf("expected blocked_paths to be cleared, got %v", merged.Workspace.BlockedPaths)
+	}
+	if merged.Execution.TimeoutMs != base.Execution.TimeoutMs {
+		t.Fatalf("expected timeout_ms to be kept, got %d", merged.Execution.TimeoutMs)
+	}
+	if !base.Execution.Enabled {
+		t.Fatalf("base config was modified")
+	}
+}
+
+func TestApplyPatchRejectsUnknownKeys(t *testing.T) {
+	if _, err := ApplyPatch(Default(), []byte(`{"execution": {"enabeld": true}}`)); err == nil {
+		t.Fatalf("expected an error for an unknown key")
+	}
+}
@@ -0,0 +1,138 @@
+package config
+
+import (
+	_ "embed"
+	"encoding/json"
+	"reflect"
+	"strings"
+)
+
+//go:generate go test -run TestSchemaAsset -update
+
+// Schema is the JSON Schema of the config file as generated by JSONSchema,
+// embedded so that it is served without reflecting over Config. Run go
+// generate after changing Config or its descriptions; TestSchemaAsset fails
+// until then.
+//
+//go:embed schema.json
+var Schema []byte
+
+// descriptions holds the human-readable text for each config key, keyed by
+// its dotted yaml path. Go struct tags cannot carry freeform text, so the
+// schema generator looks descriptions up here.
+var descriptions = map[string]string{
+	"server":                                "LLM server connection settings",
+	"server.llm_endpoint":                   "Base URL of the tldw_server LLM endpoint",
+	"server.api_key":                        "API key sent to the LLM endpoint",
+	"server.embedding_model":                "Model used for embeddings by search.semantic (empty = server default)",
+	"server.max_message_bytes":              "Maximum size of a native messaging request or ACP message in bytes",
+	"server.mcp_port":                       "Localhost port for the MCP HTTP+SSE transport (0 disables it)",
+	"server.ws_port":                        "Localhost port for the MCP WebSocket transport (0 disables it)",
+	"server.cors_origins":                   "Browser origins allowed to call the HTTP and WebSocket transports (\"*\" for any)",
+	"workspace":                             "Workspace settings",
+	"workspace.default_root":                "Workspace root used when none is set explicitly",
+	"workspace.blocked_paths":               "Glob patterns for paths that tools may never access",
+	"workspace.allowed_paths":               "When set, absolute directories outside which no path may be accessed",
+	"workspace.max_file_size_bytes":         "Largest file, in bytes, that tools will read",
+	"workspace.respect_gitignore":           "Skip .gitignore'd files when listing and searching",
+	"workspace.max_disk_usage_bytes":        "Maximum total workspace size writes may grow it to (0 = unlimited)",
+	"workspace.disk_usage_skip_dirs":        "Directory names skipped by workspace.disk_usage",
+	"workspace.audit_log_size":              "Number of file operations kept in the audit log",
+	"workspace.recent_files_limit":          "Number of recently accessed files reported by workspace.recent_files",
+	"workspace.cache_ttl_ms":                "How long read-only tool results are cached in milliseconds (0 = no caching)",
+	"execution":                             "Command execution settings",
+	"execution.enabled":                     "Allow allowlisted commands to run",
+	"execution.timeout_ms":                  "Maximum command run time in milliseconds",
+	"execution.read_timeout_ms":             "Maximum run time in milliseconds for read-only git operations",
+	"execution.shell":                       "Shell used to run commands, or \"auto\"",
+	"execution.network_allowed":             "Allow commands network access",
+	"execution.max_output_bytes":            "Maximum captured stdout/stderr size in bytes",
+	"execution.allowed_shells":              "Shells an ACP terminal may request instead of execution.shell",
+	"execution.max_terminals":               "Maximum ACP terminals running at once per session (0 = unlimited)",
+	"execution.max_background":              "Maximum exec.run background commands running at once (0 = unlimited)",
+	"execution.background_timeout_ms":       "Maximum background command run time in milliseconds (0 = no limit)",
+	"execution.env_allowlist":               "Environment variables custom command env values may reference as ${VAR}",
+	"execution.hidden_env_vars":             "Glob patterns for environment variables exec.env redacts",
+	"execution.custom_commands":             "Additional allowlisted commands",
+	"execution.custom_commands.id":          "Identifier used to invoke the command",
+	"execution.custom_commands.template":    "Command line to run",
+	"execution.custom_commands.description": "Description shown to the agent",
+	"execution.custom_commands.category":    "Command category (test, lint, format, package)",
+	"execution.custom_commands.allow_args":  "Allow extra arguments to be appended",
+	"execution.custom_commands.max_args":    "Maximum number of extra arguments",
+	"execution.custom_commands.env":         "Extra environment variables (KEY=value, ${VAR} expanded)",
+	"security":                              "Security settings",
+	"security.require_approval_for_writes":  "Require approval before write-tier tools run",
+	"security.require_approval_for_exec":    "Require approval before exec-tier tools run",
+	"security.redact_secrets":               "Redact secrets from tool output",
+	"security.read_rpm":                     "Maximum read-tier tool calls per minute (0 = unlimited)",
+	"security.write_rpm":                    "Maximum write-tier tool calls per minute (0 = unlimited)",
+	"security.exec_rpm":                     "Maximum exec-tier tool calls per minute (0 = unlimited)",
+	"security.sandbox_enabled":              "Run ACP agents under a seccomp syscall filter (Linux only)",
+	"security.allowed_syscalls":             "Syscalls permitted to sandboxed agents",
+	"security.tool_approval_rules":          "Per-tool approval overrides; the first matching rule applies",
+	"security.tool_approval_rules.pattern":  "Tool name, with * matching any characters",
+	"security.tool_approval_rules.require":  "Whether matching tools need approval",
+	"security.secret_patterns":              "Regular expressions redacted from tool results sent to the extension",
+	"security.permission_timeout_ms":        "Time an ACP permission request waits for an answer before it is cancelled (0 = no limit)",
+	"agent":                                 "Downstream ACP agent launch settings",
+	"agent.command":                         "Agent executable to launch",
+	"agent.args":                            "Arguments passed to the agent",
+	"agent.env":                             "Extra environment variables for the agent (KEY=value)",
+	"agent.drain_timeout_ms":                "Time in-flight requests get to finish on SIGTERM",
+	"debug":                                 "Enable debugging endpoints",
+}
+
+// JSONSchema returns a draft-07 JSON Schema describing the config file.
+func JSONSchema() ([]byte, error) {
+	schema := schemaFor(reflect.TypeOf(Config{}), "")
+	schema["$schema"] = "http://json-schema.org/draft-07/schema#"
+	schema["title"] = "tldw-agent configuration"
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+func schemaFor(t reflect.Type, path string) map[string]interface{} {
+	schema := map[string]interface{}{}
+	if desc, ok := descriptions[path]; ok {
+		schema["description"] = desc
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		props := map[string]interface{}{}
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			name := yamlFieldName(field)
+			if name == "" || name == "-" {
+				continue
+			}
+			props[name] = schemaFor(field.Type, joinSchemaPath(path, name))
+		}
+		schema["type"] = "object"
+		schema["properties"] = props
+		schema["additionalProperties"] = false
+	case reflect.Slice:
+		schema["type"] = "array"
+		items := schemaFor(t.Elem(), path)
+		delete(items, "description")
+		schema["items"] = items
+	case reflect.Bool:
+		schema["type"] = "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		schema["type"] = "integer"
+	case reflect.Float32, reflect.Float64:
+		schema["type"] = "number"
+	default:
+		schema["type"] = "string"
+	}
+
+	return schema
+}
+
+func joinSchemaPath(parent, name string) string {
+	if parent == "" {
+		return name
+	}
+	return strings.Join([]string{parent, name}, ".")
+}
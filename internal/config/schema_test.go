@@ -0,0 +1,44 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"os"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "regenerate schema.json")
+
+func TestSchemaAsset(t *testing.T) {
+	generated, err := JSONSchema()
+	if err != nil {
+		t.Fatalf("JSONSchema failed: %v", err)
+	}
+	if *update {
+		if err := os.WriteFile("schema.json", generated, 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	if !bytes.Equal(Schema, generated) {
+		t.Fatal("schema.json is out of date; run go generate ./internal/config")
+	}
+
+	var schema struct {
+		Schema     string                     `json:"$schema"`
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(Schema, &schema); err != nil {
+		t.Fatalf("embedded schema is not valid JSON: %v", err)
+	}
+	if schema.Schema != "http://json-schema.org/draft-07/schema#" {
+		t.Fatalf("unexpected $schema %q", schema.Schema)
+	}
+	for _, section := range []string{"server", "workspace", "execution", "security", "agent"} {
+		if _, ok := schema.Properties[section]; !ok {
+			t.Errorf("embedded schema has no %q section", section)
+		}
+	}
+}
@@ -0,0 +1,173 @@
+package config
+
+import (
+	"fmt"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ConfigError describes a single invalid config value. Warnings flag
+// values that are accepted but likely to fail when used.
+type ConfigError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+	Warning bool   `json:"warning,omitempty"`
+}
+
+// Error implements the error interface.
+func (e ConfigError) Error() string {
+	return fmt.Sprintf("%s: %s", e.Field, e.Message)
+}
+
+// Errors returns the entries of errs that are not warnings.
+func Errors(errs []ConfigError) []ConfigError {
+	var out []ConfigError
+	for _, e := range errs {
+		if !e.Warning {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// LookPath finds the executable the command template runs. Relative paths
+// such as ./scripts/lint.sh depend on the working directory the command
+// runs in and are returned as-is.
+func (c CustomCommand) LookPath() (string, error) {
+	fields := strings.Fields(c.Template)
+	if len(fields) == 0 {
+		return "", fmt.Errorf("empty template")
+	}
+	name := fields[0]
+	if !filepath.IsAbs(name) && strings.ContainsAny(name, `/\`) {
+		return name, nil
+	}
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return "", fmt.Errorf("%q was not found on PATH", name)
+	}
+	return path, nil
+}
+
+var commandIDPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Validate checks the configuration for values that would cause problems at
+// runtime. It returns every problem found, or nil if the config is valid.
+// Custom commands whose executable is not on PATH are reported as warnings;
+// use Errors to keep only the problems that make the config unusable.
+func (c *Config) Validate() []ConfigError {
+	var errs []ConfigError
+
+	if c.Server.MaxMessageBytes <= 0 {
+		errs = append(errs, ConfigError{Field: "server.max_message_bytes", Message: "must be greater than 0"})
+	}
+	if c.Server.MCPPort < 0 || c.Server.MCPPort > 65535 {
+		errs = append(errs, ConfigError{Field: "server.mcp_port", Message: "must be between 0 and 65535"})
+	}
+	if c.Server.WSPort < 0 || c.Server.WSPort > 65535 {
+		errs = append(errs, ConfigError{Field: "server.ws_port", Message: "must be between 0 and 65535"})
+	}
+	if (c.Server.MCPPort != 0 || c.Server.WSPort != 0) && c.Server.APIKey == "" {
+		errs = append(errs, ConfigError{Field: "server.api_key", Message: "required to authenticate the HTTP and WebSocket transports"})
+	}
+	if c.Execution.TimeoutMs <= 0 {
+		errs = append(errs, ConfigError{Field: "execution.timeout_ms", Message: "must be greater than 0"})
+	}
+	if c.Execution.ReadTimeoutMs <= 0 {
+		errs = append(errs, ConfigError{Field: "execution.read_timeout_ms", Message: "must be greater than 0"})
+	}
+	if c.Execution.MaxTerminals < 0 {
+		errs = append(errs, ConfigError{Field: "execution.max_terminals", Message: "must not be negative"})
+	}
+	if c.Workspace.CacheTTLMs < 0 {
+		errs = append(errs, ConfigError{Field: "workspace.cache_ttl_ms", Message: "must not be negative"})
+	}
+	if c.Execution.MaxBackground < 0 {
+		errs = append(errs, ConfigError{Field: "execution.max_background", Message: "must not be negative"})
+	}
+	if c.Execution.BackgroundTimeoutMs < 0 {
+		errs = append(errs, ConfigError{Field: "execution.background_timeout_ms", Message: "must not be negative"})
+	}
+	if c.Security.PermissionTimeoutMs < 0 {
+		errs = append(errs, ConfigError{Field: "security.permission_timeout_ms", Message: "must not be negative"})
+	}
+	if c.Security.SandboxEnabled && len(c.Security.AllowedSyscalls) == 0 {
+		errs = append(errs, ConfigError{Field: "security.allowed_syscalls", Message: "must not be empty when sandbox_enabled is set"})
+	}
+	if c.Agent.DrainTimeoutMs < 0 {
+		errs = append(errs, ConfigError{Field: "agent.drain_timeout_ms", Message: "must not be negative"})
+	}
+	for _, limit := range []struct {
+		field string
+		rpm   int
+	}{
+		{"security.read_rpm", c.Security.ReadRPM},
+		{"security.write_rpm", c.Security.WriteRPM},
+		{"security.exec_rpm", c.Security.ExecRPM},
+	} {
+		if limit.rpm < 0 {
+			errs = append(errs, ConfigError{Field: limit.field, Message: "must not be negative"})
+		}
+	}
+	if c.Workspace.MaxFileSizeBytes <= 0 {
+		errs = append(errs, ConfigError{Field: "workspace.max_file_size_bytes", Message: "must be greater than 0"})
+	}
+
+	if cmd := c.Agent.Command; cmd != "" && !filepath.IsAbs(cmd) {
+		if _, err := exec.LookPath(cmd); err != nil {
+			errs = append(errs, ConfigError{Field: "agent.command", Message: fmt.Sprintf("%q is not an absolute path and was not found on PATH", cmd)})
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i, cmd := range c.Execution.CustomCommands {
+		field := fmt.Sprintf("execution.custom_commands[%d].id", i)
+		if !commandIDPattern.MatchString(cmd.ID) {
+			errs = append(errs, ConfigError{Field: field, Message: fmt.Sprintf("%q is not a valid identifier", cmd.ID)})
+			continue
+		}
+		if seen[cmd.ID] {
+			errs = append(errs, ConfigError{Field: field, Message: fmt.Sprintf("duplicate command id %q", cmd.ID)})
+		}
+		seen[cmd.ID] = true
+
+		if _, err := cmd.LookPath(); err != nil {
+			errs = append(errs, ConfigError{
+				Field:   fmt.Sprintf("execution.custom_commands[%d].template", i),
+				Message: err.Error(),
+				Warning: true,
+			})
+		}
+	}
+
+	for i, rule := range c.Security.ToolApprovalRules {
+		if _, err := path.Match(rule.Pattern, ""); rule.Pattern == "" || err != nil {
+			errs = append(errs, ConfigError{Field: fmt.Sprintf("security.tool_approval_rules[%d].pattern", i), Message: fmt.Sprintf("invalid tool pattern %q", rule.Pattern)})
+		}
+	}
+
+	for i, pattern := range c.Security.SecretPatterns {
+		if _, err := regexp.Compile(pattern); err != nil {
+			errs = append(errs, ConfigError{Field: fmt.Sprintf("security.secret_patterns[%d]", i), Message: fmt.Sprintf("invalid regular expression %q", pattern)})
+		}
+	}
+
+	// Paths are checked once made absolute, so a relative entry would never
+	// match and would block everything
+	for i, dir := range c.Workspace.AllowedPaths {
+		if !filepath.IsAbs(dir) {
+			errs = append(errs, ConfigError{Field: fmt.Sprintf("workspace.allowed_paths[%d]", i), Message: fmt.Sprintf("must be an absolute path, got %q", dir)})
+		}
+	}
+
+	for i, pattern := range c.Workspace.BlockedPaths {
+		if _, err := compileBlockedPattern(pattern); err != nil {
+			errs = append(errs, ConfigError{Field: fmt.Sprintf("workspace.blocked_paths[%d]", i), Message: fmt.Sprintf("invalid glob pattern %q", pattern)})
+		}
+	}
+
+	return errs
+}
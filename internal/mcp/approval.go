@@ -0,0 +1,66 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"path"
+
+	"github.com/tldw/tldw-agent/internal/types"
+)
+
+type approvalKey struct{}
+
+// WithApproval returns a context marking tool calls made under it as
+// approved by the user, for tools that security.tool_approval_rules gate.
+func WithApproval(ctx context.Context) context.Context {
+	return context.WithValue(ctx, approvalKey{}, true)
+}
+
+// approved reports whether ctx carries the user's approval.
+func approved(ctx context.Context) bool {
+	ok, _ := ctx.Value(approvalKey{}).(bool)
+	return ok
+}
+
+// RequiresApproval reports whether calls to the named tool need the user's
+// approval: the first matching security.tool_approval_rules entry decides,
+// falling back to require_approval_for_writes or require_approval_for_exec
+// for the tool's tier.
+func (s *Server) RequiresApproval(name string) bool {
+	required, _ := s.approvalRule(name)
+	return required
+}
+
+// approvalRule is RequiresApproval that also reports whether the answer
+// came from an explicit rule.
+func (s *Server) approvalRule(name string) (required, ruled bool) {
+	security := s.Config().Security
+	for _, rule := range security.ToolApprovalRules {
+		if ok, _ := path.Match(rule.Pattern, name); ok {
+			return rule.Require, true
+		}
+	}
+	switch s.ToolTier(name) {
+	case "write":
+		return security.RequireApprovalForWrites, false
+	case "exec":
+		return security.RequireApprovalForExec, false
+	}
+	return false, false
+}
+
+// requireApproval is the built-in middleware rejecting calls to tools that
+// a rule requires approval for when ctx does not carry it. It runs first,
+// so a rejected call is not rate limited. The tier-wide settings are left
+// for clients to enforce, as they always have been.
+func (s *Server) requireApproval(ctx context.Context, name string, args json.RawMessage, next ToolHandler) (*ToolResult, error) {
+	if required, ruled := s.approvalRule(name); required && ruled && !approved(ctx) {
+		return &ToolResult{
+			OK:        false,
+			Error:     "approval_required",
+			ErrorCode: types.ErrPermission,
+			Data:      map[string]interface{}{"tool": name},
+		}, nil
+	}
+	return next(ctx, name, args)
+}
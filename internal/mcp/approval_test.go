@@ -0,0 +1,68 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tldw/tldw-agent/internal/config"
+)
+
+func newApprovalServer(t *testing.T) (*Server, string) {
+	t.Helper()
+	cfg := config.Default()
+	cfg.Security.ToolApprovalRules = []config.ToolApprovalRule{{Pattern: "fs.write", Require: true}}
+	s := NewServer(cfg)
+	root := t.TempDir()
+	if err := s.SetWorkspace(root); err != nil {
+		t.Fatalf("SetWorkspace failed: %v", err)
+	}
+	return s, root
+}
+
+func TestApprovalRequired(t *testing.T) {
+	s, root := newApprovalServer(t)
+	args := json.RawMessage(`{"path":"a.txt","content":"x"}`)
+
+	result, err := s.ExecuteTool("fs.write", args)
+	if err != nil {
+		t.Fatalf("ExecuteTool failed: %v", err)
+	}
+	if result.OK || result.Error != "approval_required" {
+		t.Fatalf("expected approval_required, got %+v", result)
+	}
+
+	results, err := s.ExecuteToolBatch([]ToolCall{{Name: "workspace.pwd"}, {Name: "fs.write", Arguments: args}})
+	if err != nil {
+		t.Fatalf("ExecuteToolBatch failed: %v", err)
+	}
+	if !results[0].OK || results[1].OK || results[1].Error != "approval_required" {
+		t.Fatalf("expected only the write in the batch to be rejected, got %+v, %+v", results[0], results[1])
+	}
+	if _, err := os.Stat(filepath.Join(root, "a.txt")); !os.IsNotExist(err) {
+		t.Fatal("a call without approval wrote the file")
+	}
+
+	result, err = s.ExecuteToolWithContext(WithApproval(context.Background()), "fs.write", args)
+	if err != nil || !result.OK {
+		t.Fatalf("expected an approved call to succeed, got %+v, %v", result, err)
+	}
+}
+
+func TestApprovalRunsBeforeUserMiddleware(t *testing.T) {
+	s, _ := newApprovalServer(t)
+	called := false
+	s.Use(func(ctx context.Context, name string, args json.RawMessage, next ToolHandler) (*ToolResult, error) {
+		called = true
+		return next(ctx, name, args)
+	})
+
+	if result, _ := s.ExecuteTool("fs.write", json.RawMessage(`{"path":"a.txt","content":"x"}`)); result.OK {
+		t.Fatal("expected the call to need approval")
+	}
+	if called {
+		t.Fatal("middleware ran for a call rejected for lack of approval")
+	}
+}
@@ -0,0 +1,88 @@
+package mcp
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+
+	"github.com/gorilla/websocket"
+)
+
+// WSProtocol is the WebSocket subprotocol the MCP transport selects.
+const WSProtocol = "mcp"
+
+// wsTokenPrefix marks the WebSocket subprotocol that carries the bearer
+// token. Browsers cannot set headers on a WebSocket handshake, so they
+// offer the protocols WSProtocol and "bearer.<api_key>" instead.
+const wsTokenPrefix = "bearer."
+
+// AuthMiddleware returns middleware for the network transports that
+// rejects requests without server.api_key as a bearer token, sent as an
+// "Authorization: Bearer <token>" header or, on a WebSocket handshake, as
+// a "bearer.<token>" subprotocol. An empty api_key rejects every request
+// rather than leaving the transport open. Cross-origin requests from
+// server.cors_origins ("*" for any) get CORS headers, and their preflight
+// requests are answered without authentication. Both settings are read
+// from the server's current config on every request.
+func AuthMiddleware(server *Server) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			cfg := server.Config().Server
+			if origin := r.Header.Get("Origin"); origin != "" && originAllowed(origin, cfg.CORSOrigins) {
+				h := w.Header()
+				h.Set("Access-Control-Allow-Origin", origin)
+				h.Set("Access-Control-Allow-Headers", "Authorization, Content-Type")
+				h.Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
+				h.Add("Vary", "Origin")
+				if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+					w.WriteHeader(http.StatusNoContent)
+					return
+				}
+			}
+
+			if !validBearer(r.Header.Get("Authorization"), cfg.APIKey) && !validProtocolToken(r, cfg.APIKey) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="tldw-agent"`)
+				http.Error(w, "unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// validBearer reports whether header carries token as a bearer token,
+// comparing in constant time.
+func validBearer(header, token string) bool {
+	const prefix = "Bearer "
+	if token == "" || len(header) < len(prefix) || !strings.EqualFold(header[:len(prefix)], prefix) {
+		return false
+	}
+	given := strings.TrimSpace(header[len(prefix):])
+	return subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1
+}
+
+// validProtocolToken reports whether r is a WebSocket handshake offering
+// token as a "bearer.<token>" subprotocol, comparing in constant time.
+func validProtocolToken(r *http.Request, token string) bool {
+	if token == "" || !websocket.IsWebSocketUpgrade(r) {
+		return false
+	}
+	for _, protocol := range websocket.Subprotocols(r) {
+		given, ok := strings.CutPrefix(protocol, wsTokenPrefix)
+		if ok && subtle.ConstantTimeCompare([]byte(given), []byte(token)) == 1 {
+			return true
+		}
+	}
+	return false
+}
+
+// originAllowed reports whether origin is listed in allowed, or allowed
+// holds "*".
+func originAllowed(origin string, allowed []string) bool {
+	for _, o := range allowed {
+		if o == "*" || strings.EqualFold(o, origin) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,146 @@
+package mcp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+
+	"github.com/tldw/tldw-agent/internal/config"
+)
+
+func TestValidBearer(t *testing.T) {
+	tests := []struct {
+		header string
+		token  string
+		want   bool
+	}{
+		{"Bearer secret", "secret", true},
+		{"bearer secret", "secret", true},
+		{"Bearer  secret ", "secret", true},
+		{"Bearer other", "secret", false},
+		{"Bearer secretx", "secret", false},
+		{"Basic secret", "secret", false},
+		{"secret", "secret", false},
+		{"", "secret", false},
+		// An empty token never authenticates
+		{"Bearer ", "", false},
+		{"", "", false},
+	}
+	for _, tt := range tests {
+		if got := validBearer(tt.header, tt.token); got != tt.want {
+			t.Errorf("validBearer(%q, %q) = %v, want %v", tt.header, tt.token, got, tt.want)
+		}
+	}
+}
+
+func TestOriginAllowed(t *testing.T) {
+	tests := []struct {
+		origin  string
+		allowed []string
+		want    bool
+	}{
+		{"chrome-extension://abc", []string{"chrome-extension://abc"}, true},
+		{"Chrome-Extension://ABC", []string{"chrome-extension://abc"}, true},
+		{"https://evil.example", []string{"chrome-extension://abc"}, false},
+		{"https://evil.example", []string{"*"}, true},
+		{"https://evil.example", nil, false},
+	}
+	for _, tt := range tests {
+		if got := originAllowed(tt.origin, tt.allowed); got != tt.want {
+			t.Errorf("originAllowed(%q, %v) = %v, want %v", tt.origin, tt.allowed, got, tt.want)
+		}
+	}
+}
+
+func TestAuthMiddleware(t *testing.T) {
+	cfg := config.Default()
+	cfg.Server.APIKey = "secret"
+	server := NewServer(cfg)
+	defer server.Close()
+	handler := AuthMiddleware(server)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	request := func(header http.Header) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodGet, "/", nil)
+		for name, values := range header {
+			r.Header[name] = values
+		}
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+	upgrade := func(protocols string) http.Header {
+		return http.Header{
+			"Connection":             {"Upgrade"},
+			"Upgrade":                {"websocket"},
+			"Sec-Websocket-Protocol": {protocols},
+		}
+	}
+
+	tests := []struct {
+		name   string
+		header http.Header
+		want   int
+	}{
+		{"bearer header", http.Header{"Authorization": {"Bearer secret"}}, http.StatusOK},
+		{"no token", nil, http.StatusUnauthorized},
+		{"wrong token", http.Header{"Authorization": {"Bearer nope"}}, http.StatusUnauthorized},
+		{"websocket subprotocol", upgrade("mcp, bearer.secret"), http.StatusOK},
+		{"wrong subprotocol token", upgrade("mcp, bearer.nope"), http.StatusUnauthorized},
+		// The subprotocol is only accepted on a WebSocket handshake
+		{"subprotocol without upgrade", http.Header{"Sec-Websocket-Protocol": {"bearer.secret"}}, http.StatusUnauthorized},
+	}
+	for _, tt := range tests {
+		if got := request(tt.header).Code; got != tt.want {
+			t.Errorf("%s: status %d, want %d", tt.name, got, tt.want)
+		}
+	}
+
+	// CORS origins and the key come from the live config
+	origin := http.Header{"Origin": {"chrome-extension://abc"}, "Authorization": {"Bearer secret"}}
+	if got := request(origin).Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Fatalf("unexpected CORS header before the origin is allowed: %q", got)
+	}
+	updated := config.Default()
+	updated.Server.CORSOrigins = []string{"chrome-extension://abc"}
+	updated.Server.APIKey = "rotated"
+	server.SetConfig(updated)
+	if got := request(http.Header{"Authorization": {"Bearer secret"}}).Code; got != http.StatusUnauthorized {
+		t.Fatalf("old key still accepted after reload: status %d", got)
+	}
+	origin["Authorization"] = []string{"Bearer rotated"}
+	w := request(origin)
+	if w.Code != http.StatusOK || w.Header().Get("Access-Control-Allow-Origin") != "chrome-extension://abc" {
+		t.Fatalf("expected the reloaded origin and key to apply, got status %d, headers %v", w.Code, w.Header())
+	}
+}
+
+func TestWebSocketProtocolToken(t *testing.T) {
+	cfg := config.Default()
+	cfg.Server.APIKey = "secret"
+	server := NewServer(cfg)
+	defer server.Close()
+	ts := httptest.NewServer(AuthMiddleware(server)(NewWSHandler(server)))
+	defer ts.Close()
+	url := "ws" + strings.TrimPrefix(ts.URL, "http")
+
+	dialer := websocket.Dialer{Subprotocols: []string{WSProtocol, "bearer.secret"}}
+	conn, _, err := dialer.Dial(url, nil)
+	if err != nil {
+		t.Fatalf("dial with the token subprotocol failed: %v", err)
+	}
+	defer conn.Close()
+	// The token is never echoed back
+	if got := conn.Subprotocol(); got != WSProtocol {
+		t.Fatalf("selected subprotocol %q, want %q", got, WSProtocol)
+	}
+
+	dialer.Subprotocols = []string{WSProtocol, "bearer.nope"}
+	if _, resp, err := dialer.Dial(url, nil); err == nil || resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Fatalf("expected a wrong token to be rejected, got %v", err)
+	}
+}
@@ -0,0 +1,32 @@
+package mcp
+
+import (
+	"context"
+	"strings"
+)
+
+// FilterAvailable returns every tool definition with Available set for the
+// current workspace, so clients can grey out tools that would only fail:
+// git tools other than git.init need a git working tree, and exec.run
+// needs execution to be enabled. RequiresApproval is set as well.
+func (s *Server) FilterAvailable() []ToolDefinition {
+	ts := s.tools.Load()
+	inRepo := ts.gitTools.InsideWorkTree(context.Background())
+	execEnabled := ts.config.Execution.Enabled
+
+	defs := s.ListTools()
+	for i := range defs {
+		defs[i].RequiresApproval, _ = s.approvalRule(defs[i].Name)
+		switch name := defs[i].Name; {
+		case name == "git.init":
+			defs[i].Available = true
+		case strings.HasPrefix(name, "git."):
+			defs[i].Available = inRepo
+		case name == "exec.run":
+			defs[i].Available = execEnabled
+		default:
+			defs[i].Available = true
+		}
+	}
+	return defs
+}
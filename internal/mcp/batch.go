@@ -0,0 +1,66 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+)
+
+// batchWorkers bounds how many read-tier calls of a batch run at once.
+const batchWorkers = 4
+
+// ToolCall is one call in a batch passed to ExecuteToolBatch.
+type ToolCall struct {
+	Name      string          `json:"name"`
+	Arguments json.RawMessage `json:"arguments"`
+}
+
+// ExecuteToolBatch executes calls with no cancellation. See
+// ExecuteToolBatchWithContext.
+func (s *Server) ExecuteToolBatch(calls []ToolCall) ([]*ToolResult, error) {
+	return s.ExecuteToolBatchWithContext(context.Background(), calls)
+}
+
+// ExecuteToolBatchWithContext executes calls and returns their results in
+// the same order. Write and exec calls run one at a time in order, while
+// each run of consecutive read-tier calls between them runs concurrently.
+// A failing call fails only its own slot; calls not started before ctx is
+// done fail with ctx.Err().
+func (s *Server) ExecuteToolBatchWithContext(ctx context.Context, calls []ToolCall) ([]*ToolResult, error) {
+	results := make([]*ToolResult, len(calls))
+	run := func(i int) {
+		result, err := s.ExecuteToolWithContext(ctx, calls[i].Name, calls[i].Arguments)
+		if err != nil {
+			result = &ToolResult{OK: false, Error: err.Error()}
+		}
+		results[i] = result
+	}
+
+	for i := 0; i < len(calls); {
+		if s.ToolTier(calls[i].Name) != "read" {
+			run(i)
+			i++
+			continue
+		}
+
+		end := i
+		for end < len(calls) && s.ToolTier(calls[end].Name) == "read" {
+			end++
+		}
+		var wg sync.WaitGroup
+		slots := make(chan struct{}, batchWorkers)
+		for j := i; j < end; j++ {
+			wg.Add(1)
+			slots <- struct{}{}
+			go func(j int) {
+				defer wg.Done()
+				defer func() { <-slots }()
+				run(j)
+			}(j)
+		}
+		wg.Wait()
+		i = end
+	}
+
+	return results, nil
+}
@@ -0,0 +1,201 @@
+package mcp
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxCacheEntries bounds the number of cached tool results.
+const maxCacheEntries = 256
+
+// uncacheableTools are read-tier tools that change session state or whose
+// result depends on more than their arguments. fs.read, fs.read_multiple
+// and fs.diff record the access in the audit log, and the reads also
+// update the recent files and the line ending fs.write keeps, so they
+// always run.
+var uncacheableTools = map[string]bool{
+	"workspace.chdir":        true,
+	"workspace.audit_log":    true,
+	"workspace.recent_files": true,
+	"exec.status":            true,
+	"fs.read":                true,
+	"fs.read_multiple":       true,
+	"fs.diff":                true,
+}
+
+// resultCache is an LRU cache of read-tier tool results.
+type resultCache struct {
+	mu      sync.Mutex
+	ttl     time.Duration
+	order   *list.List // Front is most recently used
+	entries map[string]*list.Element
+}
+
+type cacheEntry struct {
+	key     string
+	paths   []string // Absolute paths the call touched, nil if unknown
+	result  *ToolResult
+	expires time.Time
+}
+
+// newResultCache returns a cache keeping results for ttl, or nil if ttl is
+// not positive.
+func newResultCache(ttl time.Duration) *resultCache {
+	if ttl <= 0 {
+		return nil
+	}
+	return &resultCache{
+		ttl:     ttl,
+		order:   list.New(),
+		entries: make(map[string]*list.Element),
+	}
+}
+
+func (c *resultCache) get(key string) (*ToolResult, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*cacheEntry)
+	if time.Now().After(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return nil, false
+	}
+	c.order.MoveToFront(elem)
+	return entry.result, true
+}
+
+func (c *resultCache) put(key string, paths []string, result *ToolResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entry := &cacheEntry{key: key, paths: paths, result: result, expires: time.Now().Add(c.ttl)}
+	if elem, ok := c.entries[key]; ok {
+		elem.Value = entry
+		c.order.MoveToFront(elem)
+		return
+	}
+	c.entries[key] = c.order.PushFront(entry)
+
+	for c.order.Len() > maxCacheEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*cacheEntry).key)
+	}
+}
+
+// invalidate drops entries that may be affected by a change to paths.
+// Entries that did not record their paths, such as git.status, are always
+// dropped. A nil paths argument clears the whole cache.
+func (c *resultCache) invalidate(paths []string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for key, elem := range c.entries {
+		entry := elem.Value.(*cacheEntry)
+		if paths == nil || entry.paths == nil || pathsOverlap(entry.paths, paths) {
+			c.order.Remove(elem)
+			delete(c.entries, key)
+		}
+	}
+}
+
+// pathsOverlap reports whether any path in a equals, contains, or is
+// contained by any path in b.
+func pathsOverlap(a, b []string) bool {
+	for _, p := range a {
+		for _, q := range b {
+			if isSubpath(p, q) || isSubpath(q, p) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func isSubpath(parent, path string) bool {
+	rel, err := filepath.Rel(parent, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// cacheResults is the built-in middleware that, with
+// workspace.cache_ttl_ms set, caches the results of read-tier tools for
+// that long. Write-tier calls invalidate cached results for the paths they
+// modify, and exec-tier calls clear the cache. Results served from the
+// cache have Cached set.
+func (s *Server) cacheResults(ctx context.Context, name string, args json.RawMessage, next ToolHandler) (*ToolResult, error) {
+	cache := s.tools.Load().cache
+	if cache == nil {
+		return next(ctx, name, args)
+	}
+	cwd := s.session.AbsCwd()
+
+	switch s.ToolTier(name) {
+	case "read":
+		if uncacheableTools[name] {
+			return next(ctx, name, args)
+		}
+		sum := sha256.Sum256(append([]byte(cwd+"\x00"), args...))
+		key := name + ":" + hex.EncodeToString(sum[:])
+		if result, ok := cache.get(key); ok {
+			cached := *result
+			cached.Cached = true
+			return &cached, nil
+		}
+
+		result, err := next(ctx, name, args)
+		if err == nil && result != nil && result.OK {
+			cache.put(key, argumentPaths(cwd, args), result)
+		}
+		return result, err
+
+	case "write":
+		result, err := next(ctx, name, args)
+		cache.invalidate(argumentPaths(cwd, args))
+		return result, err
+
+	default:
+		result, err := next(ctx, name, args)
+		cache.invalidate(nil)
+		return result, err
+	}
+}
+
+// argumentPaths returns the absolute forms of the "path" and "paths"
+// arguments of a tool call, or nil if it has neither or uses a root
+// label ("@root:<label>/...") that cannot be resolved here.
+func argumentPaths(cwd string, args json.RawMessage) []string {
+	var parsed struct {
+		Path  string   `json:"path"`
+		Paths []string `json:"paths"`
+	}
+	if len(args) == 0 || json.Unmarshal(args, &parsed) != nil {
+		return nil
+	}
+
+	var paths []string
+	for _, p := range append(parsed.Paths, parsed.Path) {
+		if p == "" {
+			continue
+		}
+		if strings.HasPrefix(p, "@root:") {
+			return nil
+		}
+		if !filepath.IsAbs(p) {
+			p = filepath.Join(cwd, p)
+		}
+		paths = append(paths, filepath.Clean(p))
+	}
+	return paths
+}
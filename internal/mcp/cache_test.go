@@ -0,0 +1,59 @@
+package mcp
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tldw/tldw-agent/internal/config"
+)
+
+func TestCacheFromConfig(t *testing.T) {
+	cfg := config.Default()
+	cfg.Workspace.CacheTTLMs = 60000
+	s := NewServer(cfg)
+	root := t.TempDir()
+	if err := s.SetWorkspace(root); err != nil {
+		t.Fatalf("SetWorkspace failed: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("a\r\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	call := func(name, args string) *ToolResult {
+		t.Helper()
+		result, err := s.ExecuteTool(name, json.RawMessage(args))
+		if err != nil || !result.OK {
+			t.Fatalf("%s failed: %+v, %v", name, result, err)
+		}
+		return result
+	}
+
+	call("fs.list", `{"path":"."}`)
+	if !call("fs.list", `{"path":"."}`).Cached {
+		t.Fatal("repeated fs.list was not served from the cache")
+	}
+
+	// fs.read always runs, so it still shows up in the recent files
+	call("fs.read", `{"path":"a.txt"}`)
+	if call("fs.read", `{"path":"a.txt"}`).Cached {
+		t.Fatal("fs.read was served from the cache")
+	}
+	call("fs.read_multiple", `{"paths":["a.txt"]}`)
+	if call("fs.read_multiple", `{"paths":["a.txt"]}`).Cached {
+		t.Fatal("fs.read_multiple was served from the cache")
+	}
+	recent, _ := json.Marshal(call("workspace.recent_files", `{}`).Data)
+	if !strings.Contains(string(recent), "a.txt") {
+		t.Fatalf("fs.read was not recorded in the recent files: %s", recent)
+	}
+
+	// Turning the cache off with a reload takes effect at once
+	off := config.Default()
+	s.SetConfig(off)
+	if call("fs.list", `{"path":"."}`).Cached {
+		t.Fatal("result served from the cache after it was disabled")
+	}
+}
@@ -0,0 +1,1475 @@
+package mcp
+
+import "reflect"
+
+// ToolExample is a sample call of a tool and the result it produces. The
+// results are those of a small Go project checked out at /home/dev/project.
+type ToolExample struct {
+	Description    string                 `json:"description"`
+	Arguments      map[string]interface{} `json:"arguments"`
+	ExpectedResult map[string]interface{} `json:"expected_result"`
+}
+
+// withExamples attaches the examples of each tool to its definition and
+// lists the argument values they use under "examples" in the parameter
+// schema.
+func withExamples(defs []ToolDefinition) []ToolDefinition {
+	for i := range defs {
+		examples := toolExamples[defs[i].Name]
+		defs[i].Examples = examples
+
+		props, _ := defs[i].Parameters["properties"].(map[string]interface{})
+		for name, prop := range props {
+			schema, ok := prop.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			var values []interface{}
+			for _, ex := range examples {
+				if value, ok := ex.Arguments[name]; ok && !containsValue(values, value) {
+					values = append(values, value)
+				}
+			}
+			if len(values) > 0 {
+				schema["examples"] = values
+			}
+		}
+	}
+	return defs
+}
+
+// containsValue reports whether values holds a value equal to v.
+func containsValue(values []interface{}, v interface{}) bool {
+	for _, existing := range values {
+		if reflect.DeepEqual(existing, v) {
+			return true
+		}
+	}
+	return false
+}
+
+// toolExamples holds at least two examples for every tool in ListTools.
+var toolExamples = map[string][]ToolExample{
+	"workspace.list": {
+		{
+			Description: "List the registered workspaces",
+			Arguments:   map[string]interface{}{},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"workspaces": []interface{}{
+						map[string]interface{}{
+							"active": true,
+							"cwd":    ".",
+							"id":     "current",
+							"path":   "/home/dev/project",
+						},
+					},
+				},
+			},
+		},
+		{
+			Description: "Check which named root is active",
+			Arguments:   map[string]interface{}{},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"workspaces": []interface{}{
+						map[string]interface{}{
+							"active": true,
+							"cwd":    ".",
+							"id":     "backend",
+							"path":   "/home/dev/backend",
+						},
+						map[string]interface{}{
+							"active": false,
+							"id":     "frontend",
+							"path":   "/home/dev/frontend",
+						},
+					},
+				},
+			},
+		},
+	},
+	"workspace.pwd": {
+		{
+			Description: "Show the current directory",
+			Arguments:   map[string]interface{}{},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"abs":  "/home/dev/project",
+					"cwd":  ".",
+					"root": "/home/dev/project",
+				},
+			},
+		},
+		{
+			Description: "Confirm the directory after changing into src",
+			Arguments:   map[string]interface{}{},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"abs":  "/home/dev/project/src",
+					"cwd":  "src",
+					"root": "/home/dev/project",
+				},
+			},
+		},
+	},
+	"workspace.chdir": {
+		{
+			Description: "Return to the workspace root",
+			Arguments:   map[string]interface{}{"path": ".."},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"abs": "/home/dev/project", "cwd": "."},
+			},
+		},
+		{
+			Description: "Change into a subdirectory",
+			Arguments:   map[string]interface{}{"path": "src"},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"abs": "/home/dev/project/src", "cwd": "src"},
+			},
+		},
+	},
+	"workspace.tree": {
+		{
+			Description: "Show the top two levels of the workspace",
+			Arguments:   map[string]interface{}{"depth": 2},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"nodes": 8,
+					"path":  ".",
+					"tree": map[string]interface{}{
+						"children": []interface{}{map[string]interface{}{"name": "README.md", "type": "file"}},
+						"name":     "ws",
+						"type":     "directory",
+					},
+					"truncated": false,
+				},
+			},
+		},
+		{
+			Description: "Show the src directory including hidden files",
+			Arguments:   map[string]interface{}{"path": "src", "include_hidden": true},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"nodes": 2,
+					"path":  "src",
+					"tree": map[string]interface{}{
+						"children": []interface{}{
+							map[string]interface{}{"name": "app.go", "type": "file"},
+						},
+						"name": "src",
+						"type": "directory",
+					},
+					"truncated": false,
+				},
+			},
+		},
+	},
+	"workspace.recent_files": {
+		{
+			Description: "List files read or written recently",
+			Arguments:   map[string]interface{}{},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"files": []interface{}{
+						map[string]interface{}{"at": "2026-10-17T02:43:47Z", "op": "write", "path": "main.go"},
+					},
+				},
+			},
+		},
+		{
+			Description: "Check recent files before anything was opened",
+			Arguments:   map[string]interface{}{},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"files": []interface{}{}},
+			},
+		},
+	},
+	"workspace.bookmarks": {
+		{
+			Description: "List path bookmarks",
+			Arguments:   map[string]interface{}{},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"bookmarks": []interface{}{map[string]interface{}{"label": "src", "path": "src"}},
+				},
+			},
+		},
+		{
+			Description: "List bookmarks when none are set",
+			Arguments:   map[string]interface{}{},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"bookmarks": []interface{}{}},
+			},
+		},
+	},
+	"workspace.snapshot": {
+		{
+			Description: "Capture the session state before exploring",
+			Arguments:   map[string]interface{}{},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"cwd": ".", "root": "/home/dev/project"},
+			},
+		},
+		{
+			Description: "Save the working directory and bookmarks to restore later",
+			Arguments:   map[string]interface{}{},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"root":      "/home/dev/project",
+					"cwd":       "src",
+					"bookmarks": map[string]interface{}{"src": "/home/dev/project/src"},
+				},
+			},
+		},
+	},
+	"workspace.disk_usage": {
+		{
+			Description: "Report the size of the workspace",
+			Arguments:   map[string]interface{}{},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"used_bytes":      412,
+					"file_count":      6,
+					"dir_count":       1,
+					"limit_bytes":     0,
+					"total_bytes":     int64(270553174016),
+					"available_bytes": int64(84469657600),
+				},
+			},
+		},
+		{
+			Description: "Check usage against a configured limit",
+			Arguments:   map[string]interface{}{},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"used_bytes":      int64(52428800),
+					"file_count":      1250,
+					"dir_count":       84,
+					"limit_bytes":     int64(1073741824),
+					"total_bytes":     int64(270553174016),
+					"available_bytes": int64(84469657600),
+				},
+			},
+		},
+	},
+	"workspace.audit_log": {
+		{
+			Description: "Check the log at the start of a session",
+			Arguments:   map[string]interface{}{},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"count": 0, "entries": []interface{}{}},
+			},
+		},
+		{
+			Description: "List the files touched this session",
+			Arguments:   map[string]interface{}{},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"count": 1,
+					"entries": []interface{}{
+						map[string]interface{}{
+							"bytes": 73,
+							"op":    "read",
+							"path":  "/home/dev/project/main.go",
+							"time":  "2026-10-17T02:43:31Z",
+						},
+					},
+				},
+			},
+		},
+	},
+	"fs.list": {
+		{
+			Description: "List the workspace root",
+			Arguments:   map[string]interface{}{"path": "."},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"entries": []interface{}{
+						map[string]interface{}{
+							"mtime": "2026-10-17T02:43:18Z",
+							"name":  "README.md",
+							"size":  7,
+							"type":  "file",
+						},
+					},
+					"path":      ".",
+					"truncated": false,
+				},
+			},
+		},
+		{
+			Description: "List src sorted by size, largest first",
+			Arguments: map[string]interface{}{
+				"path":       "src",
+				"sort_by":    "size",
+				"sort_order": "desc",
+			},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"entries": []interface{}{
+						map[string]interface{}{
+							"mtime": "2026-10-17T02:43:18Z",
+							"name":  "app.go",
+							"size":  81,
+							"type":  "file",
+						},
+					},
+					"path":      "src",
+					"truncated": false,
+				},
+			},
+		},
+	},
+	"fs.read": {
+		{
+			Description: "Read a file",
+			Arguments:   map[string]interface{}{"path": "main.go"},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"content":           "package main\n\nimport \"fmt\"\n\nfunc main() {\n\tfmt.Println(\"hello\")\n}\n// TODO",
+					"detected_encoding": "UTF-8",
+					"line_count":        8,
+					"line_ending":       "lf",
+					"may_be_partial":    false,
+					"path":              "main.go",
+					"size":              74,
+					"trailing_newline":  true,
+				},
+			},
+		},
+		{
+			Description: "Read lines 1-3 with line numbers",
+			Arguments: map[string]interface{}{
+				"path":           "src/app.go",
+				"start_line":     1,
+				"end_line":       3,
+				"annotate_lines": true,
+			},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"content":           "1 | package src\n2 | \n3 | // Hello returns a greeting.",
+					"detected_encoding": "UTF-8",
+					"line_count":        4,
+					"line_ending":       "lf",
+					"may_be_partial":    false,
+					"path":              "src/app.go",
+					"size":              81,
+					"trailing_newline":  true,
+				},
+			},
+		},
+	},
+	"fs.read_multiple": {
+		{
+			Description: "Read the first line of several files",
+			Arguments: map[string]interface{}{
+				"paths":      []interface{}{"main.go", "src/app.go"},
+				"start_line": 1,
+				"end_line":   1,
+			},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"files": []interface{}{map[string]interface{}{"content": "package main", "path": "main.go"}},
+				},
+			},
+		},
+		{
+			Description: "Read two files at once",
+			Arguments:   map[string]interface{}{"paths": []interface{}{"go.mod", "README.md"}},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"files": []interface{}{
+						map[string]interface{}{"content": "# Demo", "path": "README.md"},
+						map[string]interface{}{
+							"content": "module example.com/demo\n\ngo 1.22",
+							"path":    "go.mod",
+						},
+					},
+				},
+			},
+		},
+	},
+	"search.grep": {
+		{
+			Description: "Find function definitions",
+			Arguments:   map[string]interface{}{"pattern": "^func "},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"files_searched": 6,
+					"files_skipped":  0,
+					"matches": []interface{}{
+						map[string]interface{}{
+							"column":  1,
+							"line":    5,
+							"path":    "link.go",
+							"preview": "func main() {",
+						},
+					},
+					"total_matches": 1,
+					"truncated":     false,
+				},
+			},
+		},
+		{
+			Description: "Search Go files for a word, ignoring case",
+			Arguments: map[string]interface{}{
+				"pattern":        "GREETING",
+				"glob":           "*.go",
+				"case_sensitive": false,
+			},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"files_searched": 3,
+					"files_skipped":  0,
+					"matches": []interface{}{
+						map[string]interface{}{
+							"column":  4,
+							"line":    3,
+							"path":    "src/app.go",
+							"preview": "// Hello returns a greeting.",
+						},
+					},
+					"total_matches": 1,
+					"truncated":     false,
+				},
+			},
+		},
+	},
+	"search.glob": {
+		{
+			Description: "Find all Go files",
+			Arguments:   map[string]interface{}{"pattern": "*.go"},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"count":     1,
+					"matches":   []interface{}{"link.go"},
+					"pattern":   "*.go",
+					"truncated": false,
+				},
+			},
+		},
+		{
+			Description: "Find Markdown files with sizes",
+			Arguments:   map[string]interface{}{"pattern": "*.md", "include_metadata": true},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"count": 1,
+					"matches": []interface{}{
+						map[string]interface{}{
+							"mtime": "2026-10-17T02:43:18Z",
+							"path":  "README.md",
+							"size":  7,
+						},
+					},
+					"pattern":   "*.md",
+					"truncated": false,
+				},
+			},
+		},
+	},
+	"search.semantic": {
+		{
+			Description: "Search only Go files",
+			Arguments:   map[string]interface{}{"query": "program entry point", "glob": "*.go", "max_results": 3},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"chunks_searched":  3,
+					"chunks_truncated": false,
+					"matches": []interface{}{
+						map[string]interface{}{
+							"path":       "main.go",
+							"start_line": 1,
+							"end_line":   8,
+							"score":      0.79,
+							"preview":    "package main\n\nimport \"fmt\"\n\nfunc main() {",
+						},
+					},
+				},
+			},
+		},
+		{
+			Description: "Find code related to a concept",
+			Arguments:   map[string]interface{}{"query": "print a greeting"},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"chunks_searched":  3,
+					"chunks_truncated": false,
+					"matches": []interface{}{
+						map[string]interface{}{
+							"path":       "src/app.go",
+							"start_line": 1,
+							"end_line":   4,
+							"score":      0.82,
+							"preview":    "package src\n\n// Hello returns a greeting.\nfunc Hello() string { return \"hello\" }",
+						},
+						map[string]interface{}{
+							"path":       "main.go",
+							"start_line": 1,
+							"end_line":   8,
+							"score":      0.64,
+							"preview":    "package main\n\nimport \"fmt\"\n\nfunc main() {",
+						},
+					},
+				},
+			},
+		},
+	},
+	"search.files": {
+		{
+			Description: "Find Go files",
+			Arguments:   map[string]interface{}{"extension": "go"},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"count": 1,
+					"files": []interface{}{
+						map[string]interface{}{"mtime": "2026-10-17T02:43:18Z", "path": "main.go", "size": 74},
+					},
+					"truncated": false,
+				},
+			},
+		},
+		{
+			Description: "Find files whose name contains app",
+			Arguments:   map[string]interface{}{"name_contains": "app"},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"count": 1,
+					"files": []interface{}{
+						map[string]interface{}{
+							"mtime": "2026-10-17T02:43:18Z",
+							"path":  "src/app.go",
+							"size":  81,
+						},
+					},
+					"truncated": false,
+				},
+			},
+		},
+	},
+	"git.status": {
+		{
+			Description: "Show the repository status",
+			Arguments:   map[string]interface{}{},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"branch":    "main",
+					"clean":     false,
+					"conflicts": nil,
+					"modified":  []interface{}{"main.go"},
+					"staged":    nil,
+					"untracked": nil,
+				},
+			},
+		},
+		{
+			Description: "Check that nothing is left uncommitted",
+			Arguments:   map[string]interface{}{},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"branch":    "main",
+					"clean":     true,
+					"conflicts": nil,
+					"modified":  nil,
+					"staged":    nil,
+					"untracked": nil,
+				},
+			},
+		},
+	},
+	"git.diff": {
+		{
+			Description: "List staged files and their change type",
+			Arguments:   map[string]interface{}{"staged": true, "format": "name-status"},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"count": 0, "files": []interface{}{}},
+			},
+		},
+		{
+			Description: "Show unstaged changes",
+			Arguments:   map[string]interface{}{},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"diff":      "diff --git a/main.go b/main.go\nindex d8fa929..f5e16a8 100644\n--- a/main.go\n+++ b/main.go\n@@ -5,3 +5,4 @@ import \"fmt\"\n func main() {\n \tfmt.Println(\"hello\")\n }\n+// TODO\n",
+					"truncated": false,
+				},
+			},
+		},
+	},
+	"git.log": {
+		{
+			Description: "Show the last two commits",
+			Arguments:   map[string]interface{}{"count": 2},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"commits": []interface{}{
+						map[string]interface{}{
+							"author_email": "dev@example.com",
+							"author_name":  "Dev",
+							"hash":         "e0aa8f36e954c1c3c3a55a0ede32abb138e8a692",
+							"message":      "Add greeting",
+							"timestamp":    "1792204998",
+						},
+					},
+					"count": 1,
+				},
+			},
+		},
+		{
+			Description: "Show the history of one file",
+			Arguments:   map[string]interface{}{"path": "main.go", "follow": true},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"commits": []interface{}{
+						map[string]interface{}{
+							"author_email": "dev@example.com",
+							"author_name":  "Dev",
+							"hash":         "9c0f7912cc81697c061af44ffc445d66bad0fea6",
+							"message":      "Initial commit",
+							"timestamp":    "1792204998",
+						},
+					},
+					"count": 1,
+				},
+			},
+		},
+	},
+	"git.branch": {
+		{
+			Description: "List branches",
+			Arguments:   map[string]interface{}{},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"branches": []interface{}{map[string]interface{}{"current": true, "name": "main"}},
+					"current":  "main",
+				},
+			},
+		},
+		{
+			Description: "Check how a feature branch tracks its upstream",
+			Arguments:   map[string]interface{}{},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"branches": []interface{}{
+						map[string]interface{}{
+							"current":  true,
+							"name":     "feature",
+							"tracking": "[ahead 2]",
+							"upstream": "origin/feature",
+						},
+					},
+					"current": "feature",
+				},
+			},
+		},
+	},
+	"git.shortstat": {
+		{
+			Description: "Count changed files and lines",
+			Arguments:   map[string]interface{}{},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"deletions": 0, "files_changed": 1, "insertions": 1},
+			},
+		},
+		{
+			Description: "Count staged changes",
+			Arguments:   map[string]interface{}{"staged": true},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"deletions":     0,
+					"files_changed": 0,
+					"insertions":    0,
+				},
+			},
+		},
+	},
+	"git.ls_files": {
+		{
+			Description: "List tracked files",
+			Arguments:   map[string]interface{}{},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"count": 1, "files": []interface{}{"README.md"}},
+			},
+		},
+		{
+			Description: "List untracked files",
+			Arguments:   map[string]interface{}{"others": true},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"count": 0, "files": []interface{}{}},
+			},
+		},
+	},
+	"git.worktree_list": {
+		{
+			Description: "List worktrees",
+			Arguments:   map[string]interface{}{},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"worktrees": []interface{}{
+						map[string]interface{}{
+							"branch": "main",
+							"head":   "e0aa8f36e954c1c3c3a55a0ede32abb138e8a692",
+							"path":   "/home/dev/project",
+						},
+					},
+				},
+			},
+		},
+		{
+			Description: "Find a worktree checked out at a detached commit",
+			Arguments:   map[string]interface{}{},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"worktrees": []interface{}{
+						map[string]interface{}{
+							"detached": true,
+							"head":     "9c0f7912cc81697c061af44ffc445d66bad0fea6",
+							"path":     "/home/dev/project/review",
+						},
+					},
+				},
+			},
+		},
+	},
+	"git.submodule_status": {
+		{
+			Description: "List submodules",
+			Arguments:   map[string]interface{}{},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"count": 0, "submodules": []interface{}{}},
+			},
+		},
+		{
+			Description: "Check whether submodules are initialized",
+			Arguments:   map[string]interface{}{},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"count": 1,
+					"submodules": []interface{}{
+						map[string]interface{}{
+							"hash":     "3f1c2a9d8e7b6a5c4d3e2f1a0b9c8d7e6f5a4b3c",
+							"modified": false,
+							"path":     "vendor/lib",
+							"state":    "uninitialized",
+						},
+					},
+				},
+			},
+		},
+	},
+	"git.config_get": {
+		{
+			Description: "Read the configured user name",
+			Arguments:   map[string]interface{}{"key": "user.name"},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"key": "user.name", "value": "Dev"},
+			},
+		},
+		{
+			Description: "Read a key that is not set",
+			Arguments:   map[string]interface{}{"key": "core.editor", "scope": "local"},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"key": "core.editor", "value": nil},
+			},
+		},
+	},
+	"git.conflicts": {
+		{
+			Description: "List merge conflict hunks",
+			Arguments:   map[string]interface{}{},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"count": 0, "files": []interface{}{}},
+			},
+		},
+		{
+			Description: "Show the conflicts left by a merge",
+			Arguments:   map[string]interface{}{},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"count": 1,
+					"files": []interface{}{
+						map[string]interface{}{
+							"file": "main.go",
+							"hunks": []interface{}{
+								map[string]interface{}{
+									"ours":   "\tfmt.Println(\"hello\")",
+									"theirs": "\tfmt.Println(\"hi\")",
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	},
+	"fs.diff": {
+		{
+			Description: "Confirm that two files are identical",
+			Arguments:   map[string]interface{}{"path_a": "README.md", "path_b": "README.md"},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"deletions": 0, "diff": "", "insertions": 0, "truncated": false},
+			},
+		},
+		{
+			Description: "Compare two files",
+			Arguments:   map[string]interface{}{"path_a": "main.go", "path_b": "src/app.go"},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"deletions":  7,
+					"diff":       "--- a/main.go\n+++ b/src/app.go\n@@ -1,8 +1,4 @@\n-package main\n+package src\n \n-import \"fmt\"\n-\n-func main() {\n-\tfmt.Println(\"hello\")\n-}\n-// TODO\n+// Hello returns a greeting.\n+func Hello() string { return \"hello\" }\n",
+					"insertions": 3,
+					"truncated":  false,
+				},
+			},
+		},
+	},
+	"fs.stat": {
+		{
+			Description: "Check whether a path is a directory",
+			Arguments:   map[string]interface{}{"path": "src"},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"mode":  "0755",
+					"mtime": "2026-10-17T02:43:18Z",
+					"path":  "src",
+					"size":  0,
+					"type":  "directory",
+				},
+			},
+		},
+		{
+			Description: "Get file metadata",
+			Arguments:   map[string]interface{}{"path": "main.go"},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"mode":  "0644",
+					"mtime": "2026-10-17T02:43:18Z",
+					"path":  "main.go",
+					"size":  74,
+					"type":  "file",
+				},
+			},
+		},
+	},
+	"fs.readlink": {
+		{
+			Description: "Read a symbolic link",
+			Arguments:   map[string]interface{}{"path": "link.go"},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"escapes_workspace": false,
+					"exists":            true,
+					"path":              "link.go",
+					"resolved":          "/home/dev/project/main.go",
+					"target":            "main.go",
+				},
+			},
+		},
+		{
+			Description: "Read a path that is not a link",
+			Arguments:   map[string]interface{}{"path": "main.go"},
+			ExpectedResult: map[string]interface{}{
+				"ok":         false,
+				"error":      "path is not a symbolic link",
+				"error_code": "invalid_argument",
+			},
+		},
+	},
+	"exec.which": {
+		{
+			Description:    "Check for a tool that is not installed",
+			Arguments:      map[string]interface{}{"command": "not-a-real-tool"},
+			ExpectedResult: map[string]interface{}{"ok": true, "data": map[string]interface{}{"found": false}},
+		},
+		{
+			Description: "Locate a toolchain used by an allowlisted command",
+			Arguments:   map[string]interface{}{"command": "cargo"},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"found":        true,
+					"path":         "/home/dev/.cargo/bin/cargo",
+					"version_hint": "cargo 1.79.0 (ffa9cf99a 2024-06-03)",
+				},
+			},
+		},
+	},
+	"exec.env": {
+		{
+			Description: "Read a variable that is not set",
+			Arguments:   map[string]interface{}{"keys": []interface{}{"NOT_SET_ANYWHERE"}},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"env": map[string]interface{}{}},
+			},
+		},
+		{
+			Description: "Read selected environment variables",
+			Arguments:   map[string]interface{}{"keys": []interface{}{"HOME"}},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"env": map[string]interface{}{"HOME": "/home/dev"},
+				},
+			},
+		},
+	},
+	"exec.status": {
+		{
+			Description: "Check on a background command",
+			Arguments:   map[string]interface{}{"handle": "bg_1"},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"elapsed_ms": 1250, "pid": 17131, "running": true},
+			},
+		},
+		{
+			Description: "Poll an unknown handle",
+			Arguments:   map[string]interface{}{"handle": "bg_99"},
+			ExpectedResult: map[string]interface{}{
+				"ok":         false,
+				"error":      "unknown background handle: bg_99",
+				"error_code": "not_found",
+			},
+		},
+	},
+	"workspace.bookmark": {
+		{
+			Description: "Bookmark the current directory",
+			Arguments:   map[string]interface{}{"label": "root"},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"label": "root", "path": "."},
+			},
+		},
+		{
+			Description: "Bookmark a subdirectory",
+			Arguments:   map[string]interface{}{"label": "src", "path": "src"},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"label": "src", "path": "src"},
+			},
+		},
+	},
+	"workspace.restore": {
+		{
+			Description: "Go back to the workspace root",
+			Arguments: map[string]interface{}{
+				"state": map[string]interface{}{"root": "/home/dev/project", "cwd": "."},
+			},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"cwd": ".", "root": "/home/dev/project"},
+			},
+		},
+		{
+			Description: "Restore a snapshot",
+			Arguments: map[string]interface{}{
+				"state": map[string]interface{}{"root": "/home/dev/project", "cwd": "src"},
+			},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"cwd": "src", "root": "/home/dev/project"},
+			},
+		},
+	},
+	"fs.write": {
+		{
+			Description: "Write a file",
+			Arguments:   map[string]interface{}{"path": "notes.txt", "content": "hello"},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"bytes":       6,
+					"line_count":  1,
+					"line_ending": "",
+					"path":        "notes.txt",
+				},
+			},
+		},
+		{
+			Description: "Write a file with Windows line endings",
+			Arguments: map[string]interface{}{
+				"path":        "notes.txt",
+				"content":     "a\nb",
+				"line_ending": "crlf",
+			},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"bytes":       6,
+					"line_count":  2,
+					"line_ending": "crlf",
+					"path":        "notes.txt",
+				},
+			},
+		},
+	},
+	"fs.apply_patch": {
+		{
+			Description: "Apply a patch",
+			Arguments: map[string]interface{}{
+				"patch": "--- a/main.go\n+++ b/main.go\n@@ -3,5 +3,5 @@\n import \"fmt\"\n \n func main() {\n-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"hello, world\")\n }\n",
+			},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"files": []interface{}{"main.go"}, "hunks": 1},
+			},
+		},
+		{
+			Description: "Check that a patch applies",
+			Arguments: map[string]interface{}{
+				"patch":   "--- a/main.go\n+++ b/main.go\n@@ -3,5 +3,5 @@\n import \"fmt\"\n \n func main() {\n-\tfmt.Println(\"hello\")\n+\tfmt.Println(\"hello, world\")\n }\n",
+				"dry_run": true,
+			},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"applicable": true, "hunks": 1},
+			},
+		},
+	},
+	"fs.mkdir": {
+		{
+			Description: "Create a directory",
+			Arguments:   map[string]interface{}{"path": "docs"},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"created": true, "path": "docs"},
+			},
+		},
+		{
+			Description: "Create nested directories",
+			Arguments:   map[string]interface{}{"path": "docs/api"},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"created": true, "path": "docs/api"},
+			},
+		},
+	},
+	"fs.delete": {
+		{
+			Description: "Delete a file",
+			Arguments:   map[string]interface{}{"path": "notes.txt"},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"deleted": true, "path": "notes.txt"},
+			},
+		},
+		{
+			Description: "Delete a directory and its contents",
+			Arguments:   map[string]interface{}{"path": "docs", "recursive": true},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"deleted": true, "path": "docs"},
+			},
+		},
+	},
+	"fs.chmod": {
+		{
+			Description: "Make a script executable",
+			Arguments:   map[string]interface{}{"path": "run.sh", "mode": "+x"},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"new_mode": "0755", "old_mode": "0644", "path": "run.sh"},
+			},
+		},
+		{
+			Description: "Set permissions in octal",
+			Arguments:   map[string]interface{}{"path": "run.sh", "mode": "0644"},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"new_mode": "0644",
+					"old_mode": "0755",
+					"path":     "run.sh",
+				},
+			},
+		},
+	},
+	"fs.touch": {
+		{
+			Description: "Create an empty file",
+			Arguments:   map[string]interface{}{"path": "empty.txt"},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"created": true,
+					"mtime":   "2026-10-17T02:43:47Z",
+					"path":    "empty.txt",
+				},
+			},
+		},
+		{
+			Description: "Set a file's modification time",
+			Arguments: map[string]interface{}{
+				"path":  "empty.txt",
+				"mtime": "2024-01-02T15:04:05Z",
+			},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"created": false,
+					"mtime":   "2024-01-02T15:04:05Z",
+					"path":    "empty.txt",
+				},
+			},
+		},
+	},
+	"fs.zip": {
+		{
+			Description: "Archive a directory",
+			Arguments:   map[string]interface{}{"paths": []interface{}{"src"}, "dest": "src.zip"},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"dest": "src.zip", "files": 1, "size": 342},
+			},
+		},
+		{
+			Description: "Archive several files",
+			Arguments: map[string]interface{}{
+				"paths": []interface{}{"main.go", "go.mod"},
+				"dest":  "bundle.zip",
+			},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"dest": "bundle.zip", "files": 2, "size": 396},
+			},
+		},
+	},
+	"fs.unzip": {
+		{
+			Description: "Extract an archive",
+			Arguments:   map[string]interface{}{"src": "src.zip", "dest": "out"},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"count":     1,
+					"dest":      "out",
+					"extracted": []interface{}{"out/src/app.go"},
+				},
+			},
+		},
+		{
+			Description: "Extract into a new directory",
+			Arguments:   map[string]interface{}{"src": "bundle.zip", "dest": "bundle"},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"count":     2,
+					"dest":      "bundle",
+					"extracted": []interface{}{"bundle/main.go", "bundle/go.mod"},
+				},
+			},
+		},
+	},
+	"fs.link": {
+		{
+			Description: "Create a symbolic link",
+			Arguments:   map[string]interface{}{"target": "main.go", "link": "entry.go"},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"link": "entry.go", "target": "main.go"},
+			},
+		},
+		{
+			Description: "Link to a file in a subdirectory",
+			Arguments:   map[string]interface{}{"target": "src/app.go", "link": "app.go"},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"link": "app.go", "target": "src/app.go"},
+			},
+		},
+	},
+	"git.add": {
+		{
+			Description: "Stage a file",
+			Arguments:   map[string]interface{}{"paths": []interface{}{"main.go"}},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"staged": []interface{}{"main.go"}},
+			},
+		},
+		{
+			Description: "Stage several files",
+			Arguments: map[string]interface{}{
+				"paths": []interface{}{"main.go", "src/app.go"},
+			},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"staged": []interface{}{"main.go", "src/app.go"},
+				},
+			},
+		},
+	},
+	"git.commit": {
+		{
+			Description: "Commit staged changes",
+			Arguments:   map[string]interface{}{"message": "Update greeting"},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"hash":    "cdcabcc10165eb5c02a1a51ef12b8f793d75c59b",
+					"message": "Update greeting",
+				},
+			},
+		},
+		{
+			Description: "Amend the last commit",
+			Arguments: map[string]interface{}{
+				"message": "Update greeting and docs",
+				"amend":   true,
+			},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"hash":          "92c707f63c3103702783b742281dc358f91e80c9",
+					"message":       "Update greeting and docs",
+					"original_hash": "cdcabcc10165eb5c02a1a51ef12b8f793d75c59b",
+				},
+			},
+		},
+	},
+	"git.init": {
+		{
+			Description: "Initialize a repository",
+			Arguments:   map[string]interface{}{"path": "newrepo"},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"message": "Initialized empty Git repository in /home/dev/project/newrepo/.git/",
+					"path":    "newrepo",
+				},
+			},
+		},
+		{
+			Description: "Initialize with a main branch and an empty commit",
+			Arguments: map[string]interface{}{
+				"path":           "other",
+				"initial_branch": "main",
+				"initial_commit": true,
+			},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"hash":    "3513547d1a58471aab1bf9421cd5ca31ae8fa4a3",
+					"message": "Initialized empty Git repository in /home/dev/project/other/.git/",
+					"path":    "other",
+				},
+			},
+		},
+	},
+	"git.apply": {
+		{
+			Description: "Apply a patch and stage it",
+			Arguments: map[string]interface{}{
+				"patch": "--- a/README.md\n+++ b/README.md\n@@ -1 +1,3 @@\n # Demo\n+\n+A small demo program.\n",
+				"index": true,
+			},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"files": []interface{}{"README.md"}},
+			},
+		},
+		{
+			Description: "Check that a patch applies with git apply",
+			Arguments: map[string]interface{}{
+				"patch": "--- a/README.md\n+++ b/README.md\n@@ -1 +1,3 @@\n # Demo\n+\n+A small demo program.\n",
+				"check": true,
+			},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"applicable": true,
+					"files":      []interface{}{"README.md"},
+				},
+			},
+		},
+	},
+	"git.submodule": {
+		{
+			Description: "Update submodules",
+			Arguments:   map[string]interface{}{"action": "update"},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"action": "update", "output": ""},
+			},
+		},
+		{
+			Description: "Run a command in every submodule",
+			Arguments:   map[string]interface{}{"action": "foreach", "command": "git status"},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"action": "foreach", "output": ""},
+			},
+		},
+	},
+	"git.revert": {
+		{
+			Description: "Revert without committing",
+			Arguments:   map[string]interface{}{"ref": "HEAD", "no_commit": true},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"files": []interface{}{"main.go"}, "ref": "HEAD"},
+			},
+		},
+		{
+			Description: "Revert a commit",
+			Arguments:   map[string]interface{}{"ref": "HEAD"},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"hash": "0243f5ca7542b46112668641b5b6c979075169e7",
+					"ref":  "HEAD",
+				},
+			},
+		},
+	},
+	"git.worktree": {
+		{
+			Description: "Remove a worktree",
+			Arguments:   map[string]interface{}{"action": "remove", "path": "review"},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"path": "/home/dev/project/review", "removed": true},
+			},
+		},
+		{
+			Description: "Check out the previous commit in a new worktree",
+			Arguments: map[string]interface{}{
+				"action": "add",
+				"path":   "review",
+				"branch": "HEAD~1",
+			},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"path": "/home/dev/project/review",
+					"root": "review",
+				},
+			},
+		},
+	},
+	"git.config": {
+		{
+			Description: "Read a config value",
+			Arguments:   map[string]interface{}{"action": "get", "key": "user.name"},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"key": "user.name", "value": "Dev"},
+			},
+		},
+		{
+			Description: "Set a local config value",
+			Arguments: map[string]interface{}{
+				"action": "set",
+				"key":    "user.name",
+				"value":  "Dev",
+			},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"key": "user.name", "value": "Dev"},
+			},
+		},
+	},
+	"exec.run": {
+		{
+			Description: "Start tests in the background",
+			Arguments:   map[string]interface{}{"command_id": "go_test", "background": true},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"handle": "bg_1", "pid": 17131},
+			},
+		},
+		{
+			Description: "Run the test suite",
+			Arguments:   map[string]interface{}{"command_id": "go_test"},
+			ExpectedResult: map[string]interface{}{
+				"ok": true,
+				"data": map[string]interface{}{
+					"exit_code":   0,
+					"stdout":      "ok  \texample.com/demo\t0.004s\n",
+					"stderr":      "",
+					"duration_ms": 812,
+					"truncated":   false,
+				},
+			},
+		},
+	},
+	"exec.stop": {
+		{
+			Description: "Stop a background command",
+			Arguments:   map[string]interface{}{"handle": "bg_1"},
+			ExpectedResult: map[string]interface{}{
+				"ok":   true,
+				"data": map[string]interface{}{"exit_code": -1, "handle": "bg_1"},
+			},
+		},
+		{
+			Description: "Stop a handle that was already stopped",
+			Arguments:   map[string]interface{}{"handle": "bg_1"},
+			ExpectedResult: map[string]interface{}{
+				"ok":         false,
+				"error":      "unknown background handle: bg_1",
+				"error_code": "not_found",
+			},
+		},
+	},
+}
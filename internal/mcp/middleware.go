@@ -0,0 +1,48 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// ToolHandler executes a tool call.
+type ToolHandler func(ctx context.Context, name string, args json.RawMessage) (*ToolResult, error)
+
+// ToolMiddleware intercepts a tool call. It may inspect or rewrite the
+// call, short-circuit it by returning without calling next, or post-process
+// the result returned by next. ctx is the context the call was made with.
+type ToolMiddleware func(ctx context.Context, name string, args json.RawMessage, next ToolHandler) (*ToolResult, error)
+
+// Use appends a middleware to the chain run by ExecuteTool. Middleware run
+// in the order they were added, the first one outermost. Calls already
+// running keep the chain they started with.
+func (s *Server) Use(middleware ToolMiddleware) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.middleware = append(s.middleware, middleware)
+}
+
+// chain wraps handler with the registered middleware.
+func (s *Server) chain(handler ToolHandler) ToolHandler {
+	s.mu.RLock()
+	middleware := s.middleware
+	s.mu.RUnlock()
+
+	for i := len(middleware) - 1; i >= 0; i-- {
+		mw, next := middleware[i], handler
+		handler = func(ctx context.Context, name string, args json.RawMessage) (*ToolResult, error) {
+			return mw(ctx, name, args, next)
+		}
+	}
+	return handler
+}
+
+// ToolTier returns the tier of a registered tool, or "" if name is unknown.
+func (s *Server) ToolTier(name string) string {
+	for _, tool := range toolDefinitions() {
+		if tool.Name == name {
+			return tool.Tier
+		}
+	}
+	return ""
+}
@@ -0,0 +1,75 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"sync"
+	"time"
+
+	"github.com/tldw/tldw-agent/internal/config"
+)
+
+// tokenBucket allows up to rpm calls per minute, refilling continuously.
+type tokenBucket struct {
+	mu       sync.Mutex
+	capacity float64
+	tokens   float64
+	perToken time.Duration
+	last     time.Time
+}
+
+func newTokenBucket(rpm int) *tokenBucket {
+	return &tokenBucket{
+		capacity: float64(rpm),
+		tokens:   float64(rpm),
+		perToken: time.Minute / time.Duration(rpm),
+		last:     time.Now(),
+	}
+}
+
+// take consumes a token. If none is available it reports how long until
+// the next one is.
+func (b *tokenBucket) take() (bool, time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	now := time.Now()
+	b.tokens = min(b.capacity, b.tokens+float64(now.Sub(b.last))/float64(b.perToken))
+	b.last = now
+
+	if b.tokens >= 1 {
+		b.tokens--
+		return true, 0
+	}
+	return false, time.Duration((1 - b.tokens) * float64(b.perToken))
+}
+
+// newLimiters builds a token bucket for each tier with a configured limit.
+func newLimiters(cfg *config.Config) map[string]*tokenBucket {
+	limiters := make(map[string]*tokenBucket)
+	for tier, rpm := range map[string]int{
+		"read":  cfg.Security.ReadRPM,
+		"write": cfg.Security.WriteRPM,
+		"exec":  cfg.Security.ExecRPM,
+	} {
+		if rpm > 0 {
+			limiters[tier] = newTokenBucket(rpm)
+		}
+	}
+	return limiters
+}
+
+// rateLimit is the built-in middleware enforcing the per-tier
+// security.*_rpm limits.
+func (s *Server) rateLimit(ctx context.Context, name string, args json.RawMessage, next ToolHandler) (*ToolResult, error) {
+	if limiter := s.tools.Load().limiters[s.ToolTier(name)]; limiter != nil {
+		if ok, retryAfter := limiter.take(); !ok {
+			return &ToolResult{
+				OK:           false,
+				Error:        "rate limit exceeded",
+				RetryAfterMs: retryAfter.Milliseconds() + 1,
+			}, nil
+		}
+	}
+	return next(ctx, name, args)
+}
@@ -0,0 +1,49 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"regexp"
+
+	"github.com/tldw/tldw-agent/internal/mcp/tools"
+)
+
+// secretPattern matches a secret-looking key followed by a long token-like
+// value, as in "API_KEY=..." in a .env file or "token": "..." in JSON. The
+// separator class includes backslashes so quotes escaped inside JSON
+// strings still match. The first group keeps the key.
+var secretPattern = regexp.MustCompile(`(?i)((?:api[_-]?key|token|password|secret)["\\\s:=]+)[A-Za-z0-9+/]{16,}`)
+
+// redactSecrets is the built-in middleware that, with
+// security.redact_secrets enabled, replaces secret-looking values in tool
+// results with "<redacted>" so .env contents or credentials in git config
+// don't reach the LLM. Output passed on while exec.run runs is redacted
+// the same way.
+func (s *Server) redactSecrets(ctx context.Context, name string, args json.RawMessage, next ToolHandler) (*ToolResult, error) {
+	if s.Config().Security.RedactSecrets {
+		if fn := tools.OutputFromContext(ctx); fn != nil {
+			ctx = WithOutput(ctx, func(stream string, chunk []byte) {
+				fn(stream, secretPattern.ReplaceAll(chunk, []byte("${1}<redacted>")))
+			})
+		}
+	}
+	result, err := next(ctx, name, args)
+	if err != nil || result == nil || result.Data == nil || !s.Config().Security.RedactSecrets {
+		return result, err
+	}
+
+	data, marshalErr := json.Marshal(result.Data)
+	if marshalErr != nil || !secretPattern.Match(data) {
+		return result, err
+	}
+	redacted := secretPattern.ReplaceAll(data, []byte("${1}<redacted>"))
+
+	// Copy so a result held by the cache keeps its original data
+	out := *result
+	if unmarshalErr := json.Unmarshal(redacted, &out.Data); unmarshalErr != nil {
+		// A redacted bare JSON value leaves invalid JSON; fall back to
+		// the text rather than risk leaking the secret
+		out.Data = string(redacted)
+	}
+	return &out, err
+}
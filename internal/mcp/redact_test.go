@@ -0,0 +1,110 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/tldw/tldw-agent/internal/config"
+)
+
+const testSecret = "abcdEFGH1234ijklMNOP5678"
+
+func TestSecretPattern(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"API_KEY=" + testSecret, "API_KEY=<redacted>"},
+		{"api-key: " + testSecret, "api-key: <redacted>"},
+		{`{"token": "` + testSecret + `"}`, `{"token": "<redacted>"}`},
+		// Quotes escaped inside a JSON string
+		{`"DB_PASSWORD=\"` + testSecret + `\""`, `"DB_PASSWORD=\"<redacted>\""`},
+		{"client_secret " + testSecret, "client_secret <redacted>"},
+		// Short values and other keys are left alone
+		{"PASSWORD=hunter2", "PASSWORD=hunter2"},
+		{"USERNAME=" + testSecret, "USERNAME=" + testSecret},
+	}
+	for _, tt := range tests {
+		if got := secretPattern.ReplaceAllString(tt.in, "${1}<redacted>"); got != tt.want {
+			t.Errorf("redact(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestRedactSecrets(t *testing.T) {
+	cfg := config.Default()
+	cfg.Security.RedactSecrets = true
+	s := NewServer(cfg)
+	defer s.Close()
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, "settings.txt"), []byte("API_KEY="+testSecret+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := s.SetWorkspace(root); err != nil {
+		t.Fatalf("SetWorkspace failed: %v", err)
+	}
+
+	read := func() string {
+		t.Helper()
+		result, err := s.ExecuteTool("fs.read", json.RawMessage(`{"path":"settings.txt"}`))
+		if err != nil || !result.OK {
+			t.Fatalf("fs.read failed: %+v, %v", result, err)
+		}
+		data, _ := json.Marshal(result.Data)
+		var decoded struct {
+			Content string `json:"content"`
+		}
+		if err := json.Unmarshal(data, &decoded); err != nil {
+			t.Fatal(err)
+		}
+		return decoded.Content
+	}
+
+	if got := read(); got != "API_KEY=<redacted>" {
+		t.Fatalf("expected the key to be redacted, got %s", got)
+	}
+
+	off := config.Default()
+	off.Security.RedactSecrets = false
+	s.SetConfig(off)
+	if got := read(); !strings.Contains(got, testSecret) {
+		t.Fatalf("expected no redaction once disabled, got %s", got)
+	}
+}
+
+func TestRedactSecretsInLiveOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses echo")
+	}
+	cfg := config.Default()
+	cfg.Security.RedactSecrets = true
+	cfg.Execution.CustomCommands = []config.CustomCommand{{ID: "leak", Template: "echo TOKEN=" + testSecret}}
+	s := NewServer(cfg)
+	defer s.Close()
+	if err := s.SetWorkspace(t.TempDir()); err != nil {
+		t.Fatalf("SetWorkspace failed: %v", err)
+	}
+
+	var mu sync.Mutex
+	var streamed strings.Builder
+	ctx := WithOutput(context.Background(), func(stream string, chunk []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		streamed.Write(chunk)
+	})
+	result, err := s.ExecuteToolWithContext(ctx, "exec.run", json.RawMessage(`{"command_id":"leak"}`))
+	if err != nil || !result.OK {
+		t.Fatalf("exec.run failed: %+v, %v", result, err)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if got := streamed.String(); got != "TOKEN=<redacted>\n" {
+		t.Fatalf("streamed output %q, want the token redacted", got)
+	}
+}
@@ -0,0 +1,121 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+)
+
+// protocolVersion is the MCP protocol revision spoken by the network
+// transports.
+const protocolVersion = "2024-11-05"
+
+// JSON-RPC error codes used by the network transports.
+const (
+	rpcParseError     = -32700
+	rpcInvalidRequest = -32600
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+)
+
+// rpcRequest is a JSON-RPC request or notification from an MCP client.
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcResponse is a JSON-RPC response to an MCP client.
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func newRPCError(id json.RawMessage, code int, message string) *rpcResponse {
+	if len(id) == 0 {
+		id = json.RawMessage("null")
+	}
+	return &rpcResponse{JSONRPC: "2.0", ID: id, Error: &rpcError{Code: code, Message: message}}
+}
+
+// handleRPC handles one MCP request, returning nil for notifications.
+func (s *Server) handleRPC(ctx context.Context, req *rpcRequest) *rpcResponse {
+	if req.JSONRPC != "2.0" || req.Method == "" {
+		return newRPCError(req.ID, rpcInvalidRequest, "invalid request")
+	}
+	if len(req.ID) == 0 || string(req.ID) == "null" {
+		// notifications/initialized and friends need no reply
+		return nil
+	}
+
+	var result interface{}
+	switch req.Method {
+	case "initialize":
+		result = map[string]interface{}{
+			"protocolVersion": protocolVersion,
+			"capabilities": map[string]interface{}{
+				"tools": map[string]interface{}{},
+			},
+			"serverInfo": map[string]string{
+				"name":    "tldw-agent",
+				"version": "0.1.0",
+			},
+		}
+	case "ping":
+		result = map[string]interface{}{}
+	case "tools/list":
+		defs := s.ListTools()
+		list := make([]map[string]interface{}, 0, len(defs))
+		for _, def := range defs {
+			list = append(list, map[string]interface{}{
+				"name":        def.Name,
+				"description": def.Description,
+				"inputSchema": def.Parameters,
+			})
+		}
+		result = map[string]interface{}{"tools": list}
+	case "tools/call":
+		var params struct {
+			Name      string          `json:"name"`
+			Arguments json.RawMessage `json:"arguments"`
+		}
+		if err := json.Unmarshal(req.Params, &params); err != nil || params.Name == "" {
+			return newRPCError(req.ID, rpcInvalidParams, "invalid tools/call params")
+		}
+		toolResult, err := s.ExecuteToolWithContext(ctx, params.Name, params.Arguments)
+		if err != nil {
+			return newRPCError(req.ID, rpcInternalError, err.Error())
+		}
+		result = toolCallResult(toolResult)
+	default:
+		return newRPCError(req.ID, rpcMethodNotFound, "method not found: "+req.Method)
+	}
+
+	return &rpcResponse{JSONRPC: "2.0", ID: req.ID, Result: result}
+}
+
+// toolCallResult converts a tool result to an MCP tools/call result: the
+// data, or the error, as a single text content block.
+func toolCallResult(result *ToolResult) map[string]interface{} {
+	text := result.Error
+	if result.OK {
+		data, err := json.Marshal(result.Data)
+		if err != nil {
+			text = err.Error()
+		} else {
+			text = string(data)
+		}
+	}
+	return map[string]interface{}{
+		"content": []map[string]string{{"type": "text", "text": text}},
+		"isError": !result.OK,
+	}
+}
@@ -2,8 +2,12 @@
 package mcp
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/tldw/tldw-agent/internal/config"
 	"github.com/tldw/tldw-agent/internal/mcp/tools"
@@ -17,37 +21,82 @@ type ToolDefinition struct {
 	Description string                 `json:"description"`
 	Parameters  map[string]interface{} `json:"parameters"`
 	Tier        string                 `json:"tier"` // "read", "write", "exec"
+
+	// Available reports whether the tool can be used in the current
+	// workspace. It is only set by FilterAvailable.
+	Available bool `json:"available"`
+
+	// RequiresApproval reports whether clients should ask the user before
+	// calling the tool. It is only set by FilterAvailable.
+	RequiresApproval bool `json:"requires_approval"`
+
+	// Examples shows sample calls of the tool and their results.
+	Examples []ToolExample `json:"examples,omitempty"`
 }
 
 // ToolResult is an alias for types.ToolResult for convenience.
 type ToolResult = types.ToolResult
 
+// OutputFunc is an alias for tools.OutputFunc.
+type OutputFunc = tools.OutputFunc
+
+// WithOutput returns a context under which exec.run passes its output to
+// fn while the command runs, before the result is returned.
+func WithOutput(ctx context.Context, fn OutputFunc) context.Context {
+	return tools.WithOutput(ctx, fn)
+}
+
 // Server manages MCP tools and executes tool calls.
 type Server struct {
+	// tools is replaced as a whole by SetConfig. A tool call loads it once
+	// and runs without holding a lock, so a reload never waits for
+	// long-running calls and calls never see a half-applied config.
+	tools   atomic.Pointer[toolset]
+	session *workspace.Session
+
+	mu         sync.RWMutex // Guards middleware, serializes SetConfig
+	middleware []ToolMiddleware
+	sse        sseSessions // Clients connected over HTTP+SSE
+}
+
+// toolset is a config and the tool instances built from it.
+type toolset struct {
 	config      *config.Config
-	session     *workspace.Session
 	fsTools     *tools.FSTools
 	gitTools    *tools.GitTools
 	searchTools *tools.SearchTools
 	execTools   *tools.ExecTools
+	limiters    map[string]*tokenBucket // Per-tier rate limiters
+	cache       *resultCache            // Tool result cache, nil if disabled
 }
 
-// NewServer creates a new MCP server.
-func NewServer(cfg *config.Config) *Server {
-	session := workspace.NewSession(cfg)
-
-	return &Server{
+func newToolset(cfg *config.Config, session *workspace.Session) *toolset {
+	return &toolset{
 		config:      cfg,
-		session:     session,
 		fsTools:     tools.NewFSTools(cfg, session),
 		gitTools:    tools.NewGitTools(cfg, session),
 		searchTools: tools.NewSearchTools(cfg, session),
 		execTools:   tools.NewExecTools(cfg, session),
+		limiters:    newLimiters(cfg),
+		cache:       newResultCache(time.Duration(cfg.Workspace.CacheTTLMs) * time.Millisecond),
 	}
 }
 
+// NewServer creates a new MCP server.
+func NewServer(cfg *config.Config) *Server {
+	s := &Server{session: workspace.NewSession(cfg)}
+	s.tools.Store(newToolset(cfg, s.session))
+	s.middleware = []ToolMiddleware{s.requireApproval, s.rateLimit, s.redactSecrets, s.cacheResults}
+	return s
+}
+
 // ListTools returns all available tool definitions.
 func (s *Server) ListTools() []ToolDefinition {
+	return withExamples(toolDefinitions())
+}
+
+// toolDefinitions returns the tool definitions without their examples.
+func toolDefinitions() []ToolDefinition {
 	return []ToolDefinition{
 		// Tier 0: Navigation & Read (auto-approve)
 		{
@@ -77,12 +126,83 @@ func (s *Server) ListTools() []ToolDefinition {
 				"properties": map[string]interface{}{
 					"path": map[string]interface{}{
 						"type":        "string",
-						"description": "Path to change to (relative to workspace root)",
+						"description": "Path to change to (relative to workspace root), or @<bookmark>",
 					},
 				},
 				"required": []string{"path"},
 			},
 		},
+		{
+			Name:        "workspace.tree",
+			Description: "Show a nested directory tree",
+			Tier:        "read",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "Directory path (default: current directory)",
+					},
+					"depth": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum depth to descend (default: 3)",
+					},
+					"include_hidden": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Include hidden files (default: false)",
+					},
+					"max_nodes": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum number of nodes to return (default: 500)",
+					},
+				},
+			},
+		},
+		{
+			Name:        "workspace.recent_files",
+			Description: "List recently read or written files, most recent first",
+			Tier:        "read",
+			Parameters: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		{
+			Name:        "workspace.bookmarks",
+			Description: "List registered path bookmarks",
+			Tier:        "read",
+			Parameters: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		{
+			Name:        "workspace.snapshot",
+			Description: "Capture the session state (root, working directory, roots, and bookmarks) for workspace.restore",
+			Tier:        "read",
+			Parameters: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		{
+			Name:        "workspace.disk_usage",
+			Description: "Report the total size of the workspace",
+			Tier:        "read",
+			Parameters: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		{
+			Name:        "workspace.audit_log",
+			Description: "List files read, written, or deleted in this session",
+			Tier:        "read",
+			Parameters: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
 		{
 			Name:        "fs.list",
 			Description: "List directory contents",
@@ -109,6 +229,22 @@ func (s *Server) ListTools() []ToolDefinition {
 						"description": "Maximum entries to return",
 						"default":     1000,
 					},
+					"sort_by": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"name", "size", "mtime"},
+						"description": "Sort key: name (A-Z), size (largest first), or mtime (newest first)",
+						"default":     "name",
+					},
+					"sort_order": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"asc", "desc"},
+						"description": "Override the sort direction",
+					},
+					"include_git_status": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Mark each entry as tracked, untracked, or ignored by git. Ignored entries are listed even with respect_gitignore on",
+						"default":     false,
+					},
 				},
 			},
 		},
@@ -131,10 +267,56 @@ func (s *Server) ListTools() []ToolDefinition {
 						"type":        "integer",
 						"description": "Ending line number (inclusive)",
 					},
+					"annotate_lines": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Prefix each line with its line number",
+						"default":     false,
+					},
+					"force_encoding": map[string]interface{}{
+						"type":        "string",
+						"description": "Encoding to decode the file from (e.g., ISO-8859-1, Shift_JIS) instead of detecting it",
+					},
 				},
 				"required": []string{"path"},
 			},
 		},
+		{
+			Name:        "fs.read_multiple",
+			Description: "Read several files in one call",
+			Tier:        "read",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"paths": map[string]interface{}{
+						"type":        "array",
+						"description": "Files to read: paths, or objects with path, start_line, and end_line",
+						"items": map[string]interface{}{
+							"oneOf": []interface{}{
+								map[string]interface{}{"type": "string"},
+								map[string]interface{}{
+									"type": "object",
+									"properties": map[string]interface{}{
+										"path":       map[string]interface{}{"type": "string"},
+										"start_line": map[string]interface{}{"type": "integer"},
+										"end_line":   map[string]interface{}{"type": "integer"},
+									},
+									"required": []string{"path"},
+								},
+							},
+						},
+					},
+					"start_line": map[string]interface{}{
+						"type":        "integer",
+						"description": "Default start line (1-indexed)",
+					},
+					"end_line": map[string]interface{}{
+						"type":        "integer",
+						"description": "Default end line (inclusive)",
+					},
+				},
+				"required": []string{"paths"},
+			},
+		},
 		{
 			Name:        "search.grep",
 			Description: "Search file contents using regex pattern",
@@ -160,6 +342,15 @@ func (s *Server) ListTools() []ToolDefinition {
 						"description": "Case sensitive search",
 						"default":     true,
 					},
+					"multiline": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Let matches span lines; . also matches newlines",
+						"default":     false,
+					},
+					"max_file_size_bytes": map[string]interface{}{
+						"type":        "integer",
+						"description": "Skip files larger than this (default: workspace.max_file_size_bytes)",
+					},
 					"max_results": map[string]interface{}{
 						"type":        "integer",
 						"description": "Maximum results to return",
@@ -189,10 +380,93 @@ func (s *Server) ListTools() []ToolDefinition {
 						"description": "Maximum results to return",
 						"default":     100,
 					},
+					"include_metadata": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Return {path, size, mtime} objects instead of paths",
+						"default":     false,
+					},
+					"exclude": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Glob patterns of relative paths to skip; pass [] to disable the defaults",
+						"default":     []string{"node_modules/**", ".git/**", "__pycache__/**", "vendor/**"},
+					},
 				},
 				"required": []string{"pattern"},
 			},
 		},
+		{
+			Name:        "search.semantic",
+			Description: "Find code related to a natural language query using embeddings from the LLM endpoint",
+			Tier:        "read",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"query": map[string]interface{}{
+						"type":        "string",
+						"description": "What to look for, in natural language",
+					},
+					"paths": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Paths to search in (default: workspace root)",
+					},
+					"glob": map[string]interface{}{
+						"type":        "string",
+						"description": "File glob pattern (e.g., *.go)",
+					},
+					"max_results": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum chunks to return",
+						"default":     10,
+					},
+				},
+				"required": []string{"query"},
+			},
+		},
+		{
+			Name:        "search.files",
+			Description: "Find files by name, extension, size, or modification time",
+			Tier:        "read",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "Base path to search from",
+					},
+					"name_contains": map[string]interface{}{
+						"type":        "string",
+						"description": "Case-insensitive substring of the file name",
+					},
+					"extension": map[string]interface{}{
+						"type":        "string",
+						"description": "File extension (e.g., .go)",
+					},
+					"min_size_bytes": map[string]interface{}{
+						"type":        "integer",
+						"description": "Minimum file size",
+					},
+					"max_size_bytes": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum file size",
+					},
+					"modified_after": map[string]interface{}{
+						"type":        "string",
+						"description": "Only files modified after this RFC 3339 time",
+					},
+					"modified_before": map[string]interface{}{
+						"type":        "string",
+						"description": "Only files modified before this RFC 3339 time",
+					},
+					"max_results": map[string]interface{}{
+						"type":        "integer",
+						"description": "Maximum results to return",
+						"default":     100,
+					},
+				},
+			},
+		},
 		{
 			Name:        "git.status",
 			Description: "Get git repository status",
@@ -219,6 +493,12 @@ func (s *Server) ListTools() []ToolDefinition {
 						"description": "Show staged changes",
 						"default":     false,
 					},
+					"format": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"unified", "stat", "name-only", "name-status"},
+						"description": "Output format",
+						"default":     "unified",
+					},
 				},
 			},
 		},
@@ -238,6 +518,16 @@ func (s *Server) ListTools() []ToolDefinition {
 						"type":        "string",
 						"description": "Filter by path",
 					},
+					"follow": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Follow path through renames",
+						"default":     false,
+					},
+					"graph": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Also return a text graph of the history",
+						"default":     false,
+					},
 				},
 			},
 		},
@@ -250,105 +540,589 @@ func (s *Server) ListTools() []ToolDefinition {
 				"properties": map[string]interface{}{},
 			},
 		},
-		// Tier 1: Editing (requires approval)
 		{
-			Name:        "fs.write",
-			Description: "Write content to a file",
-			Tier:        "write",
+			Name:        "git.shortstat",
+			Description: "Summarize changes as file and line counts",
+			Tier:        "read",
 			Parameters: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"path": map[string]interface{}{
-						"type":        "string",
-						"description": "File path to write",
+					"staged": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Summarize staged changes",
 					},
-					"content": map[string]interface{}{
+					"ref": map[string]interface{}{
 						"type":        "string",
-						"description": "Content to write",
+						"description": "Commit to compare against",
 					},
 				},
-				"required": []string{"path", "content"},
 			},
 		},
 		{
-			Name:        "fs.apply_patch",
-			Description: "Apply a unified diff patch",
-			Tier:        "write",
+			Name:        "git.ls_files",
+			Description: "List files tracked by git",
+			Tier:        "read",
 			Parameters: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"patch": map[string]interface{}{
+					"others": map[string]interface{}{
+						"type":        "boolean",
+						"description": "List untracked files instead (excluding ignored files)",
+					},
+					"modified": map[string]interface{}{
+						"type":        "boolean",
+						"description": "List only modified files",
+					},
+					"deleted": map[string]interface{}{
+						"type":        "boolean",
+						"description": "List only deleted files",
+					},
+					"pathspec": map[string]interface{}{
 						"type":        "string",
-						"description": "Unified diff to apply",
+						"description": "Limit to files matching this pathspec",
 					},
 				},
-				"required": []string{"patch"},
 			},
 		},
 		{
-			Name:        "fs.mkdir",
-			Description: "Create a directory",
-			Tier:        "write",
+			Name:        "git.worktree_list",
+			Description: "List linked git worktrees",
+			Tier:        "read",
+			Parameters: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		{
+			Name:        "git.submodule_status",
+			Description: "List submodules with their commit and state",
+			Tier:        "read",
+			Parameters: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		{
+			Name:        "git.config_get",
+			Description: "Read a git configuration value",
+			Tier:        "read",
 			Parameters: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"path": map[string]interface{}{
+					"key": map[string]interface{}{
 						"type":        "string",
-						"description": "Directory path to create",
+						"description": "Configuration key (e.g., user.email)",
+					},
+					"scope": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"local", "global"},
+						"description": "Configuration file to read (default: all)",
 					},
 				},
-				"required": []string{"path"},
+				"required": []string{"key"},
 			},
 		},
 		{
-			Name:        "fs.delete",
-			Description: "Delete a file or directory",
-			Tier:        "write",
+			Name:        "git.conflicts",
+			Description: "List files with merge conflicts and show their conflict hunks",
+			Tier:        "read",
+			Parameters: map[string]interface{}{
+				"type":       "object",
+				"properties": map[string]interface{}{},
+			},
+		},
+		{
+			Name:        "fs.diff",
+			Description: "Show a unified diff between two files",
+			Tier:        "read",
 			Parameters: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"path": map[string]interface{}{
+					"path_a": map[string]interface{}{
 						"type":        "string",
-						"description": "Path to delete",
+						"description": "Original file",
 					},
-					"recursive": map[string]interface{}{
-						"type":        "boolean",
-						"description": "Recursively delete directories",
-						"default":     false,
+					"path_b": map[string]interface{}{
+						"type":        "string",
+						"description": "Changed file",
 					},
 				},
-				"required": []string{"path"},
+				"required": []string{"path_a", "path_b"},
 			},
 		},
 		{
-			Name:        "git.add",
-			Description: "Stage files for commit",
-			Tier:        "write",
+			Name:        "fs.stat",
+			Description: "Get the type, size, modification time, and permissions of a path",
+			Tier:        "read",
 			Parameters: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
-					"paths": map[string]interface{}{
-						"type":        "array",
-						"items":       map[string]interface{}{"type": "string"},
-						"description": "Paths to stage",
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to inspect",
 					},
 				},
-				"required": []string{"paths"},
+				"required": []string{"path"},
 			},
 		},
 		{
-			Name:        "git.commit",
-			Description: "Create a git commit",
-			Tier:        "write",
+			Name:        "fs.readlink",
+			Description: "Read the target of a symbolic link",
+			Tier:        "read",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path of the link",
+					},
+				},
+				"required": []string{"path"},
+			},
+		},
+		{
+			Name:        "exec.which",
+			Description: "Find an executable on PATH, and report its version if an allowlisted command uses it",
+			Tier:        "read",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"command": map[string]interface{}{
+						"type":        "string",
+						"description": "Executable name (e.g., python3, node, cargo)",
+					},
+				},
+				"required": []string{"command"},
+			},
+		},
+		{
+			Name:        "exec.env",
+			Description: "Read the agent's environment variables, with secrets redacted",
+			Tier:        "read",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"keys": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Variables to return (default: all)",
+					},
+				},
+			},
+		},
+		{
+			Name:        "exec.status",
+			Description: "Check whether a background command is running, or get its exit code and output; a finished command is reported once",
+			Tier:        "read",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"handle": map[string]interface{}{
+						"type":        "string",
+						"description": "Handle returned by exec.run with background set",
+					},
+				},
+				"required": []string{"handle"},
+			},
+		},
+		// Tier 1: Editing (requires approval)
+		{
+			Name:        "workspace.bookmark",
+			Description: "Bookmark a path under a short label for use as @<label> in workspace.chdir",
+			Tier:        "write",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"label": map[string]interface{}{
+						"type":        "string",
+						"description": "Bookmark label",
+					},
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to bookmark (default: current directory)",
+					},
+				},
+				"required": []string{"label"},
+			},
+		},
+		{
+			Name:        "workspace.restore",
+			Description: "Restore session state captured by workspace.snapshot (only roots already open in the session)",
+			Tier:        "write",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"state": map[string]interface{}{
+						"type":        "object",
+						"description": "State returned by workspace.snapshot",
+					},
+				},
+				"required": []string{"state"},
+			},
+		},
+		{
+			Name:        "fs.write",
+			Description: "Write content to a file",
+			Tier:        "write",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "File path to write",
+					},
+					"content": map[string]interface{}{
+						"type":        "string",
+						"description": "Content to write",
+					},
+					"line_ending": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"lf", "crlf"},
+						"description": "Line endings to write (default: those seen when the file was last read)",
+					},
+					"trailing_newline": map[string]interface{}{
+						"type":        "boolean",
+						"description": "End the file with a newline if the content does not (default: true)",
+						"default":     true,
+					},
+				},
+				"required": []string{"path", "content"},
+			},
+		},
+		{
+			Name:        "fs.apply_patch",
+			Description: "Apply a unified diff patch",
+			Tier:        "write",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"patch": map[string]interface{}{
+						"type":        "string",
+						"description": "Unified diff to apply",
+					},
+					"dry_run": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Check that every hunk applies without changing any file",
+						"default":     false,
+					},
+					"reverse": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Undo the patch, like patch -R",
+						"default":     false,
+					},
+				},
+				"required": []string{"patch"},
+			},
+		},
+		{
+			Name:        "fs.mkdir",
+			Description: "Create a directory",
+			Tier:        "write",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "Directory path to create",
+					},
+				},
+				"required": []string{"path"},
+			},
+		},
+		{
+			Name:        "fs.delete",
+			Description: "Delete a file or directory",
+			Tier:        "write",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to delete",
+					},
+					"recursive": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Recursively delete directories",
+						"default":     false,
+					},
+				},
+				"required": []string{"path"},
+			},
+		},
+		{
+			Name:        "fs.chmod",
+			Description: "Change file permissions",
+			Tier:        "write",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to change",
+					},
+					"mode": map[string]interface{}{
+						"type":        "string",
+						"description": "Octal (e.g., 0755) or symbolic (e.g., u+x, go-w) mode; setuid, setgid, and sticky bits are not allowed",
+					},
+				},
+				"required": []string{"path", "mode"},
+			},
+		},
+		{
+			Name:        "fs.touch",
+			Description: "Create an empty file or update its modification time",
+			Tier:        "write",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "Path to the file",
+					},
+					"mtime": map[string]interface{}{
+						"type":        "string",
+						"description": "Modification time in RFC 3339 format (default: now)",
+					},
+				},
+				"required": []string{"path"},
+			},
+		},
+		{
+			Name:        "fs.zip",
+			Description: "Create a zip archive from files and directories",
+			Tier:        "write",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"paths": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Files and directories to add (directories are added recursively)",
+					},
+					"dest": map[string]interface{}{
+						"type":        "string",
+						"description": "Path of the archive to create",
+					},
+				},
+				"required": []string{"paths", "dest"},
+			},
+		},
+		{
+			Name:        "fs.unzip",
+			Description: "Extract a zip archive into a directory",
+			Tier:        "write",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"src": map[string]interface{}{
+						"type":        "string",
+						"description": "Path of the archive to extract",
+					},
+					"dest": map[string]interface{}{
+						"type":        "string",
+						"description": "Directory to extract into",
+					},
+				},
+				"required": []string{"src", "dest"},
+			},
+		},
+		{
+			Name:        "fs.link",
+			Description: "Create a symbolic link",
+			Tier:        "write",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"target": map[string]interface{}{
+						"type":        "string",
+						"description": "Path the link points to (relative paths are relative to the link's directory)",
+					},
+					"link": map[string]interface{}{
+						"type":        "string",
+						"description": "Path of the link to create",
+					},
+				},
+				"required": []string{"target", "link"},
+			},
+		},
+		{
+			Name:        "git.add",
+			Description: "Stage files for commit",
+			Tier:        "write",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"paths": map[string]interface{}{
+						"type":        "array",
+						"items":       map[string]interface{}{"type": "string"},
+						"description": "Paths to stage",
+					},
+				},
+				"required": []string{"paths"},
+			},
+		},
+		{
+			Name:        "git.commit",
+			Description: "Create a git commit",
+			Tier:        "write",
 			Parameters: map[string]interface{}{
 				"type": "object",
 				"properties": map[string]interface{}{
 					"message": map[string]interface{}{
 						"type":        "string",
-						"description": "Commit message",
+						"description": "Commit message (optional when amending)",
+					},
+					"amend": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Replace the last commit instead of creating a new one",
+						"default":     false,
+					},
+				},
+			},
+		},
+		{
+			Name:        "git.init",
+			Description: "Initialize a git repository",
+			Tier:        "write",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "Directory to initialize (default: workspace root)",
+					},
+					"initial_branch": map[string]interface{}{
+						"type":        "string",
+						"description": "Name of the initial branch",
+					},
+					"initial_commit": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Create an empty \"Initial commit\"",
+						"default":     false,
+					},
+				},
+			},
+		},
+		{
+			Name:        "git.apply",
+			Description: "Apply a patch with git apply (whitespace errors are fixed)",
+			Tier:        "write",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"patch": map[string]interface{}{
+						"type":        "string",
+						"description": "Patch in git diff format",
+					},
+					"check": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Only check whether the patch applies",
+						"default":     false,
+					},
+					"index": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Also apply the patch to the index",
+						"default":     false,
+					},
+				},
+				"required": []string{"patch"},
+			},
+		},
+		{
+			Name:        "git.submodule",
+			Description: "Initialize and update submodules, or run a command in each",
+			Tier:        "exec",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"action": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"update", "foreach", "status"},
+						"description": "Operation to perform",
+					},
+					"command": map[string]interface{}{
+						"type":        "string",
+						"description": "Command to run in each submodule (foreach only; no shell metacharacters)",
 					},
 				},
-				"required": []string{"message"},
+				"required": []string{"action"},
+			},
+		},
+		{
+			Name:        "git.revert",
+			Description: "Create a commit that undoes an earlier commit",
+			Tier:        "write",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"ref": map[string]interface{}{
+						"type":        "string",
+						"description": "Commit to revert (hash or HEAD~N)",
+					},
+					"no_commit": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Stage the inverse changes without committing",
+						"default":     false,
+					},
+					"mainline": map[string]interface{}{
+						"type":        "integer",
+						"description": "Parent number to revert to; required for merge commits",
+					},
+				},
+				"required": []string{"ref"},
+			},
+		},
+		{
+			Name:        "git.worktree",
+			Description: "Add or remove a linked git worktree; added worktrees are registered as workspace roots",
+			Tier:        "write",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"action": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"add", "remove", "list"},
+						"description": "Operation to perform",
+					},
+					"path": map[string]interface{}{
+						"type":        "string",
+						"description": "Worktree directory (inside the workspace)",
+					},
+					"branch": map[string]interface{}{
+						"type":        "string",
+						"description": "Branch or commit to check out (add only)",
+					},
+				},
+				"required": []string{"action"},
+			},
+		},
+		{
+			Name:        "git.config",
+			Description: "Get or set a git configuration value; only safe keys such as user.* and pull.rebase can be set, and only in the repository config",
+			Tier:        "write",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"action": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"get", "set"},
+						"description": "Operation to perform",
+					},
+					"key": map[string]interface{}{
+						"type":        "string",
+						"description": "Configuration key (e.g., user.email)",
+					},
+					"value": map[string]interface{}{
+						"type":        "string",
+						"description": "Value to set (set only)",
+					},
+					"scope": map[string]interface{}{
+						"type":        "string",
+						"enum":        []string{"local", "global"},
+						"description": "Configuration file to use; global is read-only",
+					},
+				},
+				"required": []string{"action", "key"},
 			},
 		},
 		// Tier 2: Execution (requires explicit approval)
@@ -376,15 +1150,78 @@ func (s *Server) ListTools() []ToolDefinition {
 						"type":        "integer",
 						"description": "Timeout in milliseconds",
 					},
+					"background": map[string]interface{}{
+						"type":        "boolean",
+						"description": "Start the command and return its pid and handle without waiting; it is killed after execution.background_timeout_ms (default: false)",
+					},
 				},
 				"required": []string{"command_id"},
 			},
 		},
+		{
+			Name:        "exec.stop",
+			Description: "Kill a command started in the background",
+			Tier:        "exec",
+			Parameters: map[string]interface{}{
+				"type": "object",
+				"properties": map[string]interface{}{
+					"handle": map[string]interface{}{
+						"type":        "string",
+						"description": "Handle returned by exec.run with background set",
+					},
+				},
+				"required": []string{"handle"},
+			},
+		},
 	}
 }
 
-// ExecuteTool executes a tool with the given arguments.
+// Config returns the configuration currently in effect.
+func (s *Server) Config() *config.Config {
+	return s.tools.Load().config
+}
+
+// SetConfig atomically replaces the server configuration. Tool instances
+// are rebuilt from the new config; the workspace session is kept so the
+// current root and working directory survive a reload. Rate limiters
+// start afresh. In-flight tool calls finish with the previous config, and
+// the reload does not wait for them.
+func (s *Server) SetConfig(cfg *config.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ts := newToolset(cfg, s.session)
+	ts.execTools.AdoptBackground(s.tools.Load().execTools)
+	s.session.SetConfig(cfg)
+	s.tools.Store(ts)
+}
+
+// ExecuteToolWithContext executes a tool, running it through the
+// middleware chain registered with Use. Commands started by exec and git
+// tools are killed when ctx is cancelled, and the file writing and search
+// tools stop at their next step. The call always waits for the tool to
+// stop, so nothing keeps running after it returns; if ctx is done by then
+// it returns ctx.Err(). Changes a tool made before it noticed the
+// cancellation are kept.
+func (s *Server) ExecuteToolWithContext(ctx context.Context, toolName string, arguments json.RawMessage) (*ToolResult, error) {
+	ts := s.tools.Load()
+	handler := s.chain(func(ctx context.Context, name string, args json.RawMessage) (*ToolResult, error) {
+		return s.dispatch(ctx, ts, name, args)
+	})
+	result, err := handler(ctx, toolName, arguments)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	return result, err
+}
+
+// ExecuteTool executes a tool with no cancellation.
 func (s *Server) ExecuteTool(toolName string, arguments json.RawMessage) (*ToolResult, error) {
+	return s.ExecuteToolWithContext(context.Background(), toolName, arguments)
+}
+
+// dispatch routes a tool call to its handler in ts.
+func (s *Server) dispatch(ctx context.Context, ts *toolset, toolName string, arguments json.RawMessage) (*ToolResult, error) {
 	// Parse arguments into a map
 	var args map[string]interface{}
 	if len(arguments) > 0 {
@@ -402,51 +1239,152 @@ func (s *Server) ExecuteTool(toolName string, arguments json.RawMessage) (*ToolR
 		return s.session.Pwd()
 	case "workspace.chdir":
 		return s.session.Chdir(args)
+	case "workspace.tree":
+		return ts.fsTools.Tree(args)
+	case "workspace.recent_files":
+		return s.session.RecentFiles()
+	case "workspace.bookmarks":
+		return s.session.Bookmarks()
+	case "workspace.bookmark":
+		return s.session.Bookmark(args)
+	case "workspace.snapshot":
+		return s.session.SnapshotTool()
+	case "workspace.restore":
+		return s.session.RestoreTool(args)
+	case "workspace.disk_usage":
+		return ts.fsTools.DiskUsage(args)
+	case "workspace.audit_log":
+		return ts.fsTools.AuditLog(args)
 
 	// Filesystem tools
 	case "fs.list":
-		return s.fsTools.List(args)
+		return ts.fsTools.List(args)
 	case "fs.read":
-		return s.fsTools.Read(args)
+		return ts.fsTools.Read(args)
+	case "fs.read_multiple":
+		return ts.fsTools.ReadMultiple(args)
 	case "fs.write":
-		return s.fsTools.Write(args)
+		return ts.fsTools.Write(ctx, args)
 	case "fs.apply_patch":
-		return s.fsTools.ApplyPatch(args)
+		return ts.fsTools.ApplyPatch(ctx, args)
 	case "fs.mkdir":
-		return s.fsTools.Mkdir(args)
+		return ts.fsTools.Mkdir(args)
 	case "fs.delete":
-		return s.fsTools.Delete(args)
+		return ts.fsTools.Delete(args)
+	case "fs.chmod":
+		return ts.fsTools.Chmod(args)
+	case "fs.touch":
+		return ts.fsTools.Touch(args)
+	case "fs.link":
+		return ts.fsTools.Link(args)
+	case "fs.zip":
+		return ts.fsTools.Zip(ctx, args)
+	case "fs.unzip":
+		return ts.fsTools.Unzip(ctx, args)
+	case "fs.diff":
+		return ts.fsTools.DiffFiles(args)
+	case "fs.readlink":
+		return ts.fsTools.Readlink(args)
+	case "fs.stat":
+		return ts.fsTools.Stat(args)
 
 	// Search tools
 	case "search.grep":
-		return s.searchTools.Grep(args)
+		return ts.searchTools.Grep(ctx, args)
 	case "search.glob":
-		return s.searchTools.Glob(args)
+		return ts.searchTools.Glob(ctx, args)
+	case "search.files":
+		return ts.searchTools.FindFiles(ctx, args)
+	case "search.semantic":
+		return ts.searchTools.Semantic(ctx, args)
 
 	// Git tools
 	case "git.status":
-		return s.gitTools.Status(args)
+		return ts.gitTools.Status(ctx, args)
 	case "git.diff":
-		return s.gitTools.Diff(args)
+		return ts.gitTools.Diff(ctx, args)
 	case "git.log":
-		return s.gitTools.Log(args)
+		return ts.gitTools.Log(ctx, args)
 	case "git.branch":
-		return s.gitTools.Branch(args)
+		return ts.gitTools.Branch(ctx, args)
+	case "git.shortstat":
+		return ts.gitTools.Shortstat(ctx, args)
+	case "git.ls_files":
+		return ts.gitTools.LsFiles(ctx, args)
+	case "git.worktree_list":
+		return ts.gitTools.Worktree(ctx, withAction(args, "list"))
+	case "git.worktree":
+		return ts.gitTools.Worktree(ctx, args)
+	case "git.submodule_status":
+		return ts.gitTools.Submodule(ctx, withAction(args, "status"))
+	case "git.submodule":
+		return ts.gitTools.Submodule(ctx, args)
+	case "git.config_get":
+		return ts.gitTools.GitConfig(ctx, withAction(args, "get"))
+	case "git.config":
+		return ts.gitTools.GitConfig(ctx, args)
+	case "git.conflicts":
+		return ts.gitTools.Conflicts(ctx, args)
 	case "git.add":
-		return s.gitTools.Add(args)
+		return ts.gitTools.Add(ctx, args)
 	case "git.commit":
-		return s.gitTools.Commit(args)
+		return ts.gitTools.Commit(ctx, args)
+	case "git.init":
+		return ts.gitTools.Init(ctx, args)
+	case "git.apply":
+		return ts.gitTools.Apply(ctx, args)
+	case "git.revert":
+		return ts.gitTools.Revert(ctx, args)
 
 	// Exec tools
 	case "exec.run":
-		return s.execTools.Run(args)
+		return ts.execTools.Run(ctx, args)
+	case "exec.which":
+		return ts.execTools.Which(ctx, args)
+	case "exec.env":
+		return ts.execTools.Env(args)
+	case "exec.status":
+		return ts.execTools.Status(args)
+	case "exec.stop":
+		return ts.execTools.Stop(args)
 
 	default:
 		return nil, fmt.Errorf("unknown tool: %s", toolName)
 	}
 }
 
+// Close kills any commands still running in the background.
+func (s *Server) Close() {
+	s.tools.Load().execTools.StopAll()
+}
+
 // SetWorkspace sets the current workspace root.
 func (s *Server) SetWorkspace(root string) error {
 	return s.session.SetRoot(root)
 }
+
+// WorkspaceRoot returns the absolute path of the current workspace root.
+func (s *Server) WorkspaceRoot() string {
+	return s.session.Root()
+}
+
+// ResetWorkspace returns to workspace.default_root, or to no workspace if
+// none is configured.
+func (s *Server) ResetWorkspace() error {
+	if root := s.Config().Workspace.DefaultRoot; root != "" {
+		return s.session.SetRoot(root)
+	}
+	s.session.ClearRoot()
+	return nil
+}
+
+// withAction returns a copy of args with "action" set, for read-tier tools
+// that expose a single action of a multi-action tool.
+func withAction(args map[string]interface{}, action string) map[string]interface{} {
+	out := make(map[string]interface{}, len(args)+1)
+	for k, v := range args {
+		out[k] = v
+	}
+	out["action"] = action
+	return out
+}
@@ -0,0 +1,98 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/tldw/tldw-agent/internal/config"
+)
+
+func TestSetConfigDoesNotWaitForCalls(t *testing.T) {
+	s := NewServer(config.Default())
+	if err := s.SetWorkspace(t.TempDir()); err != nil {
+		t.Fatalf("SetWorkspace failed: %v", err)
+	}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	s.Use(func(ctx context.Context, name string, args json.RawMessage, next ToolHandler) (*ToolResult, error) {
+		close(started)
+		<-release
+		return next(ctx, name, args)
+	})
+
+	called := make(chan struct{})
+	go func() {
+		s.ExecuteTool("workspace.pwd", nil)
+		close(called)
+	}()
+	<-started
+
+	reloaded := make(chan struct{})
+	cfg := config.Default()
+	go func() {
+		s.SetConfig(cfg)
+		close(reloaded)
+	}()
+	select {
+	case <-reloaded:
+	case <-time.After(2 * time.Second):
+		t.Fatal("SetConfig waited for a running tool call")
+	}
+	if s.Config() != cfg {
+		t.Fatal("SetConfig did not apply the new config")
+	}
+
+	close(release)
+	select {
+	case <-called:
+	case <-time.After(2 * time.Second):
+		t.Fatal("tool call did not finish")
+	}
+}
+
+func TestCancelledCallDoesNotWrite(t *testing.T) {
+	s := NewServer(config.Default())
+	root := t.TempDir()
+	if err := s.SetWorkspace(root); err != nil {
+		t.Fatalf("SetWorkspace failed: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	for _, call := range []struct {
+		name string
+		args string
+	}{
+		{"fs.write", `{"path":"a.txt","content":"x"}`},
+		{"fs.zip", `{"paths":["."],"dest":"a.zip"}`},
+		{"search.grep", `{"pattern":"x"}`},
+		{"search.glob", `{"pattern":"*"}`},
+	} {
+		if _, err := s.ExecuteToolWithContext(ctx, call.name, json.RawMessage(call.args)); !errors.Is(err, context.Canceled) {
+			t.Errorf("%s: err = %v, want context.Canceled", call.name, err)
+		}
+	}
+	for _, name := range []string{"a.txt", "a.zip"} {
+		if _, err := os.Stat(filepath.Join(root, name)); !os.IsNotExist(err) {
+			t.Errorf("%s was written after the call was cancelled", name)
+		}
+	}
+}
+
+func TestListToolsExamples(t *testing.T) {
+	defs := NewServer(config.Default()).ListTools()
+	for _, def := range defs {
+		if len(def.Examples) < 2 {
+			t.Errorf("%s has %d examples, want at least 2", def.Name, len(def.Examples))
+		}
+	}
+	if len(toolExamples) != len(defs) {
+		t.Errorf("toolExamples has %d tools, ListTools has %d", len(toolExamples), len(defs))
+	}
+}
@@ -0,0 +1,146 @@
+package mcp
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sync"
+)
+
+// sseSession is one client connected to the SSE endpoint. Responses to
+// the messages it posts are delivered over its event stream.
+type sseSession struct {
+	ctx      context.Context
+	messages chan []byte
+}
+
+// sseSessions tracks the clients connected over HTTP+SSE.
+type sseSessions struct {
+	mu       sync.Mutex
+	sessions map[string]*sseSession
+}
+
+func (s *sseSessions) add(id string, session *sseSession) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.sessions == nil {
+		s.sessions = make(map[string]*sseSession)
+	}
+	s.sessions[id] = session
+}
+
+func (s *sseSessions) remove(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, id)
+}
+
+func (s *sseSessions) get(id string) *sseSession {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.sessions[id]
+}
+
+// ServeHTTP serves the MCP HTTP+SSE transport. A client opens the event
+// stream with GET /sse, which first sends an "endpoint" event naming the
+// URL to POST its JSON-RPC messages to (/message?sessionId=...). Each
+// response then arrives on the stream as a "message" event.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/sse":
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.serveSSE(w, r)
+	case "/message":
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		s.serveMessage(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func (s *Server) serveSSE(w http.ResponseWriter, r *http.Request) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	idBytes := make([]byte, 16)
+	if _, err := rand.Read(idBytes); err != nil {
+		http.Error(w, "failed to create session", http.StatusInternalServerError)
+		return
+	}
+	id := hex.EncodeToString(idBytes)
+
+	session := &sseSession{ctx: r.Context(), messages: make(chan []byte, 16)}
+	s.sse.add(id, session)
+	defer s.sse.remove(id)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "event: endpoint\ndata: /message?sessionId=%s\n\n", id)
+	flusher.Flush()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case msg := <-session.messages:
+			if _, err := fmt.Fprintf(w, "event: message\ndata: %s\n\n", msg); err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+func (s *Server) serveMessage(w http.ResponseWriter, r *http.Request) {
+	session := s.sse.get(r.URL.Query().Get("sessionId"))
+	if session == nil {
+		http.Error(w, "unknown session", http.StatusNotFound)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.Config().Server.MaxMessageBytes))
+	if err != nil {
+		http.Error(w, "failed to read message", http.StatusRequestEntityTooLarge)
+		return
+	}
+	var req rpcRequest
+	if err := json.Unmarshal(body, &req); err != nil {
+		http.Error(w, "invalid JSON-RPC message", http.StatusBadRequest)
+		return
+	}
+
+	// Tool calls can be slow, so reply over the stream once done; they
+	// are abandoned if the client disconnects
+	w.WriteHeader(http.StatusAccepted)
+	go func() {
+		resp := s.handleRPC(session.ctx, &req)
+		if resp == nil {
+			return
+		}
+		data, err := json.Marshal(resp)
+		if err != nil {
+			log.Printf("mcp: failed to marshal response: %v", err)
+			return
+		}
+		select {
+		case session.messages <- data:
+		case <-session.ctx.Done():
+		}
+	}()
+}
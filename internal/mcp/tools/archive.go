@@ -0,0 +1,475 @@
+package tools
+
+import (
+	"archive/zip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tldw/tldw-agent/internal/types"
+)
+
+// Zip creates a zip archive at dest containing the given files and
+// directories. Directories are added recursively; entries that fail path
+// validation (such as blocked paths) are left out. The archive is not
+// written if ctx is done before it is complete.
+func (t *FSTools) Zip(ctx context.Context, args map[string]interface{}) (*types.ToolResult, error) {
+	var paths []string
+	if p, ok := args["paths"].([]interface{}); ok {
+		for _, v := range p {
+			if s, ok := v.(string); ok && s != "" {
+				paths = append(paths, s)
+			}
+		}
+	}
+	dest, _ := args["dest"].(string)
+	if len(paths) == 0 || dest == "" {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     "paths and dest are required",
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+
+	absDest, err := t.session.ResolvePath(dest)
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     err.Error(),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+
+	// Validate every input before creating the archive
+	absPaths := make([]string, 0, len(paths))
+	for _, p := range paths {
+		absPath, err := t.session.ResolvePath(p)
+		if err != nil {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     err.Error(),
+				ErrorCode: types.ErrorCodeFor(err),
+			}, nil
+		}
+		if _, err := os.Stat(absPath); err != nil {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     fmt.Sprintf("failed to stat %s: %v", p, err),
+				ErrorCode: types.ErrorCodeFor(err),
+			}, nil
+		}
+		absPaths = append(absPaths, absPath)
+	}
+
+	// Write to a temp file so a failure never leaves a partial archive
+	tmpFile, err := os.CreateTemp(filepath.Dir(absDest), ".tldw-zip-*")
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("failed to create archive: %v", err),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+
+	// The temporary archive already counts towards the disk usage, and
+	// replacing dest frees its current size
+	if err := t.session.CheckDiskQuota(-regularFileSize(absDest)); err != nil {
+		return diskQuotaResult(err), nil
+	}
+	tmpPath := tmpFile.Name()
+	defer os.Remove(tmpPath)
+
+	root := t.session.Root()
+	zw := zip.NewWriter(tmpFile)
+	files := 0
+	for _, absPath := range absPaths {
+		err = filepath.WalkDir(absPath, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+			if path == absDest {
+				return nil
+			}
+			if _, err := t.session.ResolvePath(path); err != nil {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			// Only regular files and directories are archived
+			if !d.IsDir() && !d.Type().IsRegular() {
+				return nil
+			}
+			rel, err := filepath.Rel(root, path)
+			if err != nil {
+				return err
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			header, err := zip.FileInfoHeader(info)
+			if err != nil {
+				return err
+			}
+			header.Name = filepath.ToSlash(rel)
+			if d.IsDir() {
+				header.Name += "/"
+				_, err = zw.CreateHeader(header)
+				return err
+			}
+			header.Method = zip.Deflate
+
+			w, err := zw.CreateHeader(header)
+			if err != nil {
+				return err
+			}
+			f, err := os.Open(path)
+			if err != nil {
+				return err
+			}
+			defer f.Close()
+			if _, err := io.Copy(w, f); err != nil {
+				return err
+			}
+			files++
+			return nil
+		})
+		if err != nil {
+			break
+		}
+	}
+	if err == nil {
+		err = zw.Close()
+	}
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("failed to create archive: %v", err),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+
+	if err := os.Rename(tmpPath, absDest); err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("failed to write archive: %v", err),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+
+	info, err := os.Stat(absDest)
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("failed to stat archive: %v", err),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+	t.session.RecordAccess("write", absDest, info.Size())
+
+	return &types.ToolResult{
+		OK: true,
+		Data: map[string]interface{}{
+			"dest":  dest,
+			"size":  info.Size(),
+			"files": files,
+		},
+	}, nil
+}
+
+// Unzip extracts the zip archive at src into the directory dest. Entries
+// that would land outside dest or the workspace ("zip slip") fail the whole
+// extraction before anything is written. Extraction stops between entries
+// once ctx is done; entries already extracted are kept.
+func (t *FSTools) Unzip(ctx context.Context, args map[string]interface{}) (*types.ToolResult, error) {
+	src, _ := args["src"].(string)
+	dest, _ := args["dest"].(string)
+	if src == "" || dest == "" {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     "src and dest are required",
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+
+	absSrc, err := t.session.ResolvePath(src)
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     err.Error(),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+	absDest, err := t.session.ResolvePath(dest)
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     err.Error(),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+
+	zr, err := zip.OpenReader(absSrc)
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("failed to open archive: %v", err),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+	defer zr.Close()
+
+	// Check every entry before extracting any of them
+	root := t.session.Root()
+	maxSize := t.config.Workspace.MaxFileSizeBytes
+	quota, err := t.session.RemainingDiskQuota()
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     err.Error(),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+	var declared, freed int64
+	targets := make([]string, len(zr.File))
+	for i, f := range zr.File {
+		target := filepath.Join(absDest, filepath.FromSlash(f.Name))
+		if !strings.HasPrefix(target, absDest+string(filepath.Separator)) || !t.session.Contains(target) {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     fmt.Sprintf("archive entry escapes destination: %s", f.Name),
+				ErrorCode: types.ErrPermission,
+			}, nil
+		}
+		if f.Mode()&os.ModeSymlink != 0 {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     fmt.Sprintf("archive entry is a symbolic link: %s", f.Name),
+				ErrorCode: types.ErrPermission,
+			}, nil
+		}
+		// The lexical check above can't see a symlinked directory on the
+		// way, since the path below it doesn't exist yet
+		dir := target
+		if !f.FileInfo().IsDir() {
+			dir = filepath.Dir(target)
+		}
+		if _, err := t.missingDirs(dir); err != nil {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     fmt.Sprintf("archive entry escapes destination: %s", f.Name),
+				ErrorCode: types.ErrPermission,
+			}, nil
+		}
+		if t.config.IsPathBlocked(target) {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     fmt.Sprintf("archive entry is blocked by policy: %s", f.Name),
+				ErrorCode: types.ErrPermission,
+			}, nil
+		}
+		if f.UncompressedSize64 > uint64(maxSize) {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     fmt.Sprintf("archive entry too large: %s (%d bytes, max %d)", f.Name, f.UncompressedSize64, maxSize),
+				ErrorCode: types.ErrTooLarge,
+			}, nil
+		}
+		declared += int64(f.UncompressedSize64)
+		freed += regularFileSize(target)
+		targets[i] = target
+	}
+	if quota >= 0 && declared > quota+freed {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("disk usage limit exceeded: extracting %d bytes, %d bytes left", declared, quota+freed),
+			ErrorCode: types.ErrTooLarge,
+		}, nil
+	}
+
+	extracted := []string{}
+	for i, f := range zr.File {
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		target := targets[i]
+		if f.FileInfo().IsDir() {
+			if err := t.mkdirInWorkspace(target); err != nil {
+				return &types.ToolResult{
+					OK:        false,
+					Error:     fmt.Sprintf("failed to create directory: %v", err),
+					ErrorCode: types.ErrorCodeFor(err),
+				}, nil
+			}
+			continue
+		}
+
+		// Re-validate once the parent exists, in case the file itself is
+		// a symlink out of the workspace
+		if err := t.mkdirInWorkspace(filepath.Dir(target)); err != nil {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     fmt.Sprintf("failed to create directory: %v", err),
+				ErrorCode: types.ErrorCodeFor(err),
+			}, nil
+		}
+		if _, err := t.session.ResolvePath(target); err != nil {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     fmt.Sprintf("archive entry %s: %v", f.Name, err),
+				ErrorCode: types.ErrorCodeFor(err),
+			}, nil
+		}
+
+		// The sizes in the archive are not trustworthy, so the quota is
+		// also enforced on the bytes actually written. Overwriting a file
+		// frees its current size.
+		limit := maxSize
+		if quota >= 0 {
+			quota += regularFileSize(target)
+			limit = min(limit, quota)
+		}
+		n, err := extractZipFile(f, target, limit)
+		if errors.Is(err, errEntryTooLarge) {
+			reason := fmt.Sprintf("archive entry too large: %s (max %d bytes)", f.Name, maxSize)
+			if limit < maxSize {
+				reason = fmt.Sprintf("disk usage limit exceeded while extracting %s", f.Name)
+			}
+			return &types.ToolResult{
+				OK:        false,
+				Error:     reason,
+				ErrorCode: types.ErrTooLarge,
+			}, nil
+		}
+		if err != nil {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     fmt.Sprintf("failed to extract %s: %v", f.Name, err),
+				ErrorCode: types.ErrorCodeFor(err),
+			}, nil
+		}
+		if quota >= 0 {
+			quota -= n
+		}
+		t.session.RecordAccess("write", target, n)
+
+		rel, _ := filepath.Rel(root, target)
+		extracted = append(extracted, rel)
+	}
+
+	return &types.ToolResult{
+		OK: true,
+		Data: map[string]interface{}{
+			"dest":      dest,
+			"extracted": extracted,
+			"count":     len(extracted),
+		},
+	}, nil
+}
+
+// missingDirs returns the directories that creating dir would create,
+// deepest first. The deepest existing ancestor of dir must resolve, with
+// symlinks followed, to a directory inside the workspace.
+func (t *FSTools) missingDirs(dir string) ([]string, error) {
+	var missing []string
+	existing := dir
+	for {
+		_, err := os.Lstat(existing)
+		if err == nil {
+			break
+		}
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		missing = append(missing, existing)
+		parent := filepath.Dir(existing)
+		if parent == existing {
+			break
+		}
+		existing = parent
+	}
+
+	real, err := filepath.EvalSymlinks(existing)
+	if err != nil {
+		return nil, err
+	}
+	if info, err := os.Stat(real); err != nil || !info.IsDir() {
+		return nil, fmt.Errorf("%s is not a directory", existing)
+	}
+	if !t.session.Contains(real) {
+		return nil, fmt.Errorf("%s is outside the workspace: %w", existing, fs.ErrPermission)
+	}
+	return missing, nil
+}
+
+// mkdirInWorkspace creates dir and its missing parents, like os.MkdirAll,
+// but refuses to follow a symlink out of the workspace. The missing levels
+// are created one at a time, so none of them can be a symlink.
+func (t *FSTools) mkdirInWorkspace(dir string) error {
+	missing, err := t.missingDirs(dir)
+	if err != nil {
+		return err
+	}
+	for i := len(missing) - 1; i >= 0; i-- {
+		if err := os.Mkdir(missing[i], 0755); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// errEntryTooLarge is returned by extractZipFile when an entry is larger
+// than its limit.
+var errEntryTooLarge = errors.New("archive entry too large")
+
+// extractZipFile writes one archive entry to target. It stops after limit
+// bytes, since the size in the entry header is not trustworthy. On failure
+// the partially written file is removed.
+func extractZipFile(f *zip.File, target string, limit int64) (int64, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	out, err := os.OpenFile(target, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, f.Mode().Perm()|0600)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(out, io.LimitReader(rc, limit+1))
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil && n > limit {
+		err = errEntryTooLarge
+	}
+	if err != nil {
+		os.Remove(target)
+	}
+	return n, err
+}
+
+// regularFileSize returns the size of the regular file at path, or 0 if
+// there is none.
+func regularFileSize(path string) int64 {
+	if info, err := os.Lstat(path); err == nil && info.Mode().IsRegular() {
+		return info.Size()
+	}
+	return 0
+}
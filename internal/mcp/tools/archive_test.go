@@ -0,0 +1,174 @@
+package tools
+
+import (
+	"archive/zip"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/tldw/tldw-agent/internal/config"
+	"github.com/tldw/tldw-agent/internal/types"
+	"github.com/tldw/tldw-agent/internal/workspace"
+)
+
+// zipEntry is a file to put in a test archive.
+type zipEntry struct {
+	name    string
+	content string
+	mode    os.FileMode
+}
+
+// newTestFSTools returns FSTools for a fresh workspace and its root.
+func newTestFSTools(t *testing.T, cfg *config.Config) (*FSTools, string) {
+	t.Helper()
+	root := t.TempDir()
+	session := workspace.NewSession(cfg)
+	if err := session.SetRoot(root); err != nil {
+		t.Fatalf("SetRoot failed: %v", err)
+	}
+	return NewFSTools(cfg, session), root
+}
+
+// writeZip creates an archive at path holding entries.
+func writeZip(t *testing.T, path string, entries []zipEntry) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for _, e := range entries {
+		header := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		header.SetMode(e.mode | 0644)
+		w, err := zw.CreateHeader(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(e.content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestUnzipRejectsUnsafeEntries(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []zipEntry
+		want    string
+	}{
+		{"parent directory", []zipEntry{{name: "../evil.txt", content: "x"}}, "escapes destination"},
+		{"nested parent directory", []zipEntry{{name: "a/../../evil.txt", content: "x"}}, "escapes destination"},
+		{"outside workspace", []zipEntry{{name: "../../evil.txt", content: "x"}}, "escapes destination"},
+		{"symbolic link", []zipEntry{{name: "link", content: "/etc/passwd", mode: os.ModeSymlink}}, "symbolic link"},
+		{"after a safe entry", []zipEntry{{name: "ok.txt", content: "x"}, {name: "../evil.txt", content: "x"}}, "escapes destination"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsTools, root := newTestFSTools(t, config.Default())
+			writeZip(t, filepath.Join(root, "a.zip"), tt.entries)
+
+			result, err := fsTools.Unzip(context.Background(), map[string]interface{}{"src": "a.zip", "dest": "out"})
+			if err != nil {
+				t.Fatalf("Unzip failed: %v", err)
+			}
+			if result.OK || result.ErrorCode != types.ErrPermission || !strings.Contains(result.Error, tt.want) {
+				t.Fatalf("expected a %q permission error, got %+v", tt.want, result)
+			}
+			// Nothing is extracted when any entry is rejected
+			if _, err := os.Stat(filepath.Join(root, "out")); !os.IsNotExist(err) {
+				t.Fatal("entries were extracted from a rejected archive")
+			}
+			if _, err := os.Stat(filepath.Join(filepath.Dir(root), "evil.txt")); !os.IsNotExist(err) {
+				t.Fatal("an entry was written outside the workspace")
+			}
+		})
+	}
+}
+
+func TestUnzipThroughSymlinkedDirectory(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("creating symlinks needs extra privileges on Windows")
+	}
+	fsTools, root := newTestFSTools(t, config.Default())
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(root, "link")); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, entries := range [][]zipEntry{
+		{{name: "link/evil/"}},
+		{{name: "link/evil2/x.txt", content: "x"}},
+		{{name: "ok.txt", content: "x"}, {name: "link/x.txt", content: "x"}},
+	} {
+		writeZip(t, filepath.Join(root, "a.zip"), entries)
+		result, err := fsTools.Unzip(context.Background(), map[string]interface{}{"src": "a.zip", "dest": "."})
+		if err != nil {
+			t.Fatalf("Unzip failed: %v", err)
+		}
+		if result.OK || result.ErrorCode != types.ErrPermission {
+			t.Fatalf("expected %s to be rejected, got %+v", entries[len(entries)-1].name, result)
+		}
+	}
+
+	left, err := os.ReadDir(outside)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(left) != 0 {
+		t.Fatalf("entries were created outside the workspace: %v", left)
+	}
+	if _, err := os.Stat(filepath.Join(root, "ok.txt")); !os.IsNotExist(err) {
+		t.Fatal("entries were extracted from a rejected archive")
+	}
+}
+
+func TestUnzipDiskQuota(t *testing.T) {
+	cfg := config.Default()
+	cfg.Workspace.MaxDiskUsageBytes = 2048
+	fsTools, root := newTestFSTools(t, cfg)
+
+	// The archive itself counts towards the quota
+	writeZip(t, filepath.Join(root, "big.zip"), []zipEntry{
+		{name: "a.txt", content: strings.Repeat("a", 1000)},
+		{name: "b.txt", content: strings.Repeat("b", 1000)},
+	})
+	result, err := fsTools.Unzip(context.Background(), map[string]interface{}{"src": "big.zip", "dest": "out"})
+	if err != nil {
+		t.Fatalf("Unzip failed: %v", err)
+	}
+	if result.OK || result.ErrorCode != types.ErrTooLarge {
+		t.Fatalf("expected the disk usage limit to be enforced, got %+v", result)
+	}
+
+	writeZip(t, filepath.Join(root, "big.zip"), []zipEntry{{name: "a.txt", content: "small"}})
+	result, err = fsTools.Unzip(context.Background(), map[string]interface{}{"src": "big.zip", "dest": "out"})
+	if err != nil || !result.OK {
+		t.Fatalf("expected an archive within the limit to extract, got %+v, %v", result, err)
+	}
+}
+
+func TestExtractZipFileRemovesPartialFile(t *testing.T) {
+	dir := t.TempDir()
+	src := filepath.Join(dir, "a.zip")
+	writeZip(t, src, []zipEntry{{name: "a.txt", content: strings.Repeat("a", 100)}})
+	zr, err := zip.OpenReader(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+
+	target := filepath.Join(dir, "a.txt")
+	if _, err := extractZipFile(zr.File[0], target, 10); err != errEntryTooLarge {
+		t.Fatalf("err = %v, want errEntryTooLarge", err)
+	}
+	if _, err := os.Stat(target); !os.IsNotExist(err) {
+		t.Fatal("partial file was left behind")
+	}
+}
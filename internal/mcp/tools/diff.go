@@ -0,0 +1,216 @@
+package tools
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/sergi/go-diff/diffmatchpatch"
+
+	"github.com/tldw/tldw-agent/internal/types"
+)
+
+const (
+	// diffContextLines is the number of unchanged lines shown around changes.
+	diffContextLines = 3
+	// maxDiffBytes caps fs.diff output, matching git.diff.
+	maxDiffBytes = 100000 // 100KB
+)
+
+// DiffFiles returns a unified diff between two workspace files.
+func (t *FSTools) DiffFiles(args map[string]interface{}) (*types.ToolResult, error) {
+	pathA, _ := args["path_a"].(string)
+	pathB, _ := args["path_b"].(string)
+	if pathA == "" || pathB == "" {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     "path_a and path_b are required",
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+
+	a, result := t.readChecked(pathA)
+	if result != nil {
+		return result, nil
+	}
+	b, result := t.readChecked(pathB)
+	if result != nil {
+		return result, nil
+	}
+
+	diff, insertions, deletions := unifiedDiff("a/"+pathA, "b/"+pathB, string(a), string(b))
+
+	truncated := false
+	if len(diff) > maxDiffBytes {
+		diff = diff[:maxDiffBytes]
+		truncated = true
+	}
+
+	return &types.ToolResult{
+		OK: true,
+		Data: map[string]interface{}{
+			"diff":       diff,
+			"insertions": insertions,
+			"deletions":  deletions,
+			"truncated":  truncated,
+		},
+	}, nil
+}
+
+// readChecked reads a workspace file, applying the same path and size
+// checks as fs.read. On failure it returns the result to report instead.
+func (t *FSTools) readChecked(path string) ([]byte, *types.ToolResult) {
+	absPath, err := t.session.ResolvePath(path)
+	if err != nil {
+		return nil, &types.ToolResult{
+			OK:        false,
+			Error:     err.Error(),
+			ErrorCode: types.ErrorCodeFor(err),
+		}
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return nil, &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("failed to stat file: %v", err),
+			ErrorCode: types.ErrorCodeFor(err),
+		}
+	}
+	if info.IsDir() {
+		return nil, &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("%s is a directory, not a file", path),
+			ErrorCode: types.ErrInvalidArg,
+		}
+	}
+	if info.Size() > t.config.Workspace.MaxFileSizeBytes {
+		return nil, &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("file too large: %d bytes (max %d)", info.Size(), t.config.Workspace.MaxFileSizeBytes),
+			ErrorCode: types.ErrTooLarge,
+		}
+	}
+
+	data, err := os.ReadFile(absPath)
+	if err != nil {
+		return nil, &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("failed to read file: %v", err),
+			ErrorCode: types.ErrorCodeFor(err),
+		}
+	}
+	t.session.RecordAccess("read", absPath, int64(len(data)))
+	return data, nil
+}
+
+// diffLine is one line of a line-level diff, including its newline if it
+// has one.
+type diffLine struct {
+	op   diffmatchpatch.Operation
+	text string
+}
+
+// unifiedDiff returns a unified diff of a and b with the given file names,
+// and the number of inserted and deleted lines. It returns an empty diff
+// if the contents are identical.
+func unifiedDiff(nameA, nameB, a, b string) (string, int, int) {
+	dmp := diffmatchpatch.New()
+	charsA, charsB, lineArray := dmp.DiffLinesToChars(a, b)
+	diffs := dmp.DiffCharsToLines(dmp.DiffMain(charsA, charsB, false), lineArray)
+
+	var lines []diffLine
+	insertions, deletions := 0, 0
+	for _, d := range diffs {
+		for _, text := range strings.SplitAfter(d.Text, "\n") {
+			if text == "" {
+				continue
+			}
+			lines = append(lines, diffLine{op: d.Type, text: text})
+			switch d.Type {
+			case diffmatchpatch.DiffInsert:
+				insertions++
+			case diffmatchpatch.DiffDelete:
+				deletions++
+			}
+		}
+	}
+	if insertions == 0 && deletions == 0 {
+		return "", 0, 0
+	}
+
+	// Line numbers in a and b at the start of each diff line
+	oldNo := make([]int, len(lines)+1)
+	newNo := make([]int, len(lines)+1)
+	oldNo[0], newNo[0] = 1, 1
+	for i, l := range lines {
+		oldNo[i+1], newNo[i+1] = oldNo[i], newNo[i]
+		if l.op != diffmatchpatch.DiffInsert {
+			oldNo[i+1]++
+		}
+		if l.op != diffmatchpatch.DiffDelete {
+			newNo[i+1]++
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "--- %s\n+++ %s\n", nameA, nameB)
+
+	for i := 0; i < len(lines); {
+		// Find the next change
+		for i < len(lines) && lines[i].op == diffmatchpatch.DiffEqual {
+			i++
+		}
+		if i == len(lines) {
+			break
+		}
+
+		// Grow the hunk while the next change is close enough that their
+		// context would overlap
+		start := max(i-diffContextLines, 0)
+		end := i
+		for {
+			for end < len(lines) && lines[end].op != diffmatchpatch.DiffEqual {
+				end++
+			}
+			next := end
+			for next < len(lines) && lines[next].op == diffmatchpatch.DiffEqual {
+				next++
+			}
+			if next < len(lines) && next-end <= 2*diffContextLines {
+				end = next
+				continue
+			}
+			end = min(end+diffContextLines, len(lines))
+			break
+		}
+
+		oldStart, oldCount := oldNo[start], oldNo[end]-oldNo[start]
+		newStart, newCount := newNo[start], newNo[end]-newNo[start]
+		if oldCount == 0 {
+			oldStart--
+		}
+		if newCount == 0 {
+			newStart--
+		}
+		fmt.Fprintf(&sb, "@@ -%d,%d +%d,%d @@\n", oldStart, oldCount, newStart, newCount)
+
+		for _, l := range lines[start:end] {
+			switch l.op {
+			case diffmatchpatch.DiffInsert:
+				sb.WriteByte('+')
+			case diffmatchpatch.DiffDelete:
+				sb.WriteByte('-')
+			default:
+				sb.WriteByte(' ')
+			}
+			sb.WriteString(l.text)
+			if !strings.HasSuffix(l.text, "\n") {
+				sb.WriteString("\n\\ No newline at end of file\n")
+			}
+		}
+		i = end
+	}
+
+	return sb.String(), insertions, deletions
+}
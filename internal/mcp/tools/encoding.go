@@ -0,0 +1,101 @@
+package tools
+
+import (
+	"bytes"
+	"fmt"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/ianaindex"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+// decodeText converts data to UTF-8 and returns the name of the encoding
+// it was in. force names an encoding (e.g., "ISO-8859-1", "Shift_JIS") to
+// use instead of detecting one.
+func decodeText(data []byte, force string) ([]byte, string, error) {
+	name, enc := "", encoding.Encoding(nil)
+	if force != "" {
+		var err error
+		enc, err = ianaindex.IANA.Encoding(force)
+		if err != nil || enc == nil {
+			return nil, "", fmt.Errorf("unsupported encoding: %q", force)
+		}
+		if name, err = ianaindex.MIME.Name(enc); err != nil {
+			name = force
+		}
+	} else {
+		name, enc = detectEncoding(data)
+	}
+
+	if enc == nil {
+		return bytes.TrimPrefix(data, utf8BOM), name, nil
+	}
+	decoded, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to decode file as %s: %v", name, err)
+	}
+	return decoded, name, nil
+}
+
+var utf8BOM = []byte{0xEF, 0xBB, 0xBF}
+
+// detectEncoding guesses the encoding of data from its byte order mark,
+// or failing that from which legacy encoding its bytes are valid in. A nil
+// encoding means data is UTF-8.
+func detectEncoding(data []byte) (string, encoding.Encoding) {
+	switch {
+	case bytes.HasPrefix(data, utf8BOM):
+		return "UTF-8", nil
+	case bytes.HasPrefix(data, []byte{0xFF, 0xFE}):
+		return "UTF-16LE", unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM)
+	case bytes.HasPrefix(data, []byte{0xFE, 0xFF}):
+		return "UTF-16BE", unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM)
+	}
+
+	if utf8.Valid(data) {
+		return "UTF-8", nil
+	}
+	if looksLikeShiftJIS(data) {
+		return "Shift_JIS", japanese.ShiftJIS
+	}
+
+	// Windows-1252 assigns printable characters to most of 0x80-0x9F, which
+	// are control codes in ISO-8859-1 and almost never appear in text
+	for _, b := range data {
+		if b >= 0x80 && b <= 0x9F {
+			return "windows-1252", charmap.Windows1252
+		}
+	}
+	return "ISO-8859-1", charmap.ISO8859_1
+}
+
+// looksLikeShiftJIS reports whether every non-ASCII byte in data belongs to
+// a well-formed Shift_JIS character and at least one double-byte character
+// is present. Single-byte Latin text rarely satisfies this, since accented
+// letters are lead bytes that would need a valid trail byte after them.
+func looksLikeShiftJIS(data []byte) bool {
+	doubleByte := 0
+	for i := 0; i < len(data); i++ {
+		b := data[i]
+		switch {
+		case b < 0x80:
+		case b >= 0xA1 && b <= 0xDF: // Half-width katakana
+		case (b >= 0x81 && b <= 0x9F) || (b >= 0xE0 && b <= 0xFC):
+			if i+1 >= len(data) {
+				return false
+			}
+			trail := data[i+1]
+			if trail < 0x40 || trail > 0xFC || trail == 0x7F {
+				return false
+			}
+			doubleByte++
+			i++
+		default:
+			return false
+		}
+	}
+	return doubleByte > 0
+}
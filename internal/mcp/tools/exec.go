@@ -5,7 +5,11 @@ import (
 	"bytes"
 	"context"
 	"fmt"
+	"io"
+	"log"
+	"os"
 	"os/exec"
+	"path"
 	"runtime"
 	"strings"
 	"time"
@@ -15,6 +19,10 @@ import (
 	"github.com/tldw/tldw-agent/internal/workspace"
 )
 
+// execWaitDelay is how long output is still collected after a command is
+// killed.
+const execWaitDelay = 2 * time.Second
+
 // Command represents an allowlisted command (alias for config.CustomCommand).
 type Command = config.CustomCommand
 
@@ -75,9 +83,10 @@ func defaultWindowsCommands() []Command {
 
 // ExecTools provides command execution tools.
 type ExecTools struct {
-	config   *config.Config
-	session  *workspace.Session
-	commands map[string]Command
+	config     *config.Config
+	session    *workspace.Session
+	commands   map[string]Command
+	background *backgroundTable
 }
 
 // NewExecTools creates a new ExecTools instance.
@@ -92,11 +101,32 @@ func NewExecTools(cfg *config.Config, session *workspace.Session) *ExecTools {
 		commands[cmd.ID] = cmd
 	}
 
-	return &ExecTools{
-		config:   cfg,
-		session:  session,
-		commands: commands,
+	e := &ExecTools{
+		config:     cfg,
+		session:    session,
+		commands:   commands,
+		background: &backgroundTable{procs: make(map[string]*backgroundProcess)},
+	}
+
+	// Surface misconfigured commands now rather than when the agent runs them
+	for _, problem := range e.Validate() {
+		log.Printf("Warning: %s", problem)
+	}
+
+	return e
+}
+
+// Validate returns a description of each custom command whose executable
+// cannot be found. Built-in commands are not checked since most machines
+// only have some of the toolchains they cover.
+func (e *ExecTools) Validate() []string {
+	var problems []string
+	for _, cmd := range e.config.Execution.CustomCommands {
+		if _, err := cmd.LookPath(); err != nil {
+			problems = append(problems, fmt.Sprintf("custom command %q: %v", cmd.ID, err))
+		}
 	}
+	return problems
 }
 
 // ExecResult represents the result of a command execution.
@@ -108,13 +138,15 @@ type ExecResult struct {
 	Truncated  bool   `json:"truncated"`
 }
 
-// Run executes an allowlisted command.
-func (e *ExecTools) Run(args map[string]interface{}) (*types.ToolResult, error) {
+// Run executes an allowlisted command. The command is killed if ctx is
+// cancelled; background commands outlive ctx and are stopped with Stop.
+func (e *ExecTools) Run(ctx context.Context, args map[string]interface{}) (*types.ToolResult, error) {
 	// Check if execution is enabled
 	if !e.config.Execution.Enabled {
 		return &types.ToolResult{
-			OK:    false,
-			Error: "command execution is disabled",
+			OK:        false,
+			Error:     "command execution is disabled",
+			ErrorCode: types.ErrExecDisabled,
 		}, nil
 	}
 
@@ -122,8 +154,9 @@ func (e *ExecTools) Run(args map[string]interface{}) (*types.ToolResult, error)
 	commandID, _ := args["command_id"].(string)
 	if commandID == "" {
 		return &types.ToolResult{
-			OK:    false,
-			Error: "command_id is required",
+			OK:        false,
+			Error:     "command_id is required",
+			ErrorCode: types.ErrInvalidArg,
 		}, nil
 	}
 
@@ -131,11 +164,21 @@ func (e *ExecTools) Run(args map[string]interface{}) (*types.ToolResult, error)
 	cmd, ok := e.commands[commandID]
 	if !ok {
 		return &types.ToolResult{
-			OK:    false,
-			Error: fmt.Sprintf("command %q not in allowlist", commandID),
+			OK:        false,
+			Error:     fmt.Sprintf("command %q not in allowlist", commandID),
+			ErrorCode: types.ErrInvalidArg,
 		}, nil
 	}
 
+	// What a command writes can't be known in advance, so commands are only
+	// refused once the workspace is at its disk usage limit
+	if remaining, err := e.session.RemainingDiskQuota(); err != nil || remaining == 0 {
+		if err == nil {
+			err = fmt.Errorf("%w: no space left", workspace.ErrDiskQuota)
+		}
+		return diskQuotaResult(err), nil
+	}
+
 	// Get optional arguments
 	var cmdArgs []string
 	if argsRaw, ok := args["args"].([]interface{}); ok && cmd.AllowArgs {
@@ -144,8 +187,9 @@ func (e *ExecTools) Run(args map[string]interface{}) (*types.ToolResult, error)
 				// Sanitize argument - reject shell metacharacters
 				if containsShellMeta(s) {
 					return &types.ToolResult{
-						OK:    false,
-						Error: fmt.Sprintf("argument %q contains disallowed characters", s),
+						OK:        false,
+						Error:     fmt.Sprintf("argument %q contains disallowed characters", s),
+						ErrorCode: types.ErrInvalidArg,
 					}, nil
 				}
 				cmdArgs = append(cmdArgs, s)
@@ -155,8 +199,9 @@ func (e *ExecTools) Run(args map[string]interface{}) (*types.ToolResult, error)
 		// Check max args
 		if cmd.MaxArgs > 0 && len(cmdArgs) > cmd.MaxArgs {
 			return &types.ToolResult{
-				OK:    false,
-				Error: fmt.Sprintf("too many arguments (max %d)", cmd.MaxArgs),
+				OK:        false,
+				Error:     fmt.Sprintf("too many arguments (max %d)", cmd.MaxArgs),
+				ErrorCode: types.ErrInvalidArg,
 			}, nil
 		}
 	}
@@ -168,8 +213,9 @@ func (e *ExecTools) Run(args map[string]interface{}) (*types.ToolResult, error)
 		absPath, err := e.session.ResolvePath(cwdArg)
 		if err != nil {
 			return &types.ToolResult{
-				OK:    false,
-				Error: fmt.Sprintf("invalid cwd: %v", err),
+				OK:        false,
+				Error:     fmt.Sprintf("invalid cwd: %v", err),
+				ErrorCode: types.ErrorCodeFor(err),
 			}, nil
 		}
 		cwd = absPath
@@ -192,12 +238,25 @@ func (e *ExecTools) Run(args map[string]interface{}) (*types.ToolResult, error)
 		fullCmd = fullCmd + " " + strings.Join(cmdArgs, " ")
 	}
 
+	// Start in the background and return immediately. Background commands
+	// have their own limit, which timeout_ms can only shorten.
+	if background, _ := args["background"].(bool); background {
+		timeout := time.Duration(e.config.Execution.BackgroundTimeoutMs) * time.Millisecond
+		if timeoutArg, ok := args["timeout_ms"].(float64); ok && timeoutArg > 0 {
+			if requested := time.Duration(timeoutArg) * time.Millisecond; timeout == 0 || requested < timeout {
+				timeout = requested
+			}
+		}
+		return e.startBackground(fullCmd, cwd, cmd.Env, timeout)
+	}
+
 	// Execute
-	result, err := e.executeCommand(fullCmd, cwd, timeout, cmd.Env)
+	result, err := e.executeCommand(ctx, fullCmd, cwd, timeout, cmd.Env)
 	if err != nil {
 		return &types.ToolResult{
-			OK:    false,
-			Error: err.Error(),
+			OK:        false,
+			Error:     err.Error(),
+			ErrorCode: types.ErrorCodeFor(err),
 		}, nil
 	}
 
@@ -207,59 +266,201 @@ func (e *ExecTools) Run(args map[string]interface{}) (*types.ToolResult, error)
 	}, nil
 }
 
-// ListCommands returns all available commands.
-func (e *ExecTools) ListCommands() []Command {
-	result := make([]Command, 0, len(e.commands))
+// Which locates an executable on PATH. When execution is enabled and an
+// allowlisted command runs the same program, it also runs
+// "<command> --version" briefly and reports the first line of output as a
+// version hint. Other executables are never run, since Which is a read-tier
+// tool.
+func (e *ExecTools) Which(ctx context.Context, args map[string]interface{}) (*types.ToolResult, error) {
+	name, _ := args["command"].(string)
+	if name == "" {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     "command is required",
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+	if strings.ContainsAny(name, "/\\") || containsShellMeta(name) {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("invalid command name: %q", name),
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+
+	path, err := exec.LookPath(name)
+	if err != nil {
+		return &types.ToolResult{
+			OK: true,
+			Data: map[string]interface{}{
+				"found": false,
+			},
+		}, nil
+	}
+
+	data := map[string]interface{}{
+		"found": true,
+		"path":  path,
+	}
+	if e.config.Execution.Enabled && e.allowlistedProgram(name) {
+		if hint := versionHint(ctx, path); hint != "" {
+			data["version_hint"] = hint
+		}
+	}
+
+	return &types.ToolResult{
+		OK:   true,
+		Data: data,
+	}, nil
+}
+
+// allowlistedProgram reports whether name is the program an allowlisted
+// command's template starts with.
+func (e *ExecTools) allowlistedProgram(name string) bool {
 	for _, cmd := range e.commands {
-		result = append(result, cmd)
+		if fields := strings.Fields(cmd.Template); len(fields) > 0 && fields[0] == name {
+			return true
+		}
 	}
-	return result
+	return false
 }
 
-func (e *ExecTools) executeCommand(cmdStr, cwd string, timeout time.Duration, env []string) (*ExecResult, error) {
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+// versionHint returns the first line printed by "<path> --version", or ""
+// if it exits with an error or takes longer than two seconds.
+func versionHint(ctx context.Context, path string) string {
+	ctx, cancel := context.WithTimeout(ctx, 2*time.Second)
 	defer cancel()
 
-	var cmd *exec.Cmd
+	// Kill anything the program starts along with it, as executeCommand does
+	cmd := exec.CommandContext(ctx, path, "--version")
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		killProcessGroup(cmd)
+		return nil
+	}
+	cmd.WaitDelay = execWaitDelay
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return "" // Doesn't understand --version
+	}
+	for _, line := range strings.Split(string(out), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			if len(line) > 200 {
+				line = line[:200]
+			}
+			return line
+		}
+	}
+	return ""
+}
 
-	// Use appropriate shell based on OS
-	if runtime.GOOS == "windows" {
-		shell := e.config.Execution.Shell
-		if shell == "auto" || shell == "" {
-			shell = "powershell"
+// Env returns the agent's environment variables, or only those named in
+// keys. Values of variables matching execution.hidden_env_vars are
+// replaced with "<redacted>".
+func (e *ExecTools) Env(args map[string]interface{}) (*types.ToolResult, error) {
+	var keys []string
+	if keysRaw, ok := args["keys"].([]interface{}); ok {
+		for _, k := range keysRaw {
+			if s, ok := k.(string); ok && s != "" {
+				keys = append(keys, s)
+			}
 		}
+	}
 
-		switch shell {
-		case "powershell":
-			cmd = exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", cmdStr)
-		case "cmd":
-			cmd = exec.CommandContext(ctx, "cmd", "/c", cmdStr)
-		default:
-			cmd = exec.CommandContext(ctx, shell, "-c", cmdStr)
+	vars := make(map[string]string)
+	if len(keys) > 0 {
+		for _, key := range keys {
+			if value, ok := os.LookupEnv(key); ok {
+				vars[key] = value
+			}
 		}
 	} else {
-		shell := e.config.Execution.Shell
-		if shell == "auto" || shell == "" {
-			shell = "sh"
+		for _, entry := range os.Environ() {
+			if key, value, ok := strings.Cut(entry, "="); ok && key != "" {
+				vars[key] = value
+			}
 		}
-		cmd = exec.CommandContext(ctx, shell, "-c", cmdStr)
 	}
 
+	for key := range vars {
+		if e.hiddenEnvVar(key) {
+			vars[key] = "<redacted>"
+		}
+	}
+
+	return &types.ToolResult{
+		OK: true,
+		Data: map[string]interface{}{
+			"env": vars,
+		},
+	}, nil
+}
+
+// hiddenEnvVar reports whether name matches execution.hidden_env_vars.
+func (e *ExecTools) hiddenEnvVar(name string) bool {
+	upper := strings.ToUpper(name)
+	for _, pattern := range e.config.Execution.HiddenEnvVars {
+		if matched, _ := path.Match(strings.ToUpper(pattern), upper); matched {
+			return true
+		}
+	}
+	return false
+}
+
+// ListCommands returns all available commands.
+func (e *ExecTools) ListCommands() []Command {
+	result := make([]Command, 0, len(e.commands))
+	for _, cmd := range e.commands {
+		result = append(result, cmd)
+	}
+	return result
+}
+
+func (e *ExecTools) executeCommand(ctx context.Context, cmdStr, cwd string, timeout time.Duration, env []string) (*ExecResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cmd := e.shellCommand(ctx, cmdStr)
 	cmd.Dir = cwd
 
+	// Kill everything the shell started on timeout or cancellation, and
+	// don't wait long for output from a child that escaped the group
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		killProcessGroup(cmd)
+		return nil
+	}
+	cmd.WaitDelay = execWaitDelay
+
 	// Set environment
 	if len(env) > 0 {
-		cmd.Env = append(cmd.Env, env...)
+		cmd.Env = append(os.Environ(), e.expandEnv(env)...)
+	}
+
+	maxOutput := e.config.Execution.MaxOutputBytes
+	if maxOutput <= 0 {
+		maxOutput = 1024 * 1024 // 1MB default
 	}
 
-	// Capture output
+	// Capture output, passing it on as it arrives if the caller asked for it
 	var stdout, stderr bytes.Buffer
 	cmd.Stdout = &stdout
 	cmd.Stderr = &stderr
+	var live []*outputWriter
+	if fn := OutputFromContext(ctx); fn != nil {
+		stdoutLive := newOutputWriter("stdout", fn, maxOutput)
+		stderrLive := newOutputWriter("stderr", fn, maxOutput)
+		cmd.Stdout = io.MultiWriter(&stdout, stdoutLive)
+		cmd.Stderr = io.MultiWriter(&stderr, stderrLive)
+		live = []*outputWriter{stdoutLive, stderrLive}
+	}
 
 	start := time.Now()
 	err := cmd.Run()
 	duration := time.Since(start)
+	for _, w := range live {
+		w.Flush()
+	}
 
 	result := &ExecResult{
 		DurationMs: duration.Milliseconds(),
@@ -274,16 +475,16 @@ func (e *ExecTools) executeCommand(cmdStr, cwd string, timeout time.Duration, en
 			result.ExitCode = -1
 			result.Stderr = "command timed out"
 			return result, nil
+		} else if ctx.Err() == context.Canceled {
+			result.ExitCode = -1
+			result.Stderr = "command cancelled"
+			return result, nil
 		} else {
 			return nil, fmt.Errorf("failed to execute command: %w", err)
 		}
 	}
 
 	// Get output, truncating if too large
-	maxOutput := e.config.Execution.MaxOutputBytes
-	if maxOutput <= 0 {
-		maxOutput = 1024 * 1024 // 1MB default
-	}
 	stdoutBytes := stdout.Bytes()
 	stderrBytes := stderr.Bytes()
 
@@ -302,6 +503,63 @@ func (e *ExecTools) executeCommand(cmdStr, cwd string, timeout time.Duration, en
 	return result, nil
 }
 
+// expandEnv substitutes ${VAR} references in KEY=value entries with the
+// agent's environment. Only variables in execution.env_allowlist are
+// substituted; an entry with an unresolved reference is logged and passed
+// through unexpanded.
+func (e *ExecTools) expandEnv(env []string) []string {
+	allowed := make(map[string]bool, len(e.config.Execution.EnvAllowlist))
+	for _, name := range e.config.Execution.EnvAllowlist {
+		allowed[name] = true
+	}
+
+	expanded := make([]string, 0, len(env))
+	for _, entry := range env {
+		var unresolved []string
+		value := os.Expand(entry, func(name string) string {
+			if allowed[name] {
+				if v, ok := os.LookupEnv(name); ok {
+					return v
+				}
+			}
+			unresolved = append(unresolved, name)
+			return ""
+		})
+
+		if len(unresolved) > 0 {
+			log.Printf("Warning: env %q: unresolved variables %s, using value unexpanded", entry, strings.Join(unresolved, ", "))
+			value = entry
+		}
+		expanded = append(expanded, value)
+	}
+	return expanded
+}
+
+// shellCommand wraps cmdStr in the configured shell for this OS.
+func (e *ExecTools) shellCommand(ctx context.Context, cmdStr string) *exec.Cmd {
+	shell := e.config.Execution.Shell
+
+	if runtime.GOOS == "windows" {
+		if shell == "auto" || shell == "" {
+			shell = "powershell"
+		}
+
+		switch shell {
+		case "powershell":
+			return exec.CommandContext(ctx, "powershell", "-NoProfile", "-NonInteractive", "-Command", cmdStr)
+		case "cmd":
+			return exec.CommandContext(ctx, "cmd", "/c", cmdStr)
+		default:
+			return exec.CommandContext(ctx, shell, "-c", cmdStr)
+		}
+	}
+
+	if shell == "auto" || shell == "" {
+		shell = "sh"
+	}
+	return exec.CommandContext(ctx, shell, "-c", cmdStr)
+}
+
 // containsShellMeta checks if a string contains shell metacharacters.
 func containsShellMeta(s string) bool {
 	// List of dangerous shell metacharacters
@@ -0,0 +1,277 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/tldw/tldw-agent/internal/types"
+)
+
+// backgroundTable tracks commands started with exec.run's background flag.
+// It is shared by successive ExecTools instances so that processes survive
+// a config reload.
+type backgroundTable struct {
+	mu     sync.Mutex
+	procs  map[string]*backgroundProcess
+	nextID int64
+}
+
+// backgroundProcess is a command running, or finished, in the background.
+type backgroundProcess struct {
+	handle    string
+	cmd       *exec.Cmd
+	cancel    context.CancelFunc
+	stdout    *tailBuffer
+	stderr    *tailBuffer
+	startedAt time.Time
+	done      chan struct{}
+	exitCode  int
+	timedOut  bool
+}
+
+// tailBuffer keeps the last limit bytes written to it.
+type tailBuffer struct {
+	mu        sync.Mutex
+	buf       []byte
+	limit     int
+	truncated bool
+}
+
+func (b *tailBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.buf = append(b.buf, p...)
+	if len(b.buf) > b.limit {
+		b.buf = append([]byte{}, b.buf[len(b.buf)-b.limit:]...)
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+func (b *tailBuffer) snapshot() (string, bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return string(b.buf), b.truncated
+}
+
+// startBackground starts cmdStr without waiting for it to finish and
+// returns its pid and handle. The command is killed after timeout, unless
+// timeout is 0. At most execution.max_background commands run at once.
+func (e *ExecTools) startBackground(cmdStr, cwd string, env []string, timeout time.Duration) (*types.ToolResult, error) {
+	maxOutput := e.config.Execution.MaxOutputBytes
+	if maxOutput <= 0 {
+		maxOutput = 1024 * 1024 // 1MB default
+	}
+
+	// Hold the lock from the limit check until the process is registered
+	table := e.background
+	table.mu.Lock()
+	defer table.mu.Unlock()
+	if limit := e.config.Execution.MaxBackground; limit > 0 && table.runningLocked() >= limit {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("too many background commands running (max %d)", limit),
+			ErrorCode: types.ErrTooLarge,
+		}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	if timeout > 0 {
+		cancel()
+		ctx, cancel = context.WithTimeout(context.Background(), timeout)
+	}
+	cmd := e.shellCommand(ctx, cmdStr)
+	cmd.Dir = cwd
+	if len(env) > 0 {
+		cmd.Env = append(os.Environ(), e.expandEnv(env)...)
+	}
+	setProcessGroup(cmd)
+	cmd.Cancel = func() error {
+		killProcessGroup(cmd)
+		return nil
+	}
+	cmd.WaitDelay = execWaitDelay
+
+	proc := &backgroundProcess{
+		cmd:       cmd,
+		cancel:    cancel,
+		stdout:    &tailBuffer{limit: maxOutput},
+		stderr:    &tailBuffer{limit: maxOutput},
+		startedAt: time.Now(),
+		done:      make(chan struct{}),
+	}
+	cmd.Stdout = proc.stdout
+	cmd.Stderr = proc.stderr
+
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("failed to start command: %v", err),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+
+	go func() {
+		_ = cmd.Wait()
+		proc.exitCode = cmd.ProcessState.ExitCode()
+		proc.timedOut = ctx.Err() == context.DeadlineExceeded
+		cancel()
+		close(proc.done)
+	}()
+
+	proc.handle = fmt.Sprintf("bg_%d", atomic.AddInt64(&table.nextID, 1))
+	table.procs[proc.handle] = proc
+
+	return &types.ToolResult{
+		OK: true,
+		Data: map[string]interface{}{
+			"pid":    cmd.Process.Pid,
+			"handle": proc.handle,
+		},
+	}, nil
+}
+
+// runningLocked counts the commands that have not exited yet (must hold
+// mu).
+func (t *backgroundTable) runningLocked() int {
+	n := 0
+	for _, proc := range t.procs {
+		select {
+		case <-proc.done:
+		default:
+			n++
+		}
+	}
+	return n
+}
+
+// Status reports whether a background command is still running and, once
+// it has exited, its exit code and output. A finished command is reported
+// once; its handle is then forgotten.
+func (e *ExecTools) Status(args map[string]interface{}) (*types.ToolResult, error) {
+	proc, result := e.lookupBackground(args)
+	if proc == nil {
+		return result, nil
+	}
+
+	select {
+	case <-proc.done:
+	default:
+		return &types.ToolResult{
+			OK: true,
+			Data: map[string]interface{}{
+				"running":    true,
+				"pid":        proc.cmd.Process.Pid,
+				"elapsed_ms": time.Since(proc.startedAt).Milliseconds(),
+			},
+		}, nil
+	}
+
+	table := e.background
+	table.mu.Lock()
+	delete(table.procs, proc.handle)
+	table.mu.Unlock()
+
+	stdout, stdoutTruncated := proc.stdout.snapshot()
+	stderr, stderrTruncated := proc.stderr.snapshot()
+	return &types.ToolResult{
+		OK: true,
+		Data: map[string]interface{}{
+			"running":   false,
+			"exit_code": proc.exitCode,
+			"timed_out": proc.timedOut,
+			"stdout":    stdout,
+			"stderr":    stderr,
+			"truncated": stdoutTruncated || stderrTruncated,
+		},
+	}, nil
+}
+
+// Stop kills a background command and forgets its handle.
+func (e *ExecTools) Stop(args map[string]interface{}) (*types.ToolResult, error) {
+	proc, result := e.lookupBackground(args)
+	if proc == nil {
+		return result, nil
+	}
+
+	proc.stop()
+
+	table := e.background
+	table.mu.Lock()
+	delete(table.procs, proc.handle)
+	table.mu.Unlock()
+
+	return &types.ToolResult{
+		OK: true,
+		Data: map[string]interface{}{
+			"handle":    proc.handle,
+			"exit_code": proc.exitCode,
+		},
+	}, nil
+}
+
+// StopAll kills every background command. It is called on shutdown.
+func (e *ExecTools) StopAll() {
+	table := e.background
+	table.mu.Lock()
+	procs := table.procs
+	table.procs = make(map[string]*backgroundProcess)
+	table.mu.Unlock()
+
+	for _, proc := range procs {
+		proc.stop()
+	}
+}
+
+// AdoptBackground takes over the background commands started by prev, so
+// they can still be queried and stopped after the tools are rebuilt.
+func (e *ExecTools) AdoptBackground(prev *ExecTools) {
+	if prev != nil {
+		e.background = prev.background
+	}
+}
+
+// lookupBackground returns the process named by args["handle"], or a
+// failed result if there is none.
+func (e *ExecTools) lookupBackground(args map[string]interface{}) (*backgroundProcess, *types.ToolResult) {
+	handle, _ := args["handle"].(string)
+	if handle == "" {
+		return nil, &types.ToolResult{
+			OK:        false,
+			Error:     "handle is required",
+			ErrorCode: types.ErrInvalidArg,
+		}
+	}
+
+	e.background.mu.Lock()
+	proc := e.background.procs[handle]
+	e.background.mu.Unlock()
+	if proc == nil {
+		return nil, &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("unknown background handle: %s", handle),
+			ErrorCode: types.ErrNotFound,
+		}
+	}
+	return proc, nil
+}
+
+// stop kills the process group and waits for the process to exit.
+func (p *backgroundProcess) stop() {
+	select {
+	case <-p.done:
+		return
+	default:
+	}
+
+	killProcessGroup(p.cmd)
+	p.cancel()
+	<-p.done
+}
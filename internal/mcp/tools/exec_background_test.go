@@ -0,0 +1,116 @@
+package tools
+
+import (
+	"context"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/tldw/tldw-agent/internal/config"
+	"github.com/tldw/tldw-agent/internal/types"
+	"github.com/tldw/tldw-agent/internal/workspace"
+)
+
+func newTestExecTools(t *testing.T, cfg *config.Config) *ExecTools {
+	t.Helper()
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sleep")
+	}
+	cfg.Execution.CustomCommands = append(cfg.Execution.CustomCommands, config.CustomCommand{ID: "sleep", Template: "sleep 30"})
+	session := workspace.NewSession(cfg)
+	if err := session.SetRoot(t.TempDir()); err != nil {
+		t.Fatalf("SetRoot failed: %v", err)
+	}
+	e := NewExecTools(cfg, session)
+	t.Cleanup(e.StopAll)
+	return e
+}
+
+func TestBackgroundLimit(t *testing.T) {
+	cfg := config.Default()
+	cfg.Execution.MaxBackground = 1
+	e := newTestExecTools(t, cfg)
+	args := map[string]interface{}{"command_id": "sleep", "background": true}
+
+	first, err := e.Run(context.Background(), args)
+	if err != nil || !first.OK {
+		t.Fatalf("failed to start a background command: %+v, %v", first, err)
+	}
+	second, err := e.Run(context.Background(), args)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if second.OK || second.ErrorCode != types.ErrTooLarge {
+		t.Fatalf("expected the second command to be refused, got %+v", second)
+	}
+
+	handle := first.Data.(map[string]interface{})["handle"]
+	if _, err := e.Stop(map[string]interface{}{"handle": handle}); err != nil {
+		t.Fatal(err)
+	}
+	if third, _ := e.Run(context.Background(), args); !third.OK {
+		t.Fatalf("expected a command to start once the first was stopped, got %+v", third)
+	}
+}
+
+func TestBackgroundTimeoutAndReap(t *testing.T) {
+	e := newTestExecTools(t, config.Default())
+	result, err := e.Run(context.Background(), map[string]interface{}{"command_id": "sleep", "background": true, "timeout_ms": float64(100)})
+	if err != nil || !result.OK {
+		t.Fatalf("failed to start a background command: %+v, %v", result, err)
+	}
+	handle := result.Data.(map[string]interface{})["handle"]
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		status, err := e.Status(map[string]interface{}{"handle": handle})
+		if err != nil || !status.OK {
+			t.Fatalf("Status failed: %+v, %v", status, err)
+		}
+		data := status.Data.(map[string]interface{})
+		if data["running"] == false {
+			if data["timed_out"] != true {
+				t.Fatalf("expected the command to time out, got %+v", data)
+			}
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("background command was not killed after its timeout")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+
+	// A finished command is reported once
+	status, _ := e.Status(map[string]interface{}{"handle": handle})
+	if status.OK || status.ErrorCode != types.ErrNotFound {
+		t.Fatalf("expected the handle to be released, got %+v", status)
+	}
+}
+
+func TestBackgroundFinishesWithOrphanedChild(t *testing.T) {
+	cfg := config.Default()
+	// The shell exits at once, but the sleep it leaves behind still holds
+	// its output open
+	cfg.Execution.CustomCommands = []config.CustomCommand{{ID: "orphan", Template: "sleep 10 & exit 0"}}
+	e := newTestExecTools(t, cfg)
+	result, err := e.Run(context.Background(), map[string]interface{}{"command_id": "orphan", "background": true})
+	if err != nil || !result.OK {
+		t.Fatalf("failed to start a background command: %+v, %v", result, err)
+	}
+	handle := result.Data.(map[string]interface{})["handle"]
+
+	deadline := time.Now().Add(execWaitDelay + 3*time.Second)
+	for {
+		status, err := e.Status(map[string]interface{}{"handle": handle})
+		if err != nil || !status.OK {
+			t.Fatalf("Status failed: %+v, %v", status, err)
+		}
+		if status.Data.(map[string]interface{})["running"] == false {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("background command still running after its shell exited")
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
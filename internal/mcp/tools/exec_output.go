@@ -0,0 +1,99 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"sync"
+	"unicode/utf8"
+)
+
+// outputFlushSize is how much of a line is buffered before it is passed on
+// without waiting for the newline.
+const outputFlushSize = 4096
+
+// OutputFunc receives the output of exec.run while the command runs.
+// stream is "stdout" or "stderr". Output arrives in whole lines where
+// possible and never splits a UTF-8 sequence. It is called from one
+// goroutine per stream and must not keep chunk.
+type OutputFunc func(stream string, chunk []byte)
+
+type outputKey struct{}
+
+// WithOutput returns a context under which exec.run passes its output to fn
+// as it is produced, as well as returning it in the result.
+func WithOutput(ctx context.Context, fn OutputFunc) context.Context {
+	return context.WithValue(ctx, outputKey{}, fn)
+}
+
+// OutputFromContext returns the OutputFunc set by WithOutput, or nil.
+func OutputFromContext(ctx context.Context) OutputFunc {
+	fn, _ := ctx.Value(outputKey{}).(OutputFunc)
+	return fn
+}
+
+// outputWriter passes what is written to it on to an OutputFunc, at most
+// limit bytes, so the stream carries no more than the result would.
+type outputWriter struct {
+	stream string
+	fn     OutputFunc
+	limit  int
+
+	mu      sync.Mutex
+	pending []byte
+	written int
+}
+
+func newOutputWriter(stream string, fn OutputFunc, limit int) *outputWriter {
+	return &outputWriter{stream: stream, fn: fn, limit: limit}
+}
+
+func (w *outputWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	n := len(p)
+	if room := w.limit - w.written - len(w.pending); len(p) > room {
+		p = p[:max(room, 0)]
+	}
+	w.pending = append(w.pending, p...)
+
+	end := bytes.LastIndexByte(w.pending, '\n') + 1
+	if end == 0 && len(w.pending) >= outputFlushSize {
+		// No newline in sight: pass on everything but a partial rune
+		end = completeRunes(w.pending)
+	}
+	if end > 0 {
+		w.emit(end)
+	}
+	return n, nil
+}
+
+// Flush passes on output still held back waiting for a newline.
+func (w *outputWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if len(w.pending) > 0 {
+		w.emit(len(w.pending))
+	}
+}
+
+// emit passes on the first n pending bytes.
+func (w *outputWriter) emit(n int) {
+	w.fn(w.stream, w.pending[:n])
+	w.written += n
+	w.pending = append(w.pending[:0], w.pending[n:]...)
+}
+
+// completeRunes returns the length of the longest prefix of p that does
+// not end in the middle of a UTF-8 sequence.
+func completeRunes(p []byte) int {
+	for i := len(p) - 1; i >= 0 && i >= len(p)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(p[i]) {
+			if utf8.FullRune(p[i:]) {
+				return len(p)
+			}
+			return i
+		}
+	}
+	return len(p)
+}
@@ -0,0 +1,54 @@
+package tools
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestOutputWriter(t *testing.T) {
+	tests := []struct {
+		name   string
+		writes []string
+		limit  int
+		want   []string
+	}{
+		{
+			name:   "whole lines",
+			writes: []string{"one\ntw", "o\nthree"},
+			limit:  100,
+			want:   []string{"one\n", "two\n", "three"},
+		},
+		{
+			name:   "long line keeps runes whole",
+			writes: []string{strings.Repeat("a", outputFlushSize-1) + "\xc3", "\xa9\n"},
+			limit:  2 * outputFlushSize,
+			want:   []string{strings.Repeat("a", outputFlushSize-1), "é\n"},
+		},
+		{
+			name:   "limit",
+			writes: []string{"12345\n", "67890\n"},
+			limit:  8,
+			want:   []string{"12345\n", "67"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []string
+			w := newOutputWriter("stdout", func(stream string, chunk []byte) {
+				if stream != "stdout" {
+					t.Errorf("stream = %q", stream)
+				}
+				got = append(got, string(chunk))
+			}, tt.limit)
+			for _, s := range tt.writes {
+				if n, err := w.Write([]byte(s)); n != len(s) || err != nil {
+					t.Fatalf("Write = %d, %v", n, err)
+				}
+			}
+			w.Flush()
+			if strings.Join(got, "|") != strings.Join(tt.want, "|") {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,58 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/tldw/tldw-agent/internal/config"
+	"github.com/tldw/tldw-agent/internal/workspace"
+)
+
+func TestWhichProbesOnlyAllowlistedPrograms(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses shell scripts")
+	}
+	bin := t.TempDir()
+	marker := filepath.Join(t.TempDir(), "ran")
+	for _, name := range []string{"listed", "unlisted"} {
+		script := "#!/bin/sh\necho " + name + " >> " + marker + "\necho " + name + " 1.0\n"
+		if err := os.WriteFile(filepath.Join(bin, name), []byte(script), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	t.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+
+	cfg := config.Default()
+	cfg.Execution.CustomCommands = []config.CustomCommand{{ID: "listed", Template: "listed run"}}
+	session := workspace.NewSession(cfg)
+	if err := session.SetRoot(t.TempDir()); err != nil {
+		t.Fatalf("SetRoot failed: %v", err)
+	}
+	e := NewExecTools(cfg, session)
+
+	which := func(name string) map[string]interface{} {
+		t.Helper()
+		result, err := e.Which(context.Background(), map[string]interface{}{"command": name})
+		if err != nil || !result.OK {
+			t.Fatalf("Which(%s) failed: %+v, %v", name, result, err)
+		}
+		return result.Data.(map[string]interface{})
+	}
+
+	if data := which("listed"); data["version_hint"] != "listed 1.0" {
+		t.Fatalf("expected a version hint for an allowlisted program, got %+v", data)
+	}
+	data := which("unlisted")
+	if data["found"] != true {
+		t.Fatalf("unlisted was not found: %+v", data)
+	}
+	if _, ok := data["version_hint"]; ok {
+		t.Fatalf("got a version hint for a program no command uses: %+v", data)
+	}
+	if ran, _ := os.ReadFile(marker); string(ran) != "listed\n" {
+		t.Fatalf("probes run: %q, want only listed", ran)
+	}
+}
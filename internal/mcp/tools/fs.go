@@ -3,11 +3,20 @@ package tools
 
 import (
 	"bufio"
+	"bytes"
+	"cmp"
+	"context"
+	"errors"
 	"fmt"
 	"io/fs"
 	"os"
+	"os/exec"
+	"path"
 	"path/filepath"
+	"sort"
+	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/tldw/tldw-agent/internal/config"
@@ -31,10 +40,15 @@ func NewFSTools(cfg *config.Config, session *workspace.Session) *FSTools {
 
 // FileEntry represents a file or directory entry.
 type FileEntry struct {
-	Name    string    `json:"name"`
-	Type    string    `json:"type"` // "file" or "directory"
-	Size    int64     `json:"size,omitempty"`
-	ModTime time.Time `json:"mtime,omitempty"`
+	Name       string    `json:"name"`
+	Type       string    `json:"type"` // "file", "directory", or "symlink"
+	Size       int64     `json:"size,omitempty"`
+	ModTime    time.Time `json:"mtime,omitempty"`
+	LinkTarget string    `json:"link_target,omitempty"`
+	// EscapesWorkspace is set for symlinks that resolve outside the workspace.
+	EscapesWorkspace bool `json:"escapes_workspace,omitempty"`
+	// GitStatus is "tracked", "untracked", or "ignored" when requested.
+	GitStatus string `json:"git_status,omitempty"`
 }
 
 // List lists directory contents.
@@ -60,27 +74,74 @@ func (t *FSTools) List(args map[string]interface{}) (*types.ToolResult, error) {
 		maxEntries = int(m)
 	}
 
+	sortBy, _ := args["sort_by"].(string)
+	if sortBy == "" {
+		sortBy = "name"
+	}
+	if sortBy != "name" && sortBy != "size" && sortBy != "mtime" {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("invalid sort_by: %s (expected name, size, or mtime)", sortBy),
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+
+	// Names sort ascending by default; sizes and times largest/newest first
+	descending := sortBy != "name"
+	switch order, _ := args["sort_order"].(string); order {
+	case "":
+	case "asc":
+		descending = false
+	case "desc":
+		descending = true
+	default:
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("invalid sort_order: %s (expected asc or desc)", order),
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+
 	// Resolve path
 	absPath, err := t.session.ResolvePath(path)
 	if err != nil {
 		return &types.ToolResult{
-			OK:    false,
-			Error: err.Error(),
+			OK:        false,
+			Error:     err.Error(),
+			ErrorCode: types.ErrorCodeFor(err),
 		}, nil
 	}
 
-	// List entries
+	// List entries. Asking for git status lists ignored entries too, so
+	// that they can be marked as such.
+	includeGitStatus, _ := args["include_git_status"].(bool)
 	entries := []FileEntry{}
-	truncated := false
 
-	err = t.walkDir(absPath, depth, includeHidden, maxEntries, &entries, &truncated)
+	err = t.walkDir(absPath, depth, includeHidden, !includeGitStatus, &entries)
 	if err != nil {
 		return &types.ToolResult{
-			OK:    false,
-			Error: fmt.Sprintf("failed to list directory: %v", err),
+			OK:        false,
+			Error:     fmt.Sprintf("failed to list directory: %v", err),
+			ErrorCode: types.ErrorCodeFor(err),
 		}, nil
 	}
 
+	// Sort before truncating so the first maxEntries are the ones asked for
+	sortEntries(entries, sortBy, descending)
+	truncated := false
+	if maxEntries >= 0 && len(entries) > maxEntries {
+		entries = entries[:maxEntries]
+		truncated = true
+	}
+
+	if includeGitStatus {
+		if statuses := t.gitStatuses(absPath); statuses != nil {
+			for i := range entries {
+				entries[i].GitStatus = statuses.classify(filepath.ToSlash(entries[i].Name), entries[i].Type == "directory")
+			}
+		}
+	}
+
 	return &types.ToolResult{
 		OK: true,
 		Data: map[string]interface{}{
@@ -91,8 +152,10 @@ func (t *FSTools) List(args map[string]interface{}) (*types.ToolResult, error) {
 	}, nil
 }
 
-// walkDir recursively lists directory contents.
-func (t *FSTools) walkDir(root string, maxDepth int, includeHidden bool, maxEntries int, entries *[]FileEntry, truncated *bool) error {
+// walkDir recursively lists directory contents. With skipIgnored set,
+// entries excluded by .gitignore are left out when respect_gitignore is on.
+func (t *FSTools) walkDir(root string, maxDepth int, includeHidden, skipIgnored bool, entries *[]FileEntry) error {
+	ignores := newIgnoreCache(t.session)
 	return filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return nil // Skip entries we can't access
@@ -103,12 +166,6 @@ func (t *FSTools) walkDir(root string, maxDepth int, includeHidden bool, maxEntr
 			return nil
 		}
 
-		// Check max entries
-		if len(*entries) >= maxEntries {
-			*truncated = true
-			return filepath.SkipAll
-		}
-
 		// Calculate depth
 		rel, _ := filepath.Rel(root, path)
 		depth := strings.Count(rel, string(filepath.Separator)) + 1
@@ -130,6 +187,14 @@ func (t *FSTools) walkDir(root string, maxDepth int, includeHidden bool, maxEntr
 			return nil
 		}
 
+		// Skip entries excluded by .gitignore
+		if skipIgnored && ignores.ignored(path, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
 		// Get file info
 		info, err := d.Info()
 		if err != nil {
@@ -137,28 +202,310 @@ func (t *FSTools) walkDir(root string, maxDepth int, includeHidden bool, maxEntr
 		}
 
 		entryType := "file"
+		size := info.Size()
 		if d.IsDir() {
+			// A directory's stat size is filesystem-specific, not its contents
 			entryType = "directory"
+			size = 0
 		}
 
-		*entries = append(*entries, FileEntry{
+		entry := FileEntry{
 			Name:    rel,
 			Type:    entryType,
-			Size:    info.Size(),
+			Size:    size,
 			ModTime: info.ModTime(),
-		})
+		}
 
+		// WalkDir does not follow links, so d describes the link itself
+		if d.Type()&fs.ModeSymlink != 0 {
+			entry.Type = "symlink"
+			if target, err := os.Readlink(path); err == nil {
+				resolved, _ := resolveLink(path, target)
+				entry.LinkTarget = target
+				entry.EscapesWorkspace = !t.session.Contains(resolved)
+			}
+		}
+
+		*entries = append(*entries, entry)
+
+		return nil
+	})
+}
+
+// gitFileStatuses holds the tracked and ignored paths under a directory,
+// relative to it and slash-separated. Ignored directories end with "/".
+type gitFileStatuses struct {
+	tracked     map[string]bool
+	trackedDirs map[string]bool
+	ignored     map[string]bool
+}
+
+// gitStatuses asks git which files under absDir are tracked or ignored. It
+// returns nil if absDir is not in a git repository or git is unavailable.
+func (t *FSTools) gitStatuses(absDir string) *gitFileStatuses {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Duration(t.config.Execution.ReadTimeoutMs)*time.Millisecond)
+	defer cancel()
+
+	lsFiles := func(args ...string) ([]string, error) {
+		cmd := exec.CommandContext(ctx, "git", append([]string{"ls-files", "-z"}, args...)...)
+		cmd.Dir = absDir
+		out, err := cmd.Output()
+		if err != nil {
+			return nil, err
+		}
+		var names []string
+		for _, name := range strings.Split(string(out), "\x00") {
+			if name != "" {
+				names = append(names, name)
+			}
+		}
+		return names, nil
+	}
+
+	tracked, err := lsFiles("--cached")
+	if err != nil {
 		return nil
+	}
+	ignored, err := lsFiles("--others", "--ignored", "--exclude-standard", "--directory")
+	if err != nil {
+		return nil
+	}
+
+	s := &gitFileStatuses{
+		tracked:     make(map[string]bool, len(tracked)),
+		trackedDirs: make(map[string]bool),
+		ignored:     make(map[string]bool, len(ignored)),
+	}
+	for _, name := range tracked {
+		s.tracked[name] = true
+		for dir := path.Dir(name); dir != "."; dir = path.Dir(dir) {
+			if s.trackedDirs[dir] {
+				break
+			}
+			s.trackedDirs[dir] = true
+		}
+	}
+	for _, name := range ignored {
+		s.ignored[name] = true
+	}
+	return s
+}
+
+// classify returns the git status of a slash-separated path relative to
+// the listed directory.
+func (s *gitFileStatuses) classify(rel string, isDir bool) string {
+	if isDir && s.trackedDirs[rel] || !isDir && s.tracked[rel] {
+		return "tracked"
+	}
+	if isDir && s.ignored[rel+"/"] || !isDir && s.ignored[rel] {
+		return "ignored"
+	}
+	// git reports a wholly ignored directory once, not its contents
+	for dir := path.Dir(rel); dir != "."; dir = path.Dir(dir) {
+		if s.ignored[dir+"/"] {
+			return "ignored"
+		}
+	}
+	return "untracked"
+}
+
+// sortEntries orders entries by name, size, or mtime. Directories have no
+// meaningful size, so when sorting by size they always come first.
+func sortEntries(entries []FileEntry, sortBy string, descending bool) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		a, b := entries[i], entries[j]
+		if sortBy == "size" && (a.Type == "directory") != (b.Type == "directory") {
+			return a.Type == "directory"
+		}
+
+		var order int
+		switch sortBy {
+		case "size":
+			order = cmp.Compare(a.Size, b.Size)
+		case "mtime":
+			order = a.ModTime.Compare(b.ModTime)
+		}
+		if order == 0 {
+			// Ties, and name sorting, fall back to the path
+			order = strings.Compare(a.Name, b.Name)
+			if sortBy != "name" {
+				return order < 0
+			}
+		}
+		if descending {
+			return order > 0
+		}
+		return order < 0
 	})
 }
 
+// TreeNode is a node in a hierarchical directory listing.
+type TreeNode struct {
+	Name     string      `json:"name"`
+	Type     string      `json:"type"` // "file" or "directory"
+	Children []*TreeNode `json:"children,omitempty"`
+}
+
+// Tree returns a nested view of a directory.
+func (t *FSTools) Tree(args map[string]interface{}) (*types.ToolResult, error) {
+	// Parse arguments
+	path, _ := args["path"].(string)
+	if path == "" {
+		path = "."
+	}
+
+	depth := 3
+	if d, ok := args["depth"].(float64); ok {
+		depth = int(d)
+	}
+
+	includeHidden := false
+	if h, ok := args["include_hidden"].(bool); ok {
+		includeHidden = h
+	}
+
+	maxNodes := 500
+	if m, ok := args["max_nodes"].(float64); ok {
+		maxNodes = int(m)
+	}
+
+	// Resolve path
+	absPath, err := t.session.ResolvePath(path)
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     err.Error(),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("failed to access directory: %v", err),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+	if !info.IsDir() {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     "path is not a directory",
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+
+	root := &TreeNode{Name: filepath.Base(absPath), Type: "directory"}
+	nodes := 1
+	truncated := false
+	t.buildTree(root, absPath, depth, includeHidden, maxNodes, &nodes, &truncated)
+
+	return &types.ToolResult{
+		OK: true,
+		Data: map[string]interface{}{
+			"path":      path,
+			"tree":      root,
+			"nodes":     nodes,
+			"truncated": truncated,
+		},
+	}, nil
+}
+
+// buildTree fills in the children of node, descending at most depth levels.
+func (t *FSTools) buildTree(node *TreeNode, dir string, depth int, includeHidden bool, maxNodes int, nodes *int, truncated *bool) {
+	if depth <= 0 {
+		return
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return // Skip directories we can't read
+	}
+
+	for _, entry := range entries {
+		if *nodes >= maxNodes {
+			*truncated = true
+			return
+		}
+
+		name := entry.Name()
+		if !includeHidden && strings.HasPrefix(name, ".") {
+			continue
+		}
+
+		path := filepath.Join(dir, name)
+		if t.isIgnored(path, entry.IsDir()) {
+			continue
+		}
+
+		child := &TreeNode{Name: name, Type: "file"}
+		*nodes++
+		if entry.IsDir() {
+			child.Type = "directory"
+			t.buildTree(child, path, depth-1, includeHidden, maxNodes, nodes, truncated)
+		}
+		node.Children = append(node.Children, child)
+	}
+}
+
+// isIgnored reports whether an absolute path is excluded by the workspace .gitignore.
+func (t *FSTools) isIgnored(path string, isDir bool) bool {
+	return isGitIgnored(t.session, path, isDir)
+}
+
+// ignoreCache memoizes directory .gitignore checks for the duration of a
+// single walk, since each check may read the .gitignore files between the
+// root and the directory.
+type ignoreCache struct {
+	session *workspace.Session
+	dirs    map[string]bool
+}
+
+func newIgnoreCache(session *workspace.Session) *ignoreCache {
+	return &ignoreCache{
+		session: session,
+		dirs:    make(map[string]bool),
+	}
+}
+
+// ignored reports whether an absolute path is excluded by .gitignore. Files
+// inside a directory already known to be ignored are ignored too.
+func (c *ignoreCache) ignored(path string, isDir bool) bool {
+	if !isDir {
+		if c.dirs[filepath.Dir(path)] {
+			return true
+		}
+		return isGitIgnored(c.session, path, false)
+	}
+
+	if ignored, ok := c.dirs[path]; ok {
+		return ignored
+	}
+	ignored := isGitIgnored(c.session, path, true)
+	c.dirs[path] = ignored
+	return ignored
+}
+
+// isGitIgnored checks an absolute path against the session's ignore rules.
+func isGitIgnored(session *workspace.Session, path string, isDir bool) bool {
+	rel, err := filepath.Rel(session.Root(), path)
+	if err != nil || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	if isDir {
+		rel += "/"
+	}
+	return session.IsGitIgnored(rel)
+}
+
 // Read reads file contents.
 func (t *FSTools) Read(args map[string]interface{}) (*types.ToolResult, error) {
 	path, ok := args["path"].(string)
 	if !ok || path == "" {
 		return &types.ToolResult{
-			OK:    false,
-			Error: "path is required",
+			OK:        false,
+			Error:     "path is required",
+			ErrorCode: types.ErrInvalidArg,
 		}, nil
 	}
 
@@ -166,8 +513,9 @@ func (t *FSTools) Read(args map[string]interface{}) (*types.ToolResult, error) {
 	absPath, err := t.session.ResolvePath(path)
 	if err != nil {
 		return &types.ToolResult{
-			OK:    false,
-			Error: err.Error(),
+			OK:        false,
+			Error:     err.Error(),
+			ErrorCode: types.ErrorCodeFor(err),
 		}, nil
 	}
 
@@ -175,22 +523,25 @@ func (t *FSTools) Read(args map[string]interface{}) (*types.ToolResult, error) {
 	info, err := os.Stat(absPath)
 	if err != nil {
 		return &types.ToolResult{
-			OK:    false,
-			Error: fmt.Sprintf("failed to stat file: %v", err),
+			OK:        false,
+			Error:     fmt.Sprintf("failed to stat file: %v", err),
+			ErrorCode: types.ErrorCodeFor(err),
 		}, nil
 	}
 
 	if info.IsDir() {
 		return &types.ToolResult{
-			OK:    false,
-			Error: "path is a directory, not a file",
+			OK:        false,
+			Error:     "path is a directory, not a file",
+			ErrorCode: types.ErrInvalidArg,
 		}, nil
 	}
 
 	if info.Size() > t.config.Workspace.MaxFileSizeBytes {
 		return &types.ToolResult{
-			OK:    false,
-			Error: fmt.Sprintf("file too large: %d bytes (max %d)", info.Size(), t.config.Workspace.MaxFileSizeBytes),
+			OK:        false,
+			Error:     fmt.Sprintf("file too large: %d bytes (max %d)", info.Size(), t.config.Workspace.MaxFileSizeBytes),
+			ErrorCode: types.ErrTooLarge,
 		}, nil
 	}
 
@@ -205,19 +556,44 @@ func (t *FSTools) Read(args map[string]interface{}) (*types.ToolResult, error) {
 	}
 
 	// Read file
-	file, err := os.Open(absPath)
+	raw, mayBePartial, err := readStable(absPath)
 	if err != nil {
 		return &types.ToolResult{
-			OK:    false,
-			Error: fmt.Sprintf("failed to open file: %v", err),
+			OK:        false,
+			Error:     fmt.Sprintf("failed to read file: %v", err),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+
+	// Transcode to UTF-8
+	forceEncoding, _ := args["force_encoding"].(string)
+	data, encoding, err := decodeText(raw, forceEncoding)
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     err.Error(),
+			ErrorCode: types.ErrorCodeFor(err),
 		}, nil
 	}
-	defer file.Close()
 
 	var lines []string
-	scanner := bufio.NewScanner(file)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
 	lineNum := 0
 
+	// Count line terminators as the scanner strips them
+	var crlfCount, lfCount int
+	scanner.Split(func(data []byte, atEOF bool) (int, []byte, error) {
+		advance, token, err := bufio.ScanLines(data, atEOF)
+		if advance > 0 && data[advance-1] == '\n' {
+			if advance > 1 && data[advance-2] == '\r' {
+				crlfCount++
+			} else {
+				lfCount++
+			}
+		}
+		return advance, token, err
+	})
+
 	for scanner.Scan() {
 		lineNum++
 		if startLine > 0 && lineNum < startLine {
@@ -231,39 +607,197 @@ func (t *FSTools) Read(args map[string]interface{}) (*types.ToolResult, error) {
 
 	if err := scanner.Err(); err != nil {
 		return &types.ToolResult{
-			OK:    false,
-			Error: fmt.Sprintf("failed to read file: %v", err),
+			OK:        false,
+			Error:     fmt.Sprintf("failed to read file: %v", err),
+			ErrorCode: types.ErrorCodeFor(err),
 		}, nil
 	}
 
+	lineEnding := "lf"
+	if crlfCount > lfCount {
+		lineEnding = "crlf"
+	}
+
+	trailingNewline := len(data) > 0 && data[len(data)-1] == '\n'
+
 	content := strings.Join(lines, "\n")
+	if annotate, _ := args["annotate_lines"].(bool); annotate && len(lines) > 0 {
+		content = annotateLines(lines, max(startLine, 1))
+	}
+	t.session.RecordAccess("read", absPath, int64(len(content)))
+	t.session.TouchRecent(absPath, "read")
+	if crlfCount+lfCount > 0 {
+		t.session.SetLineEnding(absPath, lineEnding)
+	}
+
+	var warnings []string
+	if limit := t.config.Workspace.MaxFileSizeBytes; info.Size() > limit/2 {
+		warnings = append(warnings, fmt.Sprintf("file is %d bytes, more than half the %d byte limit; prefer start_line and end_line", info.Size(), limit))
+	}
+
+	return &types.ToolResult{
+		OK:       true,
+		Warnings: warnings,
+		Data: map[string]interface{}{
+			"path":              path,
+			"content":           content,
+			"line_count":        lineNum,
+			"size":              info.Size(),
+			"line_ending":       lineEnding,
+			"trailing_newline":  trailingNewline,
+			"detected_encoding": encoding,
+			"may_be_partial":    mayBePartial,
+		},
+	}, nil
+}
+
+// readStableRetries is the number of times readStable rereads a file that
+// changed while it was being read.
+const readStableRetries = 3
+
+// readStable reads a file, retrying with backoff (10ms, 20ms, 40ms) if its
+// size or inode changes during the read. If it is still changing after the
+// last retry, the final read is returned with mayBePartial set.
+func readStable(path string) (data []byte, mayBePartial bool, err error) {
+	for attempt := 0; ; attempt++ {
+		before, err := os.Stat(path)
+		if err != nil {
+			return nil, false, err
+		}
+		data, err = os.ReadFile(path)
+		if err != nil {
+			return nil, false, err
+		}
+		after, err := os.Stat(path)
+		if err != nil {
+			return nil, false, err
+		}
+
+		if os.SameFile(before, after) && before.Size() == after.Size() {
+			return data, false, nil
+		}
+		if attempt == readStableRetries {
+			return data, true, nil
+		}
+		time.Sleep((10 * time.Millisecond) << attempt)
+	}
+}
+
+// annotateLines prefixes each line with its line number, counting from
+// first, right-aligned to the width of the largest number.
+func annotateLines(lines []string, first int) string {
+	width := len(strconv.Itoa(first + len(lines) - 1))
+
+	var sb strings.Builder
+	for i, line := range lines {
+		if i > 0 {
+			sb.WriteByte('\n')
+		}
+		fmt.Fprintf(&sb, "%*d | %s", width, first+i, line)
+	}
+	return sb.String()
+}
+
+// maxConcurrentReads bounds the goroutines used by ReadMultiple.
+const maxConcurrentReads = 8
+
+// ReadMultiple reads several files concurrently. Each entry of paths is
+// either a path or an object with "path" and optional "start_line" and
+// "end_line", which override the top-level line range for that file.
+func (t *FSTools) ReadMultiple(args map[string]interface{}) (*types.ToolResult, error) {
+	paths, ok := args["paths"].([]interface{})
+	if !ok || len(paths) == 0 {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     "paths is required",
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+
+	// Build the fs.read arguments for each file
+	reads := make([]map[string]interface{}, len(paths))
+	for i, p := range paths {
+		readArgs := map[string]interface{}{
+			"start_line": args["start_line"],
+			"end_line":   args["end_line"],
+		}
+		switch v := p.(type) {
+		case string:
+			readArgs["path"] = v
+		case map[string]interface{}:
+			for _, key := range []string{"path", "start_line", "end_line"} {
+				if value, ok := v[key]; ok {
+					readArgs[key] = value
+				}
+			}
+		}
+		reads[i] = readArgs
+	}
+
+	files := make([]map[string]interface{}, len(reads))
+	sem := make(chan struct{}, maxConcurrentReads)
+	var wg sync.WaitGroup
+
+	for i, readArgs := range reads {
+		wg.Add(1)
+		go func(i int, readArgs map[string]interface{}) {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+
+			path, _ := readArgs["path"].(string)
+			file := map[string]interface{}{"path": path}
+			result, _ := t.Read(readArgs)
+			if result.OK {
+				data := result.Data.(map[string]interface{})
+				file["content"] = data["content"]
+			} else {
+				file["error"] = result.Error
+			}
+			files[i] = file
+		}(i, readArgs)
+	}
+	wg.Wait()
 
 	return &types.ToolResult{
 		OK: true,
 		Data: map[string]interface{}{
-			"path":       path,
-			"content":    content,
-			"line_count": lineNum,
-			"size":       info.Size(),
+			"files": files,
 		},
 	}, nil
 }
 
-// Write writes content to a file.
-func (t *FSTools) Write(args map[string]interface{}) (*types.ToolResult, error) {
+// diskQuotaResult returns the failed result for an error from
+// Session.CheckDiskQuota.
+func diskQuotaResult(err error) *types.ToolResult {
+	code := types.ErrorCodeFor(err)
+	if errors.Is(err, workspace.ErrDiskQuota) {
+		code = types.ErrTooLarge
+	}
+	return &types.ToolResult{
+		OK:        false,
+		Error:     err.Error(),
+		ErrorCode: code,
+	}
+}
+
+// Write writes content to a file. Nothing is written once ctx is done.
+func (t *FSTools) Write(ctx context.Context, args map[string]interface{}) (*types.ToolResult, error) {
 	path, ok := args["path"].(string)
 	if !ok || path == "" {
 		return &types.ToolResult{
-			OK:    false,
-			Error: "path is required",
+			OK:        false,
+			Error:     "path is required",
+			ErrorCode: types.ErrInvalidArg,
 		}, nil
 	}
 
 	content, ok := args["content"].(string)
 	if !ok {
 		return &types.ToolResult{
-			OK:    false,
-			Error: "content is required",
+			OK:        false,
+			Error:     "content is required",
+			ErrorCode: types.ErrInvalidArg,
 		}, nil
 	}
 
@@ -271,53 +805,131 @@ func (t *FSTools) Write(args map[string]interface{}) (*types.ToolResult, error)
 	absPath, err := t.session.ResolvePath(path)
 	if err != nil {
 		return &types.ToolResult{
-			OK:    false,
-			Error: err.Error(),
+			OK:        false,
+			Error:     err.Error(),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+
+	// End non-empty content with a newline unless told not to
+	trailingNewline := true
+	if tn, ok := args["trailing_newline"].(bool); ok {
+		trailingNewline = tn
+	}
+	if trailingNewline && content != "" && !strings.HasSuffix(content, "\n") {
+		content += "\n"
+	}
+
+	// Convert line endings: explicitly requested, else those of the file
+	// when it was last read, else leave the content as given
+	lineEnding, _ := args["line_ending"].(string)
+	if lineEnding == "" {
+		lineEnding = t.session.LineEnding(absPath)
+	}
+	switch lineEnding {
+	case "":
+	case "lf":
+		content = strings.ReplaceAll(content, "\r\n", "\n")
+	case "crlf":
+		content = strings.ReplaceAll(strings.ReplaceAll(content, "\r\n", "\n"), "\n", "\r\n")
+	default:
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("invalid line_ending %q (want lf or crlf)", lineEnding),
+			ErrorCode: types.ErrInvalidArg,
 		}, nil
 	}
 
+	// Enforce the workspace disk usage limit; overwriting a file frees its
+	// current size
+	if err := t.session.CheckDiskQuota(int64(len(content)) - regularFileSize(absPath)); err != nil {
+		return diskQuotaResult(err), nil
+	}
+
+	// The disk usage walk can be slow; don't write if the call was cancelled
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
 	// Ensure parent directory exists
 	dir := filepath.Dir(absPath)
 	if err := os.MkdirAll(dir, 0755); err != nil {
 		return &types.ToolResult{
-			OK:    false,
-			Error: fmt.Sprintf("failed to create parent directory: %v", err),
+			OK:        false,
+			Error:     fmt.Sprintf("failed to create parent directory: %v", err),
+			ErrorCode: types.ErrorCodeFor(err),
 		}, nil
 	}
 
 	// Write file
 	if err := os.WriteFile(absPath, []byte(content), 0644); err != nil {
 		return &types.ToolResult{
-			OK:    false,
-			Error: fmt.Sprintf("failed to write file: %v", err),
+			OK:        false,
+			Error:     fmt.Sprintf("failed to write file: %v", err),
+			ErrorCode: types.ErrorCodeFor(err),
 		}, nil
 	}
+	t.session.RecordAccess("write", absPath, int64(len(content)))
+	t.session.TouchRecent(absPath, "write")
+	if lineEnding != "" {
+		t.session.SetLineEnding(absPath, lineEnding)
+	}
+
+	// Count lines as fs.read does, without an empty line after a final newline
+	lineCount := strings.Count(content, "\n")
+	if content != "" && !strings.HasSuffix(content, "\n") {
+		lineCount++
+	}
 
 	return &types.ToolResult{
 		OK: true,
 		Data: map[string]interface{}{
-			"path":       path,
-			"bytes":      len(content),
-			"line_count": strings.Count(content, "\n") + 1,
+			"path":        path,
+			"bytes":       len(content),
+			"line_count":  lineCount,
+			"line_ending": lineEnding,
 		},
 	}, nil
 }
 
-// ApplyPatch applies a unified diff patch.
-func (t *FSTools) ApplyPatch(args map[string]interface{}) (*types.ToolResult, error) {
-	patch, ok := args["patch"].(string)
-	if !ok || patch == "" {
+// DiskUsage reports the current size of the workspace and the space left
+// on the filesystem it lives on.
+func (t *FSTools) DiskUsage(args map[string]interface{}) (*types.ToolResult, error) {
+	usage, err := t.session.Usage(t.config.Workspace.DiskUsageSkipDirs)
+	if err != nil {
 		return &types.ToolResult{
-			OK:    false,
-			Error: "patch is required",
+			OK:        false,
+			Error:     err.Error(),
+			ErrorCode: types.ErrorCodeFor(err),
 		}, nil
 	}
 
-	// Parse the unified diff
-	// For now, return a placeholder - full implementation in Phase 2
+	data := map[string]interface{}{
+		"used_bytes":  usage.Bytes,
+		"file_count":  usage.Files,
+		"dir_count":   usage.Dirs,
+		"limit_bytes": t.config.Workspace.MaxDiskUsageBytes,
+	}
+	if available, total, err := filesystemSpace(t.session.Root()); err == nil {
+		data["available_bytes"] = available
+		data["total_bytes"] = total
+	}
+
 	return &types.ToolResult{
-		OK:    false,
-		Error: "fs.apply_patch not yet fully implemented",
+		OK:   true,
+		Data: data,
+	}, nil
+}
+
+// AuditLog returns the file operations performed in this session.
+func (t *FSTools) AuditLog(args map[string]interface{}) (*types.ToolResult, error) {
+	entries := t.session.AuditLog()
+	return &types.ToolResult{
+		OK: true,
+		Data: map[string]interface{}{
+			"entries": entries,
+			"count":   len(entries),
+		},
 	}, nil
 }
 
@@ -326,8 +938,9 @@ func (t *FSTools) Mkdir(args map[string]interface{}) (*types.ToolResult, error)
 	path, ok := args["path"].(string)
 	if !ok || path == "" {
 		return &types.ToolResult{
-			OK:    false,
-			Error: "path is required",
+			OK:        false,
+			Error:     "path is required",
+			ErrorCode: types.ErrInvalidArg,
 		}, nil
 	}
 
@@ -335,16 +948,18 @@ func (t *FSTools) Mkdir(args map[string]interface{}) (*types.ToolResult, error)
 	absPath, err := t.session.ResolvePath(path)
 	if err != nil {
 		return &types.ToolResult{
-			OK:    false,
-			Error: err.Error(),
+			OK:        false,
+			Error:     err.Error(),
+			ErrorCode: types.ErrorCodeFor(err),
 		}, nil
 	}
 
 	// Create directory
 	if err := os.MkdirAll(absPath, 0755); err != nil {
 		return &types.ToolResult{
-			OK:    false,
-			Error: fmt.Sprintf("failed to create directory: %v", err),
+			OK:        false,
+			Error:     fmt.Sprintf("failed to create directory: %v", err),
+			ErrorCode: types.ErrorCodeFor(err),
 		}, nil
 	}
 
@@ -362,8 +977,9 @@ func (t *FSTools) Delete(args map[string]interface{}) (*types.ToolResult, error)
 	path, ok := args["path"].(string)
 	if !ok || path == "" {
 		return &types.ToolResult{
-			OK:    false,
-			Error: "path is required",
+			OK:        false,
+			Error:     "path is required",
+			ErrorCode: types.ErrInvalidArg,
 		}, nil
 	}
 
@@ -376,8 +992,9 @@ func (t *FSTools) Delete(args map[string]interface{}) (*types.ToolResult, error)
 	absPath, err := t.session.ResolvePath(path)
 	if err != nil {
 		return &types.ToolResult{
-			OK:    false,
-			Error: err.Error(),
+			OK:        false,
+			Error:     err.Error(),
+			ErrorCode: types.ErrorCodeFor(err),
 		}, nil
 	}
 
@@ -385,8 +1002,9 @@ func (t *FSTools) Delete(args map[string]interface{}) (*types.ToolResult, error)
 	info, err := os.Stat(absPath)
 	if err != nil {
 		return &types.ToolResult{
-			OK:    false,
-			Error: fmt.Sprintf("path does not exist: %v", err),
+			OK:        false,
+			Error:     fmt.Sprintf("path does not exist: %v", err),
+			ErrorCode: types.ErrNotFound,
 		}, nil
 	}
 
@@ -394,19 +1012,23 @@ func (t *FSTools) Delete(args map[string]interface{}) (*types.ToolResult, error)
 	if info.IsDir() && recursive {
 		if err := os.RemoveAll(absPath); err != nil {
 			return &types.ToolResult{
-				OK:    false,
-				Error: fmt.Sprintf("failed to delete directory: %v", err),
+				OK:        false,
+				Error:     fmt.Sprintf("failed to delete directory: %v", err),
+				ErrorCode: types.ErrorCodeFor(err),
 			}, nil
 		}
 	} else {
 		if err := os.Remove(absPath); err != nil {
 			return &types.ToolResult{
-				OK:    false,
-				Error: fmt.Sprintf("failed to delete: %v", err),
+				OK:        false,
+				Error:     fmt.Sprintf("failed to delete: %v", err),
+				ErrorCode: types.ErrorCodeFor(err),
 			}, nil
 		}
 	}
 
+	t.session.RecordAccess("delete", absPath, info.Size())
+
 	return &types.ToolResult{
 		OK: true,
 		Data: map[string]interface{}{
@@ -415,3 +1037,394 @@ func (t *FSTools) Delete(args map[string]interface{}) (*types.ToolResult, error)
 		},
 	}, nil
 }
+
+// Chmod changes the permission bits of a file or directory. mode is either
+// octal ("0755") or symbolic ("u+x", "go-w", "a=r"). Setuid, setgid, and
+// sticky bits are rejected.
+func (t *FSTools) Chmod(args map[string]interface{}) (*types.ToolResult, error) {
+	path, ok := args["path"].(string)
+	if !ok || path == "" {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     "path is required",
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+
+	modeArg, _ := args["mode"].(string)
+	if modeArg == "" {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     "mode is required",
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+
+	// Resolve path
+	absPath, err := t.session.ResolvePath(path)
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     err.Error(),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("failed to stat file: %v", err),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+
+	oldMode := info.Mode().Perm()
+	newMode, err := parseFileMode(modeArg, oldMode, info.IsDir())
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     err.Error(),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+
+	if err := os.Chmod(absPath, newMode); err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("failed to change mode: %v", err),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+
+	return &types.ToolResult{
+		OK: true,
+		Data: map[string]interface{}{
+			"path":     path,
+			"old_mode": fmt.Sprintf("%04o", oldMode),
+			"new_mode": fmt.Sprintf("%04o", newMode),
+		},
+	}, nil
+}
+
+// parseFileMode applies an octal or comma-separated symbolic mode to the
+// permission bits current. It only produces permission bits, never setuid,
+// setgid, or sticky.
+func parseFileMode(spec string, current os.FileMode, isDir bool) (os.FileMode, error) {
+	if spec[0] >= '0' && spec[0] <= '9' {
+		octal, err := strconv.ParseUint(spec, 8, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid mode: %q", spec)
+		}
+		if octal&^0777 != 0 {
+			return 0, fmt.Errorf("mode %q sets setuid, setgid, or sticky bits, which are not allowed", spec)
+		}
+		return os.FileMode(octal), nil
+	}
+
+	mode := current
+	for _, clause := range strings.Split(spec, ",") {
+		// Who: any of u, g, o, a; none means all
+		i := 0
+		var who os.FileMode
+		for ; i < len(clause) && strings.IndexByte("ugoa", clause[i]) >= 0; i++ {
+			switch clause[i] {
+			case 'u':
+				who |= 0700
+			case 'g':
+				who |= 0070
+			case 'o':
+				who |= 0007
+			case 'a':
+				who |= 0777
+			}
+		}
+		if who == 0 {
+			who = 0777
+		}
+
+		if i == len(clause) || strings.IndexByte("+-=", clause[i]) < 0 {
+			return 0, fmt.Errorf("invalid mode: %q", spec)
+		}
+		op := clause[i]
+
+		// Permissions: r, w, x, and X (execute only for directories or
+		// files already executable by someone)
+		var perm os.FileMode
+		for _, c := range clause[i+1:] {
+			switch c {
+			case 'r':
+				perm |= 0444
+			case 'w':
+				perm |= 0222
+			case 'x':
+				perm |= 0111
+			case 'X':
+				if isDir || current&0111 != 0 {
+					perm |= 0111
+				}
+			case 's', 't':
+				return 0, fmt.Errorf("mode %q sets setuid, setgid, or sticky bits, which are not allowed", spec)
+			default:
+				return 0, fmt.Errorf("invalid mode: %q", spec)
+			}
+		}
+		perm &= who
+
+		switch op {
+		case '+':
+			mode |= perm
+		case '-':
+			mode &^= perm
+		case '=':
+			mode = mode&^who | perm
+		}
+	}
+	return mode, nil
+}
+
+// Link creates a symbolic link at link pointing to target. A relative
+// target is interpreted relative to the link's directory, as the OS does,
+// and both paths must be inside the workspace.
+func (t *FSTools) Link(args map[string]interface{}) (*types.ToolResult, error) {
+	target, _ := args["target"].(string)
+	link, _ := args["link"].(string)
+	if target == "" || link == "" {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     "target and link are required",
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+
+	// Resolve paths
+	absLink, err := t.session.ResolvePath(link)
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     err.Error(),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+	absTarget := target
+	if !filepath.IsAbs(target) {
+		absTarget = filepath.Join(filepath.Dir(absLink), target)
+	}
+	if _, err := t.session.ResolvePath(absTarget); err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("invalid target: %v", err),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+
+	if _, err := os.Lstat(absLink); err == nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     "link path already exists",
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+
+	if err := os.Symlink(target, absLink); err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("failed to create link: %v", err),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+	t.session.RecordAccess("write", absLink, 0)
+
+	return &types.ToolResult{
+		OK: true,
+		Data: map[string]interface{}{
+			"link":   link,
+			"target": target,
+		},
+	}, nil
+}
+
+// Readlink returns the value of a symbolic link and the absolute path it
+// resolves to. Links that point outside the workspace are reported with
+// escapes_workspace set rather than rejected.
+func (t *FSTools) Readlink(args map[string]interface{}) (*types.ToolResult, error) {
+	path, ok := args["path"].(string)
+	if !ok || path == "" {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     "path is required",
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+
+	// Resolve the link's directory rather than the link, which may point
+	// outside the workspace
+	absDir, err := t.session.ResolvePath(filepath.Dir(path))
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     err.Error(),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+	absPath := filepath.Join(absDir, filepath.Base(path))
+
+	if info, err := os.Lstat(absPath); err == nil && info.Mode()&os.ModeSymlink == 0 {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     "path is not a symbolic link",
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+
+	target, err := os.Readlink(absPath)
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("failed to read link: %v", err),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+
+	resolved, exists := resolveLink(absPath, target)
+
+	return &types.ToolResult{
+		OK: true,
+		Data: map[string]interface{}{
+			"path":              path,
+			"target":            target,
+			"resolved":          resolved,
+			"exists":            exists,
+			"escapes_workspace": !t.session.Contains(resolved),
+		},
+	}, nil
+}
+
+// Stat returns metadata for a file or directory.
+func (t *FSTools) Stat(args map[string]interface{}) (*types.ToolResult, error) {
+	path, ok := args["path"].(string)
+	if !ok || path == "" {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     "path is required",
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+
+	absPath, err := t.session.ResolvePath(path)
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     err.Error(),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("failed to stat: %v", err),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+
+	fileType := "file"
+	size := info.Size()
+	if info.IsDir() {
+		fileType = "directory"
+		size = 0
+	}
+
+	return &types.ToolResult{
+		OK: true,
+		Data: map[string]interface{}{
+			"path":  path,
+			"type":  fileType,
+			"size":  size,
+			"mtime": info.ModTime(),
+			"mode":  fmt.Sprintf("%04o", info.Mode().Perm()),
+		},
+	}, nil
+}
+
+// resolveLink returns the absolute path the symlink at absPath, whose
+// content is target, finally points to, and whether that path exists.
+// Dangling links resolve lexically.
+func resolveLink(absPath, target string) (string, bool) {
+	if real, err := filepath.EvalSymlinks(absPath); err == nil {
+		return real, true
+	}
+	if !filepath.IsAbs(target) {
+		target = filepath.Join(filepath.Dir(absPath), target)
+	}
+	return filepath.Clean(target), false
+}
+
+// Touch creates an empty file, or updates the modification time of an
+// existing one to mtime (RFC 3339) or the current time.
+func (t *FSTools) Touch(args map[string]interface{}) (*types.ToolResult, error) {
+	path, ok := args["path"].(string)
+	if !ok || path == "" {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     "path is required",
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+
+	mtime := time.Now()
+	if m, ok := args["mtime"].(string); ok && m != "" {
+		parsed, err := time.Parse(time.RFC3339, m)
+		if err != nil {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     fmt.Sprintf("invalid mtime: %v", err),
+				ErrorCode: types.ErrInvalidArg,
+			}, nil
+		}
+		mtime = parsed
+	}
+
+	// Resolve path
+	absPath, err := t.session.ResolvePath(path)
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     err.Error(),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+
+	created := false
+	if _, err := os.Stat(absPath); os.IsNotExist(err) {
+		file, err := os.OpenFile(absPath, os.O_CREATE, 0644)
+		if err != nil {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     fmt.Sprintf("failed to create file: %v", err),
+				ErrorCode: types.ErrorCodeFor(err),
+			}, nil
+		}
+		file.Close()
+		created = true
+		t.session.RecordAccess("write", absPath, 0)
+	}
+
+	if err := os.Chtimes(absPath, mtime, mtime); err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("failed to set modification time: %v", err),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+
+	return &types.ToolResult{
+		OK: true,
+		Data: map[string]interface{}{
+			"path":    path,
+			"created": created,
+			"mtime":   mtime.Format(time.RFC3339),
+		},
+	}, nil
+}
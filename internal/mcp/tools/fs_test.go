@@ -0,0 +1,107 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tldw/tldw-agent/internal/config"
+	"github.com/tldw/tldw-agent/internal/types"
+)
+
+func TestDiskQuota(t *testing.T) {
+	cfg := config.Default()
+	cfg.Workspace.MaxDiskUsageBytes = 100
+	fsTools, root := newTestFSTools(t, cfg)
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte(strings.Repeat("a", 60)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	big := strings.Repeat("b", 50)
+
+	tests := []struct {
+		name string
+		call func() (*types.ToolResult, error)
+		ok   bool
+	}{
+		{"write over the limit", func() (*types.ToolResult, error) {
+			return fsTools.Write(ctx, map[string]interface{}{"path": "b.txt", "content": big})
+		}, false},
+		{"overwrite within the limit", func() (*types.ToolResult, error) {
+			return fsTools.Write(ctx, map[string]interface{}{"path": "a.txt", "content": big})
+		}, true},
+		{"patch over the limit", func() (*types.ToolResult, error) {
+			patch := "--- /dev/null\n+++ b/c.txt\n@@ -0,0 +1 @@\n+" + big + "\n"
+			return fsTools.ApplyPatch(ctx, map[string]interface{}{"patch": patch})
+		}, false},
+	}
+	for _, tt := range tests {
+		result, err := tt.call()
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if result.OK != tt.ok {
+			t.Errorf("%s: ok = %v, want %v (%s)", tt.name, result.OK, tt.ok, result.Error)
+		}
+		if !tt.ok && result.ErrorCode != types.ErrTooLarge {
+			t.Errorf("%s: error code = %q, want %q", tt.name, result.ErrorCode, types.ErrTooLarge)
+		}
+	}
+}
+
+func TestListGitStatusShowsIgnored(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not found")
+	}
+	cfg := config.Default()
+	cfg.Workspace.RespectGitignore = true
+	fsTools, root := newTestFSTools(t, cfg)
+	for name, content := range map[string]string{
+		".gitignore": "*.log\n",
+		"main.go":    "package main\n",
+		"new.go":     "package main\n",
+		"debug.log":  "noise\n",
+	} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, args := range [][]string{{"init", "-q"}, {"add", "main.go"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = root
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	// Pick up the new .gitignore
+	if err := fsTools.session.SetRoot(root); err != nil {
+		t.Fatal(err)
+	}
+
+	list := func(gitStatus bool) map[string]string {
+		t.Helper()
+		result, err := fsTools.List(map[string]interface{}{"path": ".", "include_git_status": gitStatus})
+		if err != nil || !result.OK {
+			t.Fatalf("List failed: %v %+v", err, result)
+		}
+		got := map[string]string{}
+		for _, entry := range result.Data.(map[string]interface{})["entries"].([]FileEntry) {
+			got[entry.Name] = entry.GitStatus
+		}
+		return got
+	}
+
+	if _, ok := list(false)["debug.log"]; ok {
+		t.Fatal("expected the ignored file to be left out without git status")
+	}
+	got := list(true)
+	want := map[string]string{"main.go": "tracked", "new.go": "untracked", "debug.log": "ignored"}
+	for name, status := range want {
+		if got[name] != status {
+			t.Errorf("%s: git status %q, want %q (entries %v)", name, got[name], status, got)
+		}
+	}
+}
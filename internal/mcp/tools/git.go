@@ -2,9 +2,15 @@ package tools
 
 import (
 	"bytes"
+	"context"
 	"fmt"
+	"os"
 	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/tldw/tldw-agent/internal/config"
 	"github.com/tldw/tldw-agent/internal/types"
@@ -25,14 +31,27 @@ func NewGitTools(cfg *config.Config, session *workspace.Session) *GitTools {
 	}
 }
 
-// runGit runs a git command in the workspace.
-func (t *GitTools) runGit(args ...string) (string, string, error) {
+// readContext returns a context bounded by execution.read_timeout_ms, for
+// git operations that do not modify the repository.
+func (t *GitTools) readContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, time.Duration(t.config.Execution.ReadTimeoutMs)*time.Millisecond)
+}
+
+// writeContext returns a context bounded by execution.timeout_ms, for git
+// operations that modify the repository and may run hooks.
+func (t *GitTools) writeContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	return context.WithTimeout(ctx, time.Duration(t.config.Execution.TimeoutMs)*time.Millisecond)
+}
+
+// runGit runs a git command in the workspace. If ctx expires the command is
+// killed and stderr reports that the operation timed out.
+func (t *GitTools) runGit(ctx context.Context, args ...string) (string, string, error) {
 	cwd := t.session.AbsCwd()
 	if cwd == "" {
 		return "", "", fmt.Errorf("no workspace set")
 	}
 
-	cmd := exec.Command("git", args...)
+	cmd := exec.CommandContext(ctx, "git", args...)
 	cmd.Dir = cwd
 
 	var stdout, stderr bytes.Buffer
@@ -40,33 +59,60 @@ func (t *GitTools) runGit(args ...string) (string, string, error) {
 	cmd.Stderr = &stderr
 
 	err := cmd.Run()
+	if ctx.Err() == context.DeadlineExceeded {
+		return stdout.String(), "git operation timed out", ctx.Err()
+	}
 	return stdout.String(), stderr.String(), err
 }
 
+// gitErrorCode returns the error code for a failed git command.
+func gitErrorCode(stderr string, err error) string {
+	if strings.Contains(stderr, "not a git repository") {
+		return types.ErrGitNotRepo
+	}
+	return types.ErrorCodeFor(err)
+}
+
+// InsideWorkTree reports whether the current directory is inside a git
+// working tree.
+func (t *GitTools) InsideWorkTree(ctx context.Context) bool {
+	ctx, cancel := t.readContext(ctx)
+	defer cancel()
+
+	stdout, _, err := t.runGit(ctx, "rev-parse", "--is-inside-work-tree")
+	return err == nil && strings.TrimSpace(stdout) == "true"
+}
+
 // Status returns git repository status.
-func (t *GitTools) Status(args map[string]interface{}) (*types.ToolResult, error) {
+func (t *GitTools) Status(ctx context.Context, args map[string]interface{}) (*types.ToolResult, error) {
+	ctx, cancel := t.readContext(ctx)
+	defer cancel()
+
 	// Check if we're in a git repo
-	stdout, stderr, err := t.runGit("rev-parse", "--is-inside-work-tree")
+	stdout, stderr, err := t.runGit(ctx, "rev-parse", "--is-inside-work-tree")
 	if err != nil {
 		return &types.ToolResult{
-			OK:    false,
-			Error: fmt.Sprintf("not a git repository: %s", stderr),
+			OK:        false,
+			Error:     fmt.Sprintf("not a git repository: %s", stderr),
+			ErrorCode: types.ErrGitNotRepo,
 		}, nil
 	}
 
 	if strings.TrimSpace(stdout) != "true" {
 		return &types.ToolResult{
-			OK:    false,
-			Error: "not inside a git work tree",
+			OK:        false,
+			Error:     "not inside a git work tree",
+			ErrorCode: types.ErrGitNotRepo,
 		}, nil
 	}
 
 	// Get status
-	stdout, stderr, err = t.runGit("status", "--porcelain", "-b")
+	stdout, stderr, err = t.runGit(ctx, "status", "--porcelain", "-b")
 	if err != nil {
 		return &types.ToolResult{
-			OK:    false,
-			Error: fmt.Sprintf("git status failed: %s", stderr),
+			OK:        false,
+			Error:     fmt.Sprintf("git status failed: %s", stderr),
+			ErrorCode: gitErrorCode(stderr, err),
 		}, nil
 	}
 
@@ -74,7 +120,7 @@ func (t *GitTools) Status(args map[string]interface{}) (*types.ToolResult, error
 	lines := strings.Split(strings.TrimSpace(stdout), "\n")
 
 	var branch string
-	var staged, modified, untracked []string
+	var staged, modified, untracked, conflicts []string
 
 	for _, line := range lines {
 		if len(line) == 0 {
@@ -94,6 +140,12 @@ func (t *GitTools) Status(args map[string]interface{}) (*types.ToolResult, error
 		status := line[:2]
 		file := strings.TrimSpace(line[3:])
 
+		// Unmerged paths are reported only as conflicts
+		if isConflictStatus(status) {
+			conflicts = append(conflicts, file)
+			continue
+		}
+
 		// Index status (first char)
 		switch status[0] {
 		case 'A', 'M', 'D', 'R', 'C':
@@ -116,14 +168,46 @@ func (t *GitTools) Status(args map[string]interface{}) (*types.ToolResult, error
 			"staged":    staged,
 			"modified":  modified,
 			"untracked": untracked,
-			"clean":     len(staged) == 0 && len(modified) == 0 && len(untracked) == 0,
+			"conflicts": conflicts,
+			"clean":     len(staged) == 0 && len(modified) == 0 && len(untracked) == 0 && len(conflicts) == 0,
 		},
 	}, nil
 }
 
+// isConflictStatus reports whether a porcelain XY status code denotes an
+// unmerged path: DD, AU, UD, UA, DU, AA, or UU.
+func isConflictStatus(status string) bool {
+	switch status {
+	case "DD", "AU", "UD", "UA", "DU", "AA", "UU":
+		return true
+	}
+	return false
+}
+
 // Diff shows git diff.
-func (t *GitTools) Diff(args map[string]interface{}) (*types.ToolResult, error) {
+func (t *GitTools) Diff(ctx context.Context, args map[string]interface{}) (*types.ToolResult, error) {
+	ctx, cancel := t.readContext(ctx)
+	defer cancel()
+
+	format, _ := args["format"].(string)
+	if format == "" {
+		format = "unified"
+	}
+
 	gitArgs := []string{"diff"}
+	switch format {
+	case "unified":
+	case "stat":
+		gitArgs = append(gitArgs, "--stat")
+	case "name-only", "name-status":
+		gitArgs = append(gitArgs, "--"+format, "-z")
+	default:
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("invalid format: %s (expected unified, stat, name-only, or name-status)", format),
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
 
 	// Check if staged
 	if staged, ok := args["staged"].(bool); ok && staged {
@@ -140,11 +224,56 @@ func (t *GitTools) Diff(args map[string]interface{}) (*types.ToolResult, error)
 		}
 	}
 
-	stdout, stderr, err := t.runGit(gitArgs...)
+	stdout, stderr, err := t.runGit(ctx, gitArgs...)
 	if err != nil {
 		return &types.ToolResult{
-			OK:    false,
-			Error: fmt.Sprintf("git diff failed: %s", stderr),
+			OK:        false,
+			Error:     fmt.Sprintf("git diff failed: %s", stderr),
+			ErrorCode: gitErrorCode(stderr, err),
+		}, nil
+	}
+
+	switch format {
+	case "stat":
+		// The last line of --stat is the same summary --shortstat prints
+		stat := strings.TrimRight(stdout, "\n")
+		summary := stat[strings.LastIndexByte(stat, '\n')+1:]
+		counts := map[string]int{}
+		for _, m := range shortstatPattern.FindAllStringSubmatch(summary, -1) {
+			n, _ := strconv.Atoi(m[1])
+			counts[m[2]] = n
+		}
+		return &types.ToolResult{
+			OK: true,
+			Data: map[string]interface{}{
+				"stat":          stat,
+				"files_changed": counts["file"],
+				"insertions":    counts["insertion"],
+				"deletions":     counts["deletion"],
+			},
+		}, nil
+	case "name-only":
+		files := []string{}
+		for _, name := range strings.Split(stdout, "\x00") {
+			if name != "" {
+				files = append(files, name)
+			}
+		}
+		return &types.ToolResult{
+			OK: true,
+			Data: map[string]interface{}{
+				"files": files,
+				"count": len(files),
+			},
+		}, nil
+	case "name-status":
+		files := parseNameStatus(stdout)
+		return &types.ToolResult{
+			OK: true,
+			Data: map[string]interface{}{
+				"files": files,
+				"count": len(files),
+			},
 		}, nil
 	}
 
@@ -166,25 +295,116 @@ func (t *GitTools) Diff(args map[string]interface{}) (*types.ToolResult, error)
 	}, nil
 }
 
+// parseNameStatus parses `git diff --name-status -z` output. Renames and
+// copies carry both the old and the new path.
+func parseNameStatus(out string) []map[string]interface{} {
+	fields := strings.Split(strings.TrimSuffix(out, "\x00"), "\x00")
+	files := []map[string]interface{}{}
+	for i := 0; i+1 < len(fields); i += 2 {
+		status := fields[i]
+		entry := map[string]interface{}{
+			"status": status,
+			"path":   fields[i+1],
+		}
+		if (strings.HasPrefix(status, "R") || strings.HasPrefix(status, "C")) && i+2 < len(fields) {
+			entry["old_path"] = fields[i+1]
+			entry["path"] = fields[i+2]
+			i++
+		}
+		files = append(files, entry)
+	}
+	return files
+}
+
+// shortstatPattern matches one field of `git diff --shortstat` output,
+// e.g. "3 files changed" or "10 insertions(+)".
+var shortstatPattern = regexp.MustCompile(`(\d+) (file|insertion|deletion)`)
+
+// Shortstat summarizes a diff as file and line counts.
+func (t *GitTools) Shortstat(ctx context.Context, args map[string]interface{}) (*types.ToolResult, error) {
+	ctx, cancel := t.readContext(ctx)
+	defer cancel()
+
+	gitArgs := []string{"diff", "--shortstat"}
+
+	// Check if staged
+	if staged, ok := args["staged"].(bool); ok && staged {
+		gitArgs = append(gitArgs, "--staged")
+	}
+
+	// Compare against a specific commit if given
+	if ref, ok := args["ref"].(string); ok && ref != "" {
+		if strings.HasPrefix(ref, "-") {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     "invalid ref",
+				ErrorCode: types.ErrInvalidArg,
+			}, nil
+		}
+		gitArgs = append(gitArgs, ref)
+	}
+	gitArgs = append(gitArgs, "--")
+
+	stdout, stderr, err := t.runGit(ctx, gitArgs...)
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("git diff failed: %s", stderr),
+			ErrorCode: gitErrorCode(stderr, err),
+		}, nil
+	}
+
+	counts := map[string]int{}
+	for _, m := range shortstatPattern.FindAllStringSubmatch(stdout, -1) {
+		n, _ := strconv.Atoi(m[1])
+		counts[m[2]] = n
+	}
+
+	return &types.ToolResult{
+		OK: true,
+		Data: map[string]interface{}{
+			"files_changed": counts["file"],
+			"insertions":    counts["insertion"],
+			"deletions":     counts["deletion"],
+		},
+	}, nil
+}
+
 // Log shows recent commits.
-func (t *GitTools) Log(args map[string]interface{}) (*types.ToolResult, error) {
+func (t *GitTools) Log(ctx context.Context, args map[string]interface{}) (*types.ToolResult, error) {
+	ctx, cancel := t.readContext(ctx)
+	defer cancel()
+
 	count := 10
 	if c, ok := args["count"].(float64); ok {
 		count = int(c)
 	}
 
-	gitArgs := []string{"log", fmt.Sprintf("-n%d", count), "--pretty=format:%H|%an|%ae|%at|%s"}
-
-	// Add path filter if specified
-	if path, ok := args["path"].(string); ok && path != "" {
-		gitArgs = append(gitArgs, "--", path)
+	// Options shared by the structured log and the graph
+	var logArgs []string
+	path, _ := args["path"].(string)
+	if follow, _ := args["follow"].(bool); follow {
+		if path == "" {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     "follow requires a path",
+				ErrorCode: types.ErrInvalidArg,
+			}, nil
+		}
+		logArgs = append(logArgs, "--follow")
 	}
+	if path != "" {
+		logArgs = append(logArgs, "--", path)
+	}
+
+	gitArgs := append([]string{"log", fmt.Sprintf("-n%d", count), "--pretty=format:%H|%an|%ae|%at|%s"}, logArgs...)
 
-	stdout, stderr, err := t.runGit(gitArgs...)
+	stdout, stderr, err := t.runGit(ctx, gitArgs...)
 	if err != nil {
 		return &types.ToolResult{
-			OK:    false,
-			Error: fmt.Sprintf("git log failed: %s", stderr),
+			OK:        false,
+			Error:     fmt.Sprintf("git log failed: %s", stderr),
+			ErrorCode: gitErrorCode(stderr, err),
 		}, nil
 	}
 
@@ -211,33 +431,55 @@ func (t *GitTools) Log(args map[string]interface{}) (*types.ToolResult, error) {
 		})
 	}
 
+	data := map[string]interface{}{
+		"commits": commits,
+		"count":   len(commits),
+	}
+
+	// The graph is drawn in its own pass, since graph lines interleave
+	// with the commits and would break the field-separated format
+	if graph, _ := args["graph"].(bool); graph {
+		graphArgs := append([]string{"log", fmt.Sprintf("-n%d", count), "--graph", "--oneline"}, logArgs...)
+		stdout, stderr, err := t.runGit(ctx, graphArgs...)
+		if err != nil {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     fmt.Sprintf("git log --graph failed: %s", stderr),
+				ErrorCode: gitErrorCode(stderr, err),
+			}, nil
+		}
+		data["graph"] = strings.TrimRight(stdout, "\n")
+	}
+
 	return &types.ToolResult{
-		OK: true,
-		Data: map[string]interface{}{
-			"commits": commits,
-			"count":   len(commits),
-		},
+		OK:   true,
+		Data: data,
 	}, nil
 }
 
 // Branch shows branch information.
-func (t *GitTools) Branch(args map[string]interface{}) (*types.ToolResult, error) {
+func (t *GitTools) Branch(ctx context.Context, args map[string]interface{}) (*types.ToolResult, error) {
+	ctx, cancel := t.readContext(ctx)
+	defer cancel()
+
 	// Get current branch
-	currentBranch, stderr, err := t.runGit("rev-parse", "--abbrev-ref", "HEAD")
+	currentBranch, stderr, err := t.runGit(ctx, "rev-parse", "--abbrev-ref", "HEAD")
 	if err != nil {
 		return &types.ToolResult{
-			OK:    false,
-			Error: fmt.Sprintf("git rev-parse failed: %s", stderr),
+			OK:        false,
+			Error:     fmt.Sprintf("git rev-parse failed: %s", stderr),
+			ErrorCode: gitErrorCode(stderr, err),
 		}, nil
 	}
 	currentBranch = strings.TrimSpace(currentBranch)
 
 	// Get all branches
-	stdout, stderr, err := t.runGit("branch", "-a", "--format=%(refname:short)|%(upstream:short)|%(upstream:track)")
+	stdout, stderr, err := t.runGit(ctx, "branch", "-a", "--format=%(refname:short)|%(upstream:short)|%(upstream:track)")
 	if err != nil {
 		return &types.ToolResult{
-			OK:    false,
-			Error: fmt.Sprintf("git branch failed: %s", stderr),
+			OK:        false,
+			Error:     fmt.Sprintf("git branch failed: %s", stderr),
+			ErrorCode: gitErrorCode(stderr, err),
 		}, nil
 	}
 
@@ -276,13 +518,504 @@ func (t *GitTools) Branch(args map[string]interface{}) (*types.ToolResult, error
 	}, nil
 }
 
+// LsFiles lists files known to git, optionally filtered by state.
+func (t *GitTools) LsFiles(ctx context.Context, args map[string]interface{}) (*types.ToolResult, error) {
+	ctx, cancel := t.readContext(ctx)
+	defer cancel()
+
+	gitArgs := []string{"ls-files", "-z"}
+
+	if others, ok := args["others"].(bool); ok && others {
+		gitArgs = append(gitArgs, "--others", "--exclude-standard")
+	}
+	if modified, ok := args["modified"].(bool); ok && modified {
+		gitArgs = append(gitArgs, "--modified")
+	}
+	if deleted, ok := args["deleted"].(bool); ok && deleted {
+		gitArgs = append(gitArgs, "--deleted")
+	}
+
+	// Add pathspec if specified
+	if pathspec, ok := args["pathspec"].(string); ok && pathspec != "" {
+		gitArgs = append(gitArgs, "--", pathspec)
+	}
+
+	stdout, stderr, err := t.runGit(ctx, gitArgs...)
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("git ls-files failed: %s", stderr),
+			ErrorCode: gitErrorCode(stderr, err),
+		}, nil
+	}
+
+	files := []string{}
+	for _, name := range strings.Split(stdout, "\x00") {
+		if name != "" {
+			files = append(files, name)
+		}
+	}
+
+	return &types.ToolResult{
+		OK: true,
+		Data: map[string]interface{}{
+			"files": files,
+			"count": len(files),
+		},
+	}, nil
+}
+
+// Worktree lists, adds, or removes linked worktrees.
+func (t *GitTools) Worktree(ctx context.Context, args map[string]interface{}) (*types.ToolResult, error) {
+	action, _ := args["action"].(string)
+	switch action {
+	case "", "list":
+		return t.worktreeList(ctx)
+	case "add":
+		return t.worktreeAdd(ctx, args)
+	case "remove":
+		return t.worktreeRemove(ctx, args)
+	default:
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("unknown action: %s", action),
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+}
+
+// worktreeList parses `git worktree list --porcelain`.
+func (t *GitTools) worktreeList(ctx context.Context) (*types.ToolResult, error) {
+	ctx, cancel := t.readContext(ctx)
+	defer cancel()
+
+	stdout, stderr, err := t.runGit(ctx, "worktree", "list", "--porcelain")
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("git worktree list failed: %s", stderr),
+			ErrorCode: gitErrorCode(stderr, err),
+		}, nil
+	}
+
+	worktrees := []map[string]interface{}{}
+	var current map[string]interface{}
+	for _, line := range strings.Split(stdout, "\n") {
+		key, value, _ := strings.Cut(line, " ")
+		switch key {
+		case "worktree":
+			current = map[string]interface{}{"path": value}
+			worktrees = append(worktrees, current)
+		case "HEAD":
+			if current != nil {
+				current["head"] = value
+			}
+		case "branch":
+			if current != nil {
+				current["branch"] = strings.TrimPrefix(value, "refs/heads/")
+			}
+		case "detached", "bare", "locked", "prunable":
+			if current != nil {
+				current[key] = true
+			}
+		}
+	}
+
+	return &types.ToolResult{
+		OK: true,
+		Data: map[string]interface{}{
+			"worktrees": worktrees,
+		},
+	}, nil
+}
+
+// worktreeAdd creates a worktree inside the workspace and registers it as
+// an additional workspace root labelled with its directory name.
+func (t *GitTools) worktreeAdd(ctx context.Context, args map[string]interface{}) (*types.ToolResult, error) {
+	ctx, cancel := t.writeContext(ctx)
+	defer cancel()
+
+	path, _ := args["path"].(string)
+	if path == "" {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     "path is required",
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+
+	absPath, err := t.session.ResolvePath(path)
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     err.Error(),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+
+	gitArgs := []string{"worktree", "add", absPath}
+	if branch, ok := args["branch"].(string); ok && branch != "" {
+		gitArgs = append(gitArgs, branch)
+	}
+
+	stdout, stderr, err := t.runGit(ctx, gitArgs...)
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("git worktree add failed: %s %s", stderr, stdout),
+			ErrorCode: gitErrorCode(stderr, err),
+		}, nil
+	}
+
+	label := filepath.Base(absPath)
+	data := map[string]interface{}{
+		"path": absPath,
+		"root": label,
+	}
+	var warnings []string
+	if err := t.session.AddRoot(label, absPath); err != nil {
+		delete(data, "root")
+		warnings = append(warnings, fmt.Sprintf("worktree created but not registered as a root: %v", err))
+	}
+
+	return &types.ToolResult{
+		OK:       true,
+		Warnings: warnings,
+		Data:     data,
+	}, nil
+}
+
+// worktreeRemove removes a linked worktree.
+func (t *GitTools) worktreeRemove(ctx context.Context, args map[string]interface{}) (*types.ToolResult, error) {
+	ctx, cancel := t.writeContext(ctx)
+	defer cancel()
+
+	path, _ := args["path"].(string)
+	if path == "" {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     "path is required",
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+
+	absPath, err := t.session.ResolvePath(path)
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     err.Error(),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+
+	stdout, stderr, err := t.runGit(ctx, "worktree", "remove", absPath)
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("git worktree remove failed: %s %s", stderr, stdout),
+			ErrorCode: gitErrorCode(stderr, err),
+		}, nil
+	}
+
+	return &types.ToolResult{
+		OK: true,
+		Data: map[string]interface{}{
+			"path":    absPath,
+			"removed": true,
+		},
+	}, nil
+}
+
+// allowedGitConfigKeys are the config keys git.config may set. They only
+// change how git formats, merges and identifies commits; keys whose values
+// git runs as commands or loads files from are left out. Keys are compared
+// lowercased and "*" matches any run of characters, including dots.
+var allowedGitConfigKeys = []string{
+	"user.*",
+	"core.autocrlf",
+	"core.eol",
+	"core.filemode",
+	"core.ignorecase",
+	"core.quotepath",
+	"core.safecrlf",
+	"core.whitespace",
+	"pull.rebase",
+	"pull.ff",
+	"push.default",
+	"push.autosetupremote",
+	"fetch.prune",
+	"merge.ff",
+	"merge.conflictstyle",
+	"rebase.autostash",
+	"rebase.autosquash",
+	"rerere.enabled",
+	"init.defaultbranch",
+	"diff.algorithm",
+	"diff.renames",
+	"color.ui",
+	"log.date",
+	"status.showuntrackedfiles",
+	"branch.autosetuprebase",
+	"branch.*.remote",
+	"branch.*.merge",
+	"branch.*.rebase",
+}
+
+// isAllowedGitConfigKey reports whether git.config may set key.
+func isAllowedGitConfigKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, allowed := range allowedGitConfigKeys {
+		prefix, suffix, wildcard := strings.Cut(allowed, "*")
+		if !wildcard {
+			if key == allowed {
+				return true
+			}
+			continue
+		}
+		if len(key) > len(prefix)+len(suffix) && strings.HasPrefix(key, prefix) && strings.HasSuffix(key, suffix) {
+			return true
+		}
+	}
+	return false
+}
+
+// GitConfig gets or sets a git configuration value. Only the keys in
+// allowedGitConfigKeys can be set, and only in the repository config.
+func (t *GitTools) GitConfig(ctx context.Context, args map[string]interface{}) (*types.ToolResult, error) {
+	key, _ := args["key"].(string)
+	if key == "" {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     "key is required",
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+	if strings.HasPrefix(key, "-") {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     "invalid key",
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+
+	gitArgs := []string{"config"}
+	switch scope, _ := args["scope"].(string); scope {
+	case "":
+	case "local", "global":
+		gitArgs = append(gitArgs, "--"+scope)
+	default:
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("unknown scope: %s", scope),
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+
+	action, _ := args["action"].(string)
+	newContext := t.readContext
+	if action == "set" {
+		newContext = t.writeContext
+	}
+	ctx, cancel := newContext(ctx)
+	defer cancel()
+
+	switch action {
+	case "get":
+		stdout, stderr, err := t.runGit(ctx, append(gitArgs, "--get", key)...)
+		if err != nil {
+			// Exit status 1 means the key is not set
+			if exitErr, ok := err.(*exec.ExitError); ok && exitErr.ExitCode() == 1 {
+				return &types.ToolResult{
+					OK: true,
+					Data: map[string]interface{}{
+						"key":   key,
+						"value": nil,
+					},
+				}, nil
+			}
+			return &types.ToolResult{
+				OK:        false,
+				Error:     fmt.Sprintf("git config failed: %s", stderr),
+				ErrorCode: gitErrorCode(stderr, err),
+			}, nil
+		}
+
+		return &types.ToolResult{
+			OK: true,
+			Data: map[string]interface{}{
+				"key":   key,
+				"value": strings.TrimSpace(stdout),
+			},
+		}, nil
+
+	case "set":
+		value, ok := args["value"].(string)
+		if !ok {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     "value is required",
+				ErrorCode: types.ErrInvalidArg,
+			}, nil
+		}
+		if scope, _ := args["scope"].(string); scope == "global" {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     "the global git config cannot be changed",
+				ErrorCode: types.ErrPermission,
+			}, nil
+		}
+		if !isAllowedGitConfigKey(key) {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     fmt.Sprintf("setting %s is not allowed", key),
+				ErrorCode: types.ErrPermission,
+			}, nil
+		}
+
+		stdout, stderr, err := t.runGit(ctx, append(gitArgs, key, value)...)
+		if err != nil {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     fmt.Sprintf("git config failed: %s %s", stderr, stdout),
+				ErrorCode: gitErrorCode(stderr, err),
+			}, nil
+		}
+
+		return &types.ToolResult{
+			OK: true,
+			Data: map[string]interface{}{
+				"key":   key,
+				"value": value,
+			},
+		}, nil
+
+	default:
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("unknown action: %s", action),
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+}
+
+// ConflictHunk is one conflicted region of a file. Base is only populated
+// when the merge used the diff3 conflict style.
+type ConflictHunk struct {
+	Ours   string `json:"ours"`
+	Theirs string `json:"theirs"`
+	Base   string `json:"base,omitempty"`
+}
+
+// Conflicts lists files with unresolved merge conflicts and their hunks.
+func (t *GitTools) Conflicts(ctx context.Context, args map[string]interface{}) (*types.ToolResult, error) {
+	ctx, cancel := t.readContext(ctx)
+	defer cancel()
+
+	stdout, stderr, err := t.runGit(ctx, "diff", "--name-only", "--diff-filter=U")
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("git diff failed: %s", stderr),
+			ErrorCode: gitErrorCode(stderr, err),
+		}, nil
+	}
+
+	// Paths are relative to the repository top level, not the cwd
+	toplevel, stderr, err := t.runGit(ctx, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("git rev-parse failed: %s", stderr),
+			ErrorCode: gitErrorCode(stderr, err),
+		}, nil
+	}
+	toplevel = strings.TrimSpace(toplevel)
+
+	files := []map[string]interface{}{}
+	for _, name := range strings.Split(strings.TrimSpace(stdout), "\n") {
+		if name == "" {
+			continue
+		}
+
+		entry := map[string]interface{}{"file": name}
+		absPath, err := t.session.ResolvePath(filepath.Join(toplevel, name))
+		if err != nil {
+			entry["error"] = err.Error()
+			files = append(files, entry)
+			continue
+		}
+		content, err := os.ReadFile(absPath)
+		if err != nil {
+			entry["error"] = fmt.Sprintf("failed to read file: %v", err)
+			files = append(files, entry)
+			continue
+		}
+
+		entry["hunks"] = parseConflictHunks(string(content))
+		files = append(files, entry)
+	}
+
+	return &types.ToolResult{
+		OK: true,
+		Data: map[string]interface{}{
+			"files": files,
+			"count": len(files),
+		},
+	}, nil
+}
+
+// parseConflictHunks extracts the regions between conflict markers.
+func parseConflictHunks(content string) []ConflictHunk {
+	const (
+		outside = iota
+		inOurs
+		inBase
+		inTheirs
+	)
+
+	hunks := []ConflictHunk{}
+	var ours, base, theirs []string
+	state := outside
+
+	for _, line := range strings.Split(content, "\n") {
+		switch {
+		case strings.HasPrefix(line, "<<<<<<<") && state == outside:
+			ours, base, theirs = nil, nil, nil
+			state = inOurs
+		case strings.HasPrefix(line, "|||||||") && state == inOurs:
+			state = inBase
+		case strings.HasPrefix(line, "=======") && (state == inOurs || state == inBase):
+			state = inTheirs
+		case strings.HasPrefix(line, ">>>>>>>") && state == inTheirs:
+			hunks = append(hunks, ConflictHunk{
+				Ours:   strings.Join(ours, "\n"),
+				Theirs: strings.Join(theirs, "\n"),
+				Base:   strings.Join(base, "\n"),
+			})
+			state = outside
+		case state == inOurs:
+			ours = append(ours, line)
+		case state == inBase:
+			base = append(base, line)
+		case state == inTheirs:
+			theirs = append(theirs, line)
+		}
+	}
+
+	return hunks
+}
+
 // Add stages files for commit.
-func (t *GitTools) Add(args map[string]interface{}) (*types.ToolResult, error) {
+func (t *GitTools) Add(ctx context.Context, args map[string]interface{}) (*types.ToolResult, error) {
+	ctx, cancel := t.writeContext(ctx)
+	defer cancel()
+
 	paths, ok := args["paths"].([]interface{})
 	if !ok || len(paths) == 0 {
 		return &types.ToolResult{
-			OK:    false,
-			Error: "paths is required",
+			OK:        false,
+			Error:     "paths is required",
+			ErrorCode: types.ErrInvalidArg,
 		}, nil
 	}
 
@@ -293,11 +1026,12 @@ func (t *GitTools) Add(args map[string]interface{}) (*types.ToolResult, error) {
 		}
 	}
 
-	stdout, stderr, err := t.runGit(gitArgs...)
+	stdout, stderr, err := t.runGit(ctx, gitArgs...)
 	if err != nil {
 		return &types.ToolResult{
-			OK:    false,
-			Error: fmt.Sprintf("git add failed: %s %s", stderr, stdout),
+			OK:        false,
+			Error:     fmt.Sprintf("git add failed: %s %s", stderr, stdout),
+			ErrorCode: gitErrorCode(stderr, err),
 		}, nil
 	}
 
@@ -309,33 +1043,472 @@ func (t *GitTools) Add(args map[string]interface{}) (*types.ToolResult, error) {
 	}, nil
 }
 
-// Commit creates a git commit.
-func (t *GitTools) Commit(args map[string]interface{}) (*types.ToolResult, error) {
-	message, ok := args["message"].(string)
-	if !ok || message == "" {
+// Commit creates a git commit, or with amend replaces the last one.
+func (t *GitTools) Commit(ctx context.Context, args map[string]interface{}) (*types.ToolResult, error) {
+	ctx, cancel := t.writeContext(ctx)
+	defer cancel()
+
+	message, _ := args["message"].(string)
+	amend, _ := args["amend"].(bool)
+	if message == "" && !amend {
 		return &types.ToolResult{
-			OK:    false,
-			Error: "message is required",
+			OK:        false,
+			Error:     "message is required",
+			ErrorCode: types.ErrInvalidArg,
 		}, nil
 	}
 
-	stdout, stderr, err := t.runGit("commit", "-m", message)
+	gitArgs := []string{"commit"}
+	var originalHash string
+	published := false
+	if amend {
+		out, stderr, err := t.runGit(ctx, "rev-parse", "HEAD")
+		if err != nil {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     fmt.Sprintf("nothing to amend: %s", strings.TrimSpace(stderr)),
+				ErrorCode: types.ErrInvalidArg,
+			}, nil
+		}
+		originalHash = strings.TrimSpace(out)
+
+		// A commit is published if any remote-tracking branch contains it
+		remotes, _, err := t.runGit(ctx, "branch", "-r", "--contains", "HEAD")
+		published = err == nil && strings.TrimSpace(remotes) != ""
+
+		gitArgs = append(gitArgs, "--amend")
+		if message == "" {
+			gitArgs = append(gitArgs, "--no-edit")
+		}
+	}
+	if message != "" {
+		gitArgs = append(gitArgs, "-m", message)
+	}
+
+	stdout, stderr, err := t.runGit(ctx, gitArgs...)
 	if err != nil {
 		return &types.ToolResult{
-			OK:    false,
-			Error: fmt.Sprintf("git commit failed: %s %s", stderr, stdout),
+			OK:        false,
+			Error:     fmt.Sprintf("git commit failed: %s %s", stderr, stdout),
+			ErrorCode: gitErrorCode(stderr, err),
 		}, nil
 	}
 
 	// Get the commit hash
-	hash, _, _ := t.runGit("rev-parse", "HEAD")
+	hash, _, _ := t.runGit(ctx, "rev-parse", "HEAD")
 	hash = strings.TrimSpace(hash)
 
+	if message == "" {
+		out, _, _ := t.runGit(ctx, "log", "-1", "--format=%B")
+		message = strings.TrimSpace(out)
+	}
+
+	data := map[string]interface{}{
+		"hash":    hash,
+		"message": message,
+	}
+	var warnings []string
+	if amend {
+		data["original_hash"] = originalHash
+		if published {
+			warnings = append(warnings, fmt.Sprintf("amended commit %s had already been pushed; the remote branch will need a force push", shortHash(originalHash)))
+		}
+	}
+
+	return &types.ToolResult{
+		OK:       true,
+		Warnings: warnings,
+		Data:     data,
+	}, nil
+}
+
+// shortHash abbreviates a commit hash for messages.
+func shortHash(hash string) string {
+	if len(hash) > 12 {
+		return hash[:12]
+	}
+	return hash
+}
+
+// Init creates a git repository in the workspace root or a sub-path,
+// optionally with an empty initial commit.
+func (t *GitTools) Init(ctx context.Context, args map[string]interface{}) (*types.ToolResult, error) {
+	ctx, cancel := t.writeContext(ctx)
+	defer cancel()
+
+	path, _ := args["path"].(string)
+	if path == "" {
+		path = "."
+	}
+	absPath, err := t.session.ResolvePath(path)
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     err.Error(),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+
+	gitArgs := []string{"init"}
+	if branch, ok := args["initial_branch"].(string); ok && branch != "" {
+		if strings.HasPrefix(branch, "-") {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     fmt.Sprintf("invalid branch name: %s", branch),
+				ErrorCode: types.ErrInvalidArg,
+			}, nil
+		}
+		gitArgs = append(gitArgs, "--initial-branch="+branch)
+	}
+	gitArgs = append(gitArgs, "--", absPath)
+
+	stdout, stderr, err := t.runGit(ctx, gitArgs...)
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("git init failed: %s %s", stderr, stdout),
+			ErrorCode: gitErrorCode(stderr, err),
+		}, nil
+	}
+
+	data := map[string]interface{}{
+		"path":    path,
+		"message": strings.TrimSpace(stdout),
+	}
+
+	if initialCommit, _ := args["initial_commit"].(bool); initialCommit {
+		stdout, stderr, err := t.runGit(ctx, "-C", absPath, "commit", "--allow-empty", "-m", "Initial commit")
+		if err != nil {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     fmt.Sprintf("git commit failed: %s %s", stderr, stdout),
+				ErrorCode: gitErrorCode(stderr, err),
+			}, nil
+		}
+		hash, _, _ := t.runGit(ctx, "-C", absPath, "rev-parse", "HEAD")
+		data["hash"] = strings.TrimSpace(hash)
+	}
+
+	return &types.ToolResult{
+		OK:   true,
+		Data: data,
+	}, nil
+}
+
+// checkingPatchRe matches the per-file lines git apply -v writes to stderr.
+var checkingPatchRe = regexp.MustCompile(`(?m)^Checking patch (.+)\.\.\.$`)
+
+// Apply applies a patch with git apply, fixing whitespace errors. With
+// check it only reports whether the patch would apply; with index it also
+// stages the changes.
+func (t *GitTools) Apply(ctx context.Context, args map[string]interface{}) (*types.ToolResult, error) {
+	ctx, cancel := t.writeContext(ctx)
+	defer cancel()
+
+	patch, _ := args["patch"].(string)
+	if patch == "" {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     "patch is required",
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+	root := t.session.Root()
+	if root == "" {
+		return &types.ToolResult{
+			OK:    false,
+			Error: "no workspace set",
+		}, nil
+	}
+
+	// Everything a text patch adds is in the patch itself, so its size
+	// bounds how much the workspace can grow
+	check, _ := args["check"].(bool)
+	if !check {
+		if err := t.session.CheckDiskQuota(int64(len(patch))); err != nil {
+			return diskQuotaResult(err), nil
+		}
+	}
+
+	tmpFile, err := os.CreateTemp("", "tldw-patch-*.diff")
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("failed to write patch: %v", err),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+	defer os.Remove(tmpFile.Name())
+	_, err = tmpFile.WriteString(patch)
+	if closeErr := tmpFile.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("failed to write patch: %v", err),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+
+	// Run from the root, since git apply skips paths outside the current
+	// directory
+	gitArgs := []string{"-C", root, "apply", "-v", "--whitespace=fix"}
+	if check {
+		gitArgs = append(gitArgs, "--check")
+	}
+	if index, _ := args["index"].(bool); index {
+		gitArgs = append(gitArgs, "--index")
+	}
+	gitArgs = append(gitArgs, tmpFile.Name())
+
+	stdout, stderr, err := t.runGit(ctx, gitArgs...)
+
+	files := []string{}
+	for _, m := range checkingPatchRe.FindAllStringSubmatch(stderr, -1) {
+		files = append(files, m[1])
+	}
+
+	if err != nil {
+		if check {
+			return &types.ToolResult{
+				OK: true,
+				Data: map[string]interface{}{
+					"applicable": false,
+					"files":      files,
+					"output":     strings.TrimSpace(stderr),
+				},
+			}, nil
+		}
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("git apply failed: %s %s", stderr, stdout),
+			ErrorCode: gitErrorCode(stderr, err),
+		}, nil
+	}
+
+	data := map[string]interface{}{
+		"files": files,
+	}
+	if check {
+		data["applicable"] = true
+	}
+	return &types.ToolResult{
+		OK:   true,
+		Data: data,
+	}, nil
+}
+
+// Revert creates a commit undoing ref, or with no_commit only applies the
+// inverse changes to the index and worktree. Reverting a merge commit
+// requires mainline, the parent number to revert to.
+func (t *GitTools) Revert(ctx context.Context, args map[string]interface{}) (*types.ToolResult, error) {
+	ctx, cancel := t.writeContext(ctx)
+	defer cancel()
+
+	ref, _ := args["ref"].(string)
+	if ref == "" {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     "ref is required",
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+	if strings.HasPrefix(ref, "-") {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     "invalid ref",
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+
+	// rev-list --parents prints the commit followed by its parents
+	out, stderr, err := t.runGit(ctx, "rev-list", "--parents", "-n1", ref, "--")
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("unknown ref %s: %s", ref, strings.TrimSpace(stderr)),
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+	parents := len(strings.Fields(out)) - 1
+
+	gitArgs := []string{"revert"}
+	noCommit, _ := args["no_commit"].(bool)
+	if noCommit {
+		gitArgs = append(gitArgs, "--no-commit")
+	} else {
+		gitArgs = append(gitArgs, "--no-edit")
+	}
+
+	mainline, hasMainline := args["mainline"].(float64)
+	if parents > 1 {
+		if !hasMainline || mainline < 1 || int(mainline) > parents {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     fmt.Sprintf("%s is a merge commit; mainline must be a parent number from 1 to %d", ref, parents),
+				ErrorCode: types.ErrInvalidArg,
+			}, nil
+		}
+		gitArgs = append(gitArgs, "-m", strconv.Itoa(int(mainline)))
+	} else if hasMainline {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("%s is not a merge commit; mainline is only valid for merges", ref),
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+	gitArgs = append(gitArgs, ref)
+
+	stdout, stderr, err := t.runGit(ctx, gitArgs...)
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("git revert failed: %s %s", stderr, stdout),
+			ErrorCode: gitErrorCode(stderr, err),
+		}, nil
+	}
+
+	if noCommit {
+		out, _, _ := t.runGit(ctx, "diff", "--cached", "--name-only", "-z")
+		files := []string{}
+		for _, name := range strings.Split(out, "\x00") {
+			if name != "" {
+				files = append(files, name)
+			}
+		}
+		return &types.ToolResult{
+			OK: true,
+			Data: map[string]interface{}{
+				"ref":   ref,
+				"files": files,
+			},
+		}, nil
+	}
+
+	hash, _, _ := t.runGit(ctx, "rev-parse", "HEAD")
+	return &types.ToolResult{
+		OK: true,
+		Data: map[string]interface{}{
+			"ref":  ref,
+			"hash": strings.TrimSpace(hash),
+		},
+	}, nil
+}
+
+// Submodule reports submodule status, or updates submodules or runs a
+// command in each of them.
+func (t *GitTools) Submodule(ctx context.Context, args map[string]interface{}) (*types.ToolResult, error) {
+	action, _ := args["action"].(string)
+	switch action {
+	case "", "status":
+		return t.submoduleStatus(ctx)
+	case "update", "foreach":
+		if !t.config.Execution.Enabled {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     "command execution is disabled",
+				ErrorCode: types.ErrExecDisabled,
+			}, nil
+		}
+	default:
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("unknown action: %s", action),
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+
+	ctx, cancel := t.writeContext(ctx)
+	defer cancel()
+
+	gitArgs := []string{"submodule", "update", "--init", "--recursive"}
+	if action == "foreach" {
+		command, _ := args["command"].(string)
+		if command == "" {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     "command is required",
+				ErrorCode: types.ErrInvalidArg,
+			}, nil
+		}
+		// git runs the command through the shell
+		if containsShellMeta(command) {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     "command contains shell metacharacters",
+				ErrorCode: types.ErrInvalidArg,
+			}, nil
+		}
+		gitArgs = []string{"submodule", "foreach", command}
+	}
+
+	stdout, stderr, err := t.runGit(ctx, gitArgs...)
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("git submodule %s failed: %s %s", action, stderr, stdout),
+			ErrorCode: gitErrorCode(stderr, err),
+		}, nil
+	}
+
+	return &types.ToolResult{
+		OK: true,
+		Data: map[string]interface{}{
+			"action": action,
+			"output": stdout,
+		},
+	}, nil
+}
+
+// submoduleStatus parses `git submodule status --recursive`, whose lines
+// look like "-<hash> <path>" or "+<hash> <path> (<describe>)".
+func (t *GitTools) submoduleStatus(ctx context.Context) (*types.ToolResult, error) {
+	ctx, cancel := t.readContext(ctx)
+	defer cancel()
+
+	stdout, stderr, err := t.runGit(ctx, "submodule", "status", "--recursive")
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("git submodule status failed: %s", stderr),
+			ErrorCode: gitErrorCode(stderr, err),
+		}, nil
+	}
+
+	submodules := []map[string]interface{}{}
+	for _, line := range strings.Split(stdout, "\n") {
+		if len(line) < 2 {
+			continue
+		}
+		hash, path, ok := strings.Cut(line[1:], " ")
+		if !ok {
+			continue
+		}
+		if i := strings.LastIndex(path, " ("); i >= 0 && strings.HasSuffix(path, ")") {
+			path = path[:i]
+		}
+
+		state := "initialized"
+		switch line[0] {
+		case '-':
+			state = "uninitialized"
+		case 'U':
+			state = "conflict"
+		}
+		submodules = append(submodules, map[string]interface{}{
+			"path":     path,
+			"hash":     hash,
+			"state":    state,
+			"modified": line[0] == '+', // checked-out commit differs from the index
+		})
+	}
+
 	return &types.ToolResult{
 		OK: true,
 		Data: map[string]interface{}{
-			"hash":    hash,
-			"message": message,
+			"submodules": submodules,
+			"count":      len(submodules),
 		},
 	}, nil
 }
@@ -0,0 +1,108 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tldw/tldw-agent/internal/config"
+	"github.com/tldw/tldw-agent/internal/types"
+	"github.com/tldw/tldw-agent/internal/workspace"
+)
+
+func TestIsAllowedGitConfigKey(t *testing.T) {
+	tests := []struct {
+		key  string
+		want bool
+	}{
+		{"user.name", true},
+		{"User.Email", true},
+		{"pull.rebase", true},
+		{"core.autocrlf", true},
+		{"branch.feature/x.remote", true},
+		{"branch.autosetuprebase", true},
+		{"core.hooksPath", false},
+		{"core.fsmonitor", false},
+		{"core.sshCommand", false},
+		{"alias.st", false},
+		{"include.path", false},
+		{"filter.lfs.smudge", false},
+		{"branch.x.description", false},
+		{"user", false},
+	}
+	for _, tt := range tests {
+		if got := isAllowedGitConfigKey(tt.key); got != tt.want {
+			t.Errorf("isAllowedGitConfigKey(%q) = %v, want %v", tt.key, got, tt.want)
+		}
+	}
+}
+
+func TestGitConfigRefusesGlobalScope(t *testing.T) {
+	cfg := config.Default()
+	session := workspace.NewSession(cfg)
+	if err := session.SetRoot(t.TempDir()); err != nil {
+		t.Fatalf("SetRoot failed: %v", err)
+	}
+	git := NewGitTools(cfg, session)
+
+	result, err := git.GitConfig(context.Background(), map[string]interface{}{
+		"action": "set",
+		"key":    "user.name",
+		"value":  "Dev",
+		"scope":  "global",
+	})
+	if err != nil {
+		t.Fatalf("GitConfig failed: %v", err)
+	}
+	if result.OK || result.ErrorCode != types.ErrPermission {
+		t.Fatalf("expected a permission error, got %+v", result)
+	}
+}
+
+func TestCommitAmendWarnsWhenPublished(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	root := t.TempDir()
+	t.Setenv("GIT_CONFIG_GLOBAL", os.DevNull)
+	if err := os.WriteFile(filepath.Join(root, "a.txt"), []byte("a\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	for _, args := range [][]string{
+		{"init", "-q"},
+		{"config", "user.name", "Dev"},
+		{"config", "user.email", "dev@example.com"},
+		{"add", "a.txt"},
+		{"commit", "-q", "-m", "first"},
+		// Stands in for a push
+		{"update-ref", "refs/remotes/origin/main", "HEAD"},
+	} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = root
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v failed: %v\n%s", args, err, out)
+		}
+	}
+
+	cfg := config.Default()
+	session := workspace.NewSession(cfg)
+	if err := session.SetRoot(root); err != nil {
+		t.Fatalf("SetRoot failed: %v", err)
+	}
+	result, err := NewGitTools(cfg, session).Commit(context.Background(), map[string]interface{}{
+		"message": "first, amended",
+		"amend":   true,
+	})
+	if err != nil || !result.OK {
+		t.Fatalf("Commit failed: %+v, %v", result, err)
+	}
+	if len(result.Warnings) != 1 || !strings.Contains(result.Warnings[0], "force push") {
+		t.Fatalf("expected a force push warning, got %q", result.Warnings)
+	}
+	if _, ok := result.Data.(map[string]interface{})["warning"]; ok {
+		t.Fatal("warning still reported in the result data")
+	}
+}
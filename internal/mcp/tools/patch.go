@@ -0,0 +1,486 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/tldw/tldw-agent/internal/types"
+)
+
+// hunkHeaderPattern matches "@@ -start[,count] +start[,count] @@".
+var hunkHeaderPattern = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// filePatch is the part of a unified diff that changes one file.
+type filePatch struct {
+	oldPath string // "" for a file being created
+	newPath string // "" for a file being deleted
+	hunks   []patchHunk
+}
+
+// patchHunk is one "@@" section of a file patch.
+type patchHunk struct {
+	oldStart, oldCount int
+	newStart, newCount int
+	lines              []patchLine
+	oldNoEOL, newNoEOL bool // "\ No newline at end of file" on each side
+}
+
+// patchLine is a context (' '), deleted ('-'), or added ('+') line.
+type patchLine struct {
+	op   byte
+	text string
+}
+
+// PatchFailure explains why a hunk could not be applied.
+type PatchFailure struct {
+	File   string `json:"file"`
+	Hunk   int    `json:"hunk"` // 1-based index within the file, 0 for file-level problems
+	Reason string `json:"reason"`
+}
+
+// String formats the failure for an error message.
+func (f PatchFailure) String() string {
+	if f.Hunk == 0 {
+		return fmt.Sprintf("%s: %s", f.File, f.Reason)
+	}
+	return fmt.Sprintf("%s: hunk %d: %s", f.File, f.Hunk, f.Reason)
+}
+
+// ApplyPatch applies a unified diff to files in the workspace. Every hunk
+// is checked before any file is written, so a patch is applied entirely or
+// not at all. With dry_run set it only reports whether the patch applies;
+// with reverse set it undoes the patch, like patch -R. Nothing is written
+// once ctx is done.
+func (t *FSTools) ApplyPatch(ctx context.Context, args map[string]interface{}) (*types.ToolResult, error) {
+	patch, ok := args["patch"].(string)
+	if !ok || patch == "" {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     "patch is required",
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+	dryRun, _ := args["dry_run"].(bool)
+	reverse, _ := args["reverse"].(bool)
+
+	files, err := parsePatch(patch)
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("invalid patch: %v", err),
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+	if reverse {
+		for i := range files {
+			files[i] = files[i].reversed()
+		}
+	}
+
+	// A pending change writes content to path, removes oldPath, or both
+	// (for a rename)
+	type pendingChange struct {
+		path       string
+		absPath    string
+		content    string
+		oldAbsPath string
+	}
+
+	var changes []pendingChange
+	var failures []PatchFailure
+	hunks := 0
+	for _, fp := range files {
+		hunks += len(fp.hunks)
+		name := fp.newPath
+		if name == "" {
+			name = fp.oldPath
+		}
+
+		var change pendingChange
+		change.path = name
+		content := ""
+		if fp.oldPath != "" {
+			oldAbsPath, err := t.session.ResolvePath(fp.oldPath)
+			if err != nil {
+				failures = append(failures, PatchFailure{File: fp.oldPath, Reason: err.Error()})
+				continue
+			}
+			data, err := os.ReadFile(oldAbsPath)
+			if err != nil {
+				failures = append(failures, PatchFailure{File: fp.oldPath, Reason: fmt.Sprintf("failed to read file: %v", err)})
+				continue
+			}
+			content = string(data)
+			if fp.newPath != fp.oldPath {
+				change.oldAbsPath = oldAbsPath
+			}
+		}
+		if fp.newPath != "" {
+			absPath, err := t.session.ResolvePath(fp.newPath)
+			if err != nil {
+				failures = append(failures, PatchFailure{File: fp.newPath, Reason: err.Error()})
+				continue
+			}
+			if fp.newPath != fp.oldPath {
+				if _, err := os.Stat(absPath); err == nil {
+					failures = append(failures, PatchFailure{File: fp.newPath, Reason: "file to be created already exists"})
+					continue
+				}
+			}
+			change.absPath = absPath
+		}
+
+		patched, fileFailures := fp.apply(content)
+		if len(fileFailures) > 0 {
+			for _, f := range fileFailures {
+				f.File = name
+				failures = append(failures, f)
+			}
+			continue
+		}
+		change.content = patched
+		changes = append(changes, change)
+	}
+
+	if len(failures) > 0 {
+		if dryRun {
+			return &types.ToolResult{
+				OK: true,
+				Data: map[string]interface{}{
+					"applicable": false,
+					"hunks":      hunks,
+					"failures":   failures,
+				},
+			}, nil
+		}
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("patch does not apply: %s", failures[0]),
+			ErrorCode: types.ErrInvalidArg,
+			Data: map[string]interface{}{
+				"failures": failures,
+			},
+		}, nil
+	}
+
+	if dryRun {
+		return &types.ToolResult{
+			OK: true,
+			Data: map[string]interface{}{
+				"applicable": true,
+				"hunks":      hunks,
+			},
+		}, nil
+	}
+
+	var growth int64
+	for _, c := range changes {
+		if c.absPath != "" {
+			growth += int64(len(c.content)) - regularFileSize(c.absPath)
+		}
+		if c.oldAbsPath != "" {
+			growth -= regularFileSize(c.oldAbsPath)
+		}
+	}
+	if err := t.session.CheckDiskQuota(growth); err != nil {
+		return diskQuotaResult(err), nil
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	changed := make([]string, 0, len(changes))
+	for _, c := range changes {
+		if c.absPath != "" {
+			if err := os.MkdirAll(filepath.Dir(c.absPath), 0755); err != nil {
+				return &types.ToolResult{
+					OK:        false,
+					Error:     fmt.Sprintf("failed to create parent directory: %v", err),
+					ErrorCode: types.ErrorCodeFor(err),
+				}, nil
+			}
+			if err := os.WriteFile(c.absPath, []byte(c.content), 0644); err != nil {
+				return &types.ToolResult{
+					OK:        false,
+					Error:     fmt.Sprintf("failed to write %s: %v", c.path, err),
+					ErrorCode: types.ErrorCodeFor(err),
+				}, nil
+			}
+			t.session.RecordAccess("write", c.absPath, int64(len(c.content)))
+			t.session.TouchRecent(c.absPath, "write")
+		}
+		if c.oldAbsPath != "" {
+			if err := os.Remove(c.oldAbsPath); err != nil {
+				return &types.ToolResult{
+					OK:        false,
+					Error:     fmt.Sprintf("failed to delete %s: %v", c.oldAbsPath, err),
+					ErrorCode: types.ErrorCodeFor(err),
+				}, nil
+			}
+			t.session.RecordAccess("delete", c.oldAbsPath, 0)
+		}
+		changed = append(changed, c.path)
+	}
+
+	return &types.ToolResult{
+		OK: true,
+		Data: map[string]interface{}{
+			"files": changed,
+			"hunks": hunks,
+		},
+	}, nil
+}
+
+// parsePatch splits a unified diff into per-file patches. Lines outside
+// file headers and hunks, such as "diff --git" and "index", are ignored.
+func parsePatch(patch string) ([]filePatch, error) {
+	lines := strings.Split(strings.ReplaceAll(patch, "\r\n", "\n"), "\n")
+
+	var files []filePatch
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		switch {
+		case strings.HasPrefix(line, "--- ") && i+1 < len(lines) && strings.HasPrefix(lines[i+1], "+++ "):
+			files = append(files, filePatch{
+				oldPath: patchPath(line[4:], "a/"),
+				newPath: patchPath(lines[i+1][4:], "b/"),
+			})
+			i++
+
+		case strings.HasPrefix(line, "@@"):
+			if len(files) == 0 {
+				return nil, fmt.Errorf("hunk before file header")
+			}
+			hunk, next, err := parseHunk(lines, i)
+			if err != nil {
+				return nil, err
+			}
+			fp := &files[len(files)-1]
+			fp.hunks = append(fp.hunks, hunk)
+			i = next - 1
+		}
+	}
+
+	if len(files) == 0 {
+		return nil, fmt.Errorf("no file headers found")
+	}
+	for _, fp := range files {
+		if fp.oldPath == "" && fp.newPath == "" {
+			return nil, fmt.Errorf("file header has no path")
+		}
+		if len(fp.hunks) == 0 {
+			return nil, fmt.Errorf("%s: no hunks", fp.newPath)
+		}
+	}
+	return files, nil
+}
+
+// parseHunk parses the hunk whose header is lines[start]. It returns the
+// hunk and the index of the first line after it.
+func parseHunk(lines []string, start int) (patchHunk, int, error) {
+	m := hunkHeaderPattern.FindStringSubmatch(lines[start])
+	if m == nil {
+		return patchHunk{}, 0, fmt.Errorf("invalid hunk header: %q", lines[start])
+	}
+
+	count := func(s string) int {
+		if s == "" {
+			return 1
+		}
+		n, _ := strconv.Atoi(s)
+		return n
+	}
+	hunk := patchHunk{oldCount: count(m[2]), newCount: count(m[4])}
+	hunk.oldStart, _ = strconv.Atoi(m[1])
+	hunk.newStart, _ = strconv.Atoi(m[3])
+
+	// Read lines until both sides are complete, so removed lines that
+	// look like "--- " headers are not mistaken for them
+	oldSeen, newSeen := 0, 0
+	i := start + 1
+	for ; i < len(lines) && (oldSeen < hunk.oldCount || newSeen < hunk.newCount); i++ {
+		line := lines[i]
+		op, text := byte(' '), ""
+		if line != "" { // Some tools strip the space from empty context lines
+			op, text = line[0], line[1:]
+		}
+		switch op {
+		case ' ':
+			oldSeen++
+			newSeen++
+		case '-':
+			oldSeen++
+		case '+':
+			newSeen++
+		case '\\':
+			continue
+		default:
+			return patchHunk{}, 0, fmt.Errorf("unexpected line in hunk: %q", line)
+		}
+		hunk.lines = append(hunk.lines, patchLine{op: op, text: text})
+	}
+	if oldSeen != hunk.oldCount || newSeen != hunk.newCount {
+		return patchHunk{}, 0, fmt.Errorf("hunk %q is truncated", lines[start])
+	}
+
+	// "\ No newline at end of file" applies to the line before it
+	for ; i < len(lines) && strings.HasPrefix(lines[i], "\\"); i++ {
+	}
+	for j := start + 1; j < i; j++ {
+		if !strings.HasPrefix(lines[j], "\\") {
+			continue
+		}
+		switch prev := lines[j-1]; {
+		case strings.HasPrefix(prev, "-"):
+			hunk.oldNoEOL = true
+		case strings.HasPrefix(prev, "+"):
+			hunk.newNoEOL = true
+		default:
+			hunk.oldNoEOL = true
+			hunk.newNoEOL = true
+		}
+	}
+
+	return hunk, i, nil
+}
+
+// patchPath extracts the file name from a "---" or "+++" header value,
+// dropping any timestamp and the git a/ or b/ prefix. /dev/null yields "".
+func patchPath(value, prefix string) string {
+	if tab := strings.IndexByte(value, '\t'); tab >= 0 {
+		value = value[:tab]
+	}
+	value = strings.TrimSpace(value)
+	if value == "/dev/null" {
+		return ""
+	}
+	return strings.TrimPrefix(value, prefix)
+}
+
+// reversed returns the patch that undoes fp.
+func (fp filePatch) reversed() filePatch {
+	out := filePatch{oldPath: fp.newPath, newPath: fp.oldPath}
+	for _, h := range fp.hunks {
+		r := patchHunk{
+			oldStart: h.newStart, oldCount: h.newCount,
+			newStart: h.oldStart, newCount: h.oldCount,
+			oldNoEOL: h.newNoEOL, newNoEOL: h.oldNoEOL,
+		}
+		for _, l := range h.lines {
+			switch l.op {
+			case '+':
+				l.op = '-'
+			case '-':
+				l.op = '+'
+			}
+			r.lines = append(r.lines, l)
+		}
+		out.hunks = append(out.hunks, r)
+	}
+	return out
+}
+
+// apply applies the hunks of fp to content. Each hunk is located at its
+// stated line, adjusted by the offset of earlier hunks, or failing that at
+// the nearest position where its context and removed lines match.
+// Trailing carriage returns are ignored when matching, and added lines use
+// the file's line ending.
+func (fp filePatch) apply(content string) (string, []PatchFailure) {
+	lines := strings.Split(content, "\n")
+	trailingNewline := content == "" || strings.HasSuffix(content, "\n")
+	if strings.HasSuffix(content, "\n") || content == "" {
+		lines = lines[:len(lines)-1]
+	}
+	crlf := strings.Count(content, "\r\n") > strings.Count(content, "\n")/2
+
+	var failures []PatchFailure
+	offset := 0
+	for n, h := range fp.hunks {
+		var old, added []string
+		for _, l := range h.lines {
+			if l.op != '+' {
+				old = append(old, l.text)
+			}
+			if l.op != '-' {
+				text := l.text
+				if crlf {
+					text += "\r"
+				}
+				added = append(added, text)
+			}
+		}
+
+		want := h.oldStart - 1 + offset
+		if h.oldCount == 0 {
+			want = h.oldStart + offset // Insert after the stated line
+		}
+		pos := findHunk(lines, old, want)
+		if pos < 0 {
+			failures = append(failures, PatchFailure{
+				Hunk:   n + 1,
+				Reason: fmt.Sprintf("context does not match near line %d", max(want+1, 1)),
+			})
+			continue
+		}
+
+		atEOF := pos+len(old) == len(lines)
+		updated := make([]string, 0, len(lines)-len(old)+len(added))
+		updated = append(updated, lines[:pos]...)
+		updated = append(updated, added...)
+		updated = append(updated, lines[pos+len(old):]...)
+		lines = updated
+		offset += len(added) - len(old)
+
+		if atEOF && (h.oldNoEOL || h.newNoEOL) {
+			trailingNewline = !h.newNoEOL
+		}
+	}
+	if len(failures) > 0 {
+		return "", failures
+	}
+
+	if len(lines) == 0 {
+		return "", nil
+	}
+	result := strings.Join(lines, "\n")
+	if trailingNewline {
+		if crlf && !strings.HasSuffix(result, "\r") {
+			result += "\r"
+		}
+		result += "\n"
+	} else if crlf {
+		result = strings.TrimSuffix(result, "\r")
+	}
+	return result, nil
+}
+
+// findHunk returns the index in lines at which old matches, searching
+// outward from want, or -1 if it matches nowhere.
+func findHunk(lines, old []string, want int) int {
+	matches := func(pos int) bool {
+		if pos < 0 || pos+len(old) > len(lines) {
+			return false
+		}
+		for i, text := range old {
+			if strings.TrimSuffix(lines[pos+i], "\r") != strings.TrimSuffix(text, "\r") {
+				return false
+			}
+		}
+		return true
+	}
+
+	for delta := 0; delta <= len(lines); delta++ {
+		if matches(want - delta) {
+			return want - delta
+		}
+		if delta > 0 && matches(want+delta) {
+			return want + delta
+		}
+	}
+	return -1
+}
@@ -0,0 +1,176 @@
+package tools
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tldw/tldw-agent/internal/config"
+)
+
+func TestPatchApplyContent(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		patch   string
+		want    string
+		fails   bool
+	}{
+		{
+			name:    "modify",
+			content: "a\nb\nc\n",
+			patch:   "--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			want:    "a\nB\nc\n",
+		},
+		{
+			name:    "context moved",
+			content: "x\ny\na\nb\nc\n",
+			patch:   "--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			want:    "x\ny\na\nB\nc\n",
+		},
+		{
+			name:    "insert into empty hunk",
+			content: "a\nb\n",
+			patch:   "--- a/f\n+++ b/f\n@@ -1,0 +2 @@\n+new\n",
+			want:    "a\nnew\nb\n",
+		},
+		{
+			name:    "remove final newline",
+			content: "a\nb\n",
+			patch:   "--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n+b\n\\ No newline at end of file\n",
+			want:    "a\nb",
+		},
+		{
+			name:    "keeps CRLF",
+			content: "a\r\nb\r\n",
+			patch:   "--- a/f\n+++ b/f\n@@ -1,2 +1,2 @@\n a\n-b\n+B\n",
+			want:    "a\r\nB\r\n",
+		},
+		{
+			name:    "context mismatch",
+			content: "a\nz\nc\n",
+			patch:   "--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+			fails:   true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			files, err := parsePatch(tt.patch)
+			if err != nil {
+				t.Fatalf("parsePatch failed: %v", err)
+			}
+			got, failures := files[0].apply(tt.content)
+			if tt.fails {
+				if len(failures) == 0 {
+					t.Fatalf("expected the patch to fail, got %q", got)
+				}
+				return
+			}
+			if len(failures) > 0 {
+				t.Fatalf("unexpected failures: %v", failures)
+			}
+			if got != tt.want {
+				t.Fatalf("got %q, want %q", got, tt.want)
+			}
+
+			// Reversing the patch restores the original
+			back, failures := files[0].reversed().apply(got)
+			if len(failures) > 0 || back != tt.content {
+				t.Fatalf("reverse gave %q (%v), want %q", back, failures, tt.content)
+			}
+		})
+	}
+}
+
+func TestParsePatchErrors(t *testing.T) {
+	for _, patch := range []string{
+		"just text\n",
+		"@@ -1 +1 @@\n-a\n+b\n",
+		"--- a/f\n+++ b/f\n",
+		"--- a/f\n+++ b/f\n@@ -1,3 +1,3 @@\n a\n",
+		"--- a/f\n+++ b/f\n@@ -1 +1 @@\n?a\n",
+	} {
+		if _, err := parsePatch(patch); err == nil {
+			t.Errorf("expected an error parsing %q", patch)
+		}
+	}
+}
+
+func TestApplyPatchTool(t *testing.T) {
+	fsTools, root := newTestFSTools(t, config.Default())
+	ctx := context.Background()
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(root, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	read := func(name string) (string, bool) {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(root, name))
+		return string(data), err == nil
+	}
+	apply := func(patch string, extra map[string]interface{}) bool {
+		t.Helper()
+		args := map[string]interface{}{"patch": patch}
+		for k, v := range extra {
+			args[k] = v
+		}
+		result, err := fsTools.ApplyPatch(ctx, args)
+		if err != nil {
+			t.Fatalf("ApplyPatch failed: %v", err)
+		}
+		return result.OK
+	}
+
+	write("old.txt", "one\ntwo\n")
+	write("keep.txt", "keep\n")
+	rename := "diff --git a/old.txt b/new.txt\n--- a/old.txt\n+++ b/new.txt\n@@ -1,2 +1,2 @@\n one\n-two\n+TWO\n" +
+		"--- /dev/null\n+++ b/added.txt\n@@ -0,0 +1 @@\n+added\n"
+
+	// A dry run writes nothing
+	if !apply(rename, map[string]interface{}{"dry_run": true}) {
+		t.Fatal("dry run failed")
+	}
+	if _, ok := read("new.txt"); ok {
+		t.Fatal("dry run wrote a file")
+	}
+
+	// Rename with changes, plus a new file
+	if !apply(rename, nil) {
+		t.Fatal("rename patch failed")
+	}
+	if _, ok := read("old.txt"); ok {
+		t.Fatal("old.txt still exists after the rename")
+	}
+	if got, _ := read("new.txt"); got != "one\nTWO\n" {
+		t.Fatalf("new.txt = %q", got)
+	}
+	if got, _ := read("added.txt"); got != "added\n" {
+		t.Fatalf("added.txt = %q", got)
+	}
+
+	// Reversing undoes both
+	if !apply(rename, map[string]interface{}{"reverse": true}) {
+		t.Fatal("reverse patch failed")
+	}
+	if got, _ := read("old.txt"); got != "one\ntwo\n" {
+		t.Fatalf("old.txt = %q after reverse", got)
+	}
+	for _, name := range []string{"new.txt", "added.txt"} {
+		if _, ok := read(name); ok {
+			t.Fatalf("%s still exists after reverse", name)
+		}
+	}
+
+	// One failing hunk leaves every file untouched
+	partial := "--- a/keep.txt\n+++ b/keep.txt\n@@ -1 +1 @@\n-keep\n+kept\n" +
+		"--- a/old.txt\n+++ b/old.txt\n@@ -1 +1 @@\n-missing\n+x\n"
+	if apply(partial, nil) {
+		t.Fatal("expected the patch to fail")
+	}
+	if got, _ := read("keep.txt"); got != "keep\n" {
+		t.Fatalf("keep.txt changed to %q by a failed patch", got)
+	}
+}
@@ -2,12 +2,20 @@ package tools
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"sort"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gobwas/glob"
 
 	"github.com/tldw/tldw-agent/internal/config"
 	"github.com/tldw/tldw-agent/internal/types"
@@ -36,13 +44,15 @@ type GrepMatch struct {
 	Preview string `json:"preview"`
 }
 
-// Grep searches file contents using a regex pattern.
-func (t *SearchTools) Grep(args map[string]interface{}) (*types.ToolResult, error) {
+// Grep searches file contents using a regex pattern. It stops searching
+// and returns ctx.Err() once ctx is done.
+func (t *SearchTools) Grep(ctx context.Context, args map[string]interface{}) (*types.ToolResult, error) {
 	pattern, ok := args["pattern"].(string)
 	if !ok || pattern == "" {
 		return &types.ToolResult{
-			OK:    false,
-			Error: "pattern is required",
+			OK:        false,
+			Error:     "pattern is required",
+			ErrorCode: types.ErrInvalidArg,
 		}, nil
 	}
 
@@ -71,16 +81,30 @@ func (t *SearchTools) Grep(args map[string]interface{}) (*types.ToolResult, erro
 		maxResults = int(m)
 	}
 
+	multiline := false
+	if ml, ok := args["multiline"].(bool); ok {
+		multiline = ml
+	}
+
+	maxFileSize := t.config.Workspace.MaxFileSizeBytes
+	if m, ok := args["max_file_size_bytes"].(float64); ok && m > 0 {
+		maxFileSize = int64(m)
+	}
+
 	// Compile regex
 	regexFlags := ""
 	if !caseSensitive {
 		regexFlags = "(?i)"
 	}
+	if multiline {
+		regexFlags += "(?m)(?s)"
+	}
 	re, err := regexp.Compile(regexFlags + pattern)
 	if err != nil {
 		return &types.ToolResult{
-			OK:    false,
-			Error: fmt.Sprintf("invalid regex pattern: %v", err),
+			OK:        false,
+			Error:     fmt.Sprintf("invalid regex pattern: %v", err),
+			ErrorCode: types.ErrInvalidArg,
 		}, nil
 	}
 
@@ -89,16 +113,158 @@ func (t *SearchTools) Grep(args map[string]interface{}) (*types.ToolResult, erro
 		searchPaths = []string{"."}
 	}
 
+	// The walker feeds candidate files, numbered in walk order, to a pool
+	// of workers that search them; results are collected here in walk
+	// order, so a truncated result always holds the first matches whatever
+	// order the workers finish in. Closing done stops the walker and makes
+	// the workers drain the remaining files without searching them. It is
+	// closed when enough matches are found or ctx is done.
+	files := make(chan grepFile, 256)
+	results := make(chan grepResult, 256)
+	done := make(chan struct{})
+	var stopOnce sync.Once
+	stop := func() { stopOnce.Do(func() { close(done) }) }
+	defer context.AfterFunc(ctx, stop)()
+	var filesSkipped, binarySkipped atomic.Int64
+
+	go func() {
+		defer close(files)
+		t.walkGrepFiles(searchPaths, globPattern, maxFileSize, &filesSkipped, &binarySkipped, files, done)
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.NumCPU(); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for file := range files {
+				select {
+				case <-done:
+					continue
+				default:
+				}
+				search := t.searchFile
+				if multiline {
+					search = t.searchFileMultiline
+				}
+				fileMatches, err := search(file.path, re, maxResults)
+				// Files we can't read are skipped, but still reported so
+				// the collector can move past them
+				results <- grepResult{index: file.index, matches: fileMatches, searched: err == nil}
+			}
+		}()
+	}
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
 	matches := []GrepMatch{}
 	filesSearched := 0
+	root := t.session.Root()
+	pending := make(map[int]grepResult) // Results that arrived ahead of next
+	next := 0
+	for result := range results {
+		if len(matches) >= maxResults {
+			continue // Drain so the workers can finish
+		}
+		pending[result.index] = result
+
+		// Take results in walk order for as long as they are complete
+		for len(matches) < maxResults {
+			result, ok := pending[next]
+			if !ok {
+				break
+			}
+			delete(pending, next)
+			next++
+			if !result.searched {
+				continue
+			}
+			filesSearched++
+
+			fileMatches := result.matches
+			if remaining := maxResults - len(matches); len(fileMatches) > remaining {
+				fileMatches = fileMatches[:remaining]
+			}
+
+			// Convert paths to relative
+			for i := range fileMatches {
+				relPath, _ := filepath.Rel(root, fileMatches[i].Path)
+				fileMatches[i].Path = relPath
+			}
+			matches = append(matches, fileMatches...)
+		}
+
+		// Stop if we have enough matches
+		if len(matches) >= maxResults {
+			stop()
+		}
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// Report matches by path and line
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].Path != matches[j].Path {
+			return matches[i].Path < matches[j].Path
+		}
+		return matches[i].Line < matches[j].Line
+	})
+
+	var warnings []string
+	if n := binarySkipped.Load(); n > 0 {
+		warnings = append(warnings, fmt.Sprintf("skipped %d binary files", n))
+	}
+
+	return &types.ToolResult{
+		OK:       true,
+		Warnings: warnings,
+		Data: map[string]interface{}{
+			"matches":        matches,
+			"total_matches":  len(matches),
+			"files_searched": filesSearched,
+			"files_skipped":  filesSkipped.Load(),
+			"truncated":      len(matches) >= maxResults,
+		},
+	}, nil
+}
+
+// grepFile is a file for Grep to search, numbered in walk order.
+type grepFile struct {
+	index int
+	path  string
+}
+
+// grepResult holds the matches a worker found in the grepFile with the same
+// index. searched is false if the file could not be read.
+type grepResult struct {
+	index    int
+	matches  []GrepMatch
+	searched bool
+}
 
+// walkGrepFiles sends the files under searchPaths that Grep should search
+// to files, numbered in walk order, stopping early once done is closed.
+// Files larger than maxFileSize are counted in skipped instead, and binary
+// files in binary.
+func (t *SearchTools) walkGrepFiles(searchPaths []string, globPattern string, maxFileSize int64, skipped, binary *atomic.Int64, files chan<- grepFile, done <-chan struct{}) {
+	ignores := newIgnoreCache(t.session)
+	index := 0
 	for _, searchPath := range searchPaths {
+		select {
+		case <-done:
+			return
+		default:
+		}
+
 		absPath, err := t.session.ResolvePath(searchPath)
 		if err != nil {
 			continue // Skip invalid paths
 		}
 
-		err = filepath.WalkDir(absPath, func(path string, d fs.DirEntry, err error) error {
+		filepath.WalkDir(absPath, func(path string, d fs.DirEntry, err error) error {
 			if err != nil {
 				return nil // Skip entries we can't access
 			}
@@ -113,6 +279,15 @@ func (t *SearchTools) Grep(args map[string]interface{}) (*types.ToolResult, erro
 				if d.Name() == "node_modules" || d.Name() == "vendor" || d.Name() == "__pycache__" {
 					return filepath.SkipDir
 				}
+				// Skip directories excluded by .gitignore
+				if path != absPath && ignores.ignored(path, true) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			// Skip files excluded by .gitignore
+			if ignores.ignored(path, false) {
 				return nil
 			}
 
@@ -124,48 +299,28 @@ func (t *SearchTools) Grep(args map[string]interface{}) (*types.ToolResult, erro
 				}
 			}
 
-			// Skip binary files (simple heuristic)
+			// Skip binary files (simple heuristic), before the size check
+			// so they are never stat'ed
 			if isBinaryFile(d.Name()) {
+				binary.Add(1)
 				return nil
 			}
 
-			// Search file
-			fileMatches, err := t.searchFile(path, re, maxResults-len(matches))
-			if err != nil {
-				return nil // Skip files we can't read
-			}
-
-			// Convert paths to relative
-			root := t.session.Root()
-			for i := range fileMatches {
-				relPath, _ := filepath.Rel(root, fileMatches[i].Path)
-				fileMatches[i].Path = relPath
+			// Skip enormous files such as lockfiles and generated code
+			if info, err := d.Info(); err != nil || info.Size() > maxFileSize {
+				skipped.Add(1)
+				return nil
 			}
 
-			matches = append(matches, fileMatches...)
-			filesSearched++
-
-			// Stop if we have enough matches
-			if len(matches) >= maxResults {
+			select {
+			case files <- grepFile{index: index, path: path}:
+				index++
+				return nil
+			case <-done:
 				return filepath.SkipAll
 			}
-
-			return nil
 		})
-		if err != nil && err != filepath.SkipAll {
-			continue
-		}
 	}
-
-	return &types.ToolResult{
-		OK: true,
-		Data: map[string]interface{}{
-			"matches":        matches,
-			"total_matches":  len(matches),
-			"files_searched": filesSearched,
-			"truncated":      len(matches) >= maxResults,
-		},
-	}, nil
 }
 
 // searchFile searches a single file for the pattern.
@@ -191,31 +346,11 @@ func (t *SearchTools) searchFile(path string, re *regexp.Regexp, maxMatches int)
 				return matches, nil
 			}
 
-			// Truncate preview if too long
-			preview := line
-			if len(preview) > 200 {
-				start := loc[0] - 50
-				if start < 0 {
-					start = 0
-				}
-				end := loc[1] + 50
-				if end > len(line) {
-					end = len(line)
-				}
-				preview = line[start:end]
-				if start > 0 {
-					preview = "..." + preview
-				}
-				if end < len(line) {
-					preview = preview + "..."
-				}
-			}
-
 			matches = append(matches, GrepMatch{
 				Path:    path,
 				Line:    lineNum,
 				Column:  loc[0] + 1, // 1-indexed
-				Preview: preview,
+				Preview: grepPreview(line, loc[0], loc[1]),
 			})
 		}
 	}
@@ -223,13 +358,79 @@ func (t *SearchTools) searchFile(path string, re *regexp.Regexp, maxMatches int)
 	return matches, scanner.Err()
 }
 
-// Glob finds files matching a glob pattern.
-func (t *SearchTools) Glob(args map[string]interface{}) (*types.ToolResult, error) {
+// searchFileMultiline searches a whole file at once so that matches may
+// span lines. Line and column refer to where each match starts.
+func (t *SearchTools) searchFileMultiline(path string, re *regexp.Regexp, maxMatches int) ([]GrepMatch, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if info.Size() > t.config.Workspace.MaxFileSizeBytes {
+		return nil, fmt.Errorf("file too large: %d bytes", info.Size())
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	content := string(data)
+
+	var matches []GrepMatch
+	lineNum, lineStart, scanned := 1, 0, 0
+	for _, loc := range re.FindAllStringIndex(content, maxMatches) {
+		// Advance the line count to the start of this match
+		lineNum += strings.Count(content[scanned:loc[0]], "\n")
+		if i := strings.LastIndex(content[:loc[0]], "\n"); i >= 0 {
+			lineStart = i + 1
+		}
+		scanned = loc[0]
+
+		lineEnd := strings.IndexByte(content[lineStart:], '\n')
+		if lineEnd < 0 {
+			lineEnd = len(content)
+		} else {
+			lineEnd += lineStart
+		}
+
+		matches = append(matches, GrepMatch{
+			Path:    path,
+			Line:    lineNum,
+			Column:  loc[0] - lineStart + 1, // 1-indexed
+			Preview: grepPreview(content[lineStart:lineEnd], loc[0]-lineStart, min(loc[1], lineEnd)-lineStart),
+		})
+	}
+
+	return matches, nil
+}
+
+// grepPreview returns line, truncated around the match at [start, end) if
+// it is too long.
+func grepPreview(line string, start, end int) string {
+	if len(line) <= 200 {
+		return line
+	}
+
+	start = max(start-50, 0)
+	end = min(end+50, len(line))
+	preview := line[start:end]
+	if start > 0 {
+		preview = "..." + preview
+	}
+	if end < len(line) {
+		preview = preview + "..."
+	}
+	return preview
+}
+
+// Glob finds files matching a glob pattern. It returns ctx.Err() if ctx is
+// done before the walk finishes.
+func (t *SearchTools) Glob(ctx context.Context, args map[string]interface{}) (*types.ToolResult, error) {
 	pattern, ok := args["pattern"].(string)
 	if !ok || pattern == "" {
 		return &types.ToolResult{
-			OK:    false,
-			Error: "pattern is required",
+			OK:        false,
+			Error:     "pattern is required",
+			ErrorCode: types.ErrInvalidArg,
 		}, nil
 	}
 
@@ -243,32 +444,59 @@ func (t *SearchTools) Glob(args map[string]interface{}) (*types.ToolResult, erro
 		maxResults = int(m)
 	}
 
+	includeMetadata, _ := args["include_metadata"].(bool)
+
+	excludePatterns := defaultGlobExcludes
+	if ex, ok := args["exclude"].([]interface{}); ok {
+		excludePatterns = nil
+		for _, e := range ex {
+			if s, ok := e.(string); ok && s != "" {
+				excludePatterns = append(excludePatterns, s)
+			}
+		}
+	}
+	excludes := make([]glob.Glob, 0, len(excludePatterns))
+	for _, pattern := range excludePatterns {
+		g, err := glob.Compile(pattern, '/')
+		if err != nil {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     fmt.Sprintf("invalid exclude pattern %q: %v", pattern, err),
+				ErrorCode: types.ErrInvalidArg,
+			}, nil
+		}
+		excludes = append(excludes, g)
+	}
+
 	// Resolve base path
 	absBasePath, err := t.session.ResolvePath(basePath)
 	if err != nil {
 		return &types.ToolResult{
-			OK:    false,
-			Error: err.Error(),
+			OK:        false,
+			Error:     err.Error(),
+			ErrorCode: types.ErrorCodeFor(err),
 		}, nil
 	}
 
-	// Find matching files
-	var matches []string
+	// Find matching files. Matches are paths, or FoundFiles when
+	// metadata is requested.
+	matches := []interface{}{}
 	truncated := false
 
 	err = filepath.WalkDir(absBasePath, func(path string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return nil
 		}
-
-		// Skip hidden directories
-		if d.IsDir() && strings.HasPrefix(d.Name(), ".") {
-			return filepath.SkipDir
+		if err := ctx.Err(); err != nil {
+			return err
 		}
 
-		// Skip common large directories
+		relPath, _ := filepath.Rel(t.session.Root(), path)
+		relPath = filepath.ToSlash(relPath)
+
+		// Skip excluded directories without walking them
 		if d.IsDir() {
-			if d.Name() == "node_modules" || d.Name() == "vendor" || d.Name() == "__pycache__" {
+			if path != absBasePath && globExcluded(excludes, relPath+"/") {
 				return filepath.SkipDir
 			}
 			return nil
@@ -280,25 +508,36 @@ func (t *SearchTools) Glob(args map[string]interface{}) (*types.ToolResult, erro
 			return nil
 		}
 
-		if matched {
+		if matched && !globExcluded(excludes, relPath) {
 			if len(matches) >= maxResults {
 				truncated = true
 				return filepath.SkipAll
 			}
 
-			// Convert to relative path
-			root := t.session.Root()
-			relPath, _ := filepath.Rel(root, path)
-			matches = append(matches, relPath)
+			relPath := filepath.FromSlash(relPath)
+			if !includeMetadata {
+				matches = append(matches, relPath)
+				return nil
+			}
+
+			info, err := d.Info()
+			if err != nil {
+				return nil
+			}
+			matches = append(matches, FoundFile{Path: relPath, Size: info.Size(), ModTime: info.ModTime()})
 		}
 
 		return nil
 	})
 
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
 	if err != nil && err != filepath.SkipAll {
 		return &types.ToolResult{
-			OK:    false,
-			Error: fmt.Sprintf("failed to search: %v", err),
+			OK:        false,
+			Error:     fmt.Sprintf("failed to search: %v", err),
+			ErrorCode: types.ErrorCodeFor(err),
 		}, nil
 	}
 
@@ -313,6 +552,174 @@ func (t *SearchTools) Glob(args map[string]interface{}) (*types.ToolResult, erro
 	}, nil
 }
 
+// defaultGlobExcludes are the exclude patterns search.glob uses unless the
+// caller passes its own.
+var defaultGlobExcludes = []string{"node_modules/**", ".git/**", "__pycache__/**", "vendor/**"}
+
+// globExcluded reports whether a slash-separated path relative to the
+// workspace root matches one of excludes. Like .gitignore patterns, they
+// match at any depth: "node_modules/**" also excludes web/node_modules/x.
+func globExcluded(excludes []glob.Glob, relPath string) bool {
+	for {
+		for _, g := range excludes {
+			if g.Match(relPath) {
+				return true
+			}
+		}
+		i := strings.IndexByte(relPath, '/')
+		if i < 0 || i == len(relPath)-1 {
+			return false
+		}
+		relPath = relPath[i+1:]
+	}
+}
+
+// FoundFile describes a file matched by FindFiles.
+type FoundFile struct {
+	Path    string    `json:"path"`
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"mtime"`
+}
+
+// FindFiles finds files by name, extension, size, and modification time.
+// All given criteria must match. It returns ctx.Err() if ctx is done before
+// the walk finishes.
+func (t *SearchTools) FindFiles(ctx context.Context, args map[string]interface{}) (*types.ToolResult, error) {
+	basePath := "."
+	if p, ok := args["path"].(string); ok && p != "" {
+		basePath = p
+	}
+
+	nameContains, _ := args["name_contains"].(string)
+	extension, _ := args["extension"].(string)
+	if extension != "" && !strings.HasPrefix(extension, ".") {
+		extension = "." + extension
+	}
+
+	minSize, hasMin := args["min_size_bytes"].(float64)
+	maxSize, hasMax := args["max_size_bytes"].(float64)
+
+	var modifiedAfter, modifiedBefore time.Time
+	for key, dst := range map[string]*time.Time{
+		"modified_after":  &modifiedAfter,
+		"modified_before": &modifiedBefore,
+	} {
+		s, ok := args[key].(string)
+		if !ok || s == "" {
+			continue
+		}
+		parsed, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     fmt.Sprintf("invalid %s: expected RFC 3339 time", key),
+				ErrorCode: types.ErrInvalidArg,
+			}, nil
+		}
+		*dst = parsed
+	}
+
+	maxResults := 100
+	if m, ok := args["max_results"].(float64); ok {
+		maxResults = int(m)
+	}
+
+	// Resolve base path
+	absBasePath, err := t.session.ResolvePath(basePath)
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     err.Error(),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+
+	files := []FoundFile{}
+	truncated := false
+	root := t.session.Root()
+
+	err = filepath.WalkDir(absBasePath, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		if d.IsDir() {
+			// Skip hidden and common large directories
+			if path != absBasePath && (strings.HasPrefix(d.Name(), ".") || d.Name() == "node_modules" || d.Name() == "vendor" || d.Name() == "__pycache__") {
+				return filepath.SkipDir
+			}
+			if path != absBasePath && isGitIgnored(t.session, path, true) {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() || isGitIgnored(t.session, path, false) {
+			return nil
+		}
+
+		name := d.Name()
+		if nameContains != "" && !strings.Contains(strings.ToLower(name), strings.ToLower(nameContains)) {
+			return nil
+		}
+		if extension != "" && !strings.EqualFold(filepath.Ext(name), extension) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		if hasMin && info.Size() < int64(minSize) {
+			return nil
+		}
+		if hasMax && info.Size() > int64(maxSize) {
+			return nil
+		}
+		if !modifiedAfter.IsZero() && !info.ModTime().After(modifiedAfter) {
+			return nil
+		}
+		if !modifiedBefore.IsZero() && !info.ModTime().Before(modifiedBefore) {
+			return nil
+		}
+
+		if len(files) >= maxResults {
+			truncated = true
+			return filepath.SkipAll
+		}
+
+		relPath, _ := filepath.Rel(root, path)
+		files = append(files, FoundFile{
+			Path:    relPath,
+			Size:    info.Size(),
+			ModTime: info.ModTime(),
+		})
+		return nil
+	})
+
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil && err != filepath.SkipAll {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("failed to search: %v", err),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+
+	return &types.ToolResult{
+		OK: true,
+		Data: map[string]interface{}{
+			"files":     files,
+			"count":     len(files),
+			"truncated": truncated,
+		},
+	}, nil
+}
+
 // isBinaryFile checks if a file is likely binary based on extension.
 func isBinaryFile(name string) bool {
 	binaryExts := map[string]bool{
@@ -0,0 +1,79 @@
+package tools
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/tldw/tldw-agent/internal/config"
+	"github.com/tldw/tldw-agent/internal/workspace"
+)
+
+func TestGrepTruncatesInWalkOrder(t *testing.T) {
+	cfg := config.Default()
+	root := t.TempDir()
+	session := workspace.NewSession(cfg)
+	if err := session.SetRoot(root); err != nil {
+		t.Fatalf("SetRoot failed: %v", err)
+	}
+	search := NewSearchTools(cfg, session)
+
+	// Enough files to keep every worker busy, with two matches each
+	for i := 0; i < 300; i++ {
+		name := filepath.Join(root, fmt.Sprintf("f%03d.txt", i))
+		if err := os.WriteFile(name, []byte("match\nother\nmatch\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for run := 0; run < 20; run++ {
+		result, err := search.Grep(context.Background(), map[string]interface{}{
+			"pattern":     "match",
+			"max_results": float64(25),
+		})
+		if err != nil || !result.OK {
+			t.Fatalf("Grep failed: %+v, %v", result, err)
+		}
+		data := result.Data.(map[string]interface{})
+		matches := data["matches"].([]GrepMatch)
+		if len(matches) != 25 || data["truncated"] != true {
+			t.Fatalf("got %d matches, truncated %v", len(matches), data["truncated"])
+		}
+		// The first 25 matches in walk order: f000.txt to f011.txt, then
+		// the first line of f012.txt
+		for i, m := range matches {
+			want := fmt.Sprintf("f%03d.txt", i/2)
+			wantLine := 1 + 2*(i%2)
+			if m.Path != want || m.Line != wantLine {
+				t.Fatalf("run %d: match %d is %s:%d, want %s:%d", run, i, m.Path, m.Line, want, wantLine)
+			}
+		}
+		if got := data["files_searched"]; got != 13 {
+			t.Fatalf("run %d: files_searched = %v, want 13", run, got)
+		}
+	}
+}
+
+func TestGrepCancelled(t *testing.T) {
+	cfg := config.Default()
+	root := t.TempDir()
+	session := workspace.NewSession(cfg)
+	if err := session.SetRoot(root); err != nil {
+		t.Fatalf("SetRoot failed: %v", err)
+	}
+	for i := 0; i < 50; i++ {
+		if err := os.WriteFile(filepath.Join(root, fmt.Sprintf("f%02d.txt", i)), []byte("match\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Returns once the walker and every worker have stopped
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	result, err := NewSearchTools(cfg, session).Grep(ctx, map[string]interface{}{"pattern": "match"})
+	if err != context.Canceled {
+		t.Fatalf("got %+v, %v, want context.Canceled", result, err)
+	}
+}
@@ -0,0 +1,359 @@
+package tools
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"math"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tldw/tldw-agent/internal/types"
+)
+
+const (
+	// semanticChunkLines is the number of lines embedded per chunk.
+	semanticChunkLines = 40
+	// semanticMaxChunks bounds the chunks considered by one search.
+	semanticMaxChunks = 2000
+	// embeddingBatchSize is the number of inputs sent per embeddings call.
+	embeddingBatchSize = 64
+)
+
+// embeddingCacheFile is the cache of chunk embeddings, relative to the
+// workspace root, keyed by the SHA-256 of the chunk text.
+var embeddingCacheFile = filepath.Join(".tldw-agent", "embeddings.json")
+
+// SemanticMatch is a chunk of a file ranked by similarity to a query.
+type SemanticMatch struct {
+	Path      string  `json:"path"`
+	StartLine int     `json:"start_line"`
+	EndLine   int     `json:"end_line"`
+	Score     float64 `json:"score"`
+	Preview   string  `json:"preview"`
+}
+
+// textChunk is a range of lines from a file.
+type textChunk struct {
+	path      string
+	startLine int
+	endLine   int
+	text      string
+	hash      string
+}
+
+// Semantic ranks chunks of workspace files by embedding similarity to a
+// natural language query, using the embeddings API of server.llm_endpoint.
+// It returns ctx.Err() if ctx is done before the ranking is complete.
+func (t *SearchTools) Semantic(ctx context.Context, args map[string]interface{}) (*types.ToolResult, error) {
+	query, ok := args["query"].(string)
+	if !ok || query == "" {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     "query is required",
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+
+	var searchPaths []string
+	if paths, ok := args["paths"].([]interface{}); ok {
+		for _, p := range paths {
+			if s, ok := p.(string); ok {
+				searchPaths = append(searchPaths, s)
+			}
+		}
+	}
+	if len(searchPaths) == 0 {
+		searchPaths = []string{"."}
+	}
+
+	globPattern, _ := args["glob"].(string)
+
+	maxResults := 10
+	if m, ok := args["max_results"].(float64); ok {
+		maxResults = int(m)
+	}
+
+	chunks, truncated := t.collectChunks(ctx, searchPaths, globPattern)
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	root := t.session.Root()
+	cachePath := filepath.Join(root, embeddingCacheFile)
+	cache := loadEmbeddingCache(cachePath)
+
+	// Embed the query along with any chunks that are not cached yet
+	inputs := []string{query}
+	var pending []string
+	for _, c := range chunks {
+		if _, ok := cache[c.hash]; !ok {
+			inputs = append(inputs, c.text)
+			pending = append(pending, c.hash)
+			cache[c.hash] = nil // Don't embed duplicate chunks twice
+		}
+	}
+
+	embeddings, err := t.embed(ctx, inputs)
+	if ctx.Err() != nil {
+		return nil, ctx.Err()
+	}
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("embedding request failed: %v", err),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+	queryEmbedding := embeddings[0]
+	for i, hash := range pending {
+		cache[hash] = embeddings[i+1]
+	}
+	if len(pending) > 0 {
+		// Best effort: a cache that can't be written only costs speed
+		_ = saveEmbeddingCache(cachePath, cache)
+	}
+
+	matches := make([]SemanticMatch, 0, len(chunks))
+	for _, c := range chunks {
+		relPath, _ := filepath.Rel(root, c.path)
+		matches = append(matches, SemanticMatch{
+			Path:      relPath,
+			StartLine: c.startLine,
+			EndLine:   c.endLine,
+			Score:     cosineSimilarity(queryEmbedding, cache[c.hash]),
+			Preview:   firstLines(c.text, 5),
+		})
+	}
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Score > matches[j].Score
+	})
+	if len(matches) > maxResults {
+		matches = matches[:maxResults]
+	}
+
+	return &types.ToolResult{
+		OK: true,
+		Data: map[string]interface{}{
+			"matches":          matches,
+			"chunks_searched":  len(chunks),
+			"chunks_truncated": truncated,
+		},
+	}, nil
+}
+
+// collectChunks splits the text files under searchPaths into chunks. It
+// reports whether semanticMaxChunks was reached, and stops early once ctx
+// is done.
+func (t *SearchTools) collectChunks(ctx context.Context, searchPaths []string, globPattern string) ([]textChunk, bool) {
+	var chunks []textChunk
+
+	for _, searchPath := range searchPaths {
+		absPath, err := t.session.ResolvePath(searchPath)
+		if err != nil {
+			continue // Skip invalid paths
+		}
+
+		filepath.WalkDir(absPath, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return nil // Skip entries we can't access
+			}
+			if ctx.Err() != nil {
+				return filepath.SkipAll
+			}
+
+			if d.IsDir() {
+				name := d.Name()
+				if path != absPath && (strings.HasPrefix(name, ".") || name == "node_modules" || name == "vendor" || name == "__pycache__") {
+					return filepath.SkipDir
+				}
+				if path != absPath && isGitIgnored(t.session, path, true) {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+
+			if !d.Type().IsRegular() || isBinaryFile(d.Name()) || isGitIgnored(t.session, path, false) {
+				return nil
+			}
+			if globPattern != "" {
+				if matched, _ := filepath.Match(globPattern, d.Name()); !matched {
+					return nil
+				}
+			}
+			if info, err := d.Info(); err != nil || info.Size() > t.config.Workspace.MaxFileSizeBytes {
+				return nil
+			}
+
+			data, err := os.ReadFile(path)
+			if err != nil {
+				return nil
+			}
+			chunks = append(chunks, chunkFile(path, string(data))...)
+			if len(chunks) >= semanticMaxChunks {
+				return filepath.SkipAll
+			}
+			return nil
+		})
+
+		if len(chunks) >= semanticMaxChunks {
+			return chunks[:semanticMaxChunks], true
+		}
+	}
+
+	return chunks, false
+}
+
+// chunkFile splits content into chunks of semanticChunkLines lines,
+// skipping chunks that are only whitespace.
+func chunkFile(path, content string) []textChunk {
+	lines := strings.Split(content, "\n")
+
+	var chunks []textChunk
+	for start := 0; start < len(lines); start += semanticChunkLines {
+		end := min(start+semanticChunkLines, len(lines))
+		text := strings.Join(lines[start:end], "\n")
+		if strings.TrimSpace(text) == "" {
+			continue
+		}
+		sum := sha256.Sum256([]byte(text))
+		chunks = append(chunks, textChunk{
+			path:      path,
+			startLine: start + 1,
+			endLine:   end,
+			text:      text,
+			hash:      hex.EncodeToString(sum[:]),
+		})
+	}
+	return chunks
+}
+
+// embed returns an embedding for each input, calling the OpenAI-compatible
+// /v1/embeddings endpoint in batches.
+func (t *SearchTools) embed(ctx context.Context, inputs []string) ([][]float64, error) {
+	ctx, cancel := context.WithTimeout(ctx, time.Duration(t.config.Execution.TimeoutMs)*time.Millisecond)
+	defer cancel()
+
+	endpoint := strings.TrimRight(t.config.Server.LLMEndpoint, "/") + "/v1/embeddings"
+	embeddings := make([][]float64, 0, len(inputs))
+
+	for start := 0; start < len(inputs); start += embeddingBatchSize {
+		batch := inputs[start:min(start+embeddingBatchSize, len(inputs))]
+
+		request := map[string]interface{}{"input": batch}
+		if model := t.config.Server.EmbeddingModel; model != "" {
+			request["model"] = model
+		}
+		body, err := json.Marshal(request)
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		if key := t.config.Server.APIKey; key != "" {
+			req.Header.Set("Authorization", "Bearer "+key)
+		}
+
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		data, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		if resp.StatusCode != http.StatusOK {
+			return nil, fmt.Errorf("%s returned %s", endpoint, resp.Status)
+		}
+
+		var parsed struct {
+			Data []struct {
+				Index     int       `json:"index"`
+				Embedding []float64 `json:"embedding"`
+			} `json:"data"`
+		}
+		if err := json.Unmarshal(data, &parsed); err != nil {
+			return nil, fmt.Errorf("invalid embeddings response: %w", err)
+		}
+		if len(parsed.Data) != len(batch) {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", len(batch), len(parsed.Data))
+		}
+
+		sort.Slice(parsed.Data, func(i, j int) bool {
+			return parsed.Data[i].Index < parsed.Data[j].Index
+		})
+		for _, d := range parsed.Data {
+			embeddings = append(embeddings, d.Embedding)
+		}
+	}
+
+	return embeddings, nil
+}
+
+// loadEmbeddingCache reads the embedding cache, returning an empty cache
+// if it is missing or unreadable.
+func loadEmbeddingCache(path string) map[string][]float64 {
+	cache := make(map[string][]float64)
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return cache
+	}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		return make(map[string][]float64)
+	}
+	return cache
+}
+
+// saveEmbeddingCache writes the embedding cache.
+func saveEmbeddingCache(path string, cache map[string][]float64) error {
+	data, err := json.Marshal(cache)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	return os.WriteFile(path, data, 0644)
+}
+
+// cosineSimilarity returns the cosine of the angle between a and b, or 0
+// if they are empty or of different lengths.
+func cosineSimilarity(a, b []float64) float64 {
+	if len(a) == 0 || len(a) != len(b) {
+		return 0
+	}
+
+	var dot, normA, normB float64
+	for i := range a {
+		dot += a[i] * b[i]
+		normA += a[i] * a[i]
+		normB += b[i] * b[i]
+	}
+	if normA == 0 || normB == 0 {
+		return 0
+	}
+	return dot / (math.Sqrt(normA) * math.Sqrt(normB))
+}
+
+// firstLines returns up to n lines of text.
+func firstLines(text string, n int) string {
+	lines := strings.SplitN(text, "\n", n+1)
+	if len(lines) > n {
+		lines = lines[:n]
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,10 @@
+//go:build !unix && !windows
+
+package tools
+
+import "errors"
+
+// filesystemSpace is not supported on this platform.
+func filesystemSpace(path string) (available, total uint64, err error) {
+	return 0, 0, errors.New("filesystem space is not available on this platform")
+}
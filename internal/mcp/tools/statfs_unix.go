@@ -0,0 +1,15 @@
+//go:build unix
+
+package tools
+
+import "golang.org/x/sys/unix"
+
+// filesystemSpace returns the bytes available to unprivileged users and the
+// total size of the filesystem containing path.
+func filesystemSpace(path string) (available, total uint64, err error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(path, &st); err != nil {
+		return 0, 0, err
+	}
+	return uint64(st.Bavail) * uint64(st.Bsize), uint64(st.Blocks) * uint64(st.Bsize), nil
+}
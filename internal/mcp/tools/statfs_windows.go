@@ -0,0 +1,18 @@
+//go:build windows
+
+package tools
+
+import "golang.org/x/sys/windows"
+
+// filesystemSpace returns the bytes available to the current user and the
+// total size of the volume containing path.
+func filesystemSpace(path string) (available, total uint64, err error) {
+	dir, err := windows.UTF16PtrFromString(path)
+	if err != nil {
+		return 0, 0, err
+	}
+	if err := windows.GetDiskFreeSpaceEx(dir, &available, &total, nil); err != nil {
+		return 0, 0, err
+	}
+	return available, total, nil
+}
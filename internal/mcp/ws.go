@@ -0,0 +1,98 @@
+package mcp
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+// WSHandler serves MCP over WebSocket. Each text message from the client
+// is one JSON-RPC request; responses are written back on the same
+// connection as they complete, so slow tool calls don't block others.
+type WSHandler struct {
+	server   *Server
+	upgrader websocket.Upgrader
+}
+
+// NewWSHandler returns a WebSocket handler dispatching to server, which
+// may be shared with other transports. It selects WSProtocol when the
+// client offers it, never the subprotocol carrying the token.
+func NewWSHandler(server *Server) *WSHandler {
+	h := &WSHandler{server: server}
+	h.upgrader.CheckOrigin = h.checkOrigin
+	h.upgrader.Subprotocols = []string{WSProtocol}
+	return h
+}
+
+// checkOrigin accepts same-origin requests, requests without an Origin
+// header, and origins listed in server.cors_origins.
+func (h *WSHandler) checkOrigin(r *http.Request) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return true
+	}
+	if u, err := url.Parse(origin); err == nil && strings.EqualFold(u.Host, r.Host) {
+		return true
+	}
+	return originAllowed(origin, h.server.Config().Server.CORSOrigins)
+}
+
+// ServeHTTP upgrades the request to a WebSocket and serves it until the
+// client disconnects.
+func (h *WSHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	conn, err := h.upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// Upgrade has already replied with an HTTP error
+		return
+	}
+	defer conn.Close()
+	conn.SetReadLimit(h.server.Config().Server.MaxMessageBytes)
+
+	// In-flight tool calls are cancelled once the client goes away
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	var writeMu sync.Mutex
+	write := func(resp *rpcResponse) {
+		writeMu.Lock()
+		defer writeMu.Unlock()
+		if err := conn.WriteJSON(resp); err != nil {
+			log.Printf("mcp: websocket write failed: %v", err)
+		}
+	}
+
+	var wg sync.WaitGroup
+	defer wg.Wait()
+	for {
+		msgType, data, err := conn.ReadMessage()
+		if err != nil {
+			if !websocket.IsCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				log.Printf("mcp: websocket read failed: %v", err)
+			}
+			cancel()
+			return
+		}
+		if msgType != websocket.TextMessage {
+			continue
+		}
+
+		var req rpcRequest
+		if err := json.Unmarshal(data, &req); err != nil {
+			write(newRPCError(nil, rpcParseError, "parse error"))
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if resp := h.server.handleRPC(ctx, &req); resp != nil {
+				write(resp)
+			}
+		}()
+	}
+}
@@ -10,13 +10,20 @@ import (
 )
 
 const (
-	// MaxMessageSize is the maximum allowed message size (1MB)
+	// MaxMessageSize is the default maximum message size (1MB). It is also
+	// the limit for messages written, since browsers reject larger ones
+	// from a native host.
 	MaxMessageSize = 1024 * 1024
 )
 
-// ReadMessage reads a native messaging message from the reader.
+// ReadMessage reads a native messaging message from the reader, rejecting
+// messages larger than maxSize bytes (MaxMessageSize if maxSize <= 0).
 // The format is: 4-byte little-endian length prefix + JSON body.
-func ReadMessage(r io.Reader) ([]byte, error) {
+func ReadMessage(r io.Reader, maxSize int) ([]byte, error) {
+	if maxSize <= 0 {
+		maxSize = MaxMessageSize
+	}
+
 	// Read the 4-byte length prefix
 	var length uint32
 	if err := binary.Read(r, binary.LittleEndian, &length); err != nil {
@@ -30,8 +37,8 @@ func ReadMessage(r io.Reader) ([]byte, error) {
 	if length == 0 {
 		return nil, fmt.Errorf("message length is zero")
 	}
-	if length > MaxMessageSize {
-		return nil, fmt.Errorf("message length %d exceeds maximum %d", length, MaxMessageSize)
+	if uint64(length) > uint64(maxSize) {
+		return nil, fmt.Errorf("message length %d exceeds maximum %d", length, maxSize)
 	}
 
 	// Read the message body
@@ -65,9 +72,10 @@ func WriteMessage(w io.Writer, data []byte) error {
 	return nil
 }
 
-// ReadJSON reads and unmarshals a JSON message from the reader.
-func ReadJSON(r io.Reader, v interface{}) error {
-	data, err := ReadMessage(r)
+// ReadJSON reads and unmarshals a JSON message from the reader. Messages
+// larger than maxSize bytes are rejected, as in ReadMessage.
+func ReadJSON(r io.Reader, v interface{}, maxSize int) error {
+	data, err := ReadMessage(r, maxSize)
 	if err != nil {
 		return err
 	}
@@ -1,11 +1,17 @@
 package native
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"log"
 	"os"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
 
 	"github.com/tldw/tldw-agent/internal/config"
 	"github.com/tldw/tldw-agent/internal/mcp"
@@ -25,6 +31,7 @@ type Response struct {
 	Data      interface{} `json:"data,omitempty"`
 	Error     *ErrorInfo  `json:"error,omitempty"`
 	Streaming bool        `json:"streaming,omitempty"`
+	Warnings  []string    `json:"warnings,omitempty"`
 }
 
 // ErrorInfo contains error details.
@@ -34,21 +41,41 @@ type ErrorInfo struct {
 }
 
 // Handler manages native messaging communication with the browser extension.
+// The configuration is read from the MCP server so that a reload applied
+// there is visible to the handler as well.
 type Handler struct {
 	mcpServer *mcp.Server
-	config    *config.Config
 	stdin     io.Reader
 	stdout    io.Writer
+	logger    Logger // Request log, nil unless TLDW_DEBUG_LOG=true
+
+	writeMu sync.Mutex // Serializes messages written to stdout
+
+	inflightMu sync.Mutex
+	inflight   map[string]context.CancelFunc // Cancel funcs by request ID
+	wg         sync.WaitGroup                // Outstanding tool calls
+
+	secrets secretScanner // Redacts security.secret_patterns from tool results
 }
 
-// NewHandler creates a new native messaging handler.
-func NewHandler(mcpServer *mcp.Server, cfg *config.Config) *Handler {
-	return &Handler{
+// NewHandler creates a new native messaging handler. Setting
+// TLDW_DEBUG_LOG=true logs every request as a JSON line on stderr.
+func NewHandler(mcpServer *mcp.Server) *Handler {
+	h := &Handler{
 		mcpServer: mcpServer,
-		config:    cfg,
 		stdin:     os.Stdin,
 		stdout:    os.Stdout,
+		inflight:  make(map[string]context.CancelFunc),
 	}
+	if os.Getenv("TLDW_DEBUG_LOG") == "true" {
+		h.logger = NewJSONLogger(os.Stderr)
+	}
+	return h
+}
+
+// SetLogger replaces the request logger. A nil logger disables logging.
+func (h *Handler) SetLogger(logger Logger) {
+	h.logger = logger
 }
 
 // Run starts the native messaging loop.
@@ -58,9 +85,10 @@ func (h *Handler) Run() error {
 	for {
 		// Read incoming request
 		var req Request
-		if err := ReadJSON(h.stdin, &req); err != nil {
+		if err := ReadJSON(h.stdin, &req, int(h.mcpServer.Config().Server.MaxMessageBytes)); err != nil {
 			if err == io.EOF {
 				log.Println("EOF received, shutting down")
+				h.wg.Wait()
 				return nil
 			}
 			log.Printf("Error reading request: %v", err)
@@ -69,11 +97,53 @@ func (h *Handler) Run() error {
 
 		log.Printf("Received request: id=%s type=%s", req.ID, req.Type)
 
-		// Process request and send response
-		resp := h.handleRequest(&req)
-		if err := WriteJSON(h.stdout, resp); err != nil {
-			log.Printf("Error writing response: %v", err)
+		// Tool calls run concurrently so that they can be cancelled by a
+		// later request
+		if req.Type == "mcp_request" {
+			h.wg.Add(1)
+			go func() {
+				defer h.wg.Done()
+				h.dispatch(&req)
+			}()
+			continue
+		}
+		// Everything else is quick and runs in order on this loop, so
+		// config_update patches apply one at a time. SetConfig does not
+		// wait for running tool calls, so a reload never stalls reading.
+		h.dispatch(&req)
+	}
+}
+
+// dispatch processes a request and sends its response. Streaming handlers
+// write their own messages and return nil.
+func (h *Handler) dispatch(req *Request) {
+	start := time.Now()
+	resp := h.handleRequest(req)
+	if resp != nil {
+		h.writeResponse(resp)
+	}
+
+	if h.logger != nil {
+		entry := LogEntry{
+			Time:       start,
+			RequestID:  req.ID,
+			Type:       req.Type,
+			OK:         resp == nil || resp.OK,
+			DurationMs: time.Since(start).Milliseconds(),
 		}
+		if resp != nil && resp.Error != nil {
+			entry.Error = resp.Error.Message
+		}
+		h.logger.LogRequest(entry)
+	}
+}
+
+// writeResponse sends a single response message to the extension.
+func (h *Handler) writeResponse(resp *Response) {
+	h.writeMu.Lock()
+	defer h.writeMu.Unlock()
+	if err := WriteJSON(h.stdout, resp); err != nil {
+		log.Printf("Error writing response: %v", err)
 	}
 }
 
@@ -84,8 +154,20 @@ func (h *Handler) handleRequest(req *Request) *Response {
 		return h.handlePing(req)
 	case "config":
 		return h.handleConfig(req)
+	case "config.schema":
+		return h.handleConfigSchema(req)
+	case "config_update":
+		return h.handleConfigUpdate(req)
 	case "mcp_request":
 		return h.handleMCPRequest(req)
+	case "cancel":
+		return h.handleCancel(req)
+	case "workspace_open":
+		return h.handleWorkspaceOpen(req)
+	case "workspace_close":
+		return h.handleWorkspaceClose(req)
+	case "workspace_list":
+		return h.handleWorkspaceList(req)
 	case "mcp_list_tools":
 		return h.handleListTools(req)
 	default:
@@ -106,8 +188,9 @@ func (h *Handler) handlePing(req *Request) *Response {
 		ID: req.ID,
 		OK: true,
 		Data: map[string]interface{}{
-			"version": "0.1.0",
-			"status":  "ready",
+			"version":                  "0.1.0",
+			"status":                   "ready",
+			"security_redaction_count": h.secrets.redactions.Load(),
 		},
 	}
 }
@@ -115,20 +198,89 @@ func (h *Handler) handlePing(req *Request) *Response {
 // handleConfig returns or updates configuration.
 func (h *Handler) handleConfig(req *Request) *Response {
 	// For now, just return current config (read-only)
+	cfg := h.mcpServer.Config()
+	return &Response{
+		ID: req.ID,
+		OK: true,
+		Data: map[string]interface{}{
+			"llm_endpoint":      cfg.Server.LLMEndpoint,
+			"execution_enabled": cfg.Execution.Enabled,
+			"shell":             cfg.GetShell(),
+		},
+	}
+}
+
+// handleConfigSchema returns the JSON Schema for the config file.
+func (h *Handler) handleConfigSchema(req *Request) *Response {
+	return &Response{
+		ID:   req.ID,
+		OK:   true,
+		Data: json.RawMessage(config.Schema),
+	}
+}
+
+// handleConfigUpdate merges a partial config into the running config. The
+// response lists the changed keys but never their values, so secrets such
+// as server.api_key can be set without being echoed back. Validation
+// warnings are returned alongside but do not block the update.
+func (h *Handler) handleConfigUpdate(req *Request) *Response {
+	current := h.mcpServer.Config()
+	updated, err := config.ApplyPatch(current, req.Payload)
+	if err != nil {
+		return &Response{
+			ID: req.ID,
+			OK: false,
+			Error: &ErrorInfo{
+				Code:    "invalid_payload",
+				Message: err.Error(),
+			},
+		}
+	}
+
+	issues := updated.Validate()
+	if errs := config.Errors(issues); len(errs) > 0 {
+		messages := make([]string, len(errs))
+		for i, e := range errs {
+			messages[i] = e.Error()
+		}
+		return &Response{
+			ID: req.ID,
+			OK: false,
+			Error: &ErrorInfo{
+				Code:    "invalid_config",
+				Message: strings.Join(messages, "; "),
+			},
+		}
+	}
+
+	changed := config.Diff(current, updated)
+	if len(changed) == 0 {
+		changed = []string{}
+	} else {
+		h.mcpServer.SetConfig(updated)
+	}
+
+	warnings := []string{}
+	for _, issue := range issues {
+		if issue.Warning {
+			warnings = append(warnings, issue.Error())
+		}
+	}
+
 	return &Response{
 		ID: req.ID,
 		OK: true,
 		Data: map[string]interface{}{
-			"llm_endpoint":    h.config.Server.LLMEndpoint,
-			"execution_enabled": h.config.Execution.Enabled,
-			"shell":           h.config.GetShell(),
+			"changed":  changed,
+			"warnings": warnings,
 		},
 	}
 }
 
-// handleListTools returns the list of available MCP tools.
+// handleListTools returns the MCP tools, marking those unavailable in the
+// current workspace.
 func (h *Handler) handleListTools(req *Request) *Response {
-	tools := h.mcpServer.ListTools()
+	tools := h.mcpServer.FilterAvailable()
 	return &Response{
 		ID:   req.ID,
 		OK:   true,
@@ -136,11 +288,84 @@ func (h *Handler) handleListTools(req *Request) *Response {
 	}
 }
 
+// trackRequest records the cancel func for an in-flight request.
+func (h *Handler) trackRequest(id string, cancel context.CancelFunc) {
+	h.inflightMu.Lock()
+	defer h.inflightMu.Unlock()
+	h.inflight[id] = cancel
+}
+
+// untrackRequest releases an in-flight request once it has completed.
+func (h *Handler) untrackRequest(id string) {
+	h.inflightMu.Lock()
+	cancel, ok := h.inflight[id]
+	delete(h.inflight, id)
+	h.inflightMu.Unlock()
+	if ok {
+		cancel()
+	}
+}
+
+// CancelRequest represents a request to abort an in-flight tool call.
+type CancelRequest struct {
+	ID string `json:"id"`
+}
+
+// handleCancel aborts the in-flight request with the given ID.
+func (h *Handler) handleCancel(req *Request) *Response {
+	var cancelReq CancelRequest
+	if err := json.Unmarshal(req.Payload, &cancelReq); err != nil || cancelReq.ID == "" {
+		return &Response{
+			ID: req.ID,
+			OK: false,
+			Error: &ErrorInfo{
+				Code:    "invalid_payload",
+				Message: "cancel requires an id",
+			},
+		}
+	}
+
+	h.inflightMu.Lock()
+	cancel, ok := h.inflight[cancelReq.ID]
+	h.inflightMu.Unlock()
+	if ok {
+		cancel()
+	}
+
+	return &Response{
+		ID: req.ID,
+		OK: true,
+		Data: map[string]interface{}{
+			"id":        cancelReq.ID,
+			"cancelled": ok,
+		},
+	}
+}
+
+// streamChunkSize is the size of each chunk of a streamed tool result. It
+// keeps every message well below MaxMessageSize after JSON escaping.
+const streamChunkSize = 64 * 1024
+
+// streamingTools are tools whose results can be large, so they are sent as
+// a stream of chunks rather than a single response. exec.run also streams
+// its output while the command runs.
+var streamingTools = map[string]bool{
+	"exec.run":    true,
+	"search.grep": true,
+}
+
 // MCPRequest represents an MCP tool call request.
 type MCPRequest struct {
 	Method    string          `json:"method"`
 	ToolName  string          `json:"tool_name"`
 	Arguments json.RawMessage `json:"arguments"`
+
+	// Calls holds the tool calls of a tools/call_batch request.
+	Calls []mcp.ToolCall `json:"calls,omitempty"`
+
+	// Approved is set by the extension once the user has approved the
+	// call, for tools gated by security.tool_approval_rules.
+	Approved bool `json:"approved,omitempty"`
 }
 
 // handleMCPRequest processes an MCP tool call.
@@ -160,7 +385,86 @@ func (h *Handler) handleMCPRequest(req *Request) *Response {
 	// Handle different MCP methods
 	switch mcpReq.Method {
 	case "tools/call":
-		result, err := h.mcpServer.ExecuteTool(mcpReq.ToolName, mcpReq.Arguments)
+		ctx, cancel := context.WithCancel(context.Background())
+		h.trackRequest(req.ID, cancel)
+		defer h.untrackRequest(req.ID)
+		if mcpReq.Approved {
+			ctx = mcp.WithApproval(ctx)
+		}
+		if mcpReq.ToolName == "exec.run" {
+			ctx = mcp.WithOutput(ctx, func(stream string, chunk []byte) {
+				h.streamOutput(req.ID, stream, chunk)
+			})
+		}
+
+		result, err := h.mcpServer.ExecuteToolWithContext(ctx, mcpReq.ToolName, mcpReq.Arguments)
+		if errors.Is(err, context.Canceled) {
+			return &Response{
+				ID: req.ID,
+				OK: false,
+				Error: &ErrorInfo{
+					Code:    "cancelled",
+					Message: "request was cancelled",
+				},
+			}
+		}
+		if err != nil {
+			return &Response{
+				ID: req.ID,
+				OK: false,
+				Error: &ErrorInfo{
+					Code:    "tool_error",
+					Message: err.Error(),
+				},
+			}
+		}
+		if streamingTools[mcpReq.ToolName] && result.OK {
+			return h.streamResult(req, result)
+		}
+		data, err := h.encodeResult(result)
+		if err != nil {
+			return &Response{
+				ID: req.ID,
+				OK: false,
+				Error: &ErrorInfo{
+					Code:    "tool_error",
+					Message: fmt.Sprintf("failed to encode result: %v", err),
+				},
+			}
+		}
+		if !result.OK {
+			return toolErrorResponse(req.ID, data)
+		}
+		return &Response{
+			ID:       req.ID,
+			OK:       true,
+			Data:     json.RawMessage(data),
+			Warnings: result.Warnings,
+		}
+
+	case "tools/call_batch":
+		ctx, cancel := context.WithCancel(context.Background())
+		h.trackRequest(req.ID, cancel)
+		defer h.untrackRequest(req.ID)
+		if mcpReq.Approved {
+			ctx = mcp.WithApproval(ctx)
+		}
+
+		results, err := h.mcpServer.ExecuteToolBatchWithContext(ctx, mcpReq.Calls)
+		if errors.Is(ctx.Err(), context.Canceled) {
+			return &Response{
+				ID: req.ID,
+				OK: false,
+				Error: &ErrorInfo{
+					Code:    "cancelled",
+					Message: "request was cancelled",
+				},
+			}
+		}
+		var data []byte
+		if err == nil {
+			data, err = h.encodeResult(results)
+		}
 		if err != nil {
 			return &Response{
 				ID: req.ID,
@@ -174,7 +478,7 @@ func (h *Handler) handleMCPRequest(req *Request) *Response {
 		return &Response{
 			ID:   req.ID,
 			OK:   true,
-			Data: result,
+			Data: json.RawMessage(data),
 		}
 
 	case "tools/list":
@@ -196,3 +500,105 @@ func (h *Handler) handleMCPRequest(req *Request) *Response {
 		}
 	}
 }
+
+// toolErrorResponse returns the response for a failed tool call. data is
+// the encoded result, kept in Data for fields such as retry_after_ms; the
+// error is read back from it so that it has been through the secret
+// scanner. Results without an error code report "tool_error".
+func toolErrorResponse(id string, data []byte) *Response {
+	var result mcp.ToolResult
+	_ = json.Unmarshal(data, &result)
+	code := result.ErrorCode
+	if code == "" {
+		code = "tool_error"
+	}
+	return &Response{
+		ID:   id,
+		OK:   false,
+		Data: json.RawMessage(data),
+		Error: &ErrorInfo{
+			Code:    code,
+			Message: result.Error,
+		},
+		Warnings: result.Warnings,
+	}
+}
+
+// encodeResult encodes a tool result, or a batch of them, for the
+// extension. With security.redact_secrets set, matches of
+// security.secret_patterns are replaced in the encoded bytes, covering
+// paths and error messages as well as data.
+func (h *Handler) encodeResult(result interface{}) ([]byte, error) {
+	data, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	if security := h.mcpServer.Config().Security; security.RedactSecrets && len(security.SecretPatterns) > 0 {
+		data = h.secrets.scan(security.SecretPatterns, data)
+	}
+	return data, nil
+}
+
+// streamOutput sends output of a running command as a streaming message
+// with Data.stream ("stdout" or "stderr") and Data.output. These arrive
+// before the result, which follows through streamResult or, if the call
+// failed, as a single error response.
+func (h *Handler) streamOutput(id, stream string, chunk []byte) {
+	if security := h.mcpServer.Config().Security; security.RedactSecrets && len(security.SecretPatterns) > 0 {
+		chunk = h.secrets.scan(security.SecretPatterns, chunk)
+	}
+	h.writeResponse(&Response{
+		ID:        id,
+		OK:        true,
+		Streaming: true,
+		Data: map[string]interface{}{
+			"stream": stream,
+			"output": string(chunk),
+		},
+	})
+}
+
+// streamResult sends a tool result as a series of streaming messages. Each
+// message carries a piece of the JSON-encoded result in Data.chunk; the
+// final message has Data.done set. The extension concatenates the chunks
+// and decodes them as a single result.
+func (h *Handler) streamResult(req *Request, result *mcp.ToolResult) *Response {
+	data, err := h.encodeResult(result)
+	if err != nil {
+		return &Response{
+			ID: req.ID,
+			OK: false,
+			Error: &ErrorInfo{
+				Code:    "tool_error",
+				Message: fmt.Sprintf("failed to encode result: %v", err),
+			},
+		}
+	}
+
+	for start, end := 0, 0; start < len(data); start = end {
+		end = min(start+streamChunkSize, len(data))
+		// Don't split a multi-byte UTF-8 sequence across chunks
+		for end < len(data) && !utf8.RuneStart(data[end]) {
+			end--
+		}
+		h.writeResponse(&Response{
+			ID:        req.ID,
+			OK:        true,
+			Streaming: true,
+			Data: map[string]interface{}{
+				"chunk": string(data[start:end]),
+			},
+		})
+	}
+
+	h.writeResponse(&Response{
+		ID:        req.ID,
+		OK:        true,
+		Streaming: true,
+		Data: map[string]interface{}{
+			"done": true,
+		},
+		Warnings: result.Warnings,
+	})
+	return nil
+}
@@ -0,0 +1,141 @@
+package native
+
+import (
+	"encoding/json"
+	"io"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/tldw/tldw-agent/internal/config"
+	"github.com/tldw/tldw-agent/internal/mcp"
+)
+
+// startHandler runs a handler over pipes and returns functions to send a
+// request and to read the next response.
+func startHandler(t *testing.T, server *mcp.Server) (send func(Request), recv func() Response) {
+	t.Helper()
+	inR, inW := io.Pipe()
+	outR, outW := io.Pipe()
+	h := NewHandler(server)
+	h.stdin = inR
+	h.stdout = outW
+	go h.Run()
+	t.Cleanup(func() { inW.Close() })
+
+	responses := make(chan Response, 16)
+	go func() {
+		for {
+			var resp Response
+			if err := ReadJSON(outR, &resp, 1<<20); err != nil {
+				close(responses)
+				return
+			}
+			responses <- resp
+		}
+	}()
+
+	send = func(req Request) {
+		if err := WriteJSON(inW, req); err != nil {
+			t.Fatalf("write request: %v", err)
+		}
+	}
+	recv = func() Response {
+		select {
+		case resp, ok := <-responses:
+			if !ok {
+				t.Fatal("handler closed its output")
+			}
+			return resp
+		case <-time.After(3 * time.Second):
+			t.Fatal("no response")
+		}
+		return Response{}
+	}
+	return send, recv
+}
+
+func TestConfigUpdateDuringLongCall(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sleep")
+	}
+	cfg := config.Default()
+	cfg.Execution.CustomCommands = []config.CustomCommand{{ID: "sleep", Template: "sleep 30"}}
+	server := mcp.NewServer(cfg)
+	if err := server.SetWorkspace(t.TempDir()); err != nil {
+		t.Fatalf("SetWorkspace failed: %v", err)
+	}
+	defer server.Close()
+	send, recv := startHandler(t, server)
+
+	send(Request{ID: "run", Type: "mcp_request", Payload: json.RawMessage(`{"method":"tools/call","tool_name":"exec.run","arguments":{"command_id":"sleep"}}`)})
+	time.Sleep(100 * time.Millisecond)
+
+	// The reload is answered while the command runs, and the command can
+	// still be cancelled afterwards
+	send(Request{ID: "update", Type: "config_update", Payload: json.RawMessage(`{"security":{"read_rpm":100}}`)})
+	if resp := recv(); resp.ID != "update" || !resp.OK {
+		t.Fatalf("unexpected response to config_update: %+v", resp)
+	}
+	if got := server.Config().Security.ReadRPM; got != 100 {
+		t.Fatalf("read_rpm = %d, want 100", got)
+	}
+
+	send(Request{ID: "cancel", Type: "cancel", Payload: json.RawMessage(`{"id":"run"}`)})
+	got := map[string]Response{}
+	for len(got) < 2 {
+		resp := recv()
+		got[resp.ID] = resp
+	}
+	if run := got["run"]; run.OK || run.Error == nil || run.Error.Code != "cancelled" {
+		t.Fatalf("expected the command to be cancelled, got %+v", run)
+	}
+}
+
+func TestExecRunStreamsOutput(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses sleep")
+	}
+	cfg := config.Default()
+	cfg.Execution.CustomCommands = []config.CustomCommand{{ID: "slow", Template: "echo first; sleep 30"}}
+	server := mcp.NewServer(cfg)
+	if err := server.SetWorkspace(t.TempDir()); err != nil {
+		t.Fatalf("SetWorkspace failed: %v", err)
+	}
+	defer server.Close()
+	send, recv := startHandler(t, server)
+
+	// The output arrives while the command is still running
+	send(Request{ID: "run", Type: "mcp_request", Payload: json.RawMessage(`{"method":"tools/call","tool_name":"exec.run","arguments":{"command_id":"slow"}}`)})
+	resp := recv()
+	data, _ := resp.Data.(map[string]interface{})
+	if resp.ID != "run" || !resp.Streaming || data["stream"] != "stdout" || data["output"] != "first\n" {
+		t.Fatalf("expected streamed output, got %+v", resp)
+	}
+
+	send(Request{ID: "cancel", Type: "cancel", Payload: json.RawMessage(`{"id":"run"}`)})
+	got := map[string]Response{}
+	for len(got) < 2 {
+		resp := recv()
+		got[resp.ID] = resp
+	}
+	if run := got["run"]; run.OK || run.Error == nil || run.Error.Code != "cancelled" {
+		t.Fatalf("expected the command to be cancelled, got %+v", run)
+	}
+}
+
+func TestConfigSchemaServesEmbeddedAsset(t *testing.T) {
+	server := mcp.NewServer(config.Default())
+	defer server.Close()
+	send, recv := startHandler(t, server)
+
+	send(Request{ID: "schema", Type: "config.schema"})
+	resp := recv()
+	if !resp.OK {
+		t.Fatalf("config.schema failed: %+v", resp.Error)
+	}
+	data, _ := resp.Data.(map[string]interface{})
+	if data["$schema"] != "http://json-schema.org/draft-07/schema#" || data["properties"] == nil {
+		t.Fatalf("unexpected schema: %v", resp.Data)
+	}
+}
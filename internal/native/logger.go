@@ -0,0 +1,46 @@
+package native
+
+import (
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// LogEntry describes one handled request.
+type LogEntry struct {
+	Time       time.Time `json:"ts"`
+	RequestID  string    `json:"req_id"`
+	Type       string    `json:"type"`
+	OK         bool      `json:"ok"`
+	DurationMs int64     `json:"duration_ms"`
+	Error      string    `json:"error,omitempty"`
+}
+
+// Logger records handled requests.
+type Logger interface {
+	LogRequest(entry LogEntry)
+}
+
+// JSONLogger writes each entry as a single line of JSON.
+type JSONLogger struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// NewJSONLogger creates a logger that writes to w.
+func NewJSONLogger(w io.Writer) *JSONLogger {
+	return &JSONLogger{w: w}
+}
+
+// LogRequest implements Logger.
+func (l *JSONLogger) LogRequest(entry LogEntry) {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.w.Write(append(data, '\n'))
+}
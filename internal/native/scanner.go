@@ -0,0 +1,77 @@
+package native
+
+import (
+	"regexp"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+// redactedText replaces each secret found by a Scanner.
+var redactedText = []byte("[REDACTED]")
+
+// Scanner replaces matches of a set of secret patterns in outgoing
+// messages. It catches secrets that reach a response other than through
+// tool result data, such as in paths or error messages.
+type Scanner struct {
+	patterns []*regexp.Regexp
+}
+
+// NewScanner compiles patterns into a Scanner.
+func NewScanner(patterns []string) (*Scanner, error) {
+	s := &Scanner{}
+	for _, pattern := range patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, err
+		}
+		s.patterns = append(s.patterns, re)
+	}
+	return s, nil
+}
+
+// Scan returns data with every match replaced by [REDACTED], along with
+// the number of replacements made. data is returned as is when nothing
+// matches.
+func (s *Scanner) Scan(data []byte) ([]byte, int) {
+	count := 0
+	for _, re := range s.patterns {
+		matches := len(re.FindAllIndex(data, -1))
+		if matches == 0 {
+			continue
+		}
+		count += matches
+		data = re.ReplaceAllLiteral(data, redactedText)
+	}
+	return data, count
+}
+
+// secretScanner builds the Scanner for the current security config and
+// counts its redactions. The Scanner is rebuilt when a config reload
+// changes the patterns.
+type secretScanner struct {
+	mu       sync.Mutex
+	patterns []string
+	scanner  *Scanner
+
+	redactions atomic.Int64 // Reported by ping as security_redaction_count
+}
+
+// scan redacts data using patterns, returning data unchanged if patterns
+// is empty or fails to compile (config validation rejects bad patterns).
+func (s *secretScanner) scan(patterns []string, data []byte) []byte {
+	s.mu.Lock()
+	if s.scanner == nil || !slices.Equal(s.patterns, patterns) {
+		scanner, err := NewScanner(patterns)
+		if err != nil {
+			scanner = &Scanner{}
+		}
+		s.scanner, s.patterns = scanner, slices.Clone(patterns)
+	}
+	scanner := s.scanner
+	s.mu.Unlock()
+
+	data, count := scanner.Scan(data)
+	s.redactions.Add(int64(count))
+	return data
+}
@@ -0,0 +1,176 @@
+package native
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/tldw/tldw-agent/internal/config"
+)
+
+// maxRecentWorkspaces is the number of workspaces kept in recent.json.
+const maxRecentWorkspaces = 20
+
+// RecentWorkspace is an entry in the recently used workspaces list.
+type RecentWorkspace struct {
+	Path     string    `json:"path"`
+	OpenedAt time.Time `json:"opened_at"`
+}
+
+// WorkspaceOpenRequest is the payload of a workspace_open request.
+type WorkspaceOpenRequest struct {
+	Path string `json:"path"`
+}
+
+// recentWorkspacesPath returns the location of recent.json.
+func recentWorkspacesPath() string {
+	dir := config.DataDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, "recent.json")
+}
+
+// loadRecentWorkspaces reads the recently used workspaces, most recent
+// first. A missing file yields an empty list.
+func loadRecentWorkspaces() ([]RecentWorkspace, error) {
+	path := recentWorkspacesPath()
+	if path == "" {
+		return nil, fmt.Errorf("could not determine home directory")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []RecentWorkspace{}, nil
+		}
+		return nil, fmt.Errorf("failed to read recent workspaces: %w", err)
+	}
+
+	var recent []RecentWorkspace
+	if err := json.Unmarshal(data, &recent); err != nil {
+		return nil, fmt.Errorf("failed to parse recent workspaces: %w", err)
+	}
+	return recent, nil
+}
+
+// recordRecentWorkspace moves path to the front of recent.json.
+func recordRecentWorkspace(path string) error {
+	recent, err := loadRecentWorkspaces()
+	if err != nil {
+		recent = nil // Start over rather than fail on a corrupt file
+	}
+
+	updated := []RecentWorkspace{{Path: path, OpenedAt: time.Now()}}
+	for _, w := range recent {
+		if len(updated) >= maxRecentWorkspaces {
+			break
+		}
+		if w.Path != path {
+			updated = append(updated, w)
+		}
+	}
+
+	data, err := json.MarshalIndent(updated, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode recent workspaces: %w", err)
+	}
+
+	file := recentWorkspacesPath()
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return fmt.Errorf("failed to create directory: %w", err)
+	}
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		return fmt.Errorf("failed to write recent workspaces: %w", err)
+	}
+	return nil
+}
+
+// handleWorkspaceOpen sets the workspace root used by MCP tools.
+func (h *Handler) handleWorkspaceOpen(req *Request) *Response {
+	var openReq WorkspaceOpenRequest
+	if err := json.Unmarshal(req.Payload, &openReq); err != nil || openReq.Path == "" {
+		return &Response{
+			ID: req.ID,
+			OK: false,
+			Error: &ErrorInfo{
+				Code:    "invalid_payload",
+				Message: "workspace_open requires a path",
+			},
+		}
+	}
+
+	if err := h.mcpServer.SetWorkspace(openReq.Path); err != nil {
+		return &Response{
+			ID: req.ID,
+			OK: false,
+			Error: &ErrorInfo{
+				Code:    "workspace_error",
+				Message: err.Error(),
+			},
+		}
+	}
+
+	root := h.mcpServer.WorkspaceRoot()
+	if err := recordRecentWorkspace(root); err != nil {
+		// Not fatal: the workspace is open, it just won't be remembered
+		log.Printf("Warning: %v", err)
+	}
+
+	return &Response{
+		ID: req.ID,
+		OK: true,
+		Data: map[string]interface{}{
+			"path": root,
+		},
+	}
+}
+
+// handleWorkspaceClose returns to the default workspace.
+func (h *Handler) handleWorkspaceClose(req *Request) *Response {
+	if err := h.mcpServer.ResetWorkspace(); err != nil {
+		return &Response{
+			ID: req.ID,
+			OK: false,
+			Error: &ErrorInfo{
+				Code:    "workspace_error",
+				Message: err.Error(),
+			},
+		}
+	}
+
+	return &Response{
+		ID: req.ID,
+		OK: true,
+		Data: map[string]interface{}{
+			"path": h.mcpServer.WorkspaceRoot(),
+		},
+	}
+}
+
+// handleWorkspaceList returns recently used workspaces, most recent first.
+func (h *Handler) handleWorkspaceList(req *Request) *Response {
+	recent, err := loadRecentWorkspaces()
+	if err != nil {
+		return &Response{
+			ID: req.ID,
+			OK: false,
+			Error: &ErrorInfo{
+				Code:    "workspace_error",
+				Message: err.Error(),
+			},
+		}
+	}
+
+	return &Response{
+		ID: req.ID,
+		OK: true,
+		Data: map[string]interface{}{
+			"workspaces": recent,
+			"current":    h.mcpServer.WorkspaceRoot(),
+		},
+	}
+}
@@ -1,9 +1,57 @@
 // Package types provides shared types for the tldw-agent.
 package types
 
+import (
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+)
+
 // ToolResult represents the result of a tool execution.
 type ToolResult struct {
 	OK    bool        `json:"ok"`
 	Data  interface{} `json:"data,omitempty"`
 	Error string      `json:"error,omitempty"`
+
+	// ErrorCode classifies a failure for programmatic handling. It is one
+	// of the Err constants, or empty when no code applies.
+	ErrorCode string `json:"error_code,omitempty"`
+
+	// Warnings are advisory notes about a call that still succeeded.
+	Warnings []string `json:"warnings,omitempty"`
+
+	// Cached is set when the result was served from the tool result cache.
+	Cached bool `json:"cached,omitempty"`
+
+	// RetryAfterMs is set when a call was rejected by a rate limit.
+	RetryAfterMs int64 `json:"retry_after_ms,omitempty"`
+}
+
+// Error codes for ToolResult.ErrorCode.
+const (
+	ErrNotFound     = "not_found"
+	ErrPermission   = "permission_denied"
+	ErrTooLarge     = "too_large"
+	ErrInvalidArg   = "invalid_argument"
+	ErrExecDisabled = "exec_disabled"
+	ErrGitNotRepo   = "git_not_repo"
+	ErrTimeout      = "timeout"
+)
+
+// ErrorCodeFor returns the error code matching err: ErrNotFound for missing
+// files, ErrPermission for denied access, and ErrTimeout for deadlines. It
+// returns "" for other errors.
+func ErrorCodeFor(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, fs.ErrNotExist):
+		return ErrNotFound
+	case errors.Is(err, fs.ErrPermission):
+		return ErrPermission
+	case errors.Is(err, context.DeadlineExceeded), errors.Is(err, os.ErrDeadlineExceeded):
+		return ErrTimeout
+	}
+	return ""
 }
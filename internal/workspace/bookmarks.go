@@ -0,0 +1,211 @@
+package workspace
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/tldw/tldw-agent/internal/types"
+)
+
+// BookmarksFileName is the sidecar file, relative to the workspace root,
+// that bookmarks are persisted to.
+const BookmarksFileName = ".tldw-agent-bookmarks.json"
+
+// AddBookmark registers label as a shorthand for path, which must be under
+// the active root. Relative paths are resolved against the current working
+// directory. The bookmark keeps pointing at the same directory when
+// another root becomes active, and is persisted to the sidecar file of the
+// root it is under.
+func (s *Session) AddBookmark(label, path string) error {
+	if label == "" || strings.ContainsAny(label, "@/\\") {
+		return fmt.Errorf("invalid bookmark label: %q", label)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.root == "" {
+		return fmt.Errorf("no workspace set")
+	}
+
+	absPath := path
+	if !filepath.IsAbs(path) {
+		absPath = filepath.Join(s.root, s.cwd, path)
+	}
+	if valid, err := s.validatePathLocked(absPath); !valid {
+		return fmt.Errorf("invalid path: %w", err)
+	}
+
+	absPath = filepath.Clean(absPath)
+	if rel, err := filepath.Rel(s.root, absPath); err != nil || escapesRoot(rel) {
+		return fmt.Errorf("bookmark must be inside the active root")
+	}
+
+	if s.bookmarks == nil {
+		s.bookmarks = make(map[string]string)
+	}
+	s.bookmarks[label] = absPath
+	return s.saveBookmarksLocked()
+}
+
+// ResolveBookmark returns the absolute path that label refers to.
+func (s *Session) ResolveBookmark(label string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.resolveBookmarkLocked(label)
+}
+
+// bookmarkPathLocked resolves a "@<label>[/rest]" path against the bookmark
+// with that label (must hold lock). It reports false for other paths and
+// for unknown labels.
+func (s *Session) bookmarkPathLocked(path string) (string, bool) {
+	if !strings.HasPrefix(path, "@") {
+		return "", false
+	}
+	label, rest, _ := strings.Cut(path[1:], "/")
+	target, err := s.resolveBookmarkLocked(label)
+	if err != nil {
+		return "", false
+	}
+	return filepath.Join(target, rest), true
+}
+
+// resolveBookmarkLocked looks up a bookmark (must hold lock).
+func (s *Session) resolveBookmarkLocked(label string) (string, error) {
+	target, ok := s.bookmarks[label]
+	if !ok {
+		return "", fmt.Errorf("unknown bookmark: %s", label)
+	}
+	return target, nil
+}
+
+// displayPathLocked formats an absolute path for tool results (must hold
+// lock): relative to the active root if it is under it, otherwise in the
+// "@root:<label>/rel" form of the root it is under.
+func (s *Session) displayPathLocked(path string) string {
+	if rel, err := filepath.Rel(s.root, path); err == nil && !escapesRoot(rel) {
+		return rel
+	}
+	for label, root := range s.roots {
+		if rel, err := filepath.Rel(root, path); err == nil && !escapesRoot(rel) {
+			return rootPrefix + label + "/" + filepath.ToSlash(rel)
+		}
+	}
+	return path
+}
+
+// Bookmarks lists the registered bookmarks.
+func (s *Session) Bookmarks() (*types.ToolResult, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	labels := make([]string, 0, len(s.bookmarks))
+	for label := range s.bookmarks {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+
+	bookmarks := make([]map[string]interface{}, 0, len(labels))
+	for _, label := range labels {
+		bookmarks = append(bookmarks, map[string]interface{}{
+			"label": label,
+			"path":  s.displayPathLocked(s.bookmarks[label]),
+		})
+	}
+
+	return &types.ToolResult{
+		OK: true,
+		Data: map[string]interface{}{
+			"bookmarks": bookmarks,
+		},
+	}, nil
+}
+
+// Bookmark registers a bookmark from tool arguments.
+func (s *Session) Bookmark(args map[string]interface{}) (*types.ToolResult, error) {
+	label, _ := args["label"].(string)
+	if label == "" {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     "label is required",
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+	path, ok := args["path"].(string)
+	if !ok {
+		path = "."
+	}
+
+	if err := s.AddBookmark(label, path); err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     err.Error(),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+
+	s.mu.RLock()
+	rel := s.displayPathLocked(s.bookmarks[label])
+	s.mu.RUnlock()
+	return &types.ToolResult{
+		OK: true,
+		Data: map[string]interface{}{
+			"label": label,
+			"path":  rel,
+		},
+	}, nil
+}
+
+// loadBookmarksLocked adds the bookmarks in the sidecar file of the current
+// root (must hold write lock). The file stores paths relative to the root;
+// entries leading outside it are ignored.
+func (s *Session) loadBookmarksLocked() error {
+	if s.root == "" {
+		return nil
+	}
+
+	data, err := os.ReadFile(filepath.Join(s.root, BookmarksFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read bookmarks: %w", err)
+	}
+
+	var bookmarks map[string]string
+	if err := json.Unmarshal(data, &bookmarks); err != nil {
+		return fmt.Errorf("failed to parse bookmarks: %w", err)
+	}
+	if s.bookmarks == nil {
+		s.bookmarks = make(map[string]string)
+	}
+	for label, rel := range bookmarks {
+		if !escapesRoot(rel) {
+			s.bookmarks[label] = filepath.Join(s.root, rel)
+		}
+	}
+	return nil
+}
+
+// saveBookmarksLocked writes the bookmarks under the current root to its
+// sidecar file (must hold write lock).
+func (s *Session) saveBookmarksLocked() error {
+	bookmarks := make(map[string]string)
+	for label, target := range s.bookmarks {
+		if rel, err := filepath.Rel(s.root, target); err == nil && !escapesRoot(rel) {
+			bookmarks[label] = rel
+		}
+	}
+	data, err := json.MarshalIndent(bookmarks, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode bookmarks: %w", err)
+	}
+	if err := os.WriteFile(filepath.Join(s.root, BookmarksFileName), data, 0644); err != nil {
+		return fmt.Errorf("failed to write bookmarks: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package workspace
+
+// maxLineEndings bounds the number of files whose line ending is remembered.
+const maxLineEndings = 256
+
+// SetLineEnding remembers the line ending ("crlf" or "lf") last read from
+// path, so a later write can preserve it.
+func (s *Session) SetLineEnding(path, ending string) {
+	s.lineEndingsMu.Lock()
+	defer s.lineEndingsMu.Unlock()
+
+	if s.lineEndings == nil {
+		s.lineEndings = make(map[string]string)
+	}
+	if _, ok := s.lineEndings[path]; !ok && len(s.lineEndings) >= maxLineEndings {
+		// Evict an arbitrary entry; losing one only means a write falls
+		// back to the content's own line endings
+		for p := range s.lineEndings {
+			delete(s.lineEndings, p)
+			break
+		}
+	}
+	s.lineEndings[path] = ending
+}
+
+// LineEnding returns the line ending last read from path, or "" if it has
+// not been read.
+func (s *Session) LineEnding(path string) string {
+	s.lineEndingsMu.Lock()
+	defer s.lineEndingsMu.Unlock()
+	return s.lineEndings[path]
+}
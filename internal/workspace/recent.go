@@ -0,0 +1,59 @@
+package workspace
+
+import (
+	"time"
+
+	"github.com/tldw/tldw-agent/internal/types"
+)
+
+// defaultRecentFilesLimit is used when workspace.recent_files_limit is not positive.
+const defaultRecentFilesLimit = 20
+
+// RecentFile records the most recent access to a file.
+type RecentFile struct {
+	Path string    `json:"path"`
+	Op   string    `json:"op"` // "read" or "write"
+	At   time.Time `json:"at"`
+}
+
+// TouchRecent moves path to the front of the recently accessed files list,
+// evicting the least recently used entry once workspace.recent_files_limit
+// is reached.
+func (s *Session) TouchRecent(path, op string) {
+	s.mu.RLock()
+	limit := s.config.Workspace.RecentFilesLimit
+	s.mu.RUnlock()
+	if limit <= 0 {
+		limit = defaultRecentFilesLimit
+	}
+
+	s.recentMu.Lock()
+	defer s.recentMu.Unlock()
+
+	entry := RecentFile{Path: path, Op: op, At: time.Now()}
+	recent := make([]RecentFile, 0, limit)
+	recent = append(recent, entry)
+	for _, f := range s.recent {
+		if len(recent) >= limit {
+			break
+		}
+		if f.Path != path {
+			recent = append(recent, f)
+		}
+	}
+	s.recent = recent
+}
+
+// RecentFiles returns the recently accessed files, most recent first.
+func (s *Session) RecentFiles() (*types.ToolResult, error) {
+	s.recentMu.Lock()
+	files := append([]RecentFile{}, s.recent...)
+	s.recentMu.Unlock()
+
+	return &types.ToolResult{
+		OK: true,
+		Data: map[string]interface{}{
+			"files": files,
+		},
+	}, nil
+}
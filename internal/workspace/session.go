@@ -2,30 +2,89 @@
 package workspace
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
 	"os"
+	"os/user"
 	"path/filepath"
+	"slices"
+	"sort"
 	"strings"
 	"sync"
+	"time"
+
+	ignore "github.com/sabhiram/go-gitignore"
 
 	"github.com/tldw/tldw-agent/internal/config"
 	"github.com/tldw/tldw-agent/internal/types"
 )
 
+// defaultAuditLogSize is used when workspace.audit_log_size is not positive.
+const defaultAuditLogSize = 1000
+
 // Session manages the current workspace state.
 type Session struct {
-	config *config.Config
-	mu     sync.RWMutex
-	root   string // Workspace root directory
-	cwd    string // Current working directory (relative to root)
+	config    *config.Config
+	mu        sync.RWMutex
+	root      string            // Workspace root directory
+	cwd       string            // Current working directory (relative to root)
+	gitignore *ignore.GitIgnore // Ignore rules for the root, nil if none
+
+	nestedMu      sync.Mutex
+	nestedIgnores map[string]*ignore.GitIgnore // Subdirectory -> its .gitignore rules, nil if none
+
+	roots  map[string]string // Additional roots by label (absolute paths)
+	active string            // Label of the active root, "" if set via SetRoot
+
+	bookmarks map[string]string // Bookmark label -> absolute path
+
+	auditMu   sync.Mutex
+	audit     []AuditEntry // Circular buffer of file operations
+	auditNext int          // Index of the next slot to write
+	auditFull bool         // Whether the buffer has wrapped
+
+	recentMu sync.Mutex
+	recent   []RecentFile // Recently accessed files, most recent first
+
+	lineEndingsMu sync.Mutex
+	lineEndings   map[string]string // Absolute path -> line ending seen on last read
+}
+
+// AuditEntry records a single file operation performed through the session.
+type AuditEntry struct {
+	Time  time.Time `json:"time"`
+	Op    string    `json:"op"` // "read", "write", or "delete"
+	Path  string    `json:"path"`
+	Bytes int64     `json:"bytes"`
 }
 
-// NewSession creates a new workspace session.
+// NewSession creates a new workspace session. A workspace.default_root is
+// made the root as if by SetRoot, so its .gitignore and bookmarks apply.
 func NewSession(cfg *config.Config) *Session {
-	return &Session{
+	s := &Session{
 		config: cfg,
-		root:   cfg.Workspace.DefaultRoot,
 		cwd:    ".",
+		roots:  make(map[string]string),
+	}
+	if root := cfg.Workspace.DefaultRoot; root != "" {
+		if absRoot, err := resolveDir(root); err == nil {
+			root = absRoot
+		}
+		s.setActiveLocked("", root)
+	}
+	return s
+}
+
+// SetConfig replaces the configuration used for path validation. The
+// ignore rules are reloaded when workspace.respect_gitignore changes.
+func (s *Session) SetConfig(cfg *config.Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	reload := cfg.Workspace.RespectGitignore != s.config.Workspace.RespectGitignore
+	s.config = cfg
+	if reload {
+		_ = s.loadGitignoreLocked()
 	}
 }
 
@@ -34,26 +93,196 @@ func (s *Session) SetRoot(root string) error {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	// Resolve to absolute path
-	absRoot, err := filepath.Abs(root)
+	// Resolve to absolute path and verify directory exists
+	absRoot, err := resolveDir(root)
 	if err != nil {
-		return fmt.Errorf("failed to resolve path: %w", err)
+		return err
 	}
 
-	// Verify directory exists
-	info, err := os.Stat(absRoot)
+	s.bookmarks = nil
+	s.setActiveLocked("", absRoot)
+	return nil
+}
+
+// ClearRoot unsets the workspace root along with any additional roots.
+func (s *Session) ClearRoot() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.roots = make(map[string]string)
+	s.bookmarks = nil
+	s.setActiveLocked("", "")
+}
+
+// rootPrefix marks a path that names a registered root, as in
+// "@root:<label>/rel".
+const rootPrefix = "@root:"
+
+// AddRoot registers an additional workspace root under a label. Paths
+// prefixed with "@root:<label>/" resolve relative to it. The first root added
+// to a session without a root becomes the active root.
+func (s *Session) AddRoot(label, path string) error {
+	if label == "" || strings.ContainsAny(label, "@/\\") {
+		return fmt.Errorf("invalid root label: %q", label)
+	}
+
+	absRoot, err := resolveDir(path)
 	if err != nil {
-		return fmt.Errorf("failed to access directory: %w", err)
+		return err
 	}
-	if !info.IsDir() {
-		return fmt.Errorf("path is not a directory: %s", absRoot)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.roots[label] = absRoot
+	if s.root == "" {
+		s.setActiveLocked(label, absRoot)
 	}
+	return nil
+}
 
-	s.root = absRoot
+// SetActiveRoot makes a registered root the active one. Relative paths and
+// the working directory are resolved against the active root.
+func (s *Session) SetActiveRoot(label string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	root, ok := s.roots[label]
+	if !ok {
+		return fmt.Errorf("unknown root: %s", label)
+	}
+	s.setActiveLocked(label, root)
+	return nil
+}
+
+// setActiveLocked switches the active root (must hold write lock).
+func (s *Session) setActiveLocked(label, root string) {
+	s.root = root
+	s.active = label
 	s.cwd = "."
+
+	// Best effort: a missing or unreadable .gitignore or bookmarks file
+	// is not fatal. Bookmarks of other roots are kept.
+	_ = s.loadGitignoreLocked()
+	_ = s.loadBookmarksLocked()
+}
+
+// resolveDir resolves path to an absolute directory that must exist.
+func resolveDir(path string) (string, error) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to resolve path: %w", err)
+	}
+
+	info, err := os.Stat(absPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to access directory: %w", err)
+	}
+	if !info.IsDir() {
+		return "", fmt.Errorf("path is not a directory: %s", absPath)
+	}
+	return absPath, nil
+}
+
+// LoadGitignore (re)reads .gitignore from the workspace root, along with
+// ~/.gitignore_global if present, and drops any cached nested .gitignore
+// rules. It is a no-op when workspace.respect_gitignore is disabled.
+func (s *Session) LoadGitignore() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.loadGitignoreLocked()
+}
+
+// loadGitignoreLocked loads ignore rules (must hold write lock).
+func (s *Session) loadGitignoreLocked() error {
+	s.gitignore = nil
+	s.nestedMu.Lock()
+	s.nestedIgnores = nil
+	s.nestedMu.Unlock()
+	if s.root == "" || !s.config.Workspace.RespectGitignore {
+		return nil
+	}
+
+	var sources []string
+	if home, err := os.UserHomeDir(); err == nil {
+		sources = append(sources, filepath.Join(home, ".gitignore_global"))
+	}
+	sources = append(sources, filepath.Join(s.root, ".gitignore"))
+
+	var lines []string
+	for _, path := range sources {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			if os.IsNotExist(err) {
+				continue
+			}
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		lines = append(lines, strings.Split(string(data), "\n")...)
+	}
+
+	if len(lines) > 0 {
+		s.gitignore = ignore.CompileIgnoreLines(lines...)
+	}
 	return nil
 }
 
+// IsGitIgnored reports whether a path relative to the workspace root is
+// excluded by the loaded ignore rules, or by a .gitignore in one of the
+// directories between the root and the path. Directory paths should end
+// with a trailing slash so that directory-only patterns such as "dist/"
+// match.
+func (s *Session) IsGitIgnored(relPath string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.root == "" || !s.config.Workspace.RespectGitignore {
+		return false
+	}
+
+	relPath = filepath.ToSlash(relPath)
+	if s.gitignore != nil && s.gitignore.MatchesPath(relPath) {
+		return true
+	}
+
+	// Nested .gitignore patterns are relative to their own directory
+	for i := strings.IndexByte(relPath, '/'); i >= 0 && i < len(relPath)-1; {
+		dir, rest := relPath[:i], relPath[i+1:]
+		if gi := s.nestedGitignore(dir); gi != nil && gi.MatchesPath(rest) {
+			return true
+		}
+		next := strings.IndexByte(rest, '/')
+		if next < 0 {
+			break
+		}
+		i += next + 1
+	}
+	return false
+}
+
+// nestedGitignore returns the compiled .gitignore in dir, a slash-separated
+// path relative to the root, or nil if it has none. Rules are cached until
+// the next LoadGitignore (must hold read lock).
+func (s *Session) nestedGitignore(dir string) *ignore.GitIgnore {
+	s.nestedMu.Lock()
+	defer s.nestedMu.Unlock()
+
+	if gi, ok := s.nestedIgnores[dir]; ok {
+		return gi
+	}
+
+	var gi *ignore.GitIgnore
+	path := filepath.Join(s.root, filepath.FromSlash(dir), ".gitignore")
+	if data, err := os.ReadFile(path); err == nil {
+		gi = ignore.CompileIgnoreLines(strings.Split(string(data), "\n")...)
+	}
+
+	if s.nestedIgnores == nil {
+		s.nestedIgnores = make(map[string]*ignore.GitIgnore)
+	}
+	s.nestedIgnores[dir] = gi
+	return gi
+}
+
 // Root returns the current workspace root.
 func (s *Session) Root() string {
 	s.mu.RLock()
@@ -84,14 +313,32 @@ func (s *Session) List() (*types.ToolResult, error) {
 	defer s.mu.RUnlock()
 
 	workspaces := []map[string]interface{}{}
-	if s.root != "" {
+	if s.root != "" && s.active == "" {
 		workspaces = append(workspaces, map[string]interface{}{
-			"id":   "current",
-			"path": s.root,
-			"cwd":  s.cwd,
+			"id":     "current",
+			"path":   s.root,
+			"cwd":    s.cwd,
+			"active": true,
 		})
 	}
 
+	labels := make([]string, 0, len(s.roots))
+	for label := range s.roots {
+		labels = append(labels, label)
+	}
+	sort.Strings(labels)
+	for _, label := range labels {
+		entry := map[string]interface{}{
+			"id":     label,
+			"path":   s.roots[label],
+			"active": label == s.active,
+		}
+		if label == s.active {
+			entry["cwd"] = s.cwd
+		}
+		workspaces = append(workspaces, entry)
+	}
+
 	return &types.ToolResult{
 		OK: true,
 		Data: map[string]interface{}{
@@ -127,8 +374,9 @@ func (s *Session) Chdir(args map[string]interface{}) (*types.ToolResult, error)
 	pathArg, ok := args["path"].(string)
 	if !ok {
 		return &types.ToolResult{
-			OK:    false,
-			Error: "path is required",
+			OK:        false,
+			Error:     "path is required",
+			ErrorCode: types.ErrInvalidArg,
 		}, nil
 	}
 
@@ -142,9 +390,39 @@ func (s *Session) Chdir(args map[string]interface{}) (*types.ToolResult, error)
 		}, nil
 	}
 
-	// Resolve the new path
+	// Resolve the new path; "@<label>[/rest]" refers to a bookmark, and
+	// is an ordinary relative path (such as "@types") if there is none
 	var newCwd string
-	if filepath.IsAbs(pathArg) {
+	if target, ok := s.bookmarkPathLocked(pathArg); ok {
+		rel, err := filepath.Rel(s.root, target)
+		if err != nil || escapesRoot(rel) {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     fmt.Sprintf("bookmark is not under the active root: %s", pathArg),
+				ErrorCode: types.ErrInvalidArg,
+			}, nil
+		}
+		newCwd = rel
+	} else if strings.HasPrefix(pathArg, "~") {
+		// A home directory only works if it lies inside the workspace;
+		// anything else is rejected by the check below
+		home, err := expandHome(pathArg)
+		if err != nil {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     err.Error(),
+				ErrorCode: types.ErrorCodeFor(err),
+			}, nil
+		}
+		newCwd, err = filepath.Rel(s.root, home)
+		if err != nil {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     fmt.Sprintf("invalid path: %v", err),
+				ErrorCode: types.ErrInvalidArg,
+			}, nil
+		}
+	} else if filepath.IsAbs(pathArg) {
 		newCwd = pathArg
 	} else {
 		newCwd = filepath.Join(s.cwd, pathArg)
@@ -154,8 +432,9 @@ func (s *Session) Chdir(args map[string]interface{}) (*types.ToolResult, error)
 	absPath := filepath.Join(s.root, newCwd)
 	if valid, err := s.validatePathLocked(absPath); !valid {
 		return &types.ToolResult{
-			OK:    false,
-			Error: fmt.Sprintf("invalid path: %v", err),
+			OK:        false,
+			Error:     fmt.Sprintf("invalid path: %v", err),
+			ErrorCode: types.ErrorCodeFor(err),
 		}, nil
 	}
 
@@ -163,14 +442,16 @@ func (s *Session) Chdir(args map[string]interface{}) (*types.ToolResult, error)
 	info, err := os.Stat(absPath)
 	if err != nil {
 		return &types.ToolResult{
-			OK:    false,
-			Error: fmt.Sprintf("failed to access directory: %v", err),
+			OK:        false,
+			Error:     fmt.Sprintf("failed to access directory: %v", err),
+			ErrorCode: types.ErrorCodeFor(err),
 		}, nil
 	}
 	if !info.IsDir() {
 		return &types.ToolResult{
-			OK:    false,
-			Error: "path is not a directory",
+			OK:        false,
+			Error:     "path is not a directory",
+			ErrorCode: types.ErrInvalidArg,
 		}, nil
 	}
 
@@ -186,6 +467,175 @@ func (s *Session) Chdir(args map[string]interface{}) (*types.ToolResult, error)
 	}, nil
 }
 
+// expandHome expands a leading "~" to the current user's home directory
+// and a leading "~name" to that user's home directory.
+func expandHome(path string) (string, error) {
+	name, rest := path[1:], ""
+	if i := strings.IndexAny(name, `/`+string(filepath.Separator)); i >= 0 {
+		name, rest = name[:i], name[i+1:]
+	}
+
+	var home string
+	if name == "" {
+		dir, err := os.UserHomeDir()
+		if err != nil {
+			return "", fmt.Errorf("cannot expand ~: %w", err)
+		}
+		home = dir
+	} else {
+		u, err := user.Lookup(name)
+		if err != nil {
+			return "", fmt.Errorf("cannot expand ~%s: %w", name, err)
+		}
+		home = u.HomeDir
+	}
+	return filepath.Join(home, rest), nil
+}
+
+// DiskUsage returns the total size in bytes of the regular files under the
+// workspace root. Symlinks are not followed.
+func (s *Session) DiskUsage() (int64, error) {
+	usage, err := s.Usage(nil)
+	if err != nil {
+		return 0, err
+	}
+	return usage.Bytes, nil
+}
+
+// ErrDiskQuota is returned by CheckDiskQuota when a write would take the
+// workspace over workspace.max_disk_usage_bytes.
+var ErrDiskQuota = errors.New("disk usage limit exceeded")
+
+// RemainingDiskQuota returns how many more bytes the workspace may hold
+// under workspace.max_disk_usage_bytes, or -1 when there is no limit.
+func (s *Session) RemainingDiskQuota() (int64, error) {
+	s.mu.RLock()
+	limit := s.config.Workspace.MaxDiskUsageBytes
+	s.mu.RUnlock()
+	if limit <= 0 {
+		return -1, nil
+	}
+	usage, err := s.DiskUsage()
+	if err != nil {
+		return 0, err
+	}
+	return max(limit-usage, 0), nil
+}
+
+// CheckDiskQuota returns an error wrapping ErrDiskQuota if growing the
+// workspace by growth bytes would exceed workspace.max_disk_usage_bytes.
+// Growth may be negative when a write replaces larger files.
+func (s *Session) CheckDiskQuota(growth int64) error {
+	remaining, err := s.RemainingDiskQuota()
+	if err != nil || remaining < 0 {
+		return err
+	}
+	if growth > remaining {
+		return fmt.Errorf("%w: writing %d bytes, %d bytes left", ErrDiskQuota, growth, remaining)
+	}
+	return nil
+}
+
+// Usage summarizes the contents of the workspace root.
+type Usage struct {
+	Bytes int64 // Total size of regular files
+	Files int
+	Dirs  int // Not counting the root itself
+}
+
+// Usage walks the workspace root, skipping directories whose name is in
+// skipDirs. Symlinks are neither followed nor counted.
+func (s *Session) Usage(skipDirs []string) (Usage, error) {
+	root := s.Root()
+	if root == "" {
+		return Usage{}, fmt.Errorf("no workspace set")
+	}
+
+	var usage Usage
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil // Skip entries we can't access
+		}
+		if d.IsDir() {
+			if path == root {
+				return nil
+			}
+			if slices.Contains(skipDirs, d.Name()) {
+				return filepath.SkipDir
+			}
+			usage.Dirs++
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		usage.Bytes += info.Size()
+		usage.Files++
+		return nil
+	})
+	if err != nil {
+		return Usage{}, fmt.Errorf("failed to compute disk usage: %w", err)
+	}
+	return usage, nil
+}
+
+// RecordAccess appends a file operation to the audit log. Once the log
+// holds workspace.audit_log_size entries the oldest entry is overwritten.
+func (s *Session) RecordAccess(op, path string, bytes int64) {
+	s.mu.RLock()
+	size := s.config.Workspace.AuditLogSize
+	s.mu.RUnlock()
+	if size <= 0 {
+		size = defaultAuditLogSize
+	}
+
+	s.auditMu.Lock()
+	defer s.auditMu.Unlock()
+
+	if len(s.audit) != size {
+		// First use or the configured size changed: start a fresh buffer
+		// seeded with the most recent entries.
+		s.audit = append(make([]AuditEntry, 0, size), lastN(s.auditSnapshotLocked(), size)...)
+		s.auditNext = len(s.audit) % size
+		s.auditFull = len(s.audit) == size
+		s.audit = s.audit[:size]
+	}
+
+	s.audit[s.auditNext] = AuditEntry{Time: time.Now(), Op: op, Path: path, Bytes: bytes}
+	s.auditNext = (s.auditNext + 1) % size
+	if s.auditNext == 0 {
+		s.auditFull = true
+	}
+}
+
+// AuditLog returns a snapshot of the audit log, oldest entry first.
+func (s *Session) AuditLog() []AuditEntry {
+	s.auditMu.Lock()
+	defer s.auditMu.Unlock()
+	return s.auditSnapshotLocked()
+}
+
+// auditSnapshotLocked copies the audit entries in order (must hold auditMu).
+func (s *Session) auditSnapshotLocked() []AuditEntry {
+	if !s.auditFull {
+		return append([]AuditEntry{}, s.audit[:s.auditNext]...)
+	}
+	entries := make([]AuditEntry, 0, len(s.audit))
+	entries = append(entries, s.audit[s.auditNext:]...)
+	return append(entries, s.audit[:s.auditNext]...)
+}
+
+func lastN(entries []AuditEntry, n int) []AuditEntry {
+	if len(entries) > n {
+		return entries[len(entries)-n:]
+	}
+	return entries
+}
+
 // ValidatePath checks if a path is within the workspace and not blocked.
 func (s *Session) ValidatePath(path string) (bool, error) {
 	s.mu.RLock()
@@ -193,6 +643,14 @@ func (s *Session) ValidatePath(path string) (bool, error) {
 	return s.validatePathLocked(path)
 }
 
+// policyError reports a path rejected by workspace policy. It matches
+// fs.ErrPermission, so callers can tell it apart from I/O errors.
+type policyError string
+
+func (e policyError) Error() string { return string(e) }
+
+func (e policyError) Is(target error) bool { return target == fs.ErrPermission }
+
 // validatePathLocked performs path validation (must hold lock).
 func (s *Session) validatePathLocked(path string) (bool, error) {
 	if s.root == "" {
@@ -223,8 +681,64 @@ func (s *Session) validatePathLocked(path string) (bool, error) {
 		}
 	}
 
-	// Check if path is under workspace root
-	realRoot, err := filepath.EvalSymlinks(s.root)
+	// Check if path is under the workspace root or another registered root
+	within, err := s.withinRootsLocked(realPath)
+	if err != nil {
+		return false, err
+	}
+	if !within {
+		return false, policyError("path escapes workspace root")
+	}
+
+	// Check blocked paths
+	if s.config.IsPathBlocked(realPath) {
+		return false, policyError("path is blocked by policy")
+	}
+
+	// Check the allowlist, if one is configured
+	if len(s.config.Workspace.AllowedPaths) > 0 && !isUnderAllowedPath(realPath, s.config.Workspace.AllowedPaths) {
+		return false, policyError("path is outside allowed paths")
+	}
+
+	return true, nil
+}
+
+// Contains reports whether the absolute path, after resolving symlinks, is
+// inside the workspace root or another registered root. Unlike ValidatePath
+// it ignores blocked and allowed paths.
+func (s *Session) Contains(path string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	if s.root == "" {
+		return false
+	}
+	if realPath, err := filepath.EvalSymlinks(path); err == nil {
+		path = realPath
+	}
+	within, _ := s.withinRootsLocked(filepath.Clean(path))
+	return within
+}
+
+// withinRootsLocked reports whether a symlink-resolved path is inside the
+// workspace root or another registered root (must hold lock).
+func (s *Session) withinRootsLocked(realPath string) (bool, error) {
+	within, err := isWithinRoot(realPath, s.root)
+	if err != nil {
+		return false, err
+	}
+	for _, root := range s.roots {
+		if within {
+			break
+		}
+		within, _ = isWithinRoot(realPath, root)
+	}
+	return within, nil
+}
+
+// isWithinRoot reports whether a symlink-resolved path is inside root.
+func isWithinRoot(realPath, root string) (bool, error) {
+	realRoot, err := filepath.EvalSymlinks(root)
 	if err != nil {
 		return false, fmt.Errorf("failed to resolve workspace root: %w", err)
 	}
@@ -236,19 +750,45 @@ func (s *Session) validatePathLocked(path string) (bool, error) {
 	}
 
 	// Check for path traversal (relative path starting with ..)
-	if strings.HasPrefix(rel, "..") {
-		return false, fmt.Errorf("path escapes workspace root")
-	}
+	return !strings.HasPrefix(rel, ".."), nil
+}
 
-	// Check blocked paths
-	if s.config.IsPathBlocked(realPath) {
-		return false, fmt.Errorf("path is blocked by policy")
+// isUnderAllowedPath reports whether path is inside one of the allowed directories.
+func isUnderAllowedPath(path string, allowed []string) bool {
+	for _, dir := range allowed {
+		realDir, err := filepath.EvalSymlinks(dir)
+		if err != nil {
+			realDir = filepath.Clean(dir)
+		}
+		rel, err := filepath.Rel(realDir, path)
+		if err != nil {
+			continue
+		}
+		if rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
 	}
+	return false
+}
 
-	return true, nil
+// rootPathLocked resolves a "@root:<label>/rel" path against the root with
+// that label (must hold lock). It reports false for other paths and for
+// unknown labels.
+func (s *Session) rootPathLocked(path string) (string, bool) {
+	if !strings.HasPrefix(path, rootPrefix) {
+		return "", false
+	}
+	label, rest, _ := strings.Cut(path[len(rootPrefix):], "/")
+	root, ok := s.roots[label]
+	if !ok {
+		return "", false
+	}
+	return filepath.Join(root, rest), true
 }
 
-// ResolvePath resolves a path relative to the workspace.
+// ResolvePath resolves a path relative to the workspace. Paths of the form
+// "@root:<label>/rel" resolve against the registered root with that label;
+// if there is no such root they are taken as ordinary relative paths.
 func (s *Session) ResolvePath(path string) (string, error) {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -258,7 +798,9 @@ func (s *Session) ResolvePath(path string) (string, error) {
 	}
 
 	var absPath string
-	if filepath.IsAbs(path) {
+	if rootPath, ok := s.rootPathLocked(path); ok {
+		absPath = rootPath
+	} else if filepath.IsAbs(path) {
 		absPath = path
 	} else {
 		absPath = filepath.Join(s.root, s.cwd, path)
@@ -0,0 +1,250 @@
+package workspace
+
+import (
+	"os"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tldw/tldw-agent/internal/config"
+)
+
+// newTestSession returns a session rooted at a fresh directory, and that
+// directory with symlinks resolved.
+func newTestSession(t *testing.T) (*Session, string) {
+	t.Helper()
+	root, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewSession(config.Default())
+	if err := s.SetRoot(root); err != nil {
+		t.Fatalf("SetRoot failed: %v", err)
+	}
+	return s, root
+}
+
+func TestResolvePathRootPrefix(t *testing.T) {
+	s, root := newTestSession(t)
+	for _, dir := range []string{"@types/node", "@other", "@root:missing"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	other, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := s.AddRoot("other", other); err != nil {
+		t.Fatalf("AddRoot failed: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"@root:other/main.go", filepath.Join(other, "main.go")},
+		{"@root:other", other},
+		{"@types/node/index.d.ts", filepath.Join(root, "@types/node/index.d.ts")},
+		{"@other/main.go", filepath.Join(root, "@other/main.go")},
+		{"@root:missing/main.go", filepath.Join(root, "@root:missing/main.go")},
+	}
+	for _, tt := range tests {
+		got, err := s.ResolvePath(tt.path)
+		if err != nil {
+			t.Errorf("ResolvePath(%q) failed: %v", tt.path, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ResolvePath(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestChdirBookmarkOrPath(t *testing.T) {
+	s, root := newTestSession(t)
+	for _, dir := range []string{"@types", "src/pkg"} {
+		if err := os.MkdirAll(filepath.Join(root, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := s.AddBookmark("pkg", "src/pkg"); err != nil {
+		t.Fatalf("AddBookmark failed: %v", err)
+	}
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"@types", "@types"},
+		{"@pkg", filepath.Join("src", "pkg")},
+	}
+	for _, tt := range tests {
+		result, err := s.Chdir(map[string]interface{}{"path": tt.path})
+		if err != nil || !result.OK {
+			t.Errorf("Chdir(%q) failed: %+v, %v", tt.path, result, err)
+			continue
+		}
+		if got := s.Cwd(); got != tt.want {
+			t.Errorf("after Chdir(%q), cwd = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestBookmarksSurviveRootSwitch(t *testing.T) {
+	s := NewSession(config.Default())
+	var dirs []string
+	for _, label := range []string{"a", "b"} {
+		dir, err := filepath.EvalSymlinks(t.TempDir())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.MkdirAll(filepath.Join(dir, "src"), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := s.AddRoot(label, dir); err != nil {
+			t.Fatalf("AddRoot failed: %v", err)
+		}
+		dirs = append(dirs, dir)
+	}
+	if err := s.AddBookmark("x", "src"); err != nil {
+		t.Fatalf("AddBookmark failed: %v", err)
+	}
+
+	if err := s.SetActiveRoot("b"); err != nil {
+		t.Fatalf("SetActiveRoot failed: %v", err)
+	}
+	if got, err := s.ResolveBookmark("x"); err != nil || got != filepath.Join(dirs[0], "src") {
+		t.Fatalf("bookmark after switching roots = %q, %v", got, err)
+	}
+	if result, _ := s.Chdir(map[string]interface{}{"path": "@x"}); result.OK {
+		t.Fatal("Chdir to a bookmark in another root succeeded")
+	}
+
+	if err := s.SetActiveRoot("a"); err != nil {
+		t.Fatalf("SetActiveRoot failed: %v", err)
+	}
+	if result, _ := s.Chdir(map[string]interface{}{"path": "@x"}); !result.OK || s.Cwd() != "src" {
+		t.Fatalf("Chdir to the bookmark failed: %+v, cwd %q", result, s.Cwd())
+	}
+
+	// The bookmark is persisted relative to its root
+	other := NewSession(config.Default())
+	if err := other.SetRoot(dirs[0]); err != nil {
+		t.Fatalf("SetRoot failed: %v", err)
+	}
+	if got, err := other.ResolveBookmark("x"); err != nil || got != filepath.Join(dirs[0], "src") {
+		t.Fatalf("persisted bookmark = %q, %v", got, err)
+	}
+}
+
+// setHome points the current user's home directory at dir for the test.
+func setHome(t *testing.T, dir string) {
+	t.Helper()
+	t.Setenv("HOME", dir)
+	t.Setenv("USERPROFILE", dir)
+}
+
+func TestExpandHome(t *testing.T) {
+	home := t.TempDir()
+	setHome(t, home)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"~", home},
+		{"~/", home},
+		{"~/src/app", filepath.Join(home, "src", "app")},
+	}
+	for _, tt := range tests {
+		got, err := expandHome(tt.path)
+		if err != nil || got != tt.want {
+			t.Errorf("expandHome(%q) = %q, %v; want %q", tt.path, got, err, tt.want)
+		}
+	}
+
+	if _, err := expandHome("~no-such-user-tldw/src"); err == nil {
+		t.Error("expected an error for an unknown user")
+	}
+	if u, err := user.Current(); err == nil && u.Username != "" && !strings.ContainsAny(u.Username, `/\`) {
+		got, err := expandHome("~" + u.Username + "/src")
+		if want := filepath.Join(u.HomeDir, "src"); err != nil || got != want {
+			t.Errorf("expandHome(~%s/src) = %q, %v; want %q", u.Username, got, err, want)
+		}
+	}
+}
+
+func TestChdirHome(t *testing.T) {
+	s, root := newTestSession(t)
+	chdir := func(path string) bool {
+		t.Helper()
+		result, err := s.Chdir(map[string]interface{}{"path": path})
+		if err != nil {
+			t.Fatalf("Chdir(%q) failed: %v", path, err)
+		}
+		return result.OK
+	}
+
+	// A home directory outside the workspace is rejected
+	outside, err := filepath.EvalSymlinks(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	setHome(t, outside)
+	for _, path := range []string{"~", "~/", "~/.."} {
+		if chdir(path) {
+			t.Errorf("Chdir(%q) escaped the workspace to %q", path, s.Cwd())
+		}
+	}
+	if chdir("~no-such-user-tldw") {
+		t.Error("Chdir to an unknown user's home succeeded")
+	}
+	if got := s.Cwd(); got != "." {
+		t.Fatalf("failed Chdir calls moved cwd to %q", got)
+	}
+
+	// One inside it works like any other directory
+	inside := filepath.Join(root, "home")
+	if err := os.MkdirAll(filepath.Join(inside, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	setHome(t, inside)
+	if !chdir("~/src") {
+		t.Fatal("Chdir(~/src) failed for a home inside the workspace")
+	}
+	if got, want := s.Cwd(), filepath.Join("home", "src"); got != want {
+		t.Fatalf("cwd = %q, want %q", got, want)
+	}
+}
+
+func TestDefaultRootLoadsGitignore(t *testing.T) {
+	setHome(t, t.TempDir())
+	root := t.TempDir()
+	if err := os.WriteFile(filepath.Join(root, ".gitignore"), []byte("*.log\ndist/\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.Default()
+	cfg.Workspace.DefaultRoot = root
+	cfg.Workspace.RespectGitignore = true
+	s := NewSession(cfg)
+	if !s.IsGitIgnored("debug.log") || !s.IsGitIgnored("dist/") {
+		t.Fatal("expected the default root's .gitignore to apply without SetRoot")
+	}
+
+	// Turning respect_gitignore off and on again with a reload picks the
+	// rules back up
+	off := config.Default()
+	off.Workspace.DefaultRoot = root
+	off.Workspace.RespectGitignore = false
+	s.SetConfig(off)
+	if s.IsGitIgnored("debug.log") {
+		t.Fatal("expected no ignore rules with respect_gitignore off")
+	}
+	s.SetConfig(cfg)
+	if !s.IsGitIgnored("debug.log") {
+		t.Fatal("expected the ignore rules to be reloaded when respect_gitignore is turned on")
+	}
+}
@@ -0,0 +1,236 @@
+package workspace
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/tldw/tldw-agent/internal/types"
+)
+
+// SessionState is a copy of the mutable state of a session, for
+// checkpointing it and later going back with Restore.
+type SessionState struct {
+	Root      string            `json:"root"`
+	Cwd       string            `json:"cwd"`              // Relative to Root
+	Active    string            `json:"active,omitempty"` // Label of Root in Roots, "" if set via SetRoot
+	Roots     map[string]string `json:"roots,omitempty"`
+	Bookmarks map[string]string `json:"bookmarks,omitempty"` // Label -> absolute path
+}
+
+// Snapshot returns the current session state.
+func (s *Session) Snapshot() SessionState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.snapshotLocked()
+}
+
+// snapshotLocked copies the session state (must hold lock).
+func (s *Session) snapshotLocked() SessionState {
+	return SessionState{
+		Root:      s.root,
+		Cwd:       s.cwd,
+		Active:    s.active,
+		Roots:     copyStrings(s.roots),
+		Bookmarks: copyStrings(s.bookmarks),
+	}
+}
+
+// Restore replaces the session state with state. The state is validated
+// first: its roots must still be directories and its working directory
+// must lie inside the root and its bookmarks inside the root or one of the
+// roots. Relative bookmark paths are taken relative to the root. On error
+// the session is left unchanged.
+func (s *Session) Restore(state SessionState) error {
+	if state.Cwd == "" {
+		state.Cwd = "."
+	}
+	if state.Root == "" && (len(state.Roots) > 0 || state.Active != "" || len(state.Bookmarks) > 0 || state.Cwd != ".") {
+		return fmt.Errorf("state without a root cannot have roots, bookmarks, or a working directory")
+	}
+
+	var root string
+	if state.Root != "" {
+		var err error
+		if root, err = resolveDir(state.Root); err != nil {
+			return fmt.Errorf("invalid root: %w", err)
+		}
+	}
+
+	roots := make(map[string]string, len(state.Roots))
+	for label, path := range state.Roots {
+		if label == "" || strings.ContainsAny(label, "@/\\") {
+			return fmt.Errorf("invalid root label: %q", label)
+		}
+		absRoot, err := resolveDir(path)
+		if err != nil {
+			return fmt.Errorf("invalid root %q: %w", label, err)
+		}
+		roots[label] = absRoot
+	}
+	if state.Active != "" && roots[state.Active] != root {
+		return fmt.Errorf("active root %q does not match root", state.Active)
+	}
+
+	bookmarks := make(map[string]string, len(state.Bookmarks))
+	for label, path := range state.Bookmarks {
+		if label == "" || strings.ContainsAny(label, "@/\\") {
+			return fmt.Errorf("invalid bookmark label: %q", label)
+		}
+		if !filepath.IsAbs(path) {
+			path = filepath.Join(root, path)
+		}
+		path = filepath.Clean(path)
+		if !underAny(path, root, roots) {
+			return fmt.Errorf("bookmark %q is outside the roots", label)
+		}
+		bookmarks[label] = path
+	}
+	if escapesRoot(state.Cwd) {
+		return fmt.Errorf("working directory is outside the root")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Validating the working directory needs the new root in place, so
+	// apply the state and go back if it turns out to be invalid
+	previous := s.snapshotLocked()
+	s.roots = roots
+	s.setActiveLocked(state.Active, root)
+	s.bookmarks = bookmarks
+	if root != "" {
+		absCwd := filepath.Join(root, state.Cwd)
+		valid, err := s.validatePathLocked(absCwd)
+		if valid {
+			var info os.FileInfo
+			if info, err = os.Stat(absCwd); err == nil && !info.IsDir() {
+				err = fmt.Errorf("not a directory")
+			}
+		}
+		if !valid || err != nil {
+			s.roots = previous.Roots
+			s.setActiveLocked(previous.Active, previous.Root)
+			s.cwd = previous.Cwd
+			s.bookmarks = previous.Bookmarks
+			return fmt.Errorf("invalid working directory: %w", err)
+		}
+	}
+	s.cwd = filepath.Clean(state.Cwd)
+	return nil
+}
+
+// SnapshotTool returns the current session state as a tool result.
+func (s *Session) SnapshotTool() (*types.ToolResult, error) {
+	return &types.ToolResult{
+		OK:   true,
+		Data: s.Snapshot(),
+	}, nil
+}
+
+// RestoreTool restores a state returned by workspace.snapshot. Unlike
+// Restore it only switches between roots the session already knows, so a
+// tool call cannot open a new part of the filesystem.
+func (s *Session) RestoreTool(args map[string]interface{}) (*types.ToolResult, error) {
+	raw, ok := args["state"]
+	if !ok {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     "state is required",
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+	var state SessionState
+	data, err := json.Marshal(raw)
+	if err == nil {
+		err = json.Unmarshal(data, &state)
+	}
+	if err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     fmt.Sprintf("invalid state: %v", err),
+			ErrorCode: types.ErrInvalidArg,
+		}, nil
+	}
+
+	current := s.Snapshot()
+	known := func(path string) bool {
+		if path == current.Root {
+			return true
+		}
+		for _, root := range current.Roots {
+			if path == root {
+				return true
+			}
+		}
+		return false
+	}
+	if state.Root != "" && !known(state.Root) {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     "cannot restore a root that is not open in this session",
+			ErrorCode: types.ErrPermission,
+		}, nil
+	}
+	for _, root := range state.Roots {
+		if !known(root) {
+			return &types.ToolResult{
+				OK:        false,
+				Error:     "cannot restore a root that is not open in this session",
+				ErrorCode: types.ErrPermission,
+			}, nil
+		}
+	}
+
+	if err := s.Restore(state); err != nil {
+		return &types.ToolResult{
+			OK:        false,
+			Error:     err.Error(),
+			ErrorCode: types.ErrorCodeFor(err),
+		}, nil
+	}
+	return &types.ToolResult{
+		OK:   true,
+		Data: s.Snapshot(),
+	}, nil
+}
+
+// escapesRoot reports whether rel, a path relative to a root, is absolute
+// or leads outside the root.
+func escapesRoot(rel string) bool {
+	if filepath.IsAbs(rel) {
+		return true
+	}
+	rel = filepath.Clean(rel)
+	return rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// underAny reports whether path lies inside root or one of roots.
+func underAny(path, root string, roots map[string]string) bool {
+	under := func(dir string) bool {
+		rel, err := filepath.Rel(dir, path)
+		return dir != "" && err == nil && !escapesRoot(rel)
+	}
+	if under(root) {
+		return true
+	}
+	for _, dir := range roots {
+		if under(dir) {
+			return true
+		}
+	}
+	return false
+}
+
+func copyStrings(m map[string]string) map[string]string {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[k] = v
+	}
+	return out
+}